@@ -0,0 +1,120 @@
+// Package account implements what users can do with their account as a
+// whole: export their personal data and delete it.
+package account
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/auth"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// deletedUsername replaces the username of deleted accounts
+const deletedUsername = "deleted-user"
+
+// Service exports and deletes accounts
+type Service struct {
+	store store.Store
+	auth  *auth.Service
+}
+
+func NewService(s store.Store, a *auth.Service) *Service {
+	return &Service{store: s, auth: a}
+}
+
+// Export is a user's personal data in machine-readable form
+type Export struct {
+	ExportedAt time.Time         `json:"exportedAt"`
+	Profile    models.User       `json:"profile"`
+	Items      []*models.Item    `json:"items"`    // Items the user lists
+	Bookings   []*models.Booking `json:"bookings"` // Bookings the user made
+	Payments   []*models.Payment `json:"payments"` // Payments for those bookings
+}
+
+// Export collects the personal data of userID
+func (s *Service) Export(ctx context.Context, userID string) (*Export, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.store.ListItems(ctx, store.ItemFilter{OwnerID: userID})
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error exporting items", err)
+	}
+	bookings, err := s.store.ListBookings(ctx, store.BookingFilter{UserID: userID})
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error exporting bookings", err)
+	}
+	payments, err := s.store.ListPayments(ctx, store.PaymentFilter{UserID: userID})
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error exporting payments", err)
+	}
+
+	return &Export{
+		ExportedAt: time.Now(),
+		Profile:    user.Public(),
+		Items:      items,
+		Bookings:   bookings,
+		Payments:   payments,
+	}, nil
+}
+
+// Delete erases the personal data of userID, who confirms with their
+// password or, without one, a second-factor code or a fresh single sign-on
+// login to sessionID. The user record stays, anonymised, so the bookings
+// and payments kept for accounting still point somewhere. It is refused
+// while the user has pending or confirmed bookings, made or received.
+func (s *Service) Delete(ctx context.Context, userID, sessionID, password, code, ip string) error {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.auth.ConfirmIdentity(ctx, user, sessionID, password, code, ip); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	email := user.Email
+	erased := &models.User{
+		ID:        user.ID,
+		Username:  deletedUsername,
+		Email:     user.ID + "@deleted.invalid", // Emails are unique, and this one can never receive mail
+		Role:      models.RoleUser,
+		DeletedAt: &now,
+		CreatedAt: user.CreatedAt,
+	}
+	// The store checks for open bookings and retires the user's items
+	// atomically with the erasure
+	if err := s.store.EraseUser(ctx, erased, now); err != nil {
+		if errors.Is(err, store.ErrUserHasBookings) {
+			return apperr.New(apperr.Conflict, "Complete or cancel your pending and confirmed bookings, including those for your items, before deleting your account")
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			return apperr.New(apperr.NotFound, "User not found")
+		}
+		return apperr.Wrap(apperr.Internal, "Error deleting account", err)
+	}
+	s.auth.ForgetLoginFailures(ctx, email)
+
+	log.Printf("Account %s deleted and its personal data erased", user.ID)
+	return nil
+}
+
+func (s *Service) user(ctx context.Context, id string) (*models.User, error) {
+	user, err := s.store.GetUser(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, apperr.New(apperr.NotFound, "User not found")
+	}
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	if user.Deleted() {
+		return nil, apperr.New(apperr.NotFound, "User not found")
+	}
+	return user, nil
+}
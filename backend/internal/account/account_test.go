@@ -0,0 +1,340 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/auth"
+	"borrowhub/internal/mail"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+const (
+	testPassword   = "correct horse battery staple"
+	testBackupCode = "k7m2q-x9fhr"
+	testIP         = "192.0.2.1"
+)
+
+// succeeds marks test cases expected to return no error; every apperr.Kind,
+// including Internal, is zero or more
+const succeeds apperr.Kind = -1
+
+func newTestService(t *testing.T) (*Service, *store.Database) {
+	t.Helper()
+	keys, err := auth.GenerateKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := auth.NewTokenManager(auth.TokenOptions{
+		Keys:     keys,
+		Issuer:   "borrowhub",
+		Audience: "borrowhub-api",
+		TTL:      15 * time.Minute,
+		Secret:   []byte("test-secret-used-only-by-these-tests"),
+	})
+	db := store.NewDatabase()
+	a := auth.NewService(db, tokens, auth.Options{
+		RefreshTTL:     time.Hour,
+		Lockout:        auth.LockoutPolicy{Threshold: 3, IPThreshold: 10, Delay: time.Minute, MaxDelay: time.Hour},
+		PasswordPolicy: auth.PasswordPolicy{MinLength: 8, MinClasses: 1},
+		BcryptCost:     bcrypt.MinCost,
+		Mailer:         mail.LogMailer{},
+	})
+	return NewService(db, a), db
+}
+
+// createTestUser stores a user with testPassword, or without a password if
+// withPassword is false, and a session of theirs that signed in at signedIn
+func createTestUser(t *testing.T, db *store.Database, email string, withPassword bool, signedIn time.Time) (*models.User, *models.Session) {
+	t.Helper()
+	ctx := context.Background()
+	user := &models.User{
+		ID:        "usr_" + email,
+		Username:  email,
+		Email:     email,
+		FirstName: "Ana",
+		LastName:  "Lima",
+		Phone:     "+1 555 0100",
+		Address:   "1 Main Street",
+		Verified:  true,
+		Role:      models.RoleUser,
+		CreatedAt: time.Now(),
+	}
+	if withPassword {
+		hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user.Password = string(hash)
+	}
+	if err := db.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	session := &models.Session{ID: "ses_" + email, UserID: user.ID, IP: testIP, UserAgent: "test", CreatedAt: signedIn, LastSeenAt: signedIn}
+	if err := db.CreateSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	return user, session
+}
+
+// enableTOTP turns on two-factor authentication for userID with
+// testBackupCode as its only backup code
+func enableTOTP(t *testing.T, db *store.Database, userID string) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+	if err := db.SaveTOTPCredential(ctx, &models.TOTPCredential{UserID: userID, Secret: "JBSWY3DPEHPK3PXP", Confirmed: true, CreatedAt: now, ConfirmedAt: &now}); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("k7m2qx9fhr"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ReplaceBackupCodes(ctx, userID, []*models.BackupCode{{ID: "bkc_" + userID, UserID: userID, CodeHash: string(hash), CreatedAt: now}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createItem(t *testing.T, db *store.Database, id, ownerID string) {
+	t.Helper()
+	if err := db.CreateItem(context.Background(), &models.Item{ID: id, Name: id, DailyRate: 10, OwnerID: ownerID, Available: true, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createBooking(t *testing.T, db *store.Database, id, itemID, userID, status string) {
+	t.Helper()
+	start := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	booking := &models.Booking{ID: id, ItemID: itemID, UserID: userID, StartDate: start, EndDate: start.AddDate(0, 0, 2),
+		TotalPrice: 20, Status: models.BookingPending, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.CreateBooking(context.Background(), booking); err != nil {
+		t.Fatal(err)
+	}
+	if status != models.BookingPending {
+		if _, _, err := db.SetBookingStatus(context.Background(), id, status, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	ana, _ := createTestUser(t, db, "ana@example.com", true, time.Now())
+	ben, _ := createTestUser(t, db, "ben@example.com", true, time.Now())
+	createItem(t, db, "itm_tent", ana.ID)
+	createItem(t, db, "itm_kayak", ben.ID)
+	createBooking(t, db, "bkg_ana", "itm_kayak", ana.ID, models.BookingCompleted)
+	createBooking(t, db, "bkg_ben", "itm_tent", ben.ID, models.BookingCompleted)
+	for _, payment := range []*models.Payment{
+		{ID: "pay_ana", BookingID: "bkg_ana", Amount: 20, Status: "success", CreatedAt: time.Now()},
+		{ID: "pay_ben", BookingID: "bkg_ben", Amount: 20, Status: "success", CreatedAt: time.Now()},
+	} {
+		if err := db.CreatePayment(ctx, payment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	export, err := s.Export(ctx, ana.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Profile.ID != ana.ID || export.Profile.Email != ana.Email || export.Profile.Phone != ana.Phone {
+		t.Errorf("exported profile %+v, want ana's", export.Profile)
+	}
+	if export.Profile.Password != "" {
+		t.Error("export includes the password hash")
+	}
+	// Only the user's own items, bookings and payments
+	if len(export.Items) != 1 || export.Items[0].ID != "itm_tent" {
+		t.Errorf("exported items %+v, want itm_tent", export.Items)
+	}
+	if len(export.Bookings) != 1 || export.Bookings[0].ID != "bkg_ana" {
+		t.Errorf("exported bookings %+v, want bkg_ana", export.Bookings)
+	}
+	if len(export.Payments) != 1 || export.Payments[0].ID != "pay_ana" {
+		t.Errorf("exported payments %+v, want pay_ana", export.Payments)
+	}
+	if _, err := s.Export(ctx, "usr_missing"); apperr.KindOf(err) != apperr.NotFound {
+		t.Errorf("Export of a missing user: %v, want NotFound", err)
+	}
+}
+
+func TestDeleteAnonymises(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	ana, session := createTestUser(t, db, "ana@example.com", true, time.Now())
+	ben, _ := createTestUser(t, db, "ben@example.com", true, time.Now())
+	enableTOTP(t, db, ana.ID)
+	createItem(t, db, "itm_tent", ana.ID)
+	createItem(t, db, "itm_stove", ana.ID)
+	createBooking(t, db, "bkg_done", "itm_tent", ben.ID, models.BookingCompleted)
+
+	if err := s.Delete(ctx, ana.ID, session.ID, "wrong password", "", testIP); apperr.KindOf(err) != apperr.Forbidden {
+		t.Fatalf("Delete with a wrong password: %v, want Forbidden", err)
+	}
+	if err := s.Delete(ctx, ana.ID, session.ID, testPassword, "", testIP); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	user, err := db.GetUser(ctx, ana.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.Deleted() || user.Username != deletedUsername || user.Email != ana.ID+"@deleted.invalid" || user.Password != "" ||
+		user.FirstName != "" || user.LastName != "" || user.Phone != "" || user.Address != "" || !user.CreatedAt.Equal(ana.CreatedAt) {
+		t.Errorf("deleted user %+v, want anonymised", user)
+	}
+	if _, err := db.GetUserByEmail(ctx, ana.Email); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("lookup by the old email: %v, want ErrNotFound", err)
+	}
+	if stored, err := db.GetSession(ctx, session.ID); err != nil || stored.Active() || stored.IP != "" || stored.UserAgent != "" {
+		t.Errorf("session after deletion: %+v, %v, want revoked without its device", stored, err)
+	}
+	if _, err := db.GetTOTPCredential(ctx, ana.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("two-factor credential after deletion: %v, want ErrNotFound", err)
+	}
+	if codes, _ := db.ListBackupCodes(ctx, ana.ID); len(codes) != 0 {
+		t.Errorf("%d backup codes left after deletion", len(codes))
+	}
+	if _, err := db.GetItem(ctx, "itm_stove"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("never booked item: %v, want deleted", err)
+	}
+	if item, err := db.GetItem(ctx, "itm_tent"); err != nil || item.Available {
+		t.Errorf("booked item: %+v, %v, want kept but unavailable", item, err)
+	}
+	if booking, err := db.GetBooking(ctx, "bkg_done"); err != nil || booking.UserID != ben.ID {
+		t.Errorf("booking of the item: %+v, %v, want kept", booking, err)
+	}
+
+	// The address is free again, and the account cannot be deleted twice
+	if err := db.CreateUser(ctx, &models.User{ID: "usr_new", Username: "ana", Email: ana.Email, Role: models.RoleUser}); err != nil {
+		t.Errorf("registering the old email again: %v", err)
+	}
+	if err := s.Delete(ctx, ana.ID, session.ID, testPassword, "", testIP); apperr.KindOf(err) != apperr.NotFound {
+		t.Errorf("deleting again: %v, want NotFound", err)
+	}
+}
+
+func TestDeleteRefusedWithActiveBookings(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		itemOf string // Owner of the booked item; the other user books it
+		status string
+		want   apperr.Kind
+	}{
+		{"booking made, pending", "ben", models.BookingPending, apperr.Conflict},
+		{"booking made, confirmed", "ben", models.BookingConfirmed, apperr.Conflict},
+		{"booking received, pending", "ana", models.BookingPending, apperr.Conflict},
+		{"booking received, confirmed", "ana", models.BookingConfirmed, apperr.Conflict},
+		{"booking made, cancelled", "ben", models.BookingCancelled, succeeds},
+		{"booking received, completed", "ana", models.BookingCompleted, succeeds},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, db := newTestService(t)
+			ctx := context.Background()
+			users := make(map[string]*models.User)
+			var session *models.Session
+			users["ana"], session = createTestUser(t, db, "ana@example.com", true, time.Now())
+			users["ben"], _ = createTestUser(t, db, "ben@example.com", true, time.Now())
+			renter := users["ana"]
+			if tt.itemOf == "ana" {
+				renter = users["ben"]
+			}
+			createItem(t, db, "itm_tent", users[tt.itemOf].ID)
+			createItem(t, db, "itm_stove", users["ana"].ID)
+			createBooking(t, db, "bkg_tent", "itm_tent", renter.ID, tt.status)
+
+			err := s.Delete(ctx, users["ana"].ID, session.ID, testPassword, "", testIP)
+			if tt.want == succeeds {
+				if err != nil {
+					t.Fatalf("Delete: %v", err)
+				}
+				return
+			}
+			if apperr.KindOf(err) != tt.want {
+				t.Fatalf("Delete: %v, want %v", err, tt.want)
+			}
+			// Nothing was erased or retired
+			if user, err := db.GetUser(ctx, users["ana"].ID); err != nil || user.Deleted() {
+				t.Errorf("user after a refused deletion: %+v, %v", user, err)
+			}
+			if item, err := db.GetItem(ctx, "itm_stove"); err != nil || !item.Available {
+				t.Errorf("item after a refused deletion: %+v, %v, want unchanged", item, err)
+			}
+		})
+	}
+}
+
+func TestDeleteWithoutPassword(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		totp     bool
+		signedIn time.Duration // How long ago the caller's session signed in
+		session  string        // Session to confirm with; the caller's if empty
+		code     string
+		want     apperr.Kind
+	}{
+		{name: "fresh single sign-on login", signedIn: time.Minute, want: succeeds},
+		{name: "old single sign-on login", signedIn: time.Hour, want: apperr.Forbidden},
+		{name: "no session", signedIn: time.Minute, session: "none", want: apperr.Forbidden},
+		{name: "another user's fresh session", signedIn: time.Hour, session: "ses_ben@example.com", want: apperr.Forbidden},
+		{name: "backup code", totp: true, signedIn: time.Hour, code: testBackupCode, want: succeeds},
+		{name: "wrong code", totp: true, signedIn: time.Hour, code: "000000", want: apperr.Unauthorized},
+		{name: "no code despite a fresh login", totp: true, signedIn: time.Minute, want: apperr.Unauthorized},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, db := newTestService(t)
+			ctx := context.Background()
+			ana, session := createTestUser(t, db, "ana@example.com", false, time.Now().Add(-tt.signedIn))
+			createTestUser(t, db, "ben@example.com", false, time.Now())
+			if tt.totp {
+				enableTOTP(t, db, ana.ID)
+			}
+			sessionID := session.ID
+			switch tt.session {
+			case "":
+			case "none":
+				sessionID = ""
+			default:
+				sessionID = tt.session
+			}
+
+			err := s.Delete(ctx, ana.ID, sessionID, "", tt.code, testIP)
+			if tt.want == succeeds {
+				if err != nil {
+					t.Fatalf("Delete: %v", err)
+				}
+				return
+			}
+			if apperr.KindOf(err) != tt.want {
+				t.Fatalf("Delete: %v, want %v", err, tt.want)
+			}
+			if user, err := db.GetUser(ctx, ana.ID); err != nil || user.Deleted() {
+				t.Errorf("user after a refused deletion: %+v, %v", user, err)
+			}
+		})
+	}
+}
+
+func TestDeleteWrongCodesCountTowardsLockout(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	ana, session := createTestUser(t, db, "ana@example.com", false, time.Now())
+	enableTOTP(t, db, ana.ID)
+
+	for range 3 {
+		if err := s.Delete(ctx, ana.ID, session.ID, "", "wrong-code", testIP); apperr.KindOf(err) != apperr.Unauthorized {
+			t.Fatalf("Delete with a wrong code: %v, want Unauthorized", err)
+		}
+	}
+	if err := s.Delete(ctx, ana.ID, session.ID, "", testBackupCode, testIP); apperr.KindOf(err) != apperr.TooManyRequests {
+		t.Errorf("Delete with the right code after 3 wrong ones: %v, want TooManyRequests", err)
+	}
+}
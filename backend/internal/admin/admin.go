@@ -0,0 +1,294 @@
+// Package admin implements the moderation and support actions of staff
+// accounts. Every action is written to the audit log.
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/ids"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// Service carries out admin actions on behalf of an actor, whose
+// permission to take them the caller has already checked
+type Service struct {
+	store store.Store
+}
+
+func NewService(s store.Store) *Service {
+	return &Service{store: s}
+}
+
+// ListUsers returns every account, without password hashes
+func (s *Service) ListUsers(ctx context.Context) ([]models.User, error) {
+	users, err := s.store.ListUsers(ctx)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error loading users", err)
+	}
+	public := make([]models.User, 0, len(users))
+	for _, user := range users {
+		public = append(public, user.Public())
+	}
+	return public, nil
+}
+
+// SuspendUser stops userID from signing in and ends their sessions
+func (s *Service) SuspendUser(ctx context.Context, actorID, userID, reason string) (*models.User, error) {
+	if err := requireReason(reason); err != nil {
+		return nil, err
+	}
+	if userID == actorID {
+		return nil, apperr.New(apperr.Invalid, "You cannot suspend your own account")
+	}
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Suspended() {
+		return nil, apperr.New(apperr.Conflict, "User is already suspended")
+	}
+
+	now := time.Now()
+	user.SuspendedAt = &now
+	if err := s.store.UpdateUser(ctx, user); err != nil {
+		return nil, lookupError(err, "User not found")
+	}
+	revoked, err := s.store.RevokeSessions(ctx, store.SessionFilter{UserID: user.ID}, now)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error ending the user's sessions", err)
+	}
+
+	s.record(ctx, actorID, models.AuditUserSuspended, "user", user.ID, reason, fmt.Sprintf("%d sessions revoked", revoked))
+	public := user.Public()
+	return &public, nil
+}
+
+// RestoreUser lifts the suspension of userID
+func (s *Service) RestoreUser(ctx context.Context, actorID, userID, reason string) (*models.User, error) {
+	if err := requireReason(reason); err != nil {
+		return nil, err
+	}
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Suspended() {
+		return nil, apperr.New(apperr.Conflict, "User is not suspended")
+	}
+
+	user.SuspendedAt = nil
+	if err := s.store.UpdateUser(ctx, user); err != nil {
+		return nil, lookupError(err, "User not found")
+	}
+
+	s.record(ctx, actorID, models.AuditUserRestored, "user", user.ID, reason, "")
+	public := user.Public()
+	return &public, nil
+}
+
+// SetRole changes the role of userID. Their sessions end, so tokens issued
+// with the old role stop working at once.
+func (s *Service) SetRole(ctx context.Context, actorID, userID, role, reason string) (*models.User, error) {
+	if !models.IsValidRole(role) {
+		return nil, apperr.New(apperr.Invalid, "Role must be one of user, moderator or admin")
+	}
+	if err := requireReason(reason); err != nil {
+		return nil, err
+	}
+	if userID == actorID {
+		return nil, apperr.New(apperr.Invalid, "You cannot change your own role")
+	}
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	previous := user.Role
+	if previous == role {
+		public := user.Public()
+		return &public, nil
+	}
+
+	user.Role = role
+	if err := s.store.UpdateUser(ctx, user); err != nil {
+		return nil, lookupError(err, "User not found")
+	}
+	if _, err := s.store.RevokeSessions(ctx, store.SessionFilter{UserID: user.ID}, time.Now()); err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error ending the user's sessions", err)
+	}
+
+	s.record(ctx, actorID, models.AuditRoleChanged, "user", user.ID, reason, previous+" -> "+role)
+	public := user.Public()
+	return &public, nil
+}
+
+// ListItems returns every item, including unavailable and suspended ones
+func (s *Service) ListItems(ctx context.Context) ([]*models.Item, error) {
+	items, err := s.store.ListItems(ctx, store.ItemFilter{})
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error loading items", err)
+	}
+	return items, nil
+}
+
+// SuspendItem takes a listing down: it leaves the catalog and cannot be booked
+func (s *Service) SuspendItem(ctx context.Context, actorID, itemID, reason string) (*models.Item, error) {
+	if err := requireReason(reason); err != nil {
+		return nil, err
+	}
+	item, err := s.item(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if item.Suspended() {
+		return nil, apperr.New(apperr.Conflict, "Item is already suspended")
+	}
+
+	now := time.Now()
+	item.SuspendedAt = &now
+	if err := s.store.UpdateItem(ctx, item); err != nil {
+		return nil, lookupError(err, "Item not found")
+	}
+
+	s.record(ctx, actorID, models.AuditItemSuspended, "item", item.ID, reason, "")
+	return item, nil
+}
+
+// RestoreItem puts a suspended listing back in the catalog
+func (s *Service) RestoreItem(ctx context.Context, actorID, itemID, reason string) (*models.Item, error) {
+	if err := requireReason(reason); err != nil {
+		return nil, err
+	}
+	item, err := s.item(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if !item.Suspended() {
+		return nil, apperr.New(apperr.Conflict, "Item is not suspended")
+	}
+
+	item.SuspendedAt = nil
+	if err := s.store.UpdateItem(ctx, item); err != nil {
+		return nil, lookupError(err, "Item not found")
+	}
+
+	s.record(ctx, actorID, models.AuditItemRestored, "item", item.ID, reason, "")
+	return item, nil
+}
+
+// ForceBookingStatus sets the status of any booking, for resolving stuck or
+// disputed ones. A cancelled booking is only reopened if its dates are still free.
+func (s *Service) ForceBookingStatus(ctx context.Context, actorID, bookingID, status, reason string) (*models.Booking, error) {
+	if !models.IsValidBookingStatus(status) {
+		return nil, apperr.New(apperr.Invalid, "Invalid status")
+	}
+	if err := requireReason(reason); err != nil {
+		return nil, err
+	}
+	booking, err := s.store.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, lookupError(err, "Booking not found")
+	}
+	if booking.Status == status {
+		return booking, nil
+	}
+
+	// The store re-checks the dates of a cancelled booking atomically with the change
+	booking, previous, err := s.store.SetBookingStatus(ctx, bookingID, status, time.Now())
+	if errors.Is(err, store.ErrBookingConflict) {
+		return nil, apperr.New(apperr.Conflict, "The booking's dates have been taken by another booking")
+	}
+	if err != nil {
+		return nil, lookupError(err, "Booking not found")
+	}
+
+	s.record(ctx, actorID, models.AuditBookingStatus, "booking", booking.ID, reason, previous+" -> "+status)
+	return booking, nil
+}
+
+// ListPayments returns the payments matching filter. Looking at payments is
+// recorded too, since they reveal what users paid for.
+func (s *Service) ListPayments(ctx context.Context, actorID string, filter store.PaymentFilter) ([]*models.Payment, error) {
+	payments, err := s.store.ListPayments(ctx, filter)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error loading payments", err)
+	}
+
+	var scope []string
+	if filter.UserID != "" {
+		scope = append(scope, "user "+filter.UserID)
+	}
+	if filter.BookingID != "" {
+		scope = append(scope, "booking "+filter.BookingID)
+	}
+	details := "all payments"
+	if len(scope) > 0 {
+		details = "payments of " + strings.Join(scope, ", ")
+	}
+	s.record(ctx, actorID, models.AuditPaymentsViewed, "payment", "", "", details)
+	return payments, nil
+}
+
+// AuditLog returns the recorded admin actions matching filter, oldest first
+func (s *Service) AuditLog(ctx context.Context, filter store.AuditFilter) ([]*models.AuditEntry, error) {
+	entries, err := s.store.ListAuditEntries(ctx, filter)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error loading the audit log", err)
+	}
+	return entries, nil
+}
+
+func (s *Service) user(ctx context.Context, id string) (*models.User, error) {
+	user, err := s.store.GetUser(ctx, id)
+	if err != nil {
+		return nil, lookupError(err, "User not found")
+	}
+	return user, nil
+}
+
+func (s *Service) item(ctx context.Context, id string) (*models.Item, error) {
+	item, err := s.store.GetItem(ctx, id)
+	if err != nil {
+		return nil, lookupError(err, "Item not found")
+	}
+	return item, nil
+}
+
+// record appends to the audit log; failures are only logged, as the action
+// itself has already been taken
+func (s *Service) record(ctx context.Context, actorID, action, targetType, targetID, reason, details string) {
+	entry := &models.AuditEntry{
+		ID:         ids.New(ids.PrefixAudit),
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     reason,
+		Details:    details,
+		CreatedAt:  time.Now(),
+	}
+	log.Printf("Admin action %s by %s on %s %s: %s", action, actorID, targetType, targetID, details)
+	if err := s.store.RecordAuditEntry(ctx, entry); err != nil {
+		log.Printf("Error recording admin action %s: %v", action, err)
+	}
+}
+
+func requireReason(reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return apperr.New(apperr.Invalid, "A reason is required")
+	}
+	return nil
+}
+
+func lookupError(err error, notFoundMessage string) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return apperr.Wrap(apperr.NotFound, notFoundMessage, err)
+	}
+	return apperr.Wrap(apperr.Internal, "Internal server error", err)
+}
@@ -0,0 +1,341 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+const testActor = "usr_admin"
+
+// succeeds is the error kind of test cases that must not fail
+const succeeds apperr.Kind = -1
+
+// slowStore widens the window between a separate availability check and the
+// write that follows it, which is where overlapping bookings slip through
+type slowStore struct {
+	store.Store
+}
+
+func (s slowStore) IsItemAvailable(ctx context.Context, itemID string, start, end time.Time) (bool, error) {
+	available, err := s.Store.IsItemAvailable(ctx, itemID, start, end)
+	time.Sleep(20 * time.Millisecond)
+	return available, err
+}
+
+// newTestService returns a Service over an in-memory store holding itm_tent
+func newTestService(t *testing.T) (*Service, *store.Database) {
+	t.Helper()
+	db := store.NewDatabase()
+	if err := db.CreateItem(context.Background(), &models.Item{ID: "itm_tent", Name: "Tent", DailyRate: 10, OwnerID: "usr_owner", Available: true}); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(slowStore{db}), db
+}
+
+var testStart = time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// createBooking stores a booking of itm_tent for three days from testStart,
+// shifted by offset days
+func createBooking(t *testing.T, db store.Store, id, status string, offset int) {
+	t.Helper()
+	err := db.CreateBooking(context.Background(), &models.Booking{
+		ID:        id,
+		ItemID:    "itm_tent",
+		UserID:    "usr_renter_" + id,
+		StartDate: testStart.AddDate(0, 0, offset),
+		EndDate:   testStart.AddDate(0, 0, offset+3),
+		Status:    status,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// auditEntries returns the recorded actions taken on targetID
+func auditEntries(t *testing.T, db store.Store, targetID string) []*models.AuditEntry {
+	t.Helper()
+	entries, err := db.ListAuditEntries(context.Background(), store.AuditFilter{TargetID: targetID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestForceBookingStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string // Statuses of bookings overlapping bkg_forced, which starts cancelled
+		status   string
+		reason   string
+		want     apperr.Kind // Kind of error, or succeeds
+		wantNow  string      // Status of bkg_forced afterwards
+	}{
+		{"reopen with free dates", nil, models.BookingConfirmed, "Cancelled by mistake", succeeds, models.BookingConfirmed},
+		{"reopen over an active booking", []string{models.BookingPending}, models.BookingPending, "Cancelled by mistake", apperr.Conflict, models.BookingCancelled},
+		{"reopen over a completed booking", []string{models.BookingCompleted}, models.BookingConfirmed, "Cancelled by mistake", apperr.Conflict, models.BookingCancelled},
+		{"reopen over a cancelled booking", []string{models.BookingCancelled}, models.BookingConfirmed, "Cancelled by mistake", succeeds, models.BookingConfirmed},
+		{"no reason", nil, models.BookingConfirmed, " ", apperr.Invalid, models.BookingCancelled},
+		{"unknown status", nil, "lost", "Typo", apperr.Invalid, models.BookingCancelled},
+		{"unchanged", nil, models.BookingCancelled, "Already cancelled", succeeds, models.BookingCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, db := newTestService(t)
+			ctx := context.Background()
+			createBooking(t, db, "bkg_forced", models.BookingCancelled, 0)
+			for i, status := range tt.existing {
+				createBooking(t, db, fmt.Sprintf("bkg_other_%d", i), status, 1)
+			}
+
+			_, err := s.ForceBookingStatus(ctx, testActor, "bkg_forced", tt.status, tt.reason)
+			if (err == nil) != (tt.want == succeeds) || (err != nil && apperr.KindOf(err) != tt.want) {
+				t.Fatalf("ForceBookingStatus: %v, want kind %v", err, tt.want)
+			}
+			booking, err := db.GetBooking(ctx, "bkg_forced")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if booking.Status != tt.wantNow {
+				t.Errorf("booking is %s, want %s", booking.Status, tt.wantNow)
+			}
+
+			entries := auditEntries(t, db, "bkg_forced")
+			if changed := tt.wantNow != models.BookingCancelled; changed != (len(entries) == 1) {
+				t.Fatalf("%d audit entries, want one only for a change", len(entries))
+			}
+			if len(entries) == 1 {
+				e := entries[0]
+				if e.Action != models.AuditBookingStatus || e.ActorID != testActor || e.Reason != tt.reason || e.Details != "cancelled -> "+tt.status {
+					t.Errorf("audit entry %+v", e)
+				}
+			}
+		})
+	}
+}
+
+func TestForceBookingStatusConcurrentReactivations(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	const bookings = 8
+	for i := range bookings {
+		createBooking(t, db, fmt.Sprintf("bkg_%d", i), models.BookingCancelled, i%2)
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan error, bookings)
+	for i := range bookings {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.ForceBookingStatus(ctx, testActor, fmt.Sprintf("bkg_%d", i), models.BookingConfirmed, "Disputed cancellation")
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			succeeded++
+		case apperr.KindOf(err) != apperr.Conflict:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	active, err := db.ListBookings(ctx, store.BookingFilter{ItemID: "itm_tent", Statuses: []string{models.BookingConfirmed}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if succeeded != 1 || len(active) != 1 {
+		t.Errorf("%d requests succeeded and %d bookings are confirmed, want exactly 1", succeeded, len(active))
+	}
+}
+
+// createUser stores a regular user with a signed-in session
+func createUser(t *testing.T, db store.Store, id string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := db.CreateUser(ctx, &models.User{ID: id, Username: id, Email: id + "@example.com", Role: models.RoleUser, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateSession(ctx, &models.Session{ID: "ses_" + id, UserID: id, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChangesRequireAReason(t *testing.T) {
+	suspendUser := func(s *Service) error {
+		_, err := s.SuspendUser(context.Background(), testActor, "usr_ana", "Spam listings")
+		return err
+	}
+	suspendItem := func(s *Service) error {
+		_, err := s.SuspendItem(context.Background(), testActor, "itm_tent", "Counterfeit")
+		return err
+	}
+	tests := []struct {
+		name   string
+		target string
+		setup  func(s *Service) error // Makes the change possible; may be nil
+		change func(s *Service, reason string) error
+	}{
+		{"suspend user", "usr_ana", nil, func(s *Service, reason string) error {
+			_, err := s.SuspendUser(context.Background(), testActor, "usr_ana", reason)
+			return err
+		}},
+		{"restore user", "usr_ana", suspendUser, func(s *Service, reason string) error {
+			_, err := s.RestoreUser(context.Background(), testActor, "usr_ana", reason)
+			return err
+		}},
+		{"set role", "usr_ana", nil, func(s *Service, reason string) error {
+			_, err := s.SetRole(context.Background(), testActor, "usr_ana", models.RoleModerator, reason)
+			return err
+		}},
+		{"suspend item", "itm_tent", nil, func(s *Service, reason string) error {
+			_, err := s.SuspendItem(context.Background(), testActor, "itm_tent", reason)
+			return err
+		}},
+		{"restore item", "itm_tent", suspendItem, func(s *Service, reason string) error {
+			_, err := s.RestoreItem(context.Background(), testActor, "itm_tent", reason)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, db := newTestService(t)
+			createUser(t, db, "usr_ana")
+			if tt.setup != nil {
+				if err := tt.setup(s); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before := len(auditEntries(t, db, tt.target))
+
+			for _, reason := range []string{"", " \t"} {
+				if err := tt.change(s, reason); apperr.KindOf(err) != apperr.Invalid {
+					t.Errorf("reason %q: %v, want Invalid", reason, err)
+				}
+			}
+			if after := len(auditEntries(t, db, tt.target)); after != before {
+				t.Errorf("%d audit entries for refused changes", after-before)
+			}
+			if err := tt.change(s, "Reported by the owner"); err != nil {
+				t.Errorf("with a reason: %v", err)
+			}
+		})
+	}
+}
+
+func TestSuspendAndRestoreUser(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	createUser(t, db, "usr_ana")
+
+	if _, err := s.RestoreUser(ctx, testActor, "usr_ana", "Appeal"); apperr.KindOf(err) != apperr.Conflict {
+		t.Errorf("restoring an active user: %v, want Conflict", err)
+	}
+	if _, err := s.SuspendUser(ctx, testActor, testActor, "Testing"); apperr.KindOf(err) != apperr.Invalid {
+		t.Errorf("suspending oneself: %v, want Invalid", err)
+	}
+	user, err := s.SuspendUser(ctx, testActor, "usr_ana", "Spam listings")
+	if err != nil || !user.Suspended() || user.Password != "" {
+		t.Fatalf("SuspendUser: %+v, %v", user, err)
+	}
+	if session, err := db.GetSession(ctx, "ses_usr_ana"); err != nil || session.Active() {
+		t.Errorf("session of the suspended user: %+v, %v, want revoked", session, err)
+	}
+	if _, err := s.SuspendUser(ctx, testActor, "usr_ana", "Again"); apperr.KindOf(err) != apperr.Conflict {
+		t.Errorf("suspending twice: %v, want Conflict", err)
+	}
+
+	user, err = s.RestoreUser(ctx, testActor, "usr_ana", "Appeal upheld")
+	if err != nil || user.Suspended() {
+		t.Fatalf("RestoreUser: %+v, %v", user, err)
+	}
+	if stored, _ := db.GetUser(ctx, "usr_ana"); stored.Suspended() {
+		t.Error("the restored user is still suspended in the store")
+	}
+
+	entries := auditEntries(t, db, "usr_ana")
+	if len(entries) != 2 {
+		t.Fatalf("%d audit entries, want 2", len(entries))
+	}
+	actions := map[string]string{}
+	for _, e := range entries {
+		if e.ActorID != testActor || e.TargetType != "user" {
+			t.Errorf("audit entry %+v", e)
+		}
+		actions[e.Action] = e.Reason
+	}
+	if actions[models.AuditUserSuspended] != "Spam listings" || actions[models.AuditUserRestored] != "Appeal upheld" {
+		t.Errorf("audit reasons %v", actions)
+	}
+	if _, err := s.RestoreUser(ctx, testActor, "usr_missing", "Appeal"); apperr.KindOf(err) != apperr.NotFound {
+		t.Errorf("restoring a missing user: %v, want NotFound", err)
+	}
+}
+
+func TestSuspendAndRestoreItem(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+
+	if _, err := s.RestoreItem(ctx, testActor, "itm_tent", "Appeal"); apperr.KindOf(err) != apperr.Conflict {
+		t.Errorf("restoring a listed item: %v, want Conflict", err)
+	}
+	if item, err := s.SuspendItem(ctx, testActor, "itm_tent", "Counterfeit"); err != nil || !item.Suspended() {
+		t.Fatalf("SuspendItem: %+v, %v", item, err)
+	}
+	if listed, _ := db.ListItems(ctx, store.ItemFilter{ListedOnly: true}); len(listed) != 0 {
+		t.Errorf("%d items listed while the only one is suspended", len(listed))
+	}
+	if item, err := s.RestoreItem(ctx, testActor, "itm_tent", "Receipt provided"); err != nil || item.Suspended() {
+		t.Fatalf("RestoreItem: %+v, %v", item, err)
+	}
+	if listed, _ := db.ListItems(ctx, store.ItemFilter{ListedOnly: true}); len(listed) != 1 {
+		t.Errorf("%d items listed after restoring, want 1", len(listed))
+	}
+
+	entries := auditEntries(t, db, "itm_tent")
+	reasons := map[string]string{}
+	for _, e := range entries {
+		reasons[e.Action] = e.Reason
+	}
+	if len(entries) != 2 || reasons[models.AuditItemSuspended] != "Counterfeit" || reasons[models.AuditItemRestored] != "Receipt provided" {
+		t.Errorf("audit entries %v, want a suspension and a restore with their reasons", reasons)
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	createUser(t, db, "usr_ana")
+
+	if _, err := s.SetRole(ctx, testActor, "usr_ana", "owner", "Promotion"); apperr.KindOf(err) != apperr.Invalid {
+		t.Errorf("unknown role: %v, want Invalid", err)
+	}
+	if _, err := s.SetRole(ctx, testActor, testActor, models.RoleUser, "Stepping down"); apperr.KindOf(err) != apperr.Invalid {
+		t.Errorf("changing one's own role: %v, want Invalid", err)
+	}
+	user, err := s.SetRole(ctx, testActor, "usr_ana", models.RoleModerator, "Helps with reports")
+	if err != nil || user.Role != models.RoleModerator {
+		t.Fatalf("SetRole: %+v, %v", user, err)
+	}
+	if session, err := db.GetSession(ctx, "ses_usr_ana"); err != nil || session.Active() {
+		t.Errorf("session after the role change: %+v, %v, want revoked", session, err)
+	}
+	// Setting the same role again changes and records nothing
+	if _, err := s.SetRole(ctx, testActor, "usr_ana", models.RoleModerator, "Again"); err != nil {
+		t.Errorf("setting the same role: %v", err)
+	}
+	entries := auditEntries(t, db, "usr_ana")
+	if len(entries) != 1 || entries[0].Action != models.AuditRoleChanged || entries[0].Details != "user -> moderator" {
+		t.Errorf("audit entries %+v, want one role change", entries)
+	}
+}
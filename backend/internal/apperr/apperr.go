@@ -0,0 +1,68 @@
+// Package apperr defines the errors services return to transports. Each
+// error carries a kind, which transports map to a status code, and a message
+// that is safe to show to clients.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies an error independently of any transport
+type Kind int
+
+const (
+	Internal Kind = iota
+	Invalid
+	Unauthorized
+	Forbidden
+	NotFound
+	Conflict
+	TooManyRequests
+)
+
+// Error is a client-facing error
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error // Optional underlying cause, never shown to clients
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New creates an error of the given kind
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap creates an error of the given kind that keeps err as its cause
+func Wrap(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// KindOf returns the kind of err, or Internal when err is not an *Error
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Internal
+}
+
+// MessageOf returns the client-facing message of err
+func MessageOf(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Message
+	}
+	return "Internal server error"
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/ids"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// APIKeyPrefix starts every API key, telling them apart from access tokens
+// in the Authorization header and making leaked keys easy to search for
+const APIKeyPrefix = "bhk_"
+
+// maxAPIKeyName bounds the label a user gives a key
+const maxAPIKeyName = 100
+
+// apiKeyTouchInterval is how stale a key's last-used time may get before a
+// request updates it, so busy scripts don't write on every call
+const apiKeyTouchInterval = time.Minute
+
+// Scope is something an API key may do. Access tokens from a login are not
+// scoped.
+type Scope string
+
+// Scopes an API key can be granted
+const (
+	ScopeItemsRead     Scope = "items:read"     // List one's own items, read availability
+	ScopeItemsWrite    Scope = "items:write"    // Create, update and delete one's own items, upload images
+	ScopeBookingsRead  Scope = "bookings:read"  // List one's bookings
+	ScopeBookingsWrite Scope = "bookings:write" // Create bookings and change their status
+	ScopePaymentsRead  Scope = "payments:read"  // Read one's payment history
+	ScopePaymentsWrite Scope = "payments:write" // Create and verify payment orders
+	ScopeProfileRead   Scope = "profile:read"   // Read one's profile
+	ScopeProfileWrite  Scope = "profile:write"  // Update one's profile
+)
+
+var allScopes = []Scope{
+	ScopeItemsRead, ScopeItemsWrite, ScopeBookingsRead, ScopeBookingsWrite,
+	ScopePaymentsRead, ScopePaymentsWrite, ScopeProfileRead, ScopeProfileWrite,
+}
+
+// IsValidScope reports whether scope is one an API key can be granted
+func IsValidScope(scope string) bool {
+	return slices.Contains(allScopes, Scope(scope))
+}
+
+// NewAPIKey is a freshly created key along with the only copy of its secret
+type NewAPIKey struct {
+	Key    models.APIKey
+	Secret string // The full key to send in the Authorization header
+}
+
+// CreateAPIKey issues a named key for userID granting scopes, expiring at
+// expiresAt unless that is nil
+func (s *Service) CreateAPIKey(ctx context.Context, userID, name string, scopes []string, expiresAt *time.Time) (*NewAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, apperr.New(apperr.Invalid, "Name is required")
+	}
+	if len(name) > maxAPIKeyName {
+		return nil, apperr.New(apperr.Invalid, "Name is too long")
+	}
+	if len(scopes) == 0 {
+		return nil, apperr.New(apperr.Invalid, "At least one scope is required")
+	}
+	granted := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !IsValidScope(scope) {
+			return nil, apperr.New(apperr.Invalid, "Unknown scope: "+scope)
+		}
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	now := time.Now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, apperr.New(apperr.Invalid, "Expiry must be in the future")
+	}
+
+	secret, hash, err := newSecret()
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error generating key", err)
+	}
+	key := &models.APIKey{
+		ID:        ids.New(ids.PrefixAPIKey),
+		UserID:    userID,
+		Name:      name,
+		KeyHash:   hash,
+		Scopes:    granted,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+	if err := s.store.CreateAPIKey(ctx, key); err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error creating key", err)
+	}
+	return &NewAPIKey{Key: key.Public(), Secret: APIKeyPrefix + joinToken(key.ID, secret)}, nil
+}
+
+// ListAPIKeys returns the keys of userID, including revoked and expired ones
+func (s *Service) ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	keys, err := s.store.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error loading keys", err)
+	}
+	public := make([]models.APIKey, 0, len(keys))
+	for _, key := range keys {
+		public = append(public, key.Public())
+	}
+	return public, nil
+}
+
+// RevokeAPIKey stops key id from working. Revoking a revoked key is a no-op.
+func (s *Service) RevokeAPIKey(ctx context.Context, id string) error {
+	if err := s.store.RevokeAPIKey(ctx, id, time.Now()); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return apperr.New(apperr.NotFound, "API key not found")
+		}
+		return apperr.Wrap(apperr.Internal, "Error revoking key", err)
+	}
+	return nil
+}
+
+// authenticateAPIKey checks a key sent in place of an access token. The
+// caller gets the key owner's current role and the key's scopes.
+func (s *Service) authenticateAPIKey(ctx context.Context, apiKey string) (*Principal, error) {
+	invalid := apperr.New(apperr.Unauthorized, "Invalid API key")
+
+	id, secret, ok := splitToken(strings.TrimPrefix(apiKey, APIKeyPrefix))
+	if !ok {
+		return nil, invalid
+	}
+	key, err := s.store.GetAPIKey(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	if !secretMatches(secret, key.KeyHash) {
+		return nil, invalid
+	}
+	now := time.Now()
+	if !key.Active(now) {
+		return nil, apperr.New(apperr.Unauthorized, "API key has expired or been revoked")
+	}
+
+	user, err := s.store.GetUser(ctx, key.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	if err := checkNotSuspended(user); err != nil {
+		return nil, err
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		// The request goes ahead even if this fails
+		if err := s.store.TouchAPIKey(ctx, key.ID, now); err != nil {
+			log.Printf("Error recording use of API key %s: %v", key.ID, err)
+		}
+	}
+
+	scopes := make([]Scope, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes = append(scopes, Scope(scope))
+	}
+	return &Principal{UserID: user.ID, Email: user.Email, Role: user.Role, APIKeyID: key.ID, Scopes: scopes}, nil
+}
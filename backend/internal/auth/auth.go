@@ -0,0 +1,184 @@
+// Package auth implements registration, password and single sign-on login,
+// and sessions: short-lived access tokens backed by rotating, server-side
+// refresh tokens.
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/ids"
+	"borrowhub/internal/mail"
+	"borrowhub/internal/models"
+	"borrowhub/internal/oidc"
+	"borrowhub/internal/store"
+)
+
+// Options configure a Service
+type Options struct {
+	RefreshTTL           time.Duration // Idle lifetime of a session
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	VerificationCooldown time.Duration // Minimum gap between verification or password reset emails to one address
+	MFAChallengeTTL      time.Duration // Time to enter the second factor after the password
+	Lockout              LockoutPolicy
+	PasswordPolicy       PasswordPolicy
+	BcryptCost           int            // Cost of new password hashes; older ones are upgraded at login
+	OIDC                 *oidc.Provider // Nil unless sign-in with an external provider is configured
+	Mailer               mail.Mailer
+	AppURL               string // Frontend base URL for links in emails
+}
+
+// Service registers users, logs them in and manages their sessions
+type Service struct {
+	store  store.Store
+	tokens *TokenManager
+	opts   Options
+
+	// dummyHash is compared against when there is no password hash to check,
+	// so unknown email addresses take as long to reject as wrong passwords
+	dummyHash []byte
+	blocked   blockedLogins
+}
+
+func NewService(s store.Store, tokens *TokenManager, opts Options) *Service {
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("borrowhub-timing-equaliser"), opts.BcryptCost)
+	if err != nil {
+		// Only an invalid cost fails, which config validation rules out
+		log.Printf("Error generating dummy password hash: %v", err)
+	}
+	return &Service{store: s, tokens: tokens, opts: opts, dummyHash: dummyHash}
+}
+
+// Register creates user from the submitted fields and starts a session for
+// it. The stored password is replaced by its hash.
+func (s *Service) Register(ctx context.Context, user *models.User, client Client) (*TokenPair, error) {
+	// Basic validation
+	user.Email = normalizeEmail(user.Email)
+	if user.Email == "" || user.Password == "" {
+		return nil, apperr.New(apperr.Invalid, "Email and password are required")
+	}
+	if addr, err := netmail.ParseAddress(user.Email); err != nil || addr.Address != user.Email {
+		return nil, apperr.New(apperr.Invalid, "Invalid email address")
+	}
+
+	if err := s.opts.PasswordPolicy.check(user.Password); err != nil {
+		return nil, err
+	}
+
+	// Check if user already exists
+	if _, err := s.store.GetUserByEmail(ctx, user.Email); err == nil {
+		return nil, apperr.New(apperr.Conflict, "User already exists")
+	}
+
+	hashedPassword, err := s.hashPassword(user.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user.ID = ids.New(ids.PrefixUser)
+	user.Password = hashedPassword
+	user.Verified = false // Only a verification link can set this
+	user.Role = models.RoleUser
+	user.SuspendedAt = nil
+	user.CreatedAt = time.Now()
+	if user.Username == "" {
+		user.Username = strings.Split(user.Email, "@")[0]
+	}
+
+	if err := s.store.CreateUser(ctx, user); err != nil {
+		if errors.Is(err, store.ErrAlreadyExists) {
+			return nil, apperr.New(apperr.Conflict, "User already exists")
+		}
+		return nil, apperr.Wrap(apperr.Internal, "Error creating user", err)
+	}
+
+	// The account works without it, so a failed email must not fail registration
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("Error sending verification email to user %s: %v", user.ID, err)
+	}
+
+	return s.startSession(ctx, user, client)
+}
+
+// LoginResult is the outcome of a correct password: either a session, or a
+// challenge to complete with CompleteLogin when the user has two-factor
+// authentication enabled
+type LoginResult struct {
+	User   *models.User
+	Tokens *TokenPair
+
+	MFAToken     string
+	MFAExpiresIn time.Duration
+}
+
+// Login checks the credentials and starts a new session for the user, or
+// asks for the second factor first. The client's IP, which may be empty,
+// throttles guessing across accounts.
+func (s *Service) Login(ctx context.Context, email, password string, client Client) (*LoginResult, error) {
+	// The same form for the lockout, the lookup and the security log
+	email = normalizeEmail(email)
+	attempt, err := s.beginAttempt(ctx, email, client.IP)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unknown addresses go through the same steps, so they take as long to reject
+	user, err := s.store.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		s.releaseAttempt(ctx, attempt)
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+
+	if !s.checkPassword(user, password) {
+		s.attemptFailed(ctx, attempt, user)
+		return nil, apperr.New(apperr.Unauthorized, "Invalid credentials")
+	}
+	// A right password is not a failure, but it does not lift a lockout either
+	s.releaseAttempt(ctx, attempt)
+	s.upgradePasswordHash(ctx, user, password)
+
+	return s.finishLogin(ctx, user, client)
+}
+
+// normalizeEmail returns the form email addresses are stored and looked up
+// in. Addresses are compared ignoring case, as every mail provider does.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// finishLogin starts a session for a user whose first factor checked out,
+// or issues an MFA challenge if they need a second one
+func (s *Service) finishLogin(ctx context.Context, user *models.User, client Client) (*LoginResult, error) {
+	if err := checkNotSuspended(user); err != nil {
+		return nil, err
+	}
+	required, err := s.mfaRequired(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if required {
+		challenge, err := s.tokens.IssueChallenge(user.ID, s.opts.MFAChallengeTTL)
+		if err != nil {
+			return nil, apperr.Wrap(apperr.Internal, "Error generating token", err)
+		}
+		return &LoginResult{User: user, MFAToken: challenge, MFAExpiresIn: s.opts.MFAChallengeTTL}, nil
+	}
+
+	// Failed attempts are only forgotten once a session starts, so a known
+	// password does not buy more guesses at the second factor
+	s.loginSucceeded(ctx, user.Email)
+
+	tokens, err := s.startSession(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{User: user, Tokens: tokens}, nil
+}
@@ -0,0 +1,303 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"borrowhub/internal/mail"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+const testPassword = "correct horse battery staple"
+
+func init() {
+	backupCodeCost = bcrypt.MinCost // Ten codes are hashed per enrollment
+}
+
+var testClient = Client{IP: "192.0.2.1", UserAgent: "auth-test"}
+
+// newTestService returns a Service over an in-memory store, with options
+// small enough to exercise lockouts quickly
+func newTestService(t *testing.T, configure ...func(*Options)) (*Service, *store.Database) {
+	t.Helper()
+	keys, err := GenerateKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := NewTokenManager(TokenOptions{
+		Keys:     keys,
+		Issuer:   "borrowhub",
+		Audience: "borrowhub-api",
+		TTL:      15 * time.Minute,
+		Secret:   []byte("test-secret-used-only-by-these-tests"),
+	})
+	opts := Options{
+		RefreshTTL:           time.Hour,
+		PasswordResetTTL:     time.Hour,
+		EmailVerificationTTL: time.Hour,
+		MFAChallengeTTL:      5 * time.Minute,
+		Lockout:              LockoutPolicy{Threshold: 3, IPThreshold: 10, Delay: time.Minute, MaxDelay: time.Hour},
+		PasswordPolicy:       PasswordPolicy{MinLength: 8, MinClasses: 1},
+		BcryptCost:           bcrypt.MinCost,
+		Mailer:               mail.LogMailer{},
+		AppURL:               "http://localhost:5173",
+	}
+	for _, f := range configure {
+		f(&opts)
+	}
+	db := store.NewDatabase()
+	return NewService(db, tokens, opts), db
+}
+
+// createTestUser stores a verified user with testPassword
+func createTestUser(t *testing.T, db store.Store, email string) *models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{
+		ID:        "usr_" + email,
+		Username:  email,
+		Email:     email,
+		Password:  string(hash),
+		Verified:  true,
+		Role:      models.RoleUser,
+		CreatedAt: time.Now(),
+	}
+	if err := db.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// login logs in with testPassword and fails the test unless a session starts
+func login(t *testing.T, s *Service, email string) *TokenPair {
+	t.Helper()
+	result, err := s.Login(context.Background(), email, testPassword, testClient)
+	if err != nil {
+		t.Fatalf("Login(%s): %v", email, err)
+	}
+	if result.Tokens == nil {
+		t.Fatalf("Login(%s) asked for a second factor", email)
+	}
+	return result.Tokens
+}
+
+// pemEncode returns the PEM encoding of a private key, or of a public one
+func pemEncode(t *testing.T, key interface{}) []byte {
+	t.Helper()
+	blockType := "PUBLIC KEY"
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if _, private := key.(crypto.Signer); private {
+		blockType = "PRIVATE KEY"
+		der, err = x509.MarshalPKCS8PrivateKey(key)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+// testTokens returns a TokenManager signing with keys, configured like newTestService's
+func testTokens(keys *KeySet) *TokenManager {
+	return NewTokenManager(TokenOptions{Keys: keys, Issuer: "borrowhub", Audience: "borrowhub-api", TTL: 15 * time.Minute})
+}
+
+// forgeToken signs a token that is valid apart from its kid, algorithm and key
+func forgeToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	t.Helper()
+	now := time.Now()
+	token := jwt.NewWithClaims(method, &Claims{
+		UserID: "usr_ana",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "borrowhub",
+			Subject:   "usr_ana",
+			Audience:  jwt.ClaimStrings{"borrowhub-api"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestKeyRotation(t *testing.T) {
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, minRSABits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{ID: "usr_ana", Email: "ana@example.com", Role: models.RoleUser}
+
+	before, err := ParseKeySet(pemEncode(t, oldKey), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := testTokens(before).Issue(user, "ses_ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The new key signs while the old one, given as a public key, still verifies
+	during, err := ParseKeySet(pemEncode(t, newKey), pemEncode(t, oldKey.Public()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if during.SigningKeyID() == before.SigningKeyID() {
+		t.Fatal("the new key has the old key's kid")
+	}
+	tokens := testTokens(during)
+	if claims, err := tokens.Validate(oldToken); err != nil || claims.UserID != user.ID {
+		t.Errorf("token signed with the old key during rotation: %v", err)
+	}
+	newToken, err := tokens.Issue(user, "ses_ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokens.Validate(newToken); err != nil {
+		t.Errorf("token signed with the new key: %v", err)
+	}
+	if _, err := testTokens(before).Validate(newToken); err == nil {
+		t.Error("a key set without the new key accepted its token")
+	}
+
+	// Once the old key is dropped, its tokens are refused
+	after, err := ParseKeySet(pemEncode(t, newKey), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testTokens(after).Validate(oldToken); err == nil {
+		t.Error("token signed with a dropped key was accepted")
+	}
+	if _, err := testTokens(after).Validate(newToken); err != nil {
+		t.Errorf("token signed with the new key after rotation: %v", err)
+	}
+
+	// The JWKS publishes both keys, signing key first, each with its algorithm
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	data, err := during.JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		t.Fatal(err)
+	}
+	if len(jwks.Keys) != 2 ||
+		jwks.Keys[0].Kid != during.SigningKeyID() || jwks.Keys[0].Alg != "RS256" || jwks.Keys[0].Kty != "RSA" ||
+		jwks.Keys[1].Kid != before.SigningKeyID() || jwks.Keys[1].Alg != "EdDSA" || jwks.Keys[1].Crv != "Ed25519" {
+		t.Errorf("JWKS %s", data)
+	}
+}
+
+func TestTokensNeedAKnownKidAndItsAlgorithm(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, minRSABits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, strangerKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ParseKeySet(pemEncode(t, rsaKey), pemEncode(t, edKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := newVerificationKey(strangerKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKid := keys.SigningKeyID()
+	edKid := keys.order[1]
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"RSA key", forgeToken(t, jwt.SigningMethodRS256, rsaKid, rsaKey), true},
+		{"Ed25519 key", forgeToken(t, jwt.SigningMethodEdDSA, edKid, edKey), true},
+		{"unknown kid", forgeToken(t, jwt.SigningMethodEdDSA, stranger.kid, strangerKey), false},
+		{"no kid", forgeToken(t, jwt.SigningMethodEdDSA, "", edKey), false},
+		{"kid of another key", forgeToken(t, jwt.SigningMethodEdDSA, edKid, strangerKey), false},
+		{"RSA kid with EdDSA", forgeToken(t, jwt.SigningMethodEdDSA, rsaKid, edKey), false},
+		{"RSA kid with PS256", forgeToken(t, jwt.SigningMethodPS256, rsaKid, rsaKey), false},
+		{"RSA kid with RS512", forgeToken(t, jwt.SigningMethodRS512, rsaKid, rsaKey), false},
+		// An HMAC keyed with the public key, were the algorithm taken from the token
+		{"RSA kid with HS256", forgeToken(t, jwt.SigningMethodHS256, rsaKid, x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)), false},
+	}
+	tokens := testTokens(keys)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tokens.Validate(tt.token); (err == nil) != tt.valid {
+				t.Errorf("Validate: %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+
+	// The pin holds even for algorithms the parser would otherwise allow
+	for kid, method := range map[string]jwt.SigningMethod{rsaKid: jwt.SigningMethodEdDSA, edKid: jwt.SigningMethodRS256} {
+		token := &jwt.Token{Method: method, Header: map[string]interface{}{"kid": kid}}
+		if _, err := keys.keyfunc(token); err == nil {
+			t.Errorf("the key of %s resolved for %s", kid, method.Alg())
+		}
+	}
+}
+
+func TestParseKeySetRejects(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		signing      []byte
+		verification []byte
+		want         string // Part of the error
+	}{
+		{"no signing key", nil, nil, "expected one private key, found 0"},
+		{"public signing key", pemEncode(t, edKey.Public()), nil, "expected one private key, found 0"},
+		{"two signing keys", append(pemEncode(t, edKey), pemEncode(t, edKey)...), nil, "found 2"},
+		{"weak signing key", pemEncode(t, weakKey), nil, "at least 2048 are required"},
+		{"weak verification key", pemEncode(t, edKey), pemEncode(t, weakKey.Public()), "at least 2048 are required"},
+		{"certificate", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{0}}), nil, "unsupported PEM block"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKeySet(tt.signing, tt.verification)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseKeySet: %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSABits is the smallest RSA modulus accepted for signing or verification
+const minRSABits = 2048
+
+// verificationKey is a public key tokens may be signed with. Its algorithm
+// is fixed by the key type, so a token cannot pick a weaker one.
+type verificationKey struct {
+	kid    string
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// KeySet holds the private key that signs new tokens and every public key
+// that tokens are still accepted from. Rotating keys means publishing the
+// next key as a verification key, then signing with it, and dropping the old
+// key once the tokens it signed have expired.
+type KeySet struct {
+	signer crypto.Signer
+	signBy *verificationKey
+	keys   map[string]*verificationKey
+	order  []string // kids in the order they were configured, signing key first
+}
+
+// ParseKeySet builds a KeySet from a PEM private key (RSA or Ed25519) and
+// any number of further PEM keys, public or private, that stay valid for
+// verification
+func ParseKeySet(signingPEM, verificationPEM []byte) (*KeySet, error) {
+	signers, _, err := parsePEMKeys(signingPEM)
+	if err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+	if len(signers) != 1 {
+		return nil, fmt.Errorf("signing key: expected one private key, found %d", len(signers))
+	}
+	set, err := newKeySet(signers[0])
+	if err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+
+	extraSigners, publics, err := parsePEMKeys(verificationPEM)
+	if err != nil {
+		return nil, fmt.Errorf("verification keys: %w", err)
+	}
+	for _, signer := range extraSigners {
+		publics = append(publics, signer.Public())
+	}
+	for _, public := range publics {
+		if err := set.add(public); err != nil {
+			return nil, fmt.Errorf("verification keys: %w", err)
+		}
+	}
+	return set, nil
+}
+
+// GenerateKeySet returns a KeySet with a new Ed25519 key. Tokens it signs
+// stop validating when the process exits, so it only suits development.
+func GenerateKeySet() (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return newKeySet(private)
+}
+
+func newKeySet(signer crypto.Signer) (*KeySet, error) {
+	set := &KeySet{signer: signer, keys: make(map[string]*verificationKey)}
+	if err := set.add(signer.Public()); err != nil {
+		return nil, err
+	}
+	set.signBy = set.keys[set.order[0]]
+	return set, nil
+}
+
+func (k *KeySet) add(public crypto.PublicKey) error {
+	key, err := newVerificationKey(public)
+	if err != nil {
+		return err
+	}
+	if _, exists := k.keys[key.kid]; exists {
+		return nil
+	}
+	k.keys[key.kid] = key
+	k.order = append(k.order, key.kid)
+	return nil
+}
+
+// SigningKeyID is the kid of the key that signs new tokens
+func (k *KeySet) SigningKeyID() string {
+	return k.signBy.kid
+}
+
+// sign returns a token of claims signed with the current key and labelled with its kid
+func (k *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.signBy.method, claims)
+	token.Header["kid"] = k.signBy.kid
+	return token.SignedString(k.signer)
+}
+
+// keyfunc resolves the verification key named by a token's kid
+func (k *KeySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("key %q signs with %s, not %s", kid, key.method.Alg(), token.Method.Alg())
+	}
+	return key.public, nil
+}
+
+// jwk is the JSON Web Key (RFC 7517) form of a public key
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS returns the public keys as a JSON Web Key Set, for services that
+// verify BorrowHub tokens themselves
+func (k *KeySet) JWKS() ([]byte, error) {
+	keys := make([]jwk, 0, len(k.order))
+	for _, kid := range k.order {
+		key := k.keys[kid]
+		entry := jwk{Use: "sig", Alg: key.method.Alg(), Kid: kid}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			entry.Kty = "RSA"
+			entry.N = b64(public.N.Bytes())
+			entry.E = b64(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			entry.Kty = "OKP"
+			entry.Crv = "Ed25519"
+			entry.X = b64(public)
+		}
+		keys = append(keys, entry)
+	}
+	return json.Marshal(map[string][]jwk{"keys": keys})
+}
+
+func newVerificationKey(public crypto.PublicKey) (*verificationKey, error) {
+	// The kid is the RFC 7638 thumbprint, so it never has to be configured
+	var thumbprint string
+	var method jwt.SigningMethod
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key has %d bits, at least %d are required", public.N.BitLen(), minRSABits)
+		}
+		method = jwt.SigningMethodRS256
+		thumbprint = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, b64(big.NewInt(int64(public.E)).Bytes()), b64(public.N.Bytes()))
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+		thumbprint = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":%q}`, b64(public))
+	default:
+		return nil, fmt.Errorf("unsupported key type %T; use RSA or Ed25519", public)
+	}
+	sum := sha256.Sum256([]byte(thumbprint))
+	return &verificationKey{kid: b64(sum[:]), method: method, public: public}, nil
+}
+
+// parsePEMKeys returns the private and public keys in data, which may hold
+// any number of PEM blocks
+func parsePEMKeys(data []byte) ([]crypto.Signer, []crypto.PublicKey, error) {
+	var signers []crypto.Signer
+	var publics []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return signers, publics, nil
+		}
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			signer, ok := key.(crypto.Signer)
+			if !ok {
+				return nil, nil, fmt.Errorf("unsupported private key type %T", key)
+			}
+			signers = append(signers, signer)
+		case "RSA PRIVATE KEY":
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			signers = append(signers, key)
+		case "PUBLIC KEY":
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			publics = append(publics, key)
+		case "RSA PUBLIC KEY":
+			key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			publics = append(publics, key)
+		default:
+			return nil, nil, errors.New("unsupported PEM block " + block.Type)
+		}
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
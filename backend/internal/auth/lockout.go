@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/ids"
+	"borrowhub/internal/mail"
+	"borrowhub/internal/models"
+)
+
+// LockoutPolicy limits password guessing. Once an email address has had
+// Threshold failed logins, or a client IP IPThreshold, further attempts are
+// refused for Delay after the last failure, doubling with every failure
+// beyond the threshold up to MaxDelay.
+type LockoutPolicy struct {
+	Threshold   int
+	IPThreshold int
+	Delay       time.Duration
+	MaxDelay    time.Duration
+}
+
+// failureMemory is how long failed logins count after the last one
+const failureMemory = 24 * time.Hour
+
+// lockedUntil returns the end of the lockout imposed by throttle, or the
+// zero time if it has not reached threshold
+func (p LockoutPolicy) lockedUntil(throttle *models.LoginThrottle, threshold int) time.Time {
+	if throttle == nil || throttle.Failures < threshold {
+		return time.Time{}
+	}
+	delay := p.Delay
+	for i := threshold; i < throttle.Failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return throttle.LastFailureAt.Add(min(delay, p.MaxDelay))
+}
+
+func emailThrottleKey(email string) string {
+	return "email:" + normalizeEmail(email)
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// resetEmailThrottleKey and resetIPThrottleKey count password reset
+// requests, apart from failed logins
+func resetEmailThrottleKey(email string) string {
+	return "reset-email:" + email
+}
+
+func resetIPThrottleKey(ip string) string {
+	return "reset-ip:" + ip
+}
+
+// checkPassword reports whether password matches the user's hash. It takes
+// the same time when user is nil or has no password.
+func (s *Service) checkPassword(user *models.User, password string) bool {
+	hash := s.dummyHash
+	if user != nil && user.Password != "" {
+		hash = []byte(user.Password)
+	}
+	err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+	return err == nil && user != nil && user.Password != ""
+}
+
+// blockedLogins remembers the lockouts a login_blocked event has been
+// recorded for, so a client retrying against one adds a single event rather
+// than one per attempt. Each instance keeps its own, so a lockout is
+// recorded at most once per instance.
+type blockedLogins struct {
+	mu    sync.Mutex
+	until map[string]time.Time // Throttle key -> end of its recorded lockout
+}
+
+// first reports whether the lockout of key ending at until has not been
+// recorded yet, and remembers it
+func (b *blockedLogins) first(key string, until time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if recorded, ok := b.until[key]; ok && recorded.Equal(until) {
+		return false
+	}
+	if b.until == nil {
+		b.until = make(map[string]time.Time)
+	}
+	// Lockouts that are over need no remembering
+	now := time.Now()
+	for k, end := range b.until {
+		if !end.After(now) {
+			delete(b.until, k)
+		}
+	}
+	b.until[key] = until
+	return true
+}
+
+// attempt is a guess at a password or second factor, counted against the
+// lockout of an email address and client IP before it is checked
+type attempt struct {
+	email, ip string
+	// Counts including this attempt; ipThrottle is nil without an IP
+	emailThrottle, ipThrottle *models.LoginThrottle
+}
+
+// beginAttempt counts a guess against the email address and the client IP
+// before it is checked, so a burst of parallel guesses cannot all get in
+// under the threshold. It refuses the guess while either is locked out.
+// Unknown addresses are locked out like registered ones. Every attempt must
+// end in attemptFailed or releaseAttempt.
+func (s *Service) beginAttempt(ctx context.Context, email, ip string) (*attempt, error) {
+	a := &attempt{email: email, ip: ip}
+	key := emailThrottleKey(email)
+	var err error
+	if a.emailThrottle, err = s.reserveAttempt(ctx, key, s.opts.Lockout.Threshold); err != nil {
+		return nil, s.refuseAttempt(ctx, a, err)
+	}
+	if ip == "" {
+		return a, nil
+	}
+	if a.ipThrottle, err = s.reserveAttempt(ctx, ipThrottleKey(ip), s.opts.Lockout.IPThreshold); err != nil {
+		s.releaseKey(ctx, key)
+		return nil, s.refuseAttempt(ctx, a, err)
+	}
+	return a, nil
+}
+
+// reserveAttempt counts an attempt against key unless it is locked out at
+// threshold, in which case it returns a lockoutError
+func (s *Service) reserveAttempt(ctx context.Context, key string, threshold int) (*models.LoginThrottle, error) {
+	now := time.Now()
+	throttle, reserved, err := s.store.ReserveLoginAttempt(ctx, key, now, now.Add(-failureMemory), func(t *models.LoginThrottle) bool {
+		return s.opts.Lockout.lockedUntil(t, threshold).After(now)
+	})
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	if !reserved {
+		return nil, lockoutError{key: key, until: s.opts.Lockout.lockedUntil(throttle, threshold)}
+	}
+	return throttle, nil
+}
+
+// lockoutError is a refusal by reserveAttempt
+type lockoutError struct {
+	key   string
+	until time.Time
+}
+
+func (e lockoutError) Error() string {
+	return e.key + " is locked out until " + e.until.Format(time.RFC3339)
+}
+
+// refuseAttempt turns the error from reserveAttempt into the one to return,
+// logging the first refusal of each lockout
+func (s *Service) refuseAttempt(ctx context.Context, a *attempt, err error) error {
+	var lockout lockoutError
+	if !errors.As(err, &lockout) {
+		return err
+	}
+	if s.blocked.first(lockout.key, lockout.until) {
+		s.recordSecurityEvent(ctx, models.SecurityLoginBlocked, "", a.email, a.ip)
+	}
+	return apperr.New(apperr.TooManyRequests, "Too many failed login attempts; try again in "+describeWait(time.Until(lockout.until)))
+}
+
+// attemptFailed logs a wrong guess, which beginAttempt has already counted,
+// and notifies the user when it locked their account. user is nil when the
+// address is not registered.
+func (s *Service) attemptFailed(ctx context.Context, a *attempt, user *models.User) {
+	userID := ""
+	if user != nil {
+		userID = user.ID
+	}
+	s.recordSecurityEvent(ctx, models.SecurityLoginFailed, userID, a.email, a.ip)
+
+	// Counting is atomic, so exactly one attempt reaches each threshold
+	if throttle := a.emailThrottle; throttle.Failures == s.opts.Lockout.Threshold {
+		s.recordSecurityEvent(ctx, models.SecurityAccountLocked, userID, a.email, a.ip)
+		if user != nil {
+			// Sent in the background so registered addresses are not slower to answer
+			until := s.opts.Lockout.lockedUntil(throttle, s.opts.Lockout.Threshold)
+			go s.sendLockoutEmail(context.WithoutCancel(ctx), user, throttle.Failures, until)
+		}
+	}
+	if throttle := a.ipThrottle; throttle != nil && throttle.Failures == s.opts.Lockout.IPThreshold {
+		s.recordSecurityEvent(ctx, models.SecurityIPLocked, "", "", a.ip)
+	}
+}
+
+// releaseAttempt takes back the counts of a guess that was right, or that
+// could not be checked
+func (s *Service) releaseAttempt(ctx context.Context, a *attempt) {
+	s.releaseKey(ctx, emailThrottleKey(a.email))
+	if a.ip != "" {
+		s.releaseKey(ctx, ipThrottleKey(a.ip))
+	}
+}
+
+func (s *Service) releaseKey(ctx context.Context, key string) {
+	if err := s.store.ReleaseLoginAttempt(ctx, key); err != nil {
+		log.Printf("Error releasing login attempt for %s: %v", key, err)
+	}
+}
+
+// loginSucceeded forgets the failed attempts for the email address. Those
+// from the client IP still count, or an attacker could reset them by logging
+// in to an account of their own.
+func (s *Service) loginSucceeded(ctx context.Context, email string) {
+	if err := s.store.ClearLoginThrottle(ctx, emailThrottleKey(email)); err != nil {
+		log.Printf("Error clearing failed logins for %s: %v", email, err)
+	}
+}
+
+// recordSecurityEvent appends to the security event log; failures are only logged
+func (s *Service) recordSecurityEvent(ctx context.Context, eventType, userID, email, ip string) {
+	event := &models.SecurityEvent{
+		ID:        ids.New(ids.PrefixSecurity),
+		Type:      eventType,
+		UserID:    userID,
+		Email:     email,
+		IP:        ip,
+		CreatedAt: time.Now(),
+	}
+	log.Printf("Security event %s: user=%q email=%q ip=%q", eventType, userID, email, ip)
+	if err := s.store.RecordSecurityEvent(ctx, event); err != nil {
+		log.Printf("Error recording security event %s: %v", eventType, err)
+	}
+}
+
+func (s *Service) sendLockoutEmail(ctx context.Context, user *models.User, failures int, until time.Time) {
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Sign-in to your BorrowHub account was locked",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"There were %d failed attempts to sign in to your BorrowHub account, so we have paused "+
+			"sign-in for %s. Further failed attempts extend the pause.\n\n"+
+			"If this was you, you can try again later or choose a new password, which also lifts the pause:\n\n%s\n\n"+
+			"If it was not you, someone may be guessing your password. Choosing a new, unique password keeps your account safe.\n",
+			user.Username, failures, describeWait(time.Until(until)), strings.TrimRight(s.opts.AppURL, "/")+"/forgot-password"),
+	}
+	if err := s.opts.Mailer.Send(ctx, msg); err != nil {
+		log.Printf("Error sending lockout email to user %s: %v", user.ID, err)
+	}
+}
+
+// describeWait renders a wait for people, rounding up so clients that retry
+// after it are not refused again
+func describeWait(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%d seconds", int(d.Seconds())+1)
+	}
+	return describeDuration((d + time.Minute - 1).Truncate(time.Minute))
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// securityEvents counts the recorded events of eventType
+func securityEvents(db *store.Database, eventType string) (count int, userIDs []string) {
+	for _, event := range db.SecurityEvents {
+		if event.Type == eventType {
+			count++
+			userIDs = append(userIDs, event.UserID)
+		}
+	}
+	return count, userIDs
+}
+
+func TestLockoutIgnoresEmailCase(t *testing.T) {
+	s, db := newTestService(t)
+	user := createTestUser(t, db, "ana@example.com")
+	ctx := context.Background()
+
+	for _, email := range []string{"Ana@Example.com", " ANA@EXAMPLE.COM", "ana@example.COM"} {
+		if _, err := s.Login(ctx, email, "wrong password", testClient); apperr.KindOf(err) != apperr.Unauthorized {
+			t.Fatalf("wrong password as %q: got %v, want Unauthorized", email, err)
+		}
+	}
+	if _, err := s.Login(ctx, user.Email, testPassword, testClient); apperr.KindOf(err) != apperr.TooManyRequests {
+		t.Errorf("login after %d failures: got %v, want TooManyRequests", s.opts.Lockout.Threshold, err)
+	}
+
+	// The failures were matched to the account, so its owner hears about the lockout
+	if n, userIDs := securityEvents(db, models.SecurityAccountLocked); n != 1 || userIDs[0] != user.ID {
+		t.Errorf("account_locked events for users %v, want one for %s", userIDs, user.ID)
+	}
+	if n, userIDs := securityEvents(db, models.SecurityLoginFailed); n != 3 || userIDs[0] != user.ID {
+		t.Errorf("login_failed events for users %v, want three for %s", userIDs, user.ID)
+	}
+}
+
+func TestLoginIgnoresEmailCase(t *testing.T) {
+	s, _ := newTestService(t)
+	registered := &models.User{Email: " Ana@Example.com", Password: testPassword}
+	if _, err := s.Register(context.Background(), registered, testClient); err != nil {
+		t.Fatal(err)
+	}
+	if registered.Email != "ana@example.com" {
+		t.Errorf("registered as %q, want ana@example.com", registered.Email)
+	}
+	login(t, s, "ANA@example.com")
+	if _, err := s.Register(context.Background(), &models.User{Email: "ana@EXAMPLE.com", Password: testPassword}, testClient); apperr.KindOf(err) != apperr.Conflict {
+		t.Errorf("registering the address again in other case: got %v, want Conflict", err)
+	}
+}
+
+func TestBlockedLoginsRecordedOncePerLockout(t *testing.T) {
+	s, db := newTestService(t)
+	createTestUser(t, db, "ana@example.com")
+	ctx := context.Background()
+
+	for range s.opts.Lockout.Threshold {
+		s.Login(ctx, "ana@example.com", "wrong password", testClient)
+	}
+	for range 20 {
+		if _, err := s.Login(ctx, "ana@example.com", testPassword, testClient); apperr.KindOf(err) != apperr.TooManyRequests {
+			t.Fatalf("login during lockout: got %v, want TooManyRequests", err)
+		}
+	}
+	if n, _ := securityEvents(db, models.SecurityLoginBlocked); n != 1 {
+		t.Errorf("%d login_blocked events for one lockout, want 1", n)
+	}
+
+	// Another address locked out from the same client is a lockout of its own
+	for range s.opts.Lockout.Threshold {
+		s.Login(ctx, "bob@example.com", "wrong password", testClient)
+	}
+	s.Login(ctx, "bob@example.com", "wrong password", testClient)
+	s.Login(ctx, "bob@example.com", "wrong password", testClient)
+	if n, _ := securityEvents(db, models.SecurityLoginBlocked); n != 2 {
+		t.Errorf("%d login_blocked events for two lockouts, want 2", n)
+	}
+}
+
+// latencyStore answers user lookups after a delay, as a database would, so
+// concurrent logins interleave between checking the lockout and counting
+// their failure
+type latencyStore struct {
+	store.Store
+}
+
+func (s latencyStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	time.Sleep(10 * time.Millisecond)
+	return s.Store.GetUserByEmail(ctx, email)
+}
+
+// guessConcurrently runs guess n times at once and counts the outcomes by kind
+func guessConcurrently(n int, guess func(i int) error) map[apperr.Kind]int {
+	var wg sync.WaitGroup
+	kinds := make(chan apperr.Kind, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			kinds <- apperr.KindOf(guess(i))
+		}()
+	}
+	wg.Wait()
+	close(kinds)
+	counts := make(map[apperr.Kind]int)
+	for kind := range kinds {
+		counts[kind]++
+	}
+	return counts
+}
+
+func TestParallelGuessesLimitedByThreshold(t *testing.T) {
+	s, db := newTestService(t)
+	s.store = latencyStore{db}
+	createTestUser(t, db, "ana@example.com")
+	ctx := context.Background()
+
+	counts := guessConcurrently(20, func(i int) error {
+		client := Client{IP: fmt.Sprintf("192.0.2.%d", i)} // Spread over IPs, as a botnet would
+		_, err := s.Login(ctx, "ana@example.com", fmt.Sprintf("guess %d", i), client)
+		return err
+	})
+	if counts[apperr.Unauthorized] != s.opts.Lockout.Threshold || counts[apperr.TooManyRequests] != 20-s.opts.Lockout.Threshold {
+		t.Errorf("parallel guesses: %d checked and %d refused, want %d checked", counts[apperr.Unauthorized], counts[apperr.TooManyRequests], s.opts.Lockout.Threshold)
+	}
+	if n, _ := securityEvents(db, models.SecurityAccountLocked); n != 1 {
+		t.Errorf("%d account_locked events, want 1", n)
+	}
+}
+
+func TestParallelGuessesLimitedByIPThreshold(t *testing.T) {
+	s, db := newTestService(t)
+	s.store = latencyStore{db}
+	ctx := context.Background()
+
+	counts := guessConcurrently(30, func(i int) error {
+		_, err := s.Login(ctx, fmt.Sprintf("user%d@example.com", i), "password", testClient)
+		return err
+	})
+	if counts[apperr.Unauthorized] != s.opts.Lockout.IPThreshold || counts[apperr.TooManyRequests] != 30-s.opts.Lockout.IPThreshold {
+		t.Errorf("parallel guesses from one IP: %d checked and %d refused, want %d checked", counts[apperr.Unauthorized], counts[apperr.TooManyRequests], s.opts.Lockout.IPThreshold)
+	}
+	if n, _ := securityEvents(db, models.SecurityIPLocked); n != 1 {
+		t.Errorf("%d ip_locked events, want 1", n)
+	}
+}
+
+func TestRightPasswordsDoNotCountTowardsLockout(t *testing.T) {
+	s, db := newTestService(t)
+	user := createTestUser(t, db, "ana@example.com")
+	ctx := context.Background()
+
+	// More logins from one IP than its threshold, all of them right
+	for range s.opts.Lockout.IPThreshold + 5 {
+		login(t, s, user.Email)
+	}
+
+	for range s.opts.Lockout.Threshold - 1 {
+		if err := s.ConfirmIdentity(ctx, user, "", "wrong password", "", testClient.IP); apperr.KindOf(err) != apperr.Forbidden {
+			t.Fatalf("wrong password: %v", err)
+		}
+	}
+	// Confirming the right password neither clears nor adds to the failures
+	for range 5 {
+		if err := s.ConfirmIdentity(ctx, user, "", testPassword, "", testClient.IP); err != nil {
+			t.Fatalf("right password after failures: %v", err)
+		}
+	}
+	s.ConfirmIdentity(ctx, user, "", "wrong password", "", testClient.IP)
+	if err := s.ConfirmIdentity(ctx, user, "", testPassword, "", testClient.IP); apperr.KindOf(err) != apperr.TooManyRequests {
+		t.Errorf("right password after %d failures: %v, want TooManyRequests", s.opts.Lockout.Threshold, err)
+	}
+}
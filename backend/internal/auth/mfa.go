@@ -0,0 +1,362 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/ids"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// backupCodeCount is how many recovery codes a user gets at a time
+const backupCodeCount = 10
+
+// backupCodeCost is the bcrypt cost of backup code hashes. Codes have about
+// 50 bits of entropy, so they get a slow hash like passwords.
+var backupCodeCost = bcrypt.DefaultCost
+
+// backupCodeAlphabet leaves out characters that are easy to misread (0/o, 1/l/i)
+const backupCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// TOTPEnrollment is what a user needs to add BorrowHub to an authenticator app
+type TOTPEnrollment struct {
+	Secret string
+	URI    string // otpauth:// URI, usually shown as a QR code
+}
+
+// MFAStatus describes a user's second factor
+type MFAStatus struct {
+	Enabled              bool
+	BackupCodesRemaining int
+}
+
+// EnrollTOTP creates a new, unconfirmed authenticator secret for the user,
+// replacing any earlier unconfirmed one. Logins are unaffected until
+// ConfirmTOTP succeeds.
+func (s *Service) EnrollTOTP(ctx context.Context, userID string) (*TOTPEnrollment, error) {
+	user, err := s.store.GetUser(ctx, userID)
+	if err != nil {
+		return nil, lookupUserError(err)
+	}
+	if cred, err := s.store.GetTOTPCredential(ctx, userID); err == nil && cred.Confirmed {
+		return nil, apperr.New(apperr.Conflict, "Two-factor authentication is already enabled")
+	} else if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error generating secret", err)
+	}
+	cred := &models.TOTPCredential{UserID: userID, Secret: secret, CreatedAt: time.Now()}
+	if err := s.store.SaveTOTPCredential(ctx, cred); err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error enrolling authenticator", err)
+	}
+	return &TOTPEnrollment{Secret: secret, URI: totpURI(secret, user.Email)}, nil
+}
+
+// ConfirmTOTP turns on two-factor authentication once code shows the
+// authenticator app was set up correctly. It returns the user's backup codes,
+// which are shown this once.
+func (s *Service) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	cred, err := s.store.GetTOTPCredential(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, apperr.New(apperr.Invalid, "Start two-factor enrollment first")
+	}
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	if cred.Confirmed {
+		return nil, apperr.New(apperr.Conflict, "Two-factor authentication is already enabled")
+	}
+	if err := s.checkTOTP(ctx, cred, normalizeCode(code)); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.replaceBackupCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// The step check above advanced the stored step; keep it
+	current, err := s.store.GetTOTPCredential(ctx, userID)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error enabling two-factor authentication", err)
+	}
+	now := time.Now()
+	current.Confirmed = true
+	current.ConfirmedAt = &now
+	if err := s.store.SaveTOTPCredential(ctx, current); err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error enabling two-factor authentication", err)
+	}
+	log.Printf("Two-factor authentication enabled for user %s", userID)
+	return codes, nil
+}
+
+// DisableTOTP turns off two-factor authentication. code must be a current
+// authenticator code or an unused backup code; wrong ones count towards the
+// login lockout.
+func (s *Service) DisableTOTP(ctx context.Context, userID, code, ip string) error {
+	user, err := s.store.GetUser(ctx, userID)
+	if err != nil {
+		return lookupUserError(err)
+	}
+	cred, err := s.confirmedCredential(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.confirmSecondFactor(ctx, user, cred, code, ip); err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteTOTPCredential(ctx, userID); err != nil {
+		return apperr.Wrap(apperr.Internal, "Error disabling two-factor authentication", err)
+	}
+	if err := s.store.ReplaceBackupCodes(ctx, userID, nil); err != nil {
+		return apperr.Wrap(apperr.Internal, "Error disabling two-factor authentication", err)
+	}
+	log.Printf("Two-factor authentication disabled for user %s", userID)
+	return nil
+}
+
+// RegenerateBackupCodes replaces every backup code of the user. code must be
+// a current authenticator code; wrong ones count towards the login lockout.
+func (s *Service) RegenerateBackupCodes(ctx context.Context, userID, code, ip string) ([]string, error) {
+	user, err := s.store.GetUser(ctx, userID)
+	if err != nil {
+		return nil, lookupUserError(err)
+	}
+	cred, err := s.confirmedCredential(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.countedCheck(ctx, user, ip, func() error {
+		return s.checkTOTP(ctx, cred, normalizeCode(code))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.replaceBackupCodes(ctx, userID)
+}
+
+// MFAStatus reports whether the user has two-factor authentication enabled
+func (s *Service) MFAStatus(ctx context.Context, userID string) (*MFAStatus, error) {
+	cred, err := s.store.GetTOTPCredential(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !cred.Confirmed) {
+		return &MFAStatus{}, nil
+	}
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+
+	codes, err := s.store.ListBackupCodes(ctx, userID)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	status := &MFAStatus{Enabled: true}
+	for _, c := range codes {
+		if c.UsedAt == nil {
+			status.BackupCodesRemaining++
+		}
+	}
+	return status, nil
+}
+
+// CompleteLogin exchanges an MFA challenge token from Login and a second
+// factor for a session. Wrong codes count towards the account's lockout.
+func (s *Service) CompleteLogin(ctx context.Context, challenge, code string, client Client) (*models.User, *TokenPair, error) {
+	userID, err := s.tokens.ValidateChallenge(challenge)
+	if err != nil {
+		return nil, nil, apperr.Wrap(apperr.Unauthorized, "Invalid or expired login challenge; please log in again", err)
+	}
+	user, err := s.store.GetUser(ctx, userID)
+	if err != nil {
+		return nil, nil, apperr.Wrap(apperr.Unauthorized, "Invalid or expired login challenge; please log in again", err)
+	}
+	cred, err := s.confirmedCredential(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.confirmSecondFactor(ctx, user, cred, code, client.IP); err != nil {
+		return nil, nil, err
+	}
+	s.loginSucceeded(ctx, user.Email)
+
+	tokens, err := s.startSession(ctx, user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// mfaRequired reports whether logins of userID need a second factor
+func (s *Service) mfaRequired(ctx context.Context, userID string) (bool, error) {
+	cred, err := s.store.GetTOTPCredential(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	return cred.Confirmed, nil
+}
+
+func (s *Service) confirmedCredential(ctx context.Context, userID string) (*models.TOTPCredential, error) {
+	cred, err := s.store.GetTOTPCredential(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !cred.Confirmed) {
+		return nil, apperr.New(apperr.Invalid, "Two-factor authentication is not enabled")
+	}
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	return cred, nil
+}
+
+// confirmSecondFactor checks code as user's second factor, counting a wrong
+// one towards the login lockout like a wrong password
+func (s *Service) confirmSecondFactor(ctx context.Context, user *models.User, cred *models.TOTPCredential, code, ip string) error {
+	return s.countedCheck(ctx, user, ip, func() error {
+		return s.checkSecondFactor(ctx, cred, code)
+	})
+}
+
+// countedCheck runs check, which verifies a second factor of user, as a
+// login attempt from ip: it is refused while either is locked out, and an
+// Unauthorized result counts towards their lockouts
+func (s *Service) countedCheck(ctx context.Context, user *models.User, ip string, check func() error) error {
+	attempt, err := s.beginAttempt(ctx, user.Email, ip)
+	if err != nil {
+		return err
+	}
+	if err := check(); err != nil {
+		if apperr.KindOf(err) == apperr.Unauthorized {
+			s.attemptFailed(ctx, attempt, user)
+		} else {
+			s.releaseAttempt(ctx, attempt)
+		}
+		return err
+	}
+	s.releaseAttempt(ctx, attempt)
+	return nil
+}
+
+// checkSecondFactor accepts either a current authenticator code or an unused backup code
+func (s *Service) checkSecondFactor(ctx context.Context, cred *models.TOTPCredential, code string) error {
+	code = normalizeCode(code)
+	if len(code) == totpDigits {
+		return s.checkTOTP(ctx, cred, code)
+	}
+	return s.useBackupCode(ctx, cred.UserID, code)
+}
+
+// checkTOTP accepts each authenticator code once, so an observed code cannot be replayed
+func (s *Service) checkTOTP(ctx context.Context, cred *models.TOTPCredential, code string) error {
+	invalid := apperr.New(apperr.Unauthorized, "Invalid authentication code")
+
+	step, ok := matchTOTP(cred.Secret, code, time.Now())
+	if !ok {
+		return invalid
+	}
+	if err := s.store.AdvanceTOTPStep(ctx, cred.UserID, step); err != nil {
+		if errors.Is(err, store.ErrTokenReused) {
+			return invalid
+		}
+		return apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	return nil
+}
+
+func (s *Service) useBackupCode(ctx context.Context, userID, code string) error {
+	invalid := apperr.New(apperr.Unauthorized, "Invalid authentication code")
+	if code == "" {
+		return invalid
+	}
+
+	codes, err := s.store.ListBackupCodes(ctx, userID)
+	if err != nil {
+		return apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	for _, c := range codes {
+		if c.UsedAt != nil || bcrypt.CompareHashAndPassword([]byte(c.CodeHash), []byte(code)) != nil {
+			continue
+		}
+		if err := s.store.ConsumeBackupCode(ctx, c.ID, time.Now()); err != nil {
+			if errors.Is(err, store.ErrTokenReused) {
+				return invalid
+			}
+			return apperr.Wrap(apperr.Internal, "Internal server error", err)
+		}
+		log.Printf("Backup code used by user %s", userID)
+		return nil
+	}
+	return invalid
+}
+
+// replaceBackupCodes issues a fresh set of backup codes and returns them in plain text
+func (s *Service) replaceBackupCodes(ctx context.Context, userID string) ([]string, error) {
+	plain := make([]string, 0, backupCodeCount)
+	records := make([]*models.BackupCode, 0, backupCodeCount)
+	now := time.Now()
+	for i := 0; i < backupCodeCount; i++ {
+		code, err := newBackupCode()
+		if err != nil {
+			return nil, apperr.Wrap(apperr.Internal, "Error generating backup codes", err)
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(normalizeCode(code)), backupCodeCost)
+		if err != nil {
+			return nil, apperr.Wrap(apperr.Internal, "Error generating backup codes", err)
+		}
+		plain = append(plain, code)
+		records = append(records, &models.BackupCode{
+			ID:        ids.New(ids.PrefixBackup),
+			UserID:    userID,
+			CodeHash:  string(hash),
+			CreatedAt: now,
+		})
+	}
+	if err := s.store.ReplaceBackupCodes(ctx, userID, records); err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error saving backup codes", err)
+	}
+	return plain, nil
+}
+
+// newBackupCode returns a code like "k7m2q-x9fhr"
+func newBackupCode() (string, error) {
+	// Bytes at or above limit are skipped so every character is equally likely
+	limit := byte(256 / len(backupCodeAlphabet) * len(backupCodeAlphabet))
+	var b strings.Builder
+	buf := make([]byte, 1)
+	for n := 0; n < 10; {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		if buf[0] >= limit {
+			continue
+		}
+		if n == 5 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(backupCodeAlphabet[int(buf[0])%len(backupCodeAlphabet)])
+		n++
+	}
+	return b.String(), nil
+}
+
+// normalizeCode drops the spaces and dashes people type into codes
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+func lookupUserError(err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return apperr.New(apperr.NotFound, "User not found")
+	}
+	return apperr.Wrap(apperr.Internal, "Internal server error", err)
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"borrowhub/internal/apperr"
+)
+
+// totpCodeAt returns the code an authenticator app shows for secret at step
+func totpCodeAt(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return totpCode(key, step)
+}
+
+// enableTOTP turns on two-factor authentication for userID with the code of
+// the current step, and returns the secret and the backup codes
+func enableTOTP(t *testing.T, s *Service, userID string) (string, []string, int64) {
+	t.Helper()
+	ctx := context.Background()
+	enrollment, err := s.EnrollTOTP(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := totpStep(time.Now())
+	codes, err := s.ConfirmTOTP(ctx, userID, totpCodeAt(t, enrollment.Secret, step))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enrollment.Secret, codes, step
+}
+
+// completeLogin logs in with testPassword and answers the challenge with code
+func completeLogin(t *testing.T, s *Service, email, code string) error {
+	t.Helper()
+	result, err := s.Login(context.Background(), email, testPassword, testClient)
+	if err != nil {
+		t.Fatalf("Login(%s): %v", email, err)
+	}
+	if result.MFAToken == "" {
+		t.Fatalf("Login(%s) did not ask for a second factor", email)
+	}
+	_, _, err = s.CompleteLogin(context.Background(), result.MFAToken, code, testClient)
+	return err
+}
+
+// noLockout keeps rejected codes from locking the account mid-test
+func noLockout(opts *Options) {
+	opts.Lockout.Threshold = 100
+}
+
+func TestTOTPCodesCannotBeReplayed(t *testing.T) {
+	s, db := newTestService(t, noLockout)
+	user := createTestUser(t, db, "ana@example.com")
+	secret, _, step := enableTOTP(t, s, user.ID)
+
+	// The code that confirmed enrollment has been used
+	if err := completeLogin(t, s, user.Email, totpCodeAt(t, secret, step)); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("code used for enrollment: got %v, want Unauthorized", err)
+	}
+
+	// The next step's code is within the allowed clock skew, but only once
+	next := totpCodeAt(t, secret, step+1)
+	if err := completeLogin(t, s, user.Email, next); err != nil {
+		t.Fatalf("fresh code: %v", err)
+	}
+	if err := completeLogin(t, s, user.Email, next); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("replayed code: got %v, want Unauthorized", err)
+	}
+	// Nor is an earlier, unused code accepted once a later one has been
+	if err := completeLogin(t, s, user.Email, totpCodeAt(t, secret, step-1)); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("code older than the last one used: got %v, want Unauthorized", err)
+	}
+}
+
+func TestBackupCodesAreSingleUse(t *testing.T) {
+	s, db := newTestService(t, noLockout)
+	user := createTestUser(t, db, "ana@example.com")
+	_, codes, _ := enableTOTP(t, s, user.ID)
+	if len(codes) != backupCodeCount {
+		t.Fatalf("got %d backup codes, want %d", len(codes), backupCodeCount)
+	}
+
+	if err := completeLogin(t, s, user.Email, codes[0]); err != nil {
+		t.Fatalf("unused backup code: %v", err)
+	}
+	// Typed differently, it is still the same code
+	for _, replay := range []string{codes[0], strings.ToUpper(codes[0]), strings.ReplaceAll(codes[0], "-", " ")} {
+		if err := completeLogin(t, s, user.Email, replay); apperr.KindOf(err) != apperr.Unauthorized {
+			t.Errorf("used backup code %q: got %v, want Unauthorized", replay, err)
+		}
+	}
+
+	status, err := s.MFAStatus(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.BackupCodesRemaining != backupCodeCount-1 {
+		t.Errorf("%d backup codes remaining, want %d", status.BackupCodesRemaining, backupCodeCount-1)
+	}
+	if err := completeLogin(t, s, user.Email, codes[1]); err != nil {
+		t.Errorf("another unused backup code: %v", err)
+	}
+}
+
+func TestWrongSecondFactorsCountTowardsLockout(t *testing.T) {
+	s, db := newTestService(t)
+	user := createTestUser(t, db, "ana@example.com")
+	enableTOTP(t, s, user.ID)
+
+	for range s.opts.Lockout.Threshold {
+		if err := completeLogin(t, s, user.Email, "000000"); apperr.KindOf(err) != apperr.Unauthorized {
+			t.Fatalf("wrong code: got %v, want Unauthorized", err)
+		}
+	}
+	if _, err := s.Login(context.Background(), user.Email, testPassword, testClient); apperr.KindOf(err) != apperr.TooManyRequests {
+		t.Errorf("login after %d wrong codes: got %v, want TooManyRequests", s.opts.Lockout.Threshold, err)
+	}
+}
+
+func TestWrongCodesForMFASettingsCountTowardsLockout(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(s *Service, userID, code string) error
+	}{
+		{"disable", func(s *Service, userID, code string) error {
+			return s.DisableTOTP(context.Background(), userID, code, testClient.IP)
+		}},
+		{"regenerate backup codes", func(s *Service, userID, code string) error {
+			_, err := s.RegenerateBackupCodes(context.Background(), userID, code, testClient.IP)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, db := newTestService(t)
+			user := createTestUser(t, db, "ana@example.com")
+			secret, _, step := enableTOTP(t, s, user.ID)
+
+			for range s.opts.Lockout.Threshold {
+				if err := tt.change(s, user.ID, "000000"); apperr.KindOf(err) != apperr.Unauthorized {
+					t.Fatalf("wrong code: got %v, want Unauthorized", err)
+				}
+			}
+			if err := tt.change(s, user.ID, totpCodeAt(t, secret, step+1)); apperr.KindOf(err) != apperr.TooManyRequests {
+				t.Errorf("right code after %d wrong ones: got %v, want TooManyRequests", s.opts.Lockout.Threshold, err)
+			}
+			if _, err := s.Login(context.Background(), user.Email, testPassword, testClient); apperr.KindOf(err) != apperr.TooManyRequests {
+				t.Errorf("login after %d wrong codes: got %v, want TooManyRequests", s.opts.Lockout.Threshold, err)
+			}
+			if status, err := s.MFAStatus(context.Background(), user.ID); err != nil || !status.Enabled || status.BackupCodesRemaining != backupCodeCount {
+				t.Errorf("MFA status after refused changes: %+v, %v, want unchanged", status, err)
+			}
+		})
+	}
+}
+
+func TestMFASettingsAcceptRightCodes(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	user := createTestUser(t, db, "ana@example.com")
+	secret, codes, step := enableTOTP(t, s, user.ID)
+
+	// Backup codes cannot replace themselves
+	if _, err := s.RegenerateBackupCodes(ctx, user.ID, codes[0], testClient.IP); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("regenerating with a backup code: got %v, want Unauthorized", err)
+	}
+	fresh, err := s.RegenerateBackupCodes(ctx, user.ID, totpCodeAt(t, secret, step+1), testClient.IP)
+	if err != nil || len(fresh) != backupCodeCount {
+		t.Fatalf("regenerating with an authenticator code: %d codes, %v", len(fresh), err)
+	}
+	if err := s.DisableTOTP(ctx, user.ID, codes[1], testClient.IP); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("disabling with a replaced backup code: got %v, want Unauthorized", err)
+	}
+	if err := s.DisableTOTP(ctx, user.ID, fresh[0], testClient.IP); err != nil {
+		t.Fatalf("disabling with a new backup code: %v", err)
+	}
+	if status, err := s.MFAStatus(ctx, user.ID); err != nil || status.Enabled {
+		t.Errorf("MFA status after disabling: %+v, %v", status, err)
+	}
+
+	// Only the two wrong codes count towards the lockout
+	if throttle, err := db.GetLoginThrottle(ctx, emailThrottleKey(user.Email)); err != nil || throttle.Failures != 2 {
+		t.Errorf("login throttle after two wrong codes and two right ones: %+v, %v, want 2 failures", throttle, err)
+	}
+}
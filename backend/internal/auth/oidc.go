@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/ids"
+	"borrowhub/internal/models"
+	"borrowhub/internal/oidc"
+	"borrowhub/internal/store"
+)
+
+// oidcStateTTL bounds the round trip through the provider's sign-in page
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCProvider returns the display name of the configured provider, if any
+func (s *Service) OIDCProvider() (name string, ok bool) {
+	if s.opts.OIDC == nil {
+		return "", false
+	}
+	return s.opts.OIDC.Name(), true
+}
+
+// StartOIDCLogin returns the provider URL to send the user to and the state
+// the frontend must see again on the callback. The state is stored and works
+// once; the PKCE verifier and nonce are derived from it with a server key, so
+// neither ever reaches the browser.
+func (s *Service) StartOIDCLogin(ctx context.Context) (authURL, state string, err error) {
+	if s.opts.OIDC == nil {
+		return "", "", apperr.New(apperr.NotFound, "Single sign-on is not configured")
+	}
+
+	state, err = s.issueOneTimeToken(ctx, "", models.TokenPurposeOIDCState, oidcStateTTL)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, nonce := s.oidcSecrets(state)
+	authURL, err = s.opts.OIDC.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		return "", "", apperr.Wrap(apperr.Internal, "Single sign-on is unavailable", err)
+	}
+	return authURL, state, nil
+}
+
+// CompleteOIDCLogin redeems the authorization code the provider returned and
+// signs in the user the ID token identifies, linking or creating the account
+// on first use
+func (s *Service) CompleteOIDCLogin(ctx context.Context, code, state string, client Client) (*LoginResult, error) {
+	if s.opts.OIDC == nil {
+		return nil, apperr.New(apperr.NotFound, "Single sign-on is not configured")
+	}
+	if code == "" {
+		return nil, apperr.New(apperr.Invalid, "Authorization code is required")
+	}
+	if _, err := s.redeemOneTimeToken(ctx, state, models.TokenPurposeOIDCState); err != nil {
+		return nil, apperr.Wrap(apperr.Unauthorized, "Sign-in expired or was already used; please try again", err)
+	}
+
+	verifier, nonce := s.oidcSecrets(state)
+	rawIDToken, err := s.opts.OIDC.Exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Unauthorized, "Sign-in with "+s.opts.OIDC.Name()+" failed", err)
+	}
+	idToken, err := s.opts.OIDC.Verify(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Unauthorized, "Sign-in with "+s.opts.OIDC.Name()+" failed", err)
+	}
+
+	user, err := s.userForIdentity(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.finishLogin(ctx, user, client)
+}
+
+func (s *Service) oidcSecrets(state string) (verifier, nonce string) {
+	return s.tokens.derive("oidc-pkce-verifier", state), s.tokens.derive("oidc-nonce", state)
+}
+
+// userForIdentity finds the user linked to the provider account. An
+// unlinked account is linked to the user with the same email address, or
+// to a new user, but only when the provider has verified that address. A
+// user who had not verified the address is reclaimed first.
+func (s *Service) userForIdentity(ctx context.Context, idToken *oidc.IDToken) (*models.User, error) {
+	provider := s.opts.OIDC.Issuer()
+
+	identity, err := s.store.GetIdentity(ctx, provider, idToken.Subject)
+	if err == nil {
+		user, err := s.store.GetUser(ctx, identity.UserID)
+		if err != nil {
+			return nil, apperr.Wrap(apperr.Internal, "Error loading linked account", err)
+		}
+		return user, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+
+	if idToken.Email == "" || !idToken.EmailVerified {
+		return nil, apperr.New(apperr.Forbidden, "Your "+s.opts.OIDC.Name()+" account has no verified email address")
+	}
+
+	user, err := s.userForVerifiedEmail(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.CreateIdentity(ctx, &models.Identity{
+		ID:        ids.New(ids.PrefixIdentity),
+		UserID:    user.ID,
+		Provider:  provider,
+		Subject:   idToken.Subject,
+		Email:     idToken.Email,
+		CreatedAt: time.Now(),
+	})
+	if errors.Is(err, store.ErrAlreadyExists) {
+		// A concurrent sign-in linked it first
+		return s.userForIdentity(ctx, idToken)
+	}
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error linking account", err)
+	}
+	log.Printf("Linked %s identity %s to user %s", provider, idToken.Subject, user.ID)
+	return user, nil
+}
+
+// userForVerifiedEmail returns the user registered with the provider-verified
+// email address, creating one without a password if there is none
+func (s *Service) userForVerifiedEmail(ctx context.Context, idToken *oidc.IDToken) (*models.User, error) {
+	email := normalizeEmail(idToken.Email)
+	user, err := s.store.GetUserByEmail(ctx, email)
+	if err == nil {
+		if !user.Verified {
+			if err := s.reclaimAccount(ctx, user); err != nil {
+				return nil, err
+			}
+		}
+		return user, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+
+	username := idToken.Username
+	if username == "" {
+		username = strings.Split(email, "@")[0]
+	}
+	user = &models.User{
+		ID:        ids.New(ids.PrefixUser),
+		Username:  username,
+		Email:     email,
+		FirstName: idToken.GivenName,
+		LastName:  idToken.FamilyName,
+		Verified:  true,
+		Role:      models.RoleUser,
+		CreatedAt: time.Now(),
+		// No password: the account signs in through the provider until the user sets one with a reset
+	}
+	if err := s.store.CreateUser(ctx, user); err != nil {
+		if errors.Is(err, store.ErrAlreadyExists) {
+			return s.userForVerifiedEmail(ctx, idToken)
+		}
+		return nil, apperr.Wrap(apperr.Internal, "Error creating user", err)
+	}
+	log.Printf("Created user %s from %s sign-in", user.ID, s.opts.OIDC.Issuer())
+	return user, nil
+}
+
+// reclaimAccount hands an unverified account to the user the provider
+// vouched for. Whoever registered it never proved they own the address and
+// may be someone else squatting on it, so everything that let them in goes:
+// the password, two-factor credentials, sessions and API keys.
+func (s *Service) reclaimAccount(ctx context.Context, user *models.User) error {
+	failed := func(err error) error {
+		return apperr.Wrap(apperr.Internal, "Error linking account", err)
+	}
+	now := time.Now()
+
+	user.Verified = true
+	user.Password = "" // Set again with a password reset, which goes to the verified address
+	if err := s.store.UpdateUser(ctx, user); err != nil {
+		return failed(err)
+	}
+	if err := s.store.DeleteTOTPCredential(ctx, user.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return failed(err)
+	}
+	if err := s.store.ReplaceBackupCodes(ctx, user.ID, nil); err != nil {
+		return failed(err)
+	}
+	sessions, err := s.store.RevokeSessions(ctx, store.SessionFilter{UserID: user.ID}, now)
+	if err != nil {
+		return failed(err)
+	}
+	keys, err := s.store.ListAPIKeys(ctx, user.ID)
+	if err != nil {
+		return failed(err)
+	}
+	for _, key := range keys {
+		if key.RevokedAt == nil {
+			if err := s.store.RevokeAPIKey(ctx, key.ID, now); err != nil {
+				return failed(err)
+			}
+		}
+	}
+
+	log.Printf("Reclaimed unverified user %s through %s sign-in, revoked %d sessions", user.ID, s.opts.OIDC.Issuer(), sessions)
+	s.recordSecurityEvent(ctx, models.SecurityAccountReclaimed, user.ID, user.Email, "")
+	return nil
+}
@@ -0,0 +1,350 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/config"
+	"borrowhub/internal/models"
+	"borrowhub/internal/oidc"
+	"borrowhub/internal/store"
+)
+
+const stubClientID = "borrowhub-test"
+
+// stubProvider is an OpenID Connect provider serving discovery, a token
+// endpoint that checks PKCE, and the key set its ID tokens are signed with.
+// Tests stand in for the user at the sign-in page by calling authorize.
+type stubProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]stubGrant // Authorization code -> what it was issued for
+	codes  int
+}
+
+// stubGrant is an authorization code's pending sign-in
+type stubGrant struct {
+	challenge string // PKCE code_challenge from the authorization request
+	nonce     string // Echoed in the ID token
+	claims    jwt.MapClaims
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &stubProvider{key: key, grants: make(map[string]stubGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kid": "stub",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", p.token)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// token redeems an authorization code once, provided the code verifier
+// matches the challenge it was issued for
+func (p *stubProvider) token(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	grant, ok := p.grants[r.PostFormValue("code")]
+	delete(p.grants, r.PostFormValue("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	switch {
+	case !ok:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "unknown code"})
+		return
+	case base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	case r.PostFormValue("client_id") != stubClientID:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   p.URL,
+		"aud":   stubClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": grant.nonce,
+	}
+	for k, v := range grant.claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "stub"
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": "unused", "token_type": "Bearer", "id_token": signed})
+}
+
+// authorize plays the user signing in at authURL and returns the
+// authorization code the provider redirects back with. claims go into the
+// ID token; a "nonce" among them replaces the one requested.
+func (p *stubProvider) authorize(t *testing.T, authURL string, claims jwt.MapClaims) string {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("client_id") != stubClientID || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || q.Get("state") == "" {
+		t.Fatalf("bad authorization request %s", authURL)
+	}
+	grant := stubGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), claims: claims}
+	if nonce, ok := claims["nonce"].(string); ok {
+		grant.nonce = nonce
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.codes++
+	code := "code-" + big.NewInt(int64(p.codes)).String()
+	p.grants[code] = grant
+	return code
+}
+
+// newOIDCTestService returns a Service that signs in through a stub provider
+func newOIDCTestService(t *testing.T) (*Service, *store.Database, *stubProvider) {
+	t.Helper()
+	provider := newStubProvider(t)
+	s, db := newTestService(t, noLockout, func(opts *Options) {
+		opts.OIDC = oidc.New(config.OIDCConfig{
+			Name:     "Stub",
+			Issuer:   provider.URL,
+			ClientID: stubClientID,
+			Scopes:   []string{"openid", "email", "profile"},
+		}, "http://localhost:5173/auth/callback")
+	})
+	return s, db, provider
+}
+
+// startOIDC begins a sign-in and returns the provider URL and state
+func startOIDC(t *testing.T, s *Service) (string, string) {
+	t.Helper()
+	authURL, state, err := s.StartOIDCLogin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authURL, state
+}
+
+// signInWithOIDC runs a whole sign-in as the provider account claims describes
+func signInWithOIDC(t *testing.T, s *Service, provider *stubProvider, claims jwt.MapClaims) (*LoginResult, error) {
+	t.Helper()
+	authURL, state := startOIDC(t, s)
+	code := provider.authorize(t, authURL, claims)
+	return s.CompleteOIDCLogin(context.Background(), code, state, testClient)
+}
+
+func verifiedClaims(subject, email string) jwt.MapClaims {
+	return jwt.MapClaims{"sub": subject, "email": email, "email_verified": true, "given_name": "Ana"}
+}
+
+func TestOIDCCreatesAndSignsInUser(t *testing.T) {
+	s, db, provider := newOIDCTestService(t)
+	ctx := context.Background()
+
+	first, err := signInWithOIDC(t, s, provider, verifiedClaims("sub-1", "ana@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Tokens == nil {
+		t.Fatal("no session started")
+	}
+	user, err := db.GetUserByEmail(ctx, "ana@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.Verified || user.Password != "" || user.FirstName != "Ana" {
+		t.Errorf("created user %+v, want verified, without a password, named Ana", user)
+	}
+
+	// The identity is linked now, so a changed email still finds the user
+	second, err := signInWithOIDC(t, s, provider, verifiedClaims("sub-1", "ana@other.example"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.User.ID != user.ID {
+		t.Errorf("second sign-in as user %s, want %s", second.User.ID, user.ID)
+	}
+}
+
+func TestOIDCStateIsSingleUse(t *testing.T) {
+	s, _, provider := newOIDCTestService(t)
+	ctx := context.Background()
+	claims := verifiedClaims("sub-1", "ana@example.com")
+
+	authURL, state := startOIDC(t, s)
+	if _, err := s.CompleteOIDCLogin(ctx, provider.authorize(t, authURL, claims), state, testClient); err != nil {
+		t.Fatal(err)
+	}
+	// A fresh code for the same authorization request does not revive the state
+	_, err := s.CompleteOIDCLogin(ctx, provider.authorize(t, authURL, claims), state, testClient)
+	if apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("reused state: got %v, want Unauthorized", err)
+	}
+	if _, err := s.CompleteOIDCLogin(ctx, provider.authorize(t, authURL, claims), "not-a-state", testClient); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("unknown state: got %v, want Unauthorized", err)
+	}
+}
+
+// TestOIDCCodeBoundToItsLogin checks that PKCE stops a code issued for one
+// sign-in from completing another, as when an attacker injects their own
+// code into a victim's callback
+func TestOIDCCodeBoundToItsLogin(t *testing.T) {
+	s, _, provider := newOIDCTestService(t)
+
+	attackerURL, _ := startOIDC(t, s)
+	code := provider.authorize(t, attackerURL, verifiedClaims("sub-attacker", "mallory@example.com"))
+
+	_, victimState := startOIDC(t, s)
+	_, err := s.CompleteOIDCLogin(context.Background(), code, victimState, testClient)
+	if apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("code from another sign-in: got %v, want Unauthorized", err)
+	}
+}
+
+func TestOIDCNonceMustMatch(t *testing.T) {
+	s, _, provider := newOIDCTestService(t)
+
+	// An ID token minted for an earlier sign-in carries that sign-in's nonce
+	earlierURL, _ := startOIDC(t, s)
+	earlierNonce, _ := url.Parse(earlierURL)
+	claims := verifiedClaims("sub-1", "ana@example.com")
+	claims["nonce"] = earlierNonce.Query().Get("nonce")
+
+	_, err := signInWithOIDC(t, s, provider, claims)
+	if apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("ID token with another sign-in's nonce: got %v, want Unauthorized", err)
+	}
+}
+
+func TestOIDCRequiresVerifiedEmail(t *testing.T) {
+	s, db, provider := newOIDCTestService(t)
+	claims := verifiedClaims("sub-1", "ana@example.com")
+	claims["email_verified"] = false
+
+	if _, err := signInWithOIDC(t, s, provider, claims); apperr.KindOf(err) != apperr.Forbidden {
+		t.Errorf("unverified provider email: got %v, want Forbidden", err)
+	}
+	if _, err := db.GetUserByEmail(context.Background(), "ana@example.com"); err == nil {
+		t.Error("a user was created for an unverified email")
+	}
+}
+
+func TestOIDCLinksVerifiedAccount(t *testing.T) {
+	s, db, provider := newOIDCTestService(t)
+	owner := createTestUser(t, db, "ana@example.com")
+	session := login(t, s, owner.Email)
+
+	result, err := signInWithOIDC(t, s, provider, verifiedClaims("sub-1", owner.Email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.User.ID != owner.ID {
+		t.Fatalf("signed in as %s, want the existing user %s", result.User.ID, owner.ID)
+	}
+	// The owner proved the address before, so nothing of theirs is touched
+	if _, err := s.Authenticate(context.Background(), session.AccessToken); err != nil {
+		t.Errorf("existing session after linking: %v", err)
+	}
+	login(t, s, owner.Email)
+}
+
+// TestOIDCReclaimsUnverifiedAccount covers someone registering an address
+// they do not own, then waiting for its owner to sign in through the provider
+func TestOIDCReclaimsUnverifiedAccount(t *testing.T) {
+	s, db, provider := newOIDCTestService(t)
+	ctx := context.Background()
+	squatter := createTestUser(t, db, "ana@example.com")
+	squatter.Verified = false
+	if err := db.UpdateUser(ctx, squatter); err != nil {
+		t.Fatal(err)
+	}
+	session := login(t, s, squatter.Email)
+	key, err := s.CreateAPIKey(ctx, squatter.ID, "backdoor", []string{string(ScopeProfileRead)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enableTOTP(t, s, squatter.ID)
+
+	result, err := signInWithOIDC(t, s, provider, verifiedClaims("sub-1", squatter.Email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.User.ID != squatter.ID || result.Tokens == nil {
+		t.Fatalf("owner was not signed in to the account without the squatter's second factor: %+v", result)
+	}
+
+	if _, err := s.Login(ctx, squatter.Email, testPassword, testClient); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("squatter's password: got %v, want Unauthorized", err)
+	}
+	if _, err := s.Authenticate(ctx, session.AccessToken); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("squatter's session: got %v, want Unauthorized", err)
+	}
+	if _, err := s.Refresh(ctx, session.RefreshToken, testClient); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("squatter's refresh token: got %v, want Unauthorized", err)
+	}
+	if _, err := s.Authenticate(ctx, key.Secret); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("squatter's API key: got %v, want Unauthorized", err)
+	}
+	if status, err := s.MFAStatus(ctx, squatter.ID); err != nil || status.Enabled {
+		t.Errorf("squatter's authenticator is still enrolled (%v)", err)
+	}
+
+	reclaimed := false
+	for _, event := range db.SecurityEvents {
+		reclaimed = reclaimed || (event.Type == models.SecurityAccountReclaimed && event.UserID == squatter.ID)
+	}
+	if !reclaimed {
+		t.Error("no account_reclaimed security event was recorded")
+	}
+}
@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/mail"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// resetRequestsPerIP is how many password resets one client IP may ask for
+// per VerificationCooldown
+const resetRequestsPerIP = 10
+
+// ForgotPassword mails a password reset link if email belongs to a user.
+// It reports success either way so the endpoint cannot be used to probe
+// which addresses are registered: requests are throttled per address and per
+// client ip whether or not the address is known, and the email is sent in
+// the background so known addresses take no longer to answer. Delivery
+// failures are only logged.
+func (s *Service) ForgotPassword(ctx context.Context, email, ip string) error {
+	email = normalizeEmail(email)
+	if email == "" {
+		return apperr.New(apperr.Invalid, "Email is required")
+	}
+	// The client first, so one flooding an address cannot also hold off its owner
+	if ip != "" {
+		if err := s.throttleReset(ctx, resetIPThrottleKey(ip), resetRequestsPerIP); err != nil {
+			return err
+		}
+	}
+	if err := s.throttleReset(ctx, resetEmailThrottleKey(email), 1); err != nil {
+		return err
+	}
+
+	user, err := s.store.GetUserByEmail(ctx, email)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	go s.sendPasswordResetEmail(context.WithoutCancel(ctx), user)
+	return nil
+}
+
+// throttleReset counts a password reset request against key, refusing it
+// once limit requests were counted within VerificationCooldown
+func (s *Service) throttleReset(ctx context.Context, key string, limit int) error {
+	cooldown := s.opts.VerificationCooldown
+	if cooldown <= 0 {
+		return nil
+	}
+	now := time.Now()
+	throttle, ok, err := s.store.ReserveLoginAttempt(ctx, key, now, now.Add(-cooldown), func(previous *models.LoginThrottle) bool {
+		return previous != nil && previous.Failures >= limit
+	})
+	if err != nil {
+		return apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	if !ok {
+		return apperr.New(apperr.TooManyRequests, "A password reset was requested recently; try again in "+
+			describeWait(throttle.LastFailureAt.Add(cooldown).Sub(now)))
+	}
+	return nil
+}
+
+// sendPasswordResetEmail issues a reset token for user and mails it. Errors
+// are only logged, as the request was answered already.
+func (s *Service) sendPasswordResetEmail(ctx context.Context, user *models.User) {
+	token, err := s.issueOneTimeToken(ctx, user.ID, models.TokenPurposePasswordReset, s.opts.PasswordResetTTL)
+	if err != nil {
+		log.Printf("Error issuing password reset token for user %s: %v", user.ID, err)
+		return
+	}
+
+	link := s.appLink("/reset-password", token)
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Reset your BorrowHub password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to reset the password for your BorrowHub account. "+
+			"To choose a new password, open this link within %s:\n\n%s\n\n"+
+			"If you did not ask for this, you can ignore this email; your password will not change.\n",
+			user.Username, describeDuration(s.opts.PasswordResetTTL), link),
+	}
+	if err := s.opts.Mailer.Send(ctx, msg); err != nil {
+		log.Printf("Error sending password reset email to user %s: %v", user.ID, err)
+	}
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword. The
+// token works once, and every existing session of the user is revoked.
+func (s *Service) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if newPassword == "" {
+		return apperr.New(apperr.Invalid, "New password is required")
+	}
+	// Checked before the token is spent, so the user can try another password
+	if err := s.opts.PasswordPolicy.check(newPassword); err != nil {
+		return err
+	}
+
+	record, err := s.redeemOneTimeToken(ctx, token, models.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.store.GetUser(ctx, record.UserID)
+	if err != nil {
+		return apperr.Wrap(apperr.Unauthorized, "Invalid or expired token", err)
+	}
+
+	hashedPassword, err := s.hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	user.Verified = true // The reset link proved they own the address
+	if err := s.store.UpdateUser(ctx, user); err != nil {
+		return apperr.Wrap(apperr.Internal, "Error updating password", err)
+	}
+
+	n, err := s.store.RevokeSessions(ctx, store.SessionFilter{UserID: user.ID}, time.Now())
+	if err != nil {
+		return apperr.Wrap(apperr.Internal, "Error revoking sessions", err)
+	}
+	log.Printf("Password reset for user %s, revoked %d sessions", user.ID, n)
+
+	// The reset proved who they are, so lift any lockout
+	s.loginSucceeded(ctx, user.Email)
+	return nil
+}
+
+// ChangePassword replaces the password of userID, who must know the current
+// one. Wrong guesses count towards the login lockout. Every other session of
+// the user is revoked; sessionID, the caller's own, stays signed in.
+func (s *Service) ChangePassword(ctx context.Context, userID, sessionID, currentPassword, newPassword, ip string) error {
+	if currentPassword == "" || newPassword == "" {
+		return apperr.New(apperr.Invalid, "Current and new password are required")
+	}
+
+	user, err := s.store.GetUser(ctx, userID)
+	if err != nil {
+		return lookupUserError(err)
+	}
+	if user.Password == "" {
+		return apperr.New(apperr.Invalid, "Your account has no password yet; set one with a password reset")
+	}
+	if err := s.confirmPassword(ctx, user, currentPassword, ip); err != nil {
+		return err
+	}
+	if newPassword == currentPassword {
+		return apperr.New(apperr.Invalid, "New password must be different from the current one")
+	}
+	if err := s.opts.PasswordPolicy.check(newPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	if err := s.store.UpdateUser(ctx, user); err != nil {
+		return apperr.Wrap(apperr.Internal, "Error updating password", err)
+	}
+
+	n, err := s.store.RevokeSessions(ctx, store.SessionFilter{UserID: user.ID, ExceptID: sessionID}, time.Now())
+	if err != nil {
+		return apperr.Wrap(apperr.Internal, "Error revoking sessions", err)
+	}
+	log.Printf("Password changed for user %s, revoked %d other sessions", user.ID, n)
+	s.recordSecurityEvent(ctx, models.SecurityPasswordChanged, user.ID, user.Email, ip)
+
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Your BorrowHub password was changed",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"The password of your BorrowHub account was just changed, and you were signed out everywhere else.\n\n"+
+			"If this was not you, choose a new password right away:\n\n%s\n",
+			user.Username, strings.TrimRight(s.opts.AppURL, "/")+"/forgot-password"),
+	}
+	if err := s.opts.Mailer.Send(ctx, msg); err != nil {
+		log.Printf("Error sending password change email to user %s: %v", user.ID, err)
+	}
+	return nil
+}
+
+// reauthWindow is how recently users with neither a password nor a second
+// factor must have signed in to confirm a sensitive action
+const reauthWindow = 10 * time.Minute
+
+// ConfirmIdentity checks that the caller really is user before a sensitive
+// action. Users with a password confirm with it. Users who sign in through
+// single sign-on only confirm with a second-factor code if they have one;
+// otherwise sessionID, the caller's session, must have signed in within
+// reauthWindow. Wrong passwords and codes count towards the login lockout.
+func (s *Service) ConfirmIdentity(ctx context.Context, user *models.User, sessionID, password, code, ip string) error {
+	if user.Password != "" {
+		return s.confirmPassword(ctx, user, password, ip)
+	}
+
+	cred, err := s.store.GetTOTPCredential(ctx, user.ID)
+	if err == nil && cred.Confirmed {
+		return s.confirmSecondFactor(ctx, user, cred, code, ip)
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+
+	session, err := s.store.GetSession(ctx, sessionID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	if err != nil || session.UserID != user.ID || !session.Active() || time.Since(session.CreatedAt) > reauthWindow {
+		return apperr.New(apperr.Forbidden, "Sign in again with single sign-on to confirm, then retry within "+describeDuration(reauthWindow))
+	}
+	return nil
+}
+
+// confirmPassword checks password against user's. Wrong guesses count
+// towards the login lockout.
+func (s *Service) confirmPassword(ctx context.Context, user *models.User, password, ip string) error {
+	attempt, err := s.beginAttempt(ctx, user.Email, ip)
+	if err != nil {
+		return err
+	}
+	if !s.checkPassword(user, password) {
+		s.attemptFailed(ctx, attempt, user)
+		return apperr.New(apperr.Forbidden, "Current password is incorrect")
+	}
+	s.releaseAttempt(ctx, attempt)
+	return nil
+}
+
+// ForgetLoginFailures drops the failed login count kept for email, for
+// instance once the address no longer belongs to anyone
+func (s *Service) ForgetLoginFailures(ctx context.Context, email string) {
+	s.loginSucceeded(ctx, email)
+}
+
+// hashPassword hashes password at the configured cost
+func (s *Service) hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.opts.BcryptCost)
+	if err != nil {
+		return "", apperr.Wrap(apperr.Internal, "Error processing password", err)
+	}
+	return string(hash), nil
+}
+
+// upgradePasswordHash re-hashes the password of user, just checked to be
+// password, if its hash was made at a lower cost than the configured one.
+// Failures are only logged, as the old hash still works.
+func (s *Service) upgradePasswordHash(ctx context.Context, user *models.User, password string) {
+	cost, err := bcrypt.Cost([]byte(user.Password))
+	if err != nil || cost >= s.opts.BcryptCost {
+		return
+	}
+	hashedPassword, err := s.hashPassword(password)
+	if err != nil {
+		log.Printf("Error upgrading password hash of user %s: %v", user.ID, err)
+		return
+	}
+	user.Password = hashedPassword
+	if err := s.store.UpdateUser(ctx, user); err != nil {
+		log.Printf("Error upgrading password hash of user %s: %v", user.ID, err)
+		return
+	}
+	log.Printf("Upgraded password hash of user %s from cost %d to %d", user.ID, cost, s.opts.BcryptCost)
+}
@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/mail"
+)
+
+// outbox is a Mailer that hands each message to the test
+type outbox chan mail.Message
+
+func (o outbox) Send(ctx context.Context, msg mail.Message) error {
+	o <- msg
+	return nil
+}
+
+// withOutbox makes the service mail into a new outbox, returned through box
+func withOutbox(box *outbox) func(*Options) {
+	return func(opts *Options) {
+		*box = make(outbox, 10)
+		opts.Mailer = *box
+	}
+}
+
+// resetToken waits for the password reset email and returns its token
+func resetToken(t *testing.T, box outbox) string {
+	t.Helper()
+	return mailedToken(t, box, "/reset-password")
+}
+
+// mailedToken waits for an email linking to path and returns the link's token
+func mailedToken(t *testing.T, box outbox, path string) string {
+	t.Helper()
+	select {
+	case msg := <-box:
+		_, link, ok := strings.Cut(msg.Body, path+"?token=")
+		if !ok {
+			t.Fatalf("no %s link in %q", path, msg.Body)
+		}
+		token, err := url.QueryUnescape(strings.Fields(link)[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	case <-time.After(time.Second):
+		t.Fatalf("no email linking to %s was sent", path)
+		return ""
+	}
+}
+
+func TestResetTokensWorkOnce(t *testing.T) {
+	var box outbox
+	s, db := newTestService(t, withOutbox(&box))
+	ctx := context.Background()
+	user := createTestUser(t, db, "ana@example.com")
+	session := login(t, s, user.Email)
+
+	if err := s.ForgotPassword(ctx, " Ana@Example.com ", testClient.IP); err != nil {
+		t.Fatal(err)
+	}
+	token := resetToken(t, box)
+
+	// A password the policy refuses does not spend the token
+	if err := s.ResetPassword(ctx, token, "short"); apperr.KindOf(err) != apperr.Invalid {
+		t.Fatalf("weak password: %v, want Invalid", err)
+	}
+	if err := s.ResetPassword(ctx, token, "a new password"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if err := s.ResetPassword(ctx, token, "another new password"); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("reusing the token: %v, want Unauthorized", err)
+	}
+	if _, err := s.Login(ctx, user.Email, "a new password", testClient); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+	if _, err := s.Refresh(ctx, session.RefreshToken, testClient); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("refreshing a session from before the reset: %v, want Unauthorized", err)
+	}
+}
+
+func TestResetTokensExpire(t *testing.T) {
+	var box outbox
+	s, db := newTestService(t, withOutbox(&box), func(opts *Options) {
+		opts.PasswordResetTTL = time.Millisecond
+	})
+	ctx := context.Background()
+	user := createTestUser(t, db, "ana@example.com")
+
+	if err := s.ForgotPassword(ctx, user.Email, testClient.IP); err != nil {
+		t.Fatal(err)
+	}
+	token := resetToken(t, box)
+	time.Sleep(5 * time.Millisecond)
+	if err := s.ResetPassword(ctx, token, "a new password"); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("expired token: %v, want Unauthorized", err)
+	}
+	if _, err := s.Login(ctx, user.Email, testPassword, testClient); err != nil {
+		t.Errorf("login with the old password after a failed reset: %v", err)
+	}
+}
+
+func TestForgotPasswordThrottled(t *testing.T) {
+	var box outbox
+	s, db := newTestService(t, withOutbox(&box), func(opts *Options) {
+		opts.VerificationCooldown = time.Minute
+	})
+	ctx := context.Background()
+	user := createTestUser(t, db, "ana@example.com")
+
+	// Known and unknown addresses answer alike, including when throttled
+	for _, email := range []string{user.Email, "nobody@example.com"} {
+		if err := s.ForgotPassword(ctx, email, "192.0.2.1"); err != nil {
+			t.Fatalf("first request for %s: %v", email, err)
+		}
+		if err := s.ForgotPassword(ctx, strings.ToUpper(email), "192.0.2.2"); apperr.KindOf(err) != apperr.TooManyRequests {
+			t.Errorf("second request for %s: %v, want TooManyRequests", email, err)
+		}
+	}
+	resetToken(t, box)
+	select {
+	case msg := <-box:
+		t.Errorf("unexpected email to %s", msg.To)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// One client can only ask for so many addresses
+	for i := range resetRequestsPerIP {
+		if err := s.ForgotPassword(ctx, string(rune('a'+i))+"@example.org", "198.51.100.1"); err != nil {
+			t.Fatalf("request %d from one IP: %v", i+1, err)
+		}
+	}
+	if err := s.ForgotPassword(ctx, "z@example.org", "198.51.100.1"); apperr.KindOf(err) != apperr.TooManyRequests {
+		t.Errorf("request %d from one IP: %v, want TooManyRequests", resetRequestsPerIP+1, err)
+	}
+	// A client refused before reaching the address does not use up its request
+	if err := s.ForgotPassword(ctx, "z@example.org", "198.51.100.2"); err != nil {
+		t.Errorf("request for the same address from another IP: %v", err)
+	}
+}
+
+func TestForgotPasswordDoesNotWaitForMail(t *testing.T) {
+	// Nobody reads this outbox until the request has been answered
+	box := make(outbox)
+	s, db := newTestService(t, func(opts *Options) {
+		opts.Mailer = box
+	})
+	user := createTestUser(t, db, "ana@example.com")
+
+	answered := make(chan error)
+	go func() {
+		answered <- s.ForgotPassword(context.Background(), user.Email, testClient.IP)
+	}()
+	select {
+	case err := <-answered:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ForgotPassword waited for the email to be delivered")
+	}
+	resetToken(t, box)
+}
+
+func TestChangePassword(t *testing.T) {
+	var box outbox
+	s, db := newTestService(t, withOutbox(&box), func(opts *Options) {
+		opts.PasswordPolicy.Breached = writeBreachedList(t, "password123")
+	})
+	ctx := context.Background()
+	user := createTestUser(t, db, "ana@example.com")
+	current := login(t, s, user.Email)
+	other := login(t, s, user.Email)
+	claims, err := s.tokens.Validate(current.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		current, updated string
+		want             apperr.Kind
+	}{
+		{"no current password", "", "a new password", apperr.Invalid},
+		{"wrong current password", "wrong password", "a new password", apperr.Forbidden},
+		{"unchanged", testPassword, testPassword, apperr.Invalid},
+		{"too short", testPassword, "short", apperr.Invalid},
+		{"breached", testPassword, "password123", apperr.Invalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.ChangePassword(ctx, user.ID, claims.SessionID, tt.current, tt.updated, testClient.IP); apperr.KindOf(err) != tt.want {
+				t.Errorf("ChangePassword: %v, want %v", err, tt.want)
+			}
+		})
+	}
+	select {
+	case msg := <-box:
+		t.Fatalf("email about a refused change: %q", msg.Subject)
+	default:
+	}
+
+	if err := s.ChangePassword(ctx, user.ID, claims.SessionID, testPassword, "a new password", testClient.IP); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if _, err := s.Login(ctx, user.Email, testPassword, testClient); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("login with the old password: %v, want Unauthorized", err)
+	}
+	if _, err := s.Login(ctx, user.Email, "a new password", testClient); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+	// The session that changed the password stays signed in; the others do not
+	if _, err := s.Refresh(ctx, current.RefreshToken, testClient); err != nil {
+		t.Errorf("refreshing the session that changed the password: %v", err)
+	}
+	if _, err := s.Refresh(ctx, other.RefreshToken, testClient); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("refreshing another session: %v, want Unauthorized", err)
+	}
+	select {
+	case msg := <-box:
+		if msg.To != user.Email || !strings.Contains(msg.Subject, "password was changed") {
+			t.Errorf("email %q to %s", msg.Subject, msg.To)
+		}
+	default:
+		t.Error("no email about the change")
+	}
+}
+
+func TestChangePasswordWithoutOne(t *testing.T) {
+	s, db := newTestService(t)
+	user := createTestUser(t, db, "ana@example.com")
+	user.Password = "" // Signed up through single sign-on
+	if err := db.UpdateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	err := s.ChangePassword(context.Background(), user.ID, "", "anything", "a new password", testClient.IP)
+	if apperr.KindOf(err) != apperr.Invalid || !strings.Contains(err.Error(), "password reset") {
+		t.Errorf("ChangePassword without a password: %v, want Invalid pointing to a reset", err)
+	}
+}
+
+func TestLoginUpgradesPasswordHash(t *testing.T) {
+	s, db := newTestService(t, func(opts *Options) {
+		opts.BcryptCost = bcrypt.MinCost + 1
+	})
+	ctx := context.Background()
+	user := createTestUser(t, db, "ana@example.com") // Hashed at bcrypt.MinCost
+
+	if _, err := s.Login(ctx, user.Email, "wrong password", testClient); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Fatalf("login with a wrong password: %v", err)
+	}
+	if stored, _ := db.GetUser(ctx, user.ID); stored.Password != user.Password {
+		t.Error("a wrong password re-hashed the stored one")
+	}
+
+	login(t, s, user.Email)
+	stored, err := db.GetUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost, err := bcrypt.Cost([]byte(stored.Password)); err != nil || cost != bcrypt.MinCost+1 {
+		t.Errorf("hash cost after login %d (%v), want %d", cost, err, bcrypt.MinCost+1)
+	}
+	login(t, s, user.Email)
+	if again, _ := db.GetUser(ctx, user.ID); again.Password != stored.Password {
+		t.Error("a hash at the configured cost was re-hashed")
+	}
+
+	// Lowering the cost does not weaken existing hashes
+	s.opts.BcryptCost = bcrypt.MinCost
+	login(t, s, user.Email)
+	if again, _ := db.GetUser(ctx, user.ID); again.Password != stored.Password {
+		t.Error("a hash above the configured cost was re-hashed")
+	}
+}
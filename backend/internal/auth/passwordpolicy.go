@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"borrowhub/internal/apperr"
+)
+
+// maxPasswordBytes is as much of a password as bcrypt looks at
+const maxPasswordBytes = 72
+
+// PasswordPolicy is what new passwords must satisfy. Existing passwords
+// keep working when the policy is tightened.
+type PasswordPolicy struct {
+	MinLength  int                // In characters
+	MinClasses int                // How many of lower case, upper case, digits and symbols to mix
+	Breached   *BreachedPasswords // Refused outright; nil when no list is configured
+}
+
+// check returns an Invalid error describing the first rule password breaks
+func (p PasswordPolicy) check(password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return apperr.New(apperr.Invalid, fmt.Sprintf("Password must be at least %d characters", p.MinLength))
+	}
+	if len(password) > maxPasswordBytes {
+		return apperr.New(apperr.Invalid, fmt.Sprintf("Password must be at most %d bytes", maxPasswordBytes))
+	}
+	if characterClasses(password) < p.MinClasses {
+		return apperr.New(apperr.Invalid, fmt.Sprintf(
+			"Password must mix at least %d of lower case letters, upper case letters, digits and symbols", p.MinClasses))
+	}
+	if p.Breached.Contains(password) {
+		return apperr.New(apperr.Invalid, "This password has appeared in a data breach; choose a different one")
+	}
+	return nil
+}
+
+// characterClasses counts the classes password draws from. Letters without
+// case, such as CJK, count as symbols.
+func characterClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	n := 0
+	for _, has := range []bool{lower, upper, digit, other} {
+		if has {
+			n++
+		}
+	}
+	return n
+}
+
+// BreachedPasswords is a set of passwords known from data breaches, held as
+// SHA-1 hashes
+type BreachedPasswords struct {
+	hashes map[string]struct{}
+}
+
+// LoadBreachedPasswords reads a list with one entry per line: either a
+// password, or its upper- or lower-case hex SHA-1 hash optionally followed
+// by ":<count>", as in the Pwned Passwords downloads. Blank lines and lines
+// starting with # are skipped.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list := &BreachedPasswords{hashes: make(map[string]struct{})}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		if !isSHA1Hex(hash) {
+			hash = sha1Hex(line)
+		}
+		list.hashes[strings.ToUpper(hash)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return list, nil
+}
+
+// Len returns the number of passwords in the list
+func (b *BreachedPasswords) Len() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.hashes)
+}
+
+// Contains reports whether password is on the list. A nil list contains nothing.
+func (b *BreachedPasswords) Contains(password string) bool {
+	if b == nil {
+		return false
+	}
+	_, found := b.hashes[sha1Hex(password)]
+	return found
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != 2*sha1.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"borrowhub/internal/apperr"
+)
+
+// writeBreachedList saves lines as a breached password list and loads it
+func writeBreachedList(t *testing.T, lines ...string) *BreachedPasswords {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	list, err := LoadBreachedPasswords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 10, MinClasses: 3, Breached: writeBreachedList(t, "Summer2024!x")}
+	tests := []struct {
+		name     string
+		password string
+		want     string // Part of the error; empty when the password is accepted
+	}{
+		{"long and mixed", "Tent-pegs-4-ever", ""},
+		{"too short", "Ab1!", "at least 10 characters"},
+		// Length counts characters, not bytes
+		{"multi-byte characters", "Ünïcödé-1ß", ""},
+		{"too long for bcrypt", strings.Repeat("Ab1!", 19), "at most 72 bytes"},
+		{"two classes", "lowercase-only-words", "at least 3 of"},
+		{"letters without case count as symbols", "パスワードabcdef12", ""},
+		{"breached", "Summer2024!x", "data breach"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.check(tt.password)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("check: %v, want accepted", err)
+				}
+				return
+			}
+			if apperr.KindOf(err) != apperr.Invalid || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("check: %v, want Invalid containing %q", err, tt.want)
+			}
+		})
+	}
+
+	// Without a list nothing counts as breached
+	if err := (PasswordPolicy{MinLength: 1}).check("Summer2024!x"); err != nil {
+		t.Errorf("check without a breached list: %v", err)
+	}
+}
+
+func TestLoadBreachedPasswords(t *testing.T) {
+	list := writeBreachedList(t,
+		"# Comments and blank lines are skipped",
+		"",
+		"password123",
+		sha1Hex("hashed-upper")+":42",
+		strings.ToLower(sha1Hex("hashed-lower")),
+		"windows-line\r",
+	)
+	if list.Len() != 4 {
+		t.Errorf("loaded %d passwords, want 4", list.Len())
+	}
+	for _, password := range []string{"password123", "hashed-upper", "hashed-lower", "windows-line"} {
+		if !list.Contains(password) {
+			t.Errorf("%q is not on the list", password)
+		}
+	}
+	for _, password := range []string{"# Comments and blank lines are skipped", "", "Password123"} {
+		if list.Contains(password) {
+			t.Errorf("%q is on the list", password)
+		}
+	}
+
+	if _, err := LoadBreachedPasswords(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("loading a missing list succeeded")
+	}
+	var none *BreachedPasswords
+	if none.Len() != 0 || none.Contains("password123") {
+		t.Error("a nil list is not empty")
+	}
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID    string
+	Email     string
+	Role      string // Role when the access token was issued
+	SessionID string // Session the caller's access token belongs to
+
+	// Set when the caller sent an API key instead of an access token
+	APIKeyID string
+	Scopes   []Scope
+}
+
+// ViaAPIKey reports whether the caller authenticated with an API key
+func (p *Principal) ViaAPIKey() bool {
+	return p.APIKeyID != ""
+}
+
+// HasScope reports whether the caller may act within scope. Callers with an
+// access token are not limited by scopes.
+func (p *Principal) HasScope(scope Scope) bool {
+	return !p.ViaAPIKey() || slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx that carries p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the caller stored in ctx by WithPrincipal. It
+// reports false for requests that were not authenticated.
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
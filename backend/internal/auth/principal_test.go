@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPrincipalInContext(t *testing.T) {
+	if _, ok := PrincipalFrom(context.Background()); ok {
+		t.Error("a context without a principal reported one")
+	}
+	if _, ok := PrincipalFrom(WithPrincipal(context.Background(), nil)); ok {
+		t.Error("a nil principal was reported")
+	}
+
+	want := &Principal{UserID: "usr_ana", Email: "ana@example.com", Role: "user", SessionID: "ses_1"}
+	got, ok := PrincipalFrom(WithPrincipal(context.Background(), want))
+	if !ok || got != want {
+		t.Errorf("PrincipalFrom = %+v, %v, want %+v", got, ok, want)
+	}
+}
+
+func TestPrincipalScopes(t *testing.T) {
+	session := &Principal{UserID: "usr_ana", SessionID: "ses_1"}
+	if session.ViaAPIKey() || !session.HasScope(ScopeBookingsWrite) {
+		t.Error("a signed-in caller is limited by scopes")
+	}
+
+	key := &Principal{UserID: "usr_ana", APIKeyID: "key_1", Scopes: []Scope{ScopeItemsRead}}
+	if !key.ViaAPIKey() || !key.HasScope(ScopeItemsRead) || key.HasScope(ScopeItemsWrite) {
+		t.Errorf("API key principal %+v does not keep to its scopes", key)
+	}
+}
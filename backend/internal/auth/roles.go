@@ -0,0 +1,43 @@
+package auth
+
+import "borrowhub/internal/models"
+
+// Permission is something a role allows beyond using one's own account
+type Permission string
+
+// Permissions checked by the admin API
+const (
+	PermViewUsers      Permission = "users:view"      // List accounts
+	PermSuspendUsers   Permission = "users:suspend"   // Suspend and restore accounts
+	PermAssignRoles    Permission = "users:roles"     // Change the role of an account
+	PermModerateItems  Permission = "items:moderate"  // List every item, suspend and restore listings
+	PermManageBookings Permission = "bookings:manage" // Force the status of any booking
+	PermViewPayments   Permission = "payments:view"   // See every payment
+	PermViewAuditLog   Permission = "audit:view"      // Read the admin audit log
+)
+
+// rolePermissions is the permission matrix. Ordinary users have none.
+var rolePermissions = map[string][]Permission{
+	models.RoleModerator: {
+		PermViewUsers, PermModerateItems, PermManageBookings,
+	},
+	models.RoleAdmin: {
+		PermViewUsers, PermSuspendUsers, PermAssignRoles, PermModerateItems,
+		PermManageBookings, PermViewPayments, PermViewAuditLog,
+	},
+}
+
+// Can reports whether role grants permission
+func Can(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the caller's role grants permission
+func (p *Principal) Can(permission Permission) bool {
+	return Can(p.Role, permission)
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/ids"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// Opaque tokens handed to clients are "<record ID>.<secret>". The record ID
+// finds the stored hash without an index on secrets; the secret is compared
+// in constant time.
+
+// newSecret returns a random secret and the hash to store for it
+func newSecret() (secret, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	secret = base64.RawURLEncoding.EncodeToString(raw)
+	return secret, hashSecret(secret), nil
+}
+
+func joinToken(id, secret string) string {
+	return id + "." + secret
+}
+
+// splitToken is the inverse of joinToken
+func splitToken(token string) (id, secret string, ok bool) {
+	id, secret, ok = strings.Cut(token, ".")
+	return id, secret, ok && id != "" && secret != ""
+}
+
+// secretMatches reports whether secret hashes to storedHash
+func secretMatches(secret, storedHash string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(storedHash)) == 1
+}
+
+// hashSecret is SHA-256; secrets are random, so no salt or stretching is needed
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// appLink returns the frontend URL of path carrying token
+func (s *Service) appLink(path, token string) string {
+	return strings.TrimRight(s.opts.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// describeDuration renders d for people, e.g. "1 hour" or "30 minutes"
+func describeDuration(d time.Duration) string {
+	n, unit := int(d/time.Minute), "minute"
+	if d >= time.Hour && d%time.Hour == 0 {
+		n, unit = int(d/time.Hour), "hour"
+	}
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// issueOneTimeToken stores a new single-use token and returns the client's copy
+func (s *Service) issueOneTimeToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	secret, hash, err := newSecret()
+	if err != nil {
+		return "", apperr.Wrap(apperr.Internal, "Error generating token", err)
+	}
+
+	now := time.Now()
+	record := &models.OneTimeToken{
+		ID:        ids.New(ids.PrefixOneTime),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := s.store.CreateOneTimeToken(ctx, record); err != nil {
+		return "", apperr.Wrap(apperr.Internal, "Error generating token", err)
+	}
+	return joinToken(record.ID, secret), nil
+}
+
+// redeemOneTimeToken checks token against purpose and marks it used
+func (s *Service) redeemOneTimeToken(ctx context.Context, token, purpose string) (*models.OneTimeToken, error) {
+	invalid := apperr.New(apperr.Unauthorized, "Invalid or expired token")
+
+	id, secret, ok := splitToken(token)
+	if !ok {
+		return nil, invalid
+	}
+	record, err := s.store.GetOneTimeToken(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	if record.Purpose != purpose || !secretMatches(secret, record.TokenHash) {
+		return nil, invalid
+	}
+	if record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return nil, invalid
+	}
+
+	if err := s.store.ConsumeOneTimeToken(ctx, record.ID, time.Now()); err != nil {
+		if errors.Is(err, store.ErrTokenReused) || errors.Is(err, store.ErrNotFound) {
+			return nil, invalid
+		}
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	return record, nil
+}
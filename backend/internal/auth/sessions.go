@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/ids"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// TokenPair is what a client holds for one session
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration // Lifetime of AccessToken
+}
+
+// maxUserAgent bounds the user agent stored with a session
+const maxUserAgent = 512
+
+// sessionTouchInterval is how stale a session's last-seen time may get
+// before a request updates it
+const sessionTouchInterval = time.Minute
+
+// Client describes the device a request came from, as recorded on sessions
+type Client struct {
+	IP        string // Empty if unknown
+	UserAgent string
+}
+
+func (c Client) userAgent() string {
+	if len(c.UserAgent) > maxUserAgent {
+		return strings.ToValidUTF8(c.UserAgent[:maxUserAgent], "")
+	}
+	return c.UserAgent
+}
+
+// startSession creates a session for user on client and issues its first token pair
+func (s *Service) startSession(ctx context.Context, user *models.User, client Client) (*TokenPair, error) {
+	if err := checkNotSuspended(user); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := &models.Session{
+		ID:         ids.New(ids.PrefixSession),
+		UserID:     user.ID,
+		UserAgent:  client.userAgent(),
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	if err := s.store.CreateSession(ctx, session); err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error creating session", err)
+	}
+
+	refresh, secret, err := s.newRefreshToken(session)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.CreateRefreshToken(ctx, refresh); err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error creating session", err)
+	}
+	return s.tokenPair(user, session.ID, secret)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Every refresh
+// token can be exchanged once; presenting one again means it was copied,
+// so the whole session is revoked. The session takes on client as its device.
+func (s *Service) Refresh(ctx context.Context, refreshToken string, client Client) (*TokenPair, error) {
+	current, err := s.lookupRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.RotatedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, current)
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return nil, apperr.New(apperr.Unauthorized, "Refresh token expired")
+	}
+
+	session, err := s.store.GetSession(ctx, current.SessionID)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Unauthorized, "Invalid refresh token", err)
+	}
+	if !session.Active() {
+		return nil, apperr.New(apperr.Unauthorized, "Session has been revoked")
+	}
+
+	// New access tokens carry the user's current role
+	user, err := s.store.GetUser(ctx, current.UserID)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Unauthorized, "Invalid refresh token", err)
+	}
+	if err := checkNotSuspended(user); err != nil {
+		return nil, err
+	}
+
+	next, secret, err := s.newRefreshToken(session)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.RotateRefreshToken(ctx, current.ID, next); err != nil {
+		if errors.Is(err, store.ErrTokenReused) {
+			// Lost a race with another exchange of the same token
+			return nil, s.revokeReusedFamily(ctx, current)
+		}
+		return nil, apperr.Wrap(apperr.Internal, "Error refreshing session", err)
+	}
+	if err := s.store.TouchSession(ctx, session.ID, time.Now(), client.IP, client.userAgent()); err != nil {
+		log.Printf("Error recording use of session %s: %v", session.ID, err)
+	}
+	return s.tokenPair(user, session.ID, secret)
+}
+
+// ListSessions returns the active sessions of userID, most recently seen first
+func (s *Service) ListSessions(ctx context.Context, userID string) ([]*models.Session, error) {
+	sessions, err := s.store.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error loading sessions", err)
+	}
+	return sessions, nil
+}
+
+// Logout revokes a single session
+func (s *Service) Logout(ctx context.Context, sessionID string) error {
+	if _, err := s.store.RevokeSessions(ctx, store.SessionFilter{ID: sessionID}, time.Now()); err != nil {
+		return apperr.Wrap(apperr.Internal, "Error logging out", err)
+	}
+	return nil
+}
+
+// LogoutAll revokes every session of the user and returns how many were active
+func (s *Service) LogoutAll(ctx context.Context, userID string) (int, error) {
+	n, err := s.store.RevokeSessions(ctx, store.SessionFilter{UserID: userID}, time.Now())
+	if err != nil {
+		return 0, apperr.Wrap(apperr.Internal, "Error logging out", err)
+	}
+	return n, nil
+}
+
+// Authenticate validates an access token, checks that its session is still
+// active and returns the caller it identifies. Personal API keys are
+// accepted in place of an access token.
+func (s *Service) Authenticate(ctx context.Context, accessToken string) (*Principal, error) {
+	if strings.HasPrefix(accessToken, APIKeyPrefix) {
+		return s.authenticateAPIKey(ctx, accessToken)
+	}
+
+	claims, err := s.tokens.Validate(accessToken)
+	if err != nil || claims.SessionID == "" {
+		return nil, apperr.Wrap(apperr.Unauthorized, "Invalid token", err)
+	}
+
+	session, err := s.store.GetSession(ctx, claims.SessionID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, apperr.New(apperr.Unauthorized, "Invalid token")
+	}
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	if !session.Active() || session.UserID != claims.UserID {
+		return nil, apperr.New(apperr.Unauthorized, "Token has been revoked")
+	}
+	if now := time.Now(); now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		// The request goes ahead even if this fails
+		if err := s.store.TouchSession(ctx, session.ID, now, "", ""); err != nil {
+			log.Printf("Error recording use of session %s: %v", session.ID, err)
+		}
+	}
+	return &Principal{UserID: claims.UserID, Email: claims.Email, Role: claims.Role, SessionID: claims.SessionID}, nil
+}
+
+// JWKS returns the public keys access tokens can be verified with, as a JSON Web Key Set
+func (s *Service) JWKS() ([]byte, error) {
+	return s.tokens.Keys().JWKS()
+}
+
+// checkNotSuspended refuses new sessions and tokens for suspended accounts
+func checkNotSuspended(user *models.User) error {
+	if user.Suspended() {
+		return apperr.New(apperr.Forbidden, "This account has been suspended")
+	}
+	return nil
+}
+
+// revokeReusedFamily ends the session a replayed refresh token belongs to
+func (s *Service) revokeReusedFamily(ctx context.Context, token *models.RefreshToken) error {
+	log.Printf("Refresh token reuse detected for user %s, revoking session %s", token.UserID, token.SessionID)
+	if _, err := s.store.RevokeSessions(ctx, store.SessionFilter{ID: token.SessionID}, time.Now()); err != nil {
+		return apperr.Wrap(apperr.Internal, "Error refreshing session", err)
+	}
+	return apperr.New(apperr.Unauthorized, "Refresh token has already been used; please log in again")
+}
+
+// newRefreshToken creates the next refresh token of session and returns it with the client's copy
+func (s *Service) newRefreshToken(session *models.Session) (*models.RefreshToken, string, error) {
+	secret, hash, err := newSecret()
+	if err != nil {
+		return nil, "", apperr.Wrap(apperr.Internal, "Error generating token", err)
+	}
+
+	now := time.Now()
+	token := &models.RefreshToken{
+		ID:        ids.New(ids.PrefixRefresh),
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		TokenHash: hash,
+		ExpiresAt: now.Add(s.opts.RefreshTTL),
+		CreatedAt: now,
+	}
+	return token, joinToken(token.ID, secret), nil
+}
+
+func (s *Service) lookupRefreshToken(ctx context.Context, refreshToken string) (*models.RefreshToken, error) {
+	invalid := apperr.New(apperr.Unauthorized, "Invalid refresh token")
+
+	id, secret, ok := splitToken(refreshToken)
+	if !ok {
+		return nil, invalid
+	}
+	token, err := s.store.GetRefreshToken(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	if !secretMatches(secret, token.TokenHash) {
+		return nil, invalid
+	}
+	return token, nil
+}
+
+func (s *Service) tokenPair(user *models.User, sessionID, refreshToken string) (*TokenPair, error) {
+	access, err := s.tokens.Issue(user, sessionID)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error generating token", err)
+	}
+	return &TokenPair{AccessToken: access, RefreshToken: refreshToken, ExpiresIn: s.tokens.TTL()}, nil
+}
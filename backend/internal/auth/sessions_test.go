@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"borrowhub/internal/apperr"
+)
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	s, db := newTestService(t)
+	createTestUser(t, db, "ana@example.com")
+	ctx := context.Background()
+
+	first := login(t, s, "ana@example.com")
+	second, err := s.Refresh(ctx, first.RefreshToken, testClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("Refresh returned the same refresh token")
+	}
+	if _, err := s.Refresh(ctx, second.RefreshToken, testClient); err != nil {
+		t.Errorf("refreshing with the new token: %v", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	s, db := newTestService(t)
+	createTestUser(t, db, "ana@example.com")
+	ctx := context.Background()
+
+	stolen := login(t, s, "ana@example.com")
+	other := login(t, s, "ana@example.com") // Another device
+	rotated, err := s.Refresh(ctx, stolen.RefreshToken, testClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying the spent token ends its session...
+	if _, err := s.Refresh(ctx, stolen.RefreshToken, testClient); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Fatalf("replayed refresh token: got %v, want Unauthorized", err)
+	}
+	// ...so the token it was exchanged for and its access tokens stop working
+	if _, err := s.Refresh(ctx, rotated.RefreshToken, testClient); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("refresh after reuse: got %v, want Unauthorized", err)
+	}
+	if _, err := s.Authenticate(ctx, rotated.AccessToken); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("access token after reuse: got %v, want Unauthorized", err)
+	}
+
+	// Other sessions of the user are untouched
+	if _, err := s.Authenticate(ctx, other.AccessToken); err != nil {
+		t.Errorf("other session after reuse: %v", err)
+	}
+	if _, err := s.Refresh(ctx, other.RefreshToken, testClient); err != nil {
+		t.Errorf("refreshing other session after reuse: %v", err)
+	}
+}
+
+// TestConcurrentRefreshIsReuse checks that racing exchanges of one token
+// are caught: at most one wins, and the losers revoke the session
+func TestConcurrentRefreshIsReuse(t *testing.T) {
+	s, db := newTestService(t)
+	createTestUser(t, db, "ana@example.com")
+	ctx := context.Background()
+	pair := login(t, s, "ana@example.com")
+
+	const clients = 8
+	var wg sync.WaitGroup
+	results := make(chan *TokenPair, clients)
+	for range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			next, err := s.Refresh(ctx, pair.RefreshToken, testClient)
+			if err != nil {
+				if apperr.KindOf(err) != apperr.Unauthorized {
+					t.Errorf("Refresh: %v", err)
+				}
+				return
+			}
+			results <- next
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var winners []*TokenPair
+	for next := range results {
+		winners = append(winners, next)
+	}
+	if len(winners) != 1 {
+		t.Fatalf("%d concurrent refreshes succeeded, want 1", len(winners))
+	}
+	if _, err := s.Authenticate(ctx, winners[0].AccessToken); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("session survived a raced refresh: got %v, want Unauthorized", err)
+	}
+}
+
+func TestSessionsRecordDevices(t *testing.T) {
+	s, db := newTestService(t)
+	user := createTestUser(t, db, "ana@example.com")
+	ctx := context.Background()
+
+	// Overlong user agents are cut without splitting a character
+	longAgent := strings.Repeat("a", maxUserAgent-1) + "é and more"
+	result, err := s.Login(ctx, user.Email, testPassword, Client{IP: "192.0.2.1", UserAgent: longAgent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := s.ListSessions(ctx, user.ID)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("ListSessions: %v, %v", sessions, err)
+	}
+	session := sessions[0]
+	if session.IP != "192.0.2.1" || session.UserAgent != strings.Repeat("a", maxUserAgent-1) || !session.LastSeenAt.Equal(session.CreatedAt) {
+		t.Errorf("new session %+v", session)
+	}
+
+	// Refreshing from another network moves the session there
+	if _, err := s.Refresh(ctx, result.Tokens.RefreshToken, Client{IP: "198.51.100.7", UserAgent: "Browser 2"}); err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := db.GetSession(ctx, session.ID)
+	if err != nil || refreshed.IP != "198.51.100.7" || refreshed.UserAgent != "Browser 2" || refreshed.LastSeenAt.Before(session.LastSeenAt) {
+		t.Errorf("session after refreshing: %+v, %v", refreshed, err)
+	}
+}
+
+func TestAuthenticateTouchesStaleSessions(t *testing.T) {
+	s, db := newTestService(t)
+	createTestUser(t, db, "ana@example.com")
+	ctx := context.Background()
+	pair := login(t, s, "ana@example.com")
+	principal, err := s.Authenticate(ctx, pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastSeen := func() time.Time {
+		t.Helper()
+		session, err := db.GetSession(ctx, principal.SessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return session.LastSeenAt
+	}
+
+	// Within the interval requests leave the session alone
+	recent := time.Now().Add(-sessionTouchInterval / 2)
+	if err := db.TouchSession(ctx, principal.SessionID, recent, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(ctx, pair.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if !lastSeen().Equal(recent) {
+		t.Error("a recently seen session was touched")
+	}
+
+	stale := time.Now().Add(-2 * sessionTouchInterval)
+	if err := db.TouchSession(ctx, principal.SessionID, stale, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(ctx, pair.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if seen := lastSeen(); time.Since(seen) > time.Second {
+		t.Errorf("stale session last seen %v after a request", seen)
+	}
+}
+
+func TestRevokedSessionsRejected(t *testing.T) {
+	s, db := newTestService(t)
+	user := createTestUser(t, db, "ana@example.com")
+	ctx := context.Background()
+	first := login(t, s, user.Email)
+	second := login(t, s, user.Email)
+	principal, err := s.Authenticate(ctx, first.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Logout(ctx, principal.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(ctx, first.AccessToken); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("access token of a revoked session: %v, want Unauthorized", err)
+	}
+	if _, err := s.Refresh(ctx, first.RefreshToken, testClient); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("refreshing a revoked session: %v, want Unauthorized", err)
+	}
+	if _, err := s.Authenticate(ctx, second.AccessToken); err != nil {
+		t.Errorf("other session after logging out: %v", err)
+	}
+
+	if n, err := s.LogoutAll(ctx, user.ID); err != nil || n != 1 {
+		t.Errorf("LogoutAll: %d, %v, want 1", n, err)
+	}
+	if _, err := s.Authenticate(ctx, second.AccessToken); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("access token after logging out everywhere: %v, want Unauthorized", err)
+	}
+	if sessions, err := s.ListSessions(ctx, user.ID); err != nil || len(sessions) != 0 {
+		t.Errorf("sessions after logging out everywhere: %v, %v", sessions, err)
+	}
+
+	// A token for a session that never existed is refused as well
+	forged, err := s.tokens.Issue(user, "ses_forged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(ctx, forged); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("token for an unknown session: %v, want Unauthorized", err)
+	}
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"borrowhub/internal/models"
+)
+
+// JWT Claims structure
+type Claims struct {
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role,omitempty"`
+	SessionID string `json:"sid"` // Session the token was issued for; revoking it invalidates the token
+	jwt.RegisteredClaims
+}
+
+// mfaAudience marks MFA challenge tokens, which only prove the password was checked
+const mfaAudience = "borrowhub:mfa"
+
+// TokenOptions configure a TokenManager
+type TokenOptions struct {
+	Keys     *KeySet
+	Issuer   string // iss of every token
+	Audience string // aud of access tokens
+	TTL      time.Duration
+	Secret   []byte // HMAC key for values only this server can derive, such as the OIDC nonce; never signs tokens
+}
+
+// TokenManager signs and validates short-lived access tokens with the
+// asymmetric keys of a KeySet, so other services can verify them from the
+// published public keys
+type TokenManager struct {
+	opts TokenOptions
+}
+
+func NewTokenManager(opts TokenOptions) *TokenManager {
+	return &TokenManager{opts: opts}
+}
+
+// TTL is how long issued access tokens stay valid
+func (m *TokenManager) TTL() time.Duration {
+	return m.opts.TTL
+}
+
+// Keys returns the keys tokens are signed and verified with
+func (m *TokenManager) Keys() *KeySet {
+	return m.opts.Keys
+}
+
+// Issue returns a signed access token for the user's session
+func (m *TokenManager) Issue(user *models.User, sessionID string) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.opts.Issuer,
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{m.opts.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.opts.TTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	return m.opts.Keys.sign(claims)
+}
+
+// Validate parses tokenString and returns its claims if it is an access
+// token from this issuer with a valid signature that has not expired
+func (m *TokenManager) Validate(tokenString string) (*Claims, error) {
+	return m.parse(tokenString, m.opts.Audience)
+}
+
+// derive returns a value only this server can compute from input, bound to purpose
+func (m *TokenManager) derive(purpose, input string) string {
+	mac := hmac.New(sha256.New, m.opts.Secret)
+	mac.Write([]byte(purpose + "\x00" + input))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// IssueChallenge returns a token proving userID passed the password check,
+// to be exchanged for a session once the second factor is verified. Its
+// audience keeps it from being accepted as an access token.
+func (m *TokenManager) IssueChallenge(userID string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.opts.Issuer,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{mfaAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	return m.opts.Keys.sign(claims)
+}
+
+// ValidateChallenge returns the user ID of a token from IssueChallenge
+func (m *TokenManager) ValidateChallenge(tokenString string) (string, error) {
+	claims, err := m.parse(tokenString, mfaAudience)
+	if err != nil {
+		return "", err
+	}
+	return claims.UserID, nil
+}
+
+// parse verifies a token signed by one of the keys for the given audience
+func (m *TokenManager) parse(tokenString, audience string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, m.opts.Keys.keyfunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(m.opts.Issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt())
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.UserID == "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/mail"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// VerifyEmail marks the user a verification token was sent to as verified
+func (s *Service) VerifyEmail(ctx context.Context, token string) error {
+	record, err := s.redeemOneTimeToken(ctx, token, models.TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.store.GetUser(ctx, record.UserID)
+	if err != nil {
+		return apperr.Wrap(apperr.Unauthorized, "Invalid or expired token", err)
+	}
+	if user.Verified {
+		return nil
+	}
+	user.Verified = true
+	if err := s.store.UpdateUser(ctx, user); err != nil {
+		return apperr.Wrap(apperr.Internal, "Error verifying email", err)
+	}
+	log.Printf("Email verified for user %s", user.ID)
+	return nil
+}
+
+// ResendVerification mails a new verification link, at most once per
+// VerificationCooldown. Earlier links stay valid until they expire.
+func (s *Service) ResendVerification(ctx context.Context, userID string) error {
+	user, err := s.store.GetUser(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return apperr.New(apperr.NotFound, "User not found")
+	}
+	if err != nil {
+		return apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	if user.Verified {
+		return apperr.New(apperr.Conflict, "Email address is already verified")
+	}
+
+	last, err := s.store.LatestOneTimeToken(ctx, user.ID, models.TokenPurposeEmailVerification)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	if err == nil {
+		if wait := s.opts.VerificationCooldown - time.Since(last.CreatedAt); wait > 0 {
+			return apperr.New(apperr.TooManyRequests, fmt.Sprintf(
+				"A verification email was sent recently; try again in %d seconds", int(wait.Seconds())+1))
+		}
+	}
+
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		return apperr.Wrap(apperr.Internal, "Error sending verification email", err)
+	}
+	return nil
+}
+
+// RequireVerified fails with Forbidden unless the user has verified their email address
+func (s *Service) RequireVerified(ctx context.Context, userID string) error {
+	user, err := s.store.GetUser(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return apperr.New(apperr.Unauthorized, "User not found")
+	}
+	if err != nil {
+		return apperr.Wrap(apperr.Internal, "Internal server error", err)
+	}
+	if !user.Verified {
+		return apperr.New(apperr.Forbidden, "Please verify your email address first")
+	}
+	return nil
+}
+
+func (s *Service) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := s.issueOneTimeToken(ctx, user.ID, models.TokenPurposeEmailVerification, s.opts.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.opts.Mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address for BorrowHub",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm this is your email address by opening this link within %s:\n\n%s\n\n"+
+			"You can list items and make bookings once it is confirmed. "+
+			"If you did not create a BorrowHub account, you can ignore this email.\n",
+			user.Username, describeDuration(s.opts.EmailVerificationTTL), s.appLink("/verify-email", token)),
+	})
+}
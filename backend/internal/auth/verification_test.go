@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/mail"
+	"borrowhub/internal/models"
+)
+
+type failingMailer struct{}
+
+func (failingMailer) Send(context.Context, mail.Message) error {
+	return errors.New("mail server unreachable")
+}
+
+// register signs up email with testPassword and fails the test on error
+func register(t *testing.T, s *Service, email string) *models.User {
+	t.Helper()
+	// Claiming to be verified has no effect
+	user := &models.User{Email: email, Password: testPassword, Verified: true}
+	if _, err := s.Register(context.Background(), user, testClient); err != nil {
+		t.Fatalf("Register(%s): %v", email, err)
+	}
+	return user
+}
+
+func TestRegisterRequiresVerification(t *testing.T) {
+	var box outbox
+	s, db := newTestService(t, withOutbox(&box))
+	ctx := context.Background()
+	user := register(t, s, " Ana@Example.com ")
+
+	stored, err := db.GetUser(ctx, user.ID)
+	if err != nil || stored.Verified || stored.Email != "ana@example.com" {
+		t.Fatalf("registered user %+v (err %v), want ana@example.com unverified", stored, err)
+	}
+	if err := s.RequireVerified(ctx, user.ID); apperr.KindOf(err) != apperr.Forbidden {
+		t.Errorf("RequireVerified before verifying: %v, want Forbidden", err)
+	}
+	token := mailedToken(t, box, "/verify-email")
+
+	// Other one-time tokens do not verify an address
+	if err := s.ForgotPassword(ctx, "ana@example.com", testClient.IP); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.VerifyEmail(ctx, resetToken(t, box)); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("VerifyEmail with a reset token: %v, want Unauthorized", err)
+	}
+	if err := s.VerifyEmail(ctx, "vrf_forged.secret"); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("VerifyEmail with a forged token: %v, want Unauthorized", err)
+	}
+
+	if err := s.VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if err := s.RequireVerified(ctx, user.ID); err != nil {
+		t.Errorf("RequireVerified after verifying: %v", err)
+	}
+	if err := s.VerifyEmail(ctx, token); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("VerifyEmail with a used token: %v, want Unauthorized", err)
+	}
+}
+
+func TestRegisterRejects(t *testing.T) {
+	s, db := newTestService(t)
+	createTestUser(t, db, "ana@example.com")
+	tests := []struct {
+		name  string
+		email string
+		want  apperr.Kind
+	}{
+		{"no address", "", apperr.Invalid},
+		{"display name", "Ana <ana2@example.com>", apperr.Invalid},
+		{"not an address", "ana at example.com", apperr.Invalid},
+		{"taken", "ANA@example.com", apperr.Conflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Register(context.Background(), &models.User{Email: tt.email, Password: testPassword}, testClient)
+			if apperr.KindOf(err) != tt.want {
+				t.Errorf("Register(%q): %v, want %v", tt.email, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterSurvivesMailFailure(t *testing.T) {
+	s, _ := newTestService(t, func(opts *Options) { opts.Mailer = failingMailer{} })
+	user := register(t, s, "ana@example.com")
+	if err := s.RequireVerified(context.Background(), user.ID); apperr.KindOf(err) != apperr.Forbidden {
+		t.Errorf("RequireVerified: %v, want Forbidden", err)
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	const cooldown = 200 * time.Millisecond
+	var box outbox
+	s, _ := newTestService(t, withOutbox(&box), func(opts *Options) { opts.VerificationCooldown = cooldown })
+	ctx := context.Background()
+	user := register(t, s, "ana@example.com")
+	first := mailedToken(t, box, "/verify-email")
+
+	err := s.ResendVerification(ctx, user.ID)
+	if apperr.KindOf(err) != apperr.TooManyRequests || !strings.Contains(err.Error(), "try again in 1 seconds") {
+		t.Errorf("ResendVerification within the cooldown: %v, want TooManyRequests", err)
+	}
+	if len(box) != 0 {
+		t.Error("a throttled resend sent an email")
+	}
+
+	time.Sleep(cooldown)
+	if err := s.ResendVerification(ctx, user.ID); err != nil {
+		t.Fatalf("ResendVerification after the cooldown: %v", err)
+	}
+	second := mailedToken(t, box, "/verify-email")
+	if second == first {
+		t.Error("the resent link repeats the first one")
+	}
+
+	// Earlier links stay valid
+	if err := s.VerifyEmail(ctx, first); err != nil {
+		t.Errorf("VerifyEmail with the first link: %v", err)
+	}
+	if err := s.ResendVerification(ctx, user.ID); apperr.KindOf(err) != apperr.Conflict {
+		t.Errorf("ResendVerification once verified: %v, want Conflict", err)
+	}
+	if err := s.ResendVerification(ctx, "usr_missing"); apperr.KindOf(err) != apperr.NotFound {
+		t.Errorf("ResendVerification for an unknown user: %v, want NotFound", err)
+	}
+	if err := s.RequireVerified(ctx, "usr_missing"); apperr.KindOf(err) != apperr.Unauthorized {
+		t.Errorf("RequireVerified for an unknown user: %v, want Unauthorized", err)
+	}
+}
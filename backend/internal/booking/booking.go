@@ -0,0 +1,160 @@
+// Package booking implements reservations and item availability.
+package booking
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/ids"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// Service creates and manages bookings
+type Service struct {
+	store store.Store
+}
+
+func NewService(s store.Store) *Service {
+	return &Service{store: s}
+}
+
+// Create validates and stores a new pending booking made by userID. The
+// booking's ID, price, status and timestamps are filled in.
+func (s *Service) Create(ctx context.Context, userID string, booking *models.Booking) error {
+	// Basic validation
+	if booking.ItemID == "" {
+		return apperr.New(apperr.Invalid, "Item ID is required")
+	}
+	if booking.StartDate.IsZero() || booking.EndDate.IsZero() {
+		return apperr.New(apperr.Invalid, "Start date and end date are required")
+	}
+	if booking.StartDate.After(booking.EndDate) || booking.StartDate.Equal(booking.EndDate) {
+		return apperr.New(apperr.Invalid, "End date must be after start date")
+	}
+	if booking.StartDate.Before(time.Now().Truncate(24 * time.Hour)) {
+		return apperr.New(apperr.Invalid, "Start date cannot be in the past")
+	}
+
+	// Check if item exists and is available
+	item, err := s.store.GetItem(ctx, booking.ItemID)
+	if err != nil {
+		return lookupError(err, "Item not found")
+	}
+	if !item.Available || item.Suspended() {
+		return apperr.New(apperr.Conflict, "Item is not available")
+	}
+
+	// Prevent self-booking
+	if item.OwnerID == userID {
+		return apperr.New(apperr.Invalid, "You cannot book your own item")
+	}
+
+	// Calculate total price
+	days := int(booking.EndDate.Sub(booking.StartDate).Hours() / 24)
+	if days < 1 {
+		days = 1
+	}
+	booking.TotalPrice = float64(days) * item.DailyRate
+
+	booking.ID = ids.New(ids.PrefixBooking)
+	booking.UserID = userID
+	booking.Status = models.BookingPending
+	booking.CreatedAt = time.Now()
+	booking.UpdatedAt = time.Now()
+
+	// The store checks for conflicting bookings atomically with the insert
+	if err := s.store.CreateBooking(ctx, booking); err != nil {
+		if errors.Is(err, store.ErrBookingConflict) {
+			return apperr.New(apperr.Conflict, "Item is not available for the selected dates")
+		}
+		return lookupError(err, "Item not found")
+	}
+	return nil
+}
+
+// ListForUser returns the bookings made by userID
+func (s *Service) ListForUser(ctx context.Context, userID string) ([]*models.Booking, error) {
+	bookings, err := s.store.ListBookings(ctx, store.BookingFilter{UserID: userID})
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error loading bookings", err)
+	}
+	return bookings, nil
+}
+
+// UpdateStatus sets the status of a booking. Both the booker and the item
+// owner may change it. A cancelled booking is only re-activated if its dates
+// are still free.
+func (s *Service) UpdateStatus(ctx context.Context, userID, bookingID, status string) (*models.Booking, error) {
+	if !models.IsValidBookingStatus(status) {
+		return nil, apperr.New(apperr.Invalid, "Invalid status")
+	}
+
+	booking, err := s.store.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, lookupError(err, "Booking not found")
+	}
+
+	item, err := s.store.GetItem(ctx, booking.ItemID)
+	if err != nil {
+		return nil, lookupError(err, "Associated item not found")
+	}
+
+	if booking.UserID != userID && item.OwnerID != userID {
+		return nil, apperr.New(apperr.Forbidden, "You can only update your own bookings or bookings for your items")
+	}
+
+	// The store re-checks the dates of a cancelled booking atomically with the change
+	booking, _, err = s.store.SetBookingStatus(ctx, bookingID, status, time.Now())
+	if errors.Is(err, store.ErrBookingConflict) {
+		return nil, apperr.New(apperr.Conflict, "The booking's dates have been taken by another booking")
+	}
+	if err != nil {
+		return nil, lookupError(err, "Booking not found")
+	}
+	return booking, nil
+}
+
+// Calendar returns the per-day availability of an item for a month. Zero
+// month or year default to the current one; unknown items have an empty calendar.
+func (s *Service) Calendar(ctx context.Context, itemID string, month, year int) ([]models.AvailabilityCalendar, error) {
+	if month == 0 {
+		month = int(time.Now().Month())
+	}
+	if year == 0 {
+		year = time.Now().Year()
+	}
+
+	// Get the first and last day of the month
+	firstDay := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := firstDay.AddDate(0, 1, -1)
+
+	var calendar []models.AvailabilityCalendar
+
+	item, err := s.store.GetItem(ctx, itemID)
+	if errors.Is(err, store.ErrNotFound) {
+		return calendar, nil
+	}
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error loading availability", err)
+	}
+
+	// Only bookings touching this month matter
+	endOfMonth := lastDay.AddDate(0, 0, 1)
+	booked, err := s.store.BookedRanges(ctx, itemID, firstDay, endOfMonth)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error loading availability", err)
+	}
+
+	return buildCalendar(firstDay, lastDay.Day(), booked, item.DailyRate), nil
+}
+
+// lookupError maps store.ErrNotFound to a NotFound error with message
+func lookupError(err error, notFoundMessage string) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return apperr.Wrap(apperr.NotFound, notFoundMessage, err)
+	}
+	return apperr.Wrap(apperr.Internal, "Internal server error", err)
+}
@@ -0,0 +1,139 @@
+package booking
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+func TestUpdateStatusReactivatesOnlyFreeDates(t *testing.T) {
+	db := store.NewDatabase()
+	s := NewService(db)
+	ctx := context.Background()
+	if err := db.CreateItem(ctx, &models.Item{ID: "itm_tent", Name: "Tent", DailyRate: 10, OwnerID: "usr_owner", Available: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 7)
+	first := &models.Booking{ItemID: "itm_tent", StartDate: start, EndDate: start.AddDate(0, 0, 3)}
+	if err := s.Create(ctx, "usr_first", first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateStatus(ctx, "usr_first", first.ID, models.BookingCancelled); err != nil {
+		t.Fatal(err)
+	}
+	second := &models.Booking{ItemID: "itm_tent", StartDate: start.AddDate(0, 0, 1), EndDate: start.AddDate(0, 0, 4)}
+	if err := s.Create(ctx, "usr_second", second); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, status := range []string{models.BookingPending, models.BookingConfirmed} {
+		if _, err := s.UpdateStatus(ctx, "usr_first", first.ID, status); apperr.KindOf(err) != apperr.Conflict {
+			t.Errorf("re-activating a cancelled booking as %s over a newer one: %v, want a conflict", status, err)
+		}
+	}
+	if b, err := db.GetBooking(ctx, first.ID); err != nil || b.Status != models.BookingCancelled {
+		t.Errorf("refused booking is %+v (err %v), want it still cancelled", b, err)
+	}
+
+	if _, err := s.UpdateStatus(ctx, "usr_second", second.ID, models.BookingCancelled); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateStatus(ctx, "usr_first", first.ID, models.BookingPending); err != nil {
+		t.Errorf("re-activating a cancelled booking whose dates are free: %v", err)
+	}
+}
+
+// slowStore widens the window between a separate availability check and the
+// write that follows it, which is where overlapping bookings slip through
+type slowStore struct {
+	store.Store
+}
+
+func (s slowStore) IsItemAvailable(ctx context.Context, itemID string, start, end time.Time) (bool, error) {
+	available, err := s.Store.IsItemAvailable(ctx, itemID, start, end)
+	time.Sleep(20 * time.Millisecond)
+	return available, err
+}
+
+// cancelledBookings stores n cancelled bookings of itm_tent whose dates all
+// overlap, made by usr_renter_0 to usr_renter_<n-1>
+func cancelledBookings(t *testing.T, db store.Store, start time.Time, n int) []string {
+	t.Helper()
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("bkg_%d", i)
+		err := db.CreateBooking(context.Background(), &models.Booking{
+			ID:        ids[i],
+			ItemID:    "itm_tent",
+			UserID:    fmt.Sprintf("usr_renter_%d", i),
+			StartDate: start.AddDate(0, 0, i%2),
+			EndDate:   start.AddDate(0, 0, 3),
+			Status:    models.BookingCancelled,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ids
+}
+
+func TestUpdateStatusConcurrentReactivations(t *testing.T) {
+	for _, newBooking := range []bool{false, true} {
+		t.Run(fmt.Sprintf("new booking %v", newBooking), func(t *testing.T) {
+			db := store.NewDatabase()
+			s := NewService(slowStore{db})
+			ctx := context.Background()
+			if err := db.CreateItem(ctx, &models.Item{ID: "itm_tent", Name: "Tent", DailyRate: 10, OwnerID: "usr_owner", Available: true}); err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 7)
+			bookings := cancelledBookings(t, db, start, 8)
+
+			// Every renter re-activates their booking, maybe while someone
+			// else books the same dates
+			var wg sync.WaitGroup
+			results := make(chan error, len(bookings)+1)
+			for i, id := range bookings {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := s.UpdateStatus(ctx, fmt.Sprintf("usr_renter_%d", i), id, models.BookingPending)
+					results <- err
+				}()
+			}
+			if newBooking {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					results <- s.Create(ctx, "usr_latecomer", &models.Booking{ItemID: "itm_tent", StartDate: start, EndDate: start.AddDate(0, 0, 2)})
+				}()
+			}
+			wg.Wait()
+			close(results)
+
+			succeeded := 0
+			for err := range results {
+				switch {
+				case err == nil:
+					succeeded++
+				case apperr.KindOf(err) != apperr.Conflict:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+			active, err := db.ListBookings(ctx, store.BookingFilter{ItemID: "itm_tent", Statuses: []string{models.BookingPending}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if succeeded != 1 || len(active) != 1 {
+				t.Errorf("%d requests succeeded and %d bookings are pending, want exactly 1", succeeded, len(active))
+			}
+		})
+	}
+}
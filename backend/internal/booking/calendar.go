@@ -0,0 +1,41 @@
+package booking
+
+import (
+	"time"
+
+	"borrowhub/internal/models"
+)
+
+// buildCalendar marks each day in [firstDay, firstDay+days) as available or
+// not in O(len(booked) + days), using a difference array over days
+func buildCalendar(firstDay time.Time, days int, booked []models.DateRange, price float64) []models.AvailabilityCalendar {
+	delta := make([]int, days+1)
+	for _, r := range booked {
+		// Day d covers [firstDay+d, firstDay+d+1); it is booked if it intersects [r.Start, r.End)
+		lo := int(r.Start.Sub(firstDay) / (24 * time.Hour))
+		if r.Start.Before(firstDay) {
+			lo = 0
+		}
+		hi := int((r.End.Sub(firstDay) + 24*time.Hour - 1) / (24 * time.Hour))
+		if hi > days {
+			hi = days
+		}
+		if lo >= hi {
+			continue
+		}
+		delta[lo]++
+		delta[hi]--
+	}
+
+	calendar := make([]models.AvailabilityCalendar, 0, days)
+	active := 0
+	for d := 0; d < days; d++ {
+		active += delta[d]
+		calendar = append(calendar, models.AvailabilityCalendar{
+			Date:      firstDay.AddDate(0, 0, d).Format("2006-01-02"),
+			Available: active == 0,
+			Price:     price,
+		})
+	}
+	return calendar
+}
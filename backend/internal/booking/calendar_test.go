@@ -0,0 +1,55 @@
+package booking
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"borrowhub/internal/models"
+)
+
+// bruteForceCalendar checks every day against every range
+func bruteForceCalendar(firstDay time.Time, days int, booked []models.DateRange) []bool {
+	available := make([]bool, days)
+	for d := range days {
+		dayStart := firstDay.AddDate(0, 0, d)
+		dayEnd := dayStart.AddDate(0, 0, 1)
+		available[d] = true
+		for _, r := range booked {
+			if models.DatesOverlap(r.Start, r.End, dayStart, dayEnd) {
+				available[d] = false
+			}
+		}
+	}
+	return available
+}
+
+func TestBuildCalendarMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	firstDay := time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC)
+	const days = 28
+
+	for trial := range 1000 {
+		// Ranges may start before the month, end after it, overlap each
+		// other and begin or end part-way through a day
+		booked := make([]models.DateRange, rng.Intn(6))
+		for i := range booked {
+			start := firstDay.Add(time.Duration(rng.Intn(45*24)-10*24) * time.Hour)
+			booked[i] = models.DateRange{Start: start, End: start.Add(time.Duration(1+rng.Intn(8*24)) * time.Hour)}
+		}
+
+		calendar := buildCalendar(firstDay, days, booked, 25)
+		want := bruteForceCalendar(firstDay, days, booked)
+		if len(calendar) != days {
+			t.Fatalf("trial %d: %d days, want %d", trial, len(calendar), days)
+		}
+		for d, day := range calendar {
+			if day.Date != firstDay.AddDate(0, 0, d).Format("2006-01-02") || day.Price != 25 {
+				t.Fatalf("trial %d: day %d is %+v", trial, d, day)
+			}
+			if day.Available != want[d] {
+				t.Fatalf("trial %d: %s available = %v, want %v (booked %v)", trial, day.Date, day.Available, want[d], booked)
+			}
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package catalog
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+const (
+	maxTags        = 10
+	maxTagLength   = 30
+	maxTextLength  = 200 // Of text attribute values
+	rangeSeparator = ".."
+)
+
+// Classify checks the category, tags and attributes of item against the
+// taxonomy and normalises them in place: tags are lower-cased and
+// deduplicated, choices take the spelling of their option, and whole
+// numbers given as integers become float64 like those decoded from JSON.
+// Items may be left uncategorised, but then cannot have attributes.
+func (t *Taxonomy) Classify(item *models.Item) error {
+	tags, err := normaliseTags(item.Tags)
+	if err != nil {
+		return err
+	}
+
+	if item.Category == "" {
+		if len(item.Attributes) > 0 {
+			return apperr.New(apperr.Invalid, "Attributes need a category")
+		}
+		item.Tags, item.Attributes = tags, nil
+		return nil
+	}
+	category, ok := t.Category(item.Category)
+	if !ok {
+		return apperr.New(apperr.Invalid, "Unknown category: "+item.Category)
+	}
+
+	attributes := make(models.Attributes, len(item.Attributes))
+	// In key order, so errors name the same attribute every time
+	for _, key := range slices.Sorted(maps.Keys(item.Attributes)) {
+		value := item.Attributes[key]
+		if value == nil {
+			continue // Explicit nulls leave the attribute unset
+		}
+		attribute, ok := category.Attribute(key)
+		if !ok {
+			return apperr.New(apperr.Invalid, fmt.Sprintf("Attribute %s does not apply to %s", key, category.Name))
+		}
+		normalised, err := attribute.normalise(value)
+		if err != nil {
+			return err
+		}
+		attributes[key] = normalised
+	}
+	for _, attribute := range category.Attributes {
+		if _, set := attributes[attribute.Key]; attribute.Required && !set {
+			return apperr.New(apperr.Invalid, fmt.Sprintf("%s is required for %s", attribute.Name, category.Name))
+		}
+	}
+	if len(attributes) == 0 {
+		attributes = nil
+	}
+
+	item.Tags, item.Attributes = tags, attributes
+	return nil
+}
+
+// normaliseTags lower-cases and checks tags, dropping repeats
+func normaliseTags(tags []string) ([]string, error) {
+	var normalised []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !slugPattern.MatchString(tag) || len(tag) > maxTagLength {
+			return nil, apperr.New(apperr.Invalid, fmt.Sprintf(
+				"Tags must be at most %d lower-case letters, digits and dashes: %q", maxTagLength, tag))
+		}
+		if !slices.Contains(normalised, tag) {
+			normalised = append(normalised, tag)
+		}
+	}
+	if len(normalised) > maxTags {
+		return nil, apperr.New(apperr.Invalid, fmt.Sprintf("An item can have at most %d tags", maxTags))
+	}
+	return normalised, nil
+}
+
+// normalise checks that value suits the attribute and returns it in canonical form
+func (a Attribute) normalise(value interface{}) (interface{}, error) {
+	invalid := apperr.New(apperr.Invalid, fmt.Sprintf("%s must be %s", a.Name, a.describe()))
+	switch a.Type {
+	case TypeNumber:
+		var n float64
+		switch v := value.(type) {
+		case float64:
+			n = v
+		case int:
+			n = float64(v)
+		case int64:
+			n = float64(v)
+		default:
+			return nil, invalid
+		}
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, invalid
+		}
+		return n, nil
+	case TypeBoolean:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+		return nil, invalid
+	case TypeChoice:
+		if v, ok := value.(string); ok {
+			if option, ok := a.option(v); ok {
+				return option, nil
+			}
+		}
+		return nil, invalid
+	default:
+		v, ok := value.(string)
+		v = strings.TrimSpace(v)
+		if !ok || v == "" || utf8.RuneCountInString(v) > maxTextLength {
+			return nil, invalid
+		}
+		return v, nil
+	}
+}
+
+// option returns the option of a choice attribute matching value, ignoring case
+func (a Attribute) option(value string) (string, bool) {
+	for _, option := range a.Options {
+		if strings.EqualFold(option, strings.TrimSpace(value)) {
+			return option, true
+		}
+	}
+	return "", false
+}
+
+// describe names the values the attribute accepts, for error messages
+func (a Attribute) describe() string {
+	switch a.Type {
+	case TypeNumber:
+		return "a number"
+	case TypeBoolean:
+		return "true or false"
+	case TypeChoice:
+		return "one of " + strings.Join(a.Options, ", ")
+	}
+	return fmt.Sprintf("text of at most %d characters", maxTextLength)
+}
+
+// Query selects items by how they are classified. Zero values match everything.
+type Query struct {
+	Category string   // Also matches the category's subcategories
+	Tags     []string // Items must carry every one
+	// Attribute values by key. Numbers also take ranges, min..max, with
+	// either end left open. Attributes need a category to be looked up in.
+	Attributes map[string]string
+}
+
+// Filter is a Query checked against the taxonomy. The store narrows items
+// down by category and tags; attribute conditions are applied by Matches.
+type Filter struct {
+	categories []string
+	tags       []string
+	conditions []condition
+}
+
+// condition is a test of one attribute's value
+type condition struct {
+	key      string
+	value    interface{} // Exact value; nil for number ranges
+	min, max float64     // Number ranges, inclusive
+}
+
+// Compile checks q and turns it into a Filter
+func (t *Taxonomy) Compile(q Query) (*Filter, error) {
+	var f Filter
+	tags, err := normaliseTags(q.Tags)
+	if err != nil {
+		return nil, err
+	}
+	f.tags = tags
+
+	if q.Category == "" {
+		if len(q.Attributes) > 0 {
+			return nil, apperr.New(apperr.Invalid, "Filtering by attributes needs a category")
+		}
+		return &f, nil
+	}
+	category, ok := t.Category(q.Category)
+	if !ok {
+		return nil, apperr.New(apperr.Invalid, "Unknown category: "+q.Category)
+	}
+	f.categories = t.subtree(category.Slug)
+
+	for _, key := range slices.Sorted(maps.Keys(q.Attributes)) {
+		c, err := t.condition(f.categories, key, q.Attributes[key])
+		if err != nil {
+			return nil, err
+		}
+		f.conditions = append(f.conditions, c)
+	}
+	return &f, nil
+}
+
+// condition parses the filter value raw for the attribute key, which may be
+// defined by any of categories. A key has the same type wherever it is
+// defined, but choices may offer different options in different categories.
+func (t *Taxonomy) condition(categories []string, key, raw string) (condition, error) {
+	var firstErr error
+	for _, slug := range categories {
+		attribute, ok := t.bySlug[slug].Attribute(key)
+		if !ok {
+			continue
+		}
+		c, err := attribute.condition(raw)
+		if err == nil {
+			return c, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return condition{}, firstErr
+	}
+	return condition{}, apperr.New(apperr.Invalid, fmt.Sprintf(
+		"Attribute %s does not apply to %s", key, t.bySlug[categories[0]].Name))
+}
+
+// condition parses the filter value raw for the attribute
+func (a Attribute) condition(raw string) (condition, error) {
+	invalid := apperr.New(apperr.Invalid, fmt.Sprintf("Filter on %s must be %s", a.Key, a.describe()))
+	c := condition{key: a.Key}
+	switch a.Type {
+	case TypeNumber:
+		low, high, isRange := strings.Cut(raw, rangeSeparator)
+		if !isRange {
+			n, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return c, invalid
+			}
+			c.value = n
+			return c, nil
+		}
+		c.min, c.max = math.Inf(-1), math.Inf(1)
+		for _, bound := range []struct {
+			text string
+			to   *float64
+		}{{low, &c.min}, {high, &c.max}} {
+			if bound.text == "" {
+				continue
+			}
+			n, err := strconv.ParseFloat(bound.text, 64)
+			if err != nil {
+				return c, apperr.New(apperr.Invalid, fmt.Sprintf("Filter on %s must be a number or a range such as 10..20", a.Key))
+			}
+			*bound.to = n
+		}
+		return c, nil
+	case TypeBoolean:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return c, invalid
+		}
+		c.value = b
+		return c, nil
+	case TypeChoice:
+		option, ok := a.option(raw)
+		if !ok {
+			return c, invalid
+		}
+		c.value = option
+		return c, nil
+	default:
+		c.value = strings.TrimSpace(raw)
+		return c, nil
+	}
+}
+
+// Narrow returns filter further restricted to the filter's categories and tags
+func (f *Filter) Narrow(filter store.ItemFilter) store.ItemFilter {
+	filter.Categories = f.categories
+	filter.Tags = f.tags
+	return filter
+}
+
+// Matches reports whether item meets the attribute conditions of the filter
+func (f *Filter) Matches(item *models.Item) bool {
+	for _, c := range f.conditions {
+		value, set := item.Attributes[c.key]
+		if !set {
+			return false
+		}
+		switch want := c.value.(type) {
+		case nil:
+			n, ok := value.(float64)
+			if !ok || n < c.min || n > c.max {
+				return false
+			}
+		case string:
+			// Text matches ignoring case; choices are already in canonical form
+			if s, ok := value.(string); !ok || !strings.EqualFold(s, want) {
+				return false
+			}
+		default:
+			if value != want {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package catalog
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+func TestClassify(t *testing.T) {
+	taxonomy := newTestTaxonomy(t)
+	tests := []struct {
+		name     string
+		item     models.Item
+		want     string       // Part of the error; empty when the item is accepted
+		wantItem *models.Item // Normalised tags and attributes, when accepted
+	}{
+		{
+			name:     "uncategorised",
+			item:     models.Item{Tags: []string{" Camping ", "camping", "tent"}},
+			wantItem: &models.Item{Tags: []string{"camping", "tent"}},
+		},
+		{
+			name: "attributes without a category",
+			item: models.Item{Attributes: models.Attributes{"brand": "Nikon"}},
+			want: "Attributes need a category",
+		},
+		{
+			name: "unknown category",
+			item: models.Item{Category: "boats"},
+			want: "Unknown category: boats",
+		},
+		{
+			name: "unknown attribute",
+			item: models.Item{Category: "bikes", Attributes: models.Attributes{"brand": "Trek"}},
+			want: "Attribute brand does not apply to Bikes",
+		},
+		{
+			name: "attribute of a subcategory on its parent",
+			item: models.Item{Category: "cameras", Attributes: models.Attributes{"brand": "Nikon", "weather_sealed": true}},
+			want: "Attribute weather_sealed does not apply to Cameras",
+		},
+		{
+			name: "required attribute missing",
+			item: models.Item{Category: "dslr", Attributes: models.Attributes{"megapixels": 24.0}},
+			want: "Brand is required for DSLR cameras",
+		},
+		{
+			name: "required attribute null",
+			item: models.Item{Category: "dslr", Attributes: models.Attributes{"brand": nil}},
+			want: "Brand is required for DSLR cameras",
+		},
+		{
+			name: "number given as text",
+			item: models.Item{Category: "dslr", Attributes: models.Attributes{"brand": "Nikon", "megapixels": "24"}},
+			want: "Megapixels must be a number",
+		},
+		{
+			name: "number not finite",
+			item: models.Item{Category: "dslr", Attributes: models.Attributes{"brand": "Nikon", "megapixels": math.Inf(1)}},
+			want: "Megapixels must be a number",
+		},
+		{
+			name: "boolean given as text",
+			item: models.Item{Category: "dslr", Attributes: models.Attributes{"brand": "Nikon", "weather_sealed": "yes"}},
+			want: "Weather sealed must be true or false",
+		},
+		{
+			name: "choice not among the options",
+			item: models.Item{Category: "dslr", Attributes: models.Attributes{"brand": "Nikon", "sensor_size": "Medium format"}},
+			want: "Sensor size must be one of Full-frame, APS-C",
+		},
+		{
+			name: "choice given as a number",
+			item: models.Item{Category: "dslr", Attributes: models.Attributes{"brand": "Nikon", "sensor_size": 35.0}},
+			want: "Sensor size must be one of Full-frame, APS-C",
+		},
+		{
+			name: "text blank",
+			item: models.Item{Category: "dslr", Attributes: models.Attributes{"brand": "  "}},
+			want: "Brand must be text",
+		},
+		{
+			name: "text too long",
+			item: models.Item{Category: "dslr", Attributes: models.Attributes{"brand": strings.Repeat("n", maxTextLength+1)}},
+			want: "Brand must be text",
+		},
+		{
+			name: "text given as a boolean",
+			item: models.Item{Category: "dslr", Attributes: models.Attributes{"brand": true}},
+			want: "Brand must be text",
+		},
+		{
+			name: "bad tag",
+			item: models.Item{Tags: []string{"two words"}},
+			want: "Tags must be",
+		},
+		{
+			name: "too many tags",
+			item: models.Item{Tags: strings.Fields("a b c d e f g h i j k")},
+			want: "at most 10 tags",
+		},
+		{
+			name: "normalised",
+			item: models.Item{Category: "dslr", Attributes: models.Attributes{
+				"brand": " Nikon ", "megapixels": 24, "sensor_size": "aps-c", "weather_sealed": false,
+			}},
+			wantItem: &models.Item{Category: "dslr", Attributes: models.Attributes{
+				"brand": "Nikon", "megapixels": 24.0, "sensor_size": "APS-C", "weather_sealed": false,
+			}},
+		},
+		{
+			name:     "nulls leave optional attributes unset",
+			item:     models.Item{Category: "cameras", Attributes: models.Attributes{"brand": "Nikon", "megapixels": nil}},
+			wantItem: &models.Item{Category: "cameras", Attributes: models.Attributes{"brand": "Nikon"}},
+		},
+		{
+			name:     "category without attributes",
+			item:     models.Item{Category: "bikes", Attributes: models.Attributes{}},
+			wantItem: &models.Item{Category: "bikes"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := tt.item
+			err := taxonomy.Classify(&item)
+			if tt.want != "" {
+				if apperr.KindOf(err) != apperr.Invalid || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("Classify: %v, want Invalid containing %q", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Classify: %v", err)
+			}
+			if !reflect.DeepEqual(item, *tt.wantItem) {
+				t.Errorf("classified as %+v, want %+v", item, *tt.wantItem)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	taxonomy := newTestTaxonomy(t)
+	full := &models.Item{Category: "dslr", Attributes: models.Attributes{
+		"brand": "Nikon", "megapixels": 24.0, "sensor_size": "Full-frame", "weather_sealed": true,
+	}}
+	crop := &models.Item{Category: "dslr", Attributes: models.Attributes{"brand": "Canon", "sensor_size": "APS-C"}}
+
+	tests := []struct {
+		name    string
+		query   Query
+		want    string // Part of the error; empty when the query is accepted
+		matches []*models.Item
+	}{
+		{name: "everything", query: Query{}, matches: []*models.Item{full, crop}},
+		{name: "attributes without a category", query: Query{Attributes: map[string]string{"brand": "Nikon"}}, want: "needs a category"},
+		{name: "unknown category", query: Query{Category: "boats"}, want: "Unknown category: boats"},
+		{name: "unknown attribute", query: Query{Category: "bikes", Attributes: map[string]string{"brand": "Trek"}}, want: "does not apply to Bikes"},
+		{name: "bad number", query: Query{Category: "dslr", Attributes: map[string]string{"megapixels": "many"}}, want: "must be a number"},
+		{name: "bad range", query: Query{Category: "dslr", Attributes: map[string]string{"megapixels": "10..many"}}, want: "range such as 10..20"},
+		{name: "bad boolean", query: Query{Category: "dslr", Attributes: map[string]string{"weather_sealed": "maybe"}}, want: "true or false"},
+		{name: "bad choice", query: Query{Category: "dslr", Attributes: map[string]string{"sensor_size": "huge"}}, want: "one of Full-frame, APS-C"},
+		{name: "text ignores case", query: Query{Category: "dslr", Attributes: map[string]string{"brand": "nikon"}}, matches: []*models.Item{full}},
+		{name: "choice ignores case", query: Query{Category: "dslr", Attributes: map[string]string{"sensor_size": "aps-c"}}, matches: []*models.Item{crop}},
+		{name: "boolean", query: Query{Category: "dslr", Attributes: map[string]string{"weather_sealed": "true"}}, matches: []*models.Item{full}},
+		{name: "number", query: Query{Category: "dslr", Attributes: map[string]string{"megapixels": "24"}}, matches: []*models.Item{full}},
+		{name: "open range", query: Query{Category: "dslr", Attributes: map[string]string{"megapixels": "20.."}}, matches: []*models.Item{full}},
+		{name: "range excluding", query: Query{Category: "dslr", Attributes: map[string]string{"megapixels": "..20"}}},
+		// Attributes of subcategories can be filtered on from their parent
+		{name: "subcategory attribute", query: Query{Category: "cameras", Attributes: map[string]string{"sensor_size": "full-frame"}}, matches: []*models.Item{full}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := taxonomy.Compile(tt.query)
+			if tt.want != "" {
+				if apperr.KindOf(err) != apperr.Invalid || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("Compile: %v, want Invalid containing %q", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			for _, item := range []*models.Item{full, crop} {
+				want := false
+				for _, m := range tt.matches {
+					want = want || m == item
+				}
+				if got := filter.Matches(item); got != want {
+					t.Errorf("Matches(%s) = %v, want %v", item.Attributes["brand"], got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNarrow(t *testing.T) {
+	filter, err := newTestTaxonomy(t).Compile(Query{Category: "cameras", Tags: []string{"Travel"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	narrowed := filter.Narrow(store.ItemFilter{OwnerID: "usr_ana"})
+	if narrowed.OwnerID != "usr_ana" || !reflect.DeepEqual(narrowed.Categories, []string{"cameras", "dslr"}) ||
+		!reflect.DeepEqual(narrowed.Tags, []string{"travel"}) {
+		t.Errorf("narrowed to %+v", narrowed)
+	}
+}
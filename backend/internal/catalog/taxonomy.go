@@ -0,0 +1,198 @@
+// Package catalog defines the category taxonomy of listings: parent and
+// child categories, the typed attributes each category asks for, and the
+// rules for item tags.
+package catalog
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed categories.yaml
+var builtinCategories []byte
+
+var (
+	slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	keyPattern  = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// AttributeType is the kind of value an attribute holds
+type AttributeType string
+
+// Attribute types
+const (
+	TypeText    AttributeType = "text"
+	TypeNumber  AttributeType = "number"
+	TypeBoolean AttributeType = "boolean"
+	TypeChoice  AttributeType = "choice" // One of the attribute's options
+)
+
+// Attribute is a structured detail of the items in a category, such as a
+// camera's sensor size
+type Attribute struct {
+	Key      string        `yaml:"key" json:"key"` // Name in item attributes and filters
+	Name     string        `yaml:"name" json:"name"`
+	Type     AttributeType `yaml:"type" json:"type"`
+	Unit     string        `yaml:"unit" json:"unit,omitempty"`       // Of numbers, for display
+	Options  []string      `yaml:"options" json:"options,omitempty"` // Choices only
+	Required bool          `yaml:"required" json:"required"`
+}
+
+// Category is a node of the taxonomy
+type Category struct {
+	Slug   string `json:"slug"`
+	Name   string `json:"name"`
+	Parent string `json:"parent,omitempty"` // Slug of the parent; empty for top-level categories
+	// Attributes includes those inherited from the category's ancestors
+	Attributes []Attribute `json:"attributes"`
+}
+
+// Attribute returns the attribute of c with key
+func (c *Category) Attribute(key string) (Attribute, bool) {
+	for _, attribute := range c.Attributes {
+		if attribute.Key == key {
+			return attribute, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// taxonomyFile is the YAML (or JSON) document defining a taxonomy
+type taxonomyFile struct {
+	Categories []struct {
+		Slug       string      `yaml:"slug"`
+		Name       string      `yaml:"name"`
+		Parent     string      `yaml:"parent"`
+		Attributes []Attribute `yaml:"attributes"` // Only the category's own
+	} `yaml:"categories"`
+}
+
+// Taxonomy is the set of categories items can be listed in. It is read-only
+// once loaded.
+type Taxonomy struct {
+	categories []*Category // In definition order, so parents precede children
+	bySlug     map[string]*Category
+}
+
+// Load reads the taxonomy at path, or the built-in one when path is empty
+func Load(path string) (*Taxonomy, error) {
+	if path == "" {
+		return Parse(builtinCategories, "built-in categories")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read categories file: %w", err)
+	}
+	return Parse(data, path)
+}
+
+// Parse builds a taxonomy from a YAML or JSON document. source names the
+// document in errors.
+func Parse(data []byte, source string) (*Taxonomy, error) {
+	var file taxonomyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse categories from %s: %w", source, err)
+	}
+	if len(file.Categories) == 0 {
+		return nil, fmt.Errorf("%s defines no categories", source)
+	}
+
+	t := &Taxonomy{bySlug: make(map[string]*Category)}
+	types := make(map[string]AttributeType) // Key -> type, which must agree across categories for filters to work
+	for _, spec := range file.Categories {
+		if !slugPattern.MatchString(spec.Slug) {
+			return nil, fmt.Errorf("%s: category slug %q must be lower-case letters, digits and dashes", source, spec.Slug)
+		}
+		if _, exists := t.bySlug[spec.Slug]; exists {
+			return nil, fmt.Errorf("%s: category %s is defined twice", source, spec.Slug)
+		}
+		if spec.Name == "" {
+			return nil, fmt.Errorf("%s: category %s has no name", source, spec.Slug)
+		}
+
+		category := &Category{Slug: spec.Slug, Name: spec.Name, Parent: spec.Parent}
+		if spec.Parent != "" {
+			// Requiring parents first also rules out cycles
+			parent, ok := t.bySlug[spec.Parent]
+			if !ok {
+				return nil, fmt.Errorf("%s: category %s must come after its parent %s", source, spec.Slug, spec.Parent)
+			}
+			category.Attributes = slices.Clone(parent.Attributes)
+		}
+		for _, attribute := range spec.Attributes {
+			if err := checkAttribute(attribute); err != nil {
+				return nil, fmt.Errorf("%s: category %s: %w", source, spec.Slug, err)
+			}
+			if _, exists := category.Attribute(attribute.Key); exists {
+				return nil, fmt.Errorf("%s: category %s: attribute %s is defined twice", source, spec.Slug, attribute.Key)
+			}
+			if other, seen := types[attribute.Key]; seen && other != attribute.Type {
+				return nil, fmt.Errorf("%s: category %s: attribute %s is a %s elsewhere", source, spec.Slug, attribute.Key, other)
+			}
+			types[attribute.Key] = attribute.Type
+			category.Attributes = append(category.Attributes, attribute)
+		}
+		if category.Attributes == nil {
+			category.Attributes = []Attribute{}
+		}
+
+		t.categories = append(t.categories, category)
+		t.bySlug[category.Slug] = category
+	}
+	return t, nil
+}
+
+func checkAttribute(attribute Attribute) error {
+	if !keyPattern.MatchString(attribute.Key) {
+		return fmt.Errorf("attribute key %q must be lower-case letters, digits and underscores", attribute.Key)
+	}
+	if attribute.Name == "" {
+		return fmt.Errorf("attribute %s has no name", attribute.Key)
+	}
+	switch attribute.Type {
+	case TypeText, TypeNumber, TypeBoolean:
+		if len(attribute.Options) > 0 {
+			return fmt.Errorf("attribute %s: only choices have options", attribute.Key)
+		}
+	case TypeChoice:
+		if len(attribute.Options) == 0 {
+			return fmt.Errorf("attribute %s: choices need options", attribute.Key)
+		}
+	default:
+		return fmt.Errorf("attribute %s: type must be text, number, boolean or choice, got %q", attribute.Key, attribute.Type)
+	}
+	return nil
+}
+
+// Len returns the number of categories
+func (t *Taxonomy) Len() int {
+	return len(t.categories)
+}
+
+// Categories returns every category, each parent before its children
+func (t *Taxonomy) Categories() []*Category {
+	return slices.Clone(t.categories)
+}
+
+// Category returns the category with slug
+func (t *Taxonomy) Category(slug string) (*Category, bool) {
+	category, ok := t.bySlug[slug]
+	return category, ok
+}
+
+// subtree returns slug and the slugs of all its descendants
+func (t *Taxonomy) subtree(slug string) []string {
+	slugs := []string{slug}
+	// Children always follow their parents, so one pass collects every generation
+	for _, category := range t.categories {
+		if category.Parent != "" && slices.Contains(slugs, category.Parent) {
+			slugs = append(slugs, category.Slug)
+		}
+	}
+	return slugs
+}
@@ -0,0 +1,121 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCategories is a small taxonomy with one attribute of each type and a
+// subcategory inheriting its parent's attributes
+const testCategories = `
+categories:
+  - slug: cameras
+    name: Cameras
+    attributes:
+      - {key: brand, name: Brand, type: text, required: true}
+      - {key: megapixels, name: Megapixels, type: number}
+  - slug: dslr
+    name: DSLR cameras
+    parent: cameras
+    attributes:
+      - {key: sensor_size, name: Sensor size, type: choice, options: [Full-frame, APS-C]}
+      - {key: weather_sealed, name: Weather sealed, type: boolean}
+  - slug: bikes
+    name: Bikes
+`
+
+func newTestTaxonomy(t *testing.T) *Taxonomy {
+	t.Helper()
+	taxonomy, err := Parse([]byte(testCategories), "test categories")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return taxonomy
+}
+
+func TestParse(t *testing.T) {
+	taxonomy := newTestTaxonomy(t)
+	if taxonomy.Len() != 3 {
+		t.Fatalf("%d categories, want 3", taxonomy.Len())
+	}
+	dslr, ok := taxonomy.Category("dslr")
+	if !ok {
+		t.Fatal("dslr not found")
+	}
+	var keys []string
+	for _, attribute := range dslr.Attributes {
+		keys = append(keys, attribute.Key)
+	}
+	// Inherited attributes come first
+	if got := strings.Join(keys, ","); got != "brand,megapixels,sensor_size,weather_sealed" {
+		t.Errorf("dslr attributes %s", got)
+	}
+	if bikes, _ := taxonomy.Category("bikes"); bikes.Attributes == nil {
+		t.Error("a category without attributes has nil ones, want an empty list for JSON")
+	}
+	if got := taxonomy.subtree("cameras"); strings.Join(got, ",") != "cameras,dslr" {
+		t.Errorf("subtree of cameras: %v", got)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string // Part of the error
+	}{
+		{"no categories", `categories: []`, "defines no categories"},
+		{"not YAML", `categories: [`, "parse categories"},
+		{"bad slug", `categories: [{slug: Cameras, name: Cameras}]`, "slug"},
+		{"duplicate slug", `categories: [{slug: bikes, name: Bikes}, {slug: bikes, name: Bicycles}]`, "defined twice"},
+		{"no name", `categories: [{slug: bikes}]`, "has no name"},
+		{"parent after child", `categories: [{slug: dslr, name: DSLR, parent: cameras}, {slug: cameras, name: Cameras}]`, "must come after its parent"},
+		{"unknown parent", `categories: [{slug: dslr, name: DSLR, parent: cameras}]`, "must come after its parent"},
+		{"bad attribute key", `categories: [{slug: bikes, name: Bikes, attributes: [{key: Frame, name: Frame, type: text}]}]`, "attribute key"},
+		{"attribute without a name", `categories: [{slug: bikes, name: Bikes, attributes: [{key: frame, type: text}]}]`, "has no name"},
+		{"unknown type", `categories: [{slug: bikes, name: Bikes, attributes: [{key: frame, name: Frame, type: date}]}]`, "type must be"},
+		{"choice without options", `categories: [{slug: bikes, name: Bikes, attributes: [{key: frame, name: Frame, type: choice}]}]`, "choices need options"},
+		{"options on text", `categories: [{slug: bikes, name: Bikes, attributes: [{key: frame, name: Frame, type: text, options: [a]}]}]`, "only choices have options"},
+		{"attribute redefined by a child", `categories: [{slug: bikes, name: Bikes, attributes: [{key: frame, name: Frame, type: text}]}, {slug: bmx, name: BMX, parent: bikes, attributes: [{key: frame, name: Frame, type: text}]}]`, "defined twice"},
+		{"key with two types", `categories: [{slug: bikes, name: Bikes, attributes: [{key: size, name: Size, type: text}]}, {slug: tents, name: Tents, attributes: [{key: size, name: Size, type: number}]}]`, "is a text elsewhere"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.yaml), "test")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse: %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	builtin, err := Load("")
+	if err != nil {
+		t.Fatalf("built-in categories: %v", err)
+	}
+	if builtin.Len() == 0 {
+		t.Error("the built-in taxonomy is empty")
+	}
+
+	path := filepath.Join(t.TempDir(), "categories.yaml")
+	if err := os.WriteFile(path, []byte(testCategories), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	taxonomy, err := Load(path)
+	if err != nil || taxonomy.Len() != 3 {
+		t.Errorf("Load(%s): %v", path, err)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+	if err := os.WriteFile(path, []byte(`categories: [{slug: Bad}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("Load of an invalid file: %v, want an error naming it", err)
+	}
+}
@@ -0,0 +1,587 @@
+// Package config loads and validates the runtime configuration.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// Placeholder secrets that are fine for local development but must never reach production
+const (
+	defaultJWTSecret       = "your-secret-key"
+	defaultRazorpayKeyID   = "rzp_test_key"
+	minProductionSecretLen = 32
+)
+
+// Config is the complete runtime configuration. Values come from the
+// defaults below, then an optional YAML/JSON file (BORROWHUB_CONFIG_FILE),
+// then environment variables, each layer overriding the previous one.
+type Config struct {
+	Env      string         `yaml:"env"` // "development" or "production"
+	Server   ServerConfig   `yaml:"server"`
+	Auth     AuthConfig     `yaml:"auth"`
+	CORS     CORSConfig     `yaml:"cors"`
+	Payments PaymentsConfig `yaml:"payments"`
+	Store    StoreConfig    `yaml:"store"`
+	IDs      IDConfig       `yaml:"ids"`
+	Seed     SeedConfig     `yaml:"seed"`
+	Catalog  CatalogConfig  `yaml:"catalog"`
+	Mail     MailConfig     `yaml:"mail"`
+	OIDC     OIDCConfig     `yaml:"oidc"`
+}
+
+type ServerConfig struct {
+	Addr              string        `yaml:"addr"`
+	ReadTimeout       time.Duration `yaml:"readTimeout"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+	MaxHeaderBytes    int           `yaml:"maxHeaderBytes"`
+	ShutdownTimeout   time.Duration `yaml:"shutdownTimeout"` // Budget for draining requests and running shutdown hooks
+	TrustProxy        bool          `yaml:"trustProxy"`      // Take client IPs from X-Forwarded-For, as set by a reverse proxy in front
+}
+
+type AuthConfig struct {
+	JWTSecret             string        `yaml:"jwtSecret"`            // HMAC key for the single sign-on PKCE verifier and nonce, derived from the state; access tokens are signed with the keys below
+	SigningKey            string        `yaml:"signingKey"`           // PEM RSA or Ed25519 private key that signs access tokens
+	SigningKeyFile        string        `yaml:"signingKeyFile"`       // Read into SigningKey at startup
+	VerificationKeys      string        `yaml:"verificationKeys"`     // PEM keys whose tokens are still accepted, e.g. the previous signing key
+	VerificationKeysFile  string        `yaml:"verificationKeysFile"` // Read into VerificationKeys at startup
+	Issuer                string        `yaml:"issuer"`
+	Audience              string        `yaml:"audience"`
+	AccessTokenTTL        time.Duration `yaml:"accessTokenTtl"`
+	RefreshTokenTTL       time.Duration `yaml:"refreshTokenTtl"` // Idle lifetime of a session: each refresh extends it
+	PasswordResetTTL      time.Duration `yaml:"passwordResetTtl"`
+	EmailVerificationTTL  time.Duration `yaml:"emailVerificationTtl"`
+	VerificationCooldown  time.Duration `yaml:"verificationCooldown"` // Minimum gap between verification or password reset emails to one address
+	MFAChallengeTTL       time.Duration `yaml:"mfaChallengeTtl"`      // Time to enter the second factor after the password
+	LockoutThreshold      int           `yaml:"lockoutThreshold"`     // Failed logins for one email address before it is locked out
+	IPLockoutThreshold    int           `yaml:"ipLockoutThreshold"`   // Failed logins from one client IP before it is locked out
+	LockoutDelay          time.Duration `yaml:"lockoutDelay"`         // First lockout; every further failure doubles it
+	MaxLockoutDelay       time.Duration `yaml:"maxLockoutDelay"`
+	PasswordMinLength     int           `yaml:"passwordMinLength"`
+	PasswordMinClasses    int           `yaml:"passwordMinClasses"`    // Of lower case, upper case, digits and symbols
+	BreachedPasswordsFile string        `yaml:"breachedPasswordsFile"` // Passwords or SHA-1 hashes to refuse, one per line
+	BcryptCost            int           `yaml:"bcryptCost"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowedOrigins"`
+}
+
+type PaymentsConfig struct {
+	RazorpayKeyID string `yaml:"razorpayKeyId"`
+}
+
+type StoreConfig struct {
+	Backend          string        `yaml:"backend"` // "memory", "sqlite" or "postgres"
+	SQLitePath       string        `yaml:"sqlitePath"`
+	DatabaseURL      string        `yaml:"databaseUrl"`
+	SnapshotPath     string        `yaml:"snapshotPath"`
+	SnapshotInterval time.Duration `yaml:"snapshotInterval"`
+}
+
+type IDConfig struct {
+	Format   string `yaml:"format"` // "ulid" or "uuidv7"
+	Prefixes bool   `yaml:"prefixes"`
+}
+
+type MailConfig struct {
+	Driver       string `yaml:"driver"` // "log", "file" or "smtp"
+	Dir          string `yaml:"dir"`    // Output directory of the file driver
+	From         string `yaml:"from"`
+	AppURL       string `yaml:"appUrl"` // Frontend base URL used for links in emails
+	SMTPHost     string `yaml:"smtpHost"`
+	SMTPPort     int    `yaml:"smtpPort"`
+	SMTPUsername string `yaml:"smtpUsername"`
+	SMTPPassword string `yaml:"smtpPassword"`
+}
+
+// OIDCConfig configures sign-in with an external OpenID Connect provider.
+// It is disabled while Issuer is empty.
+type OIDCConfig struct {
+	Name         string   `yaml:"name"`   // Shown on the sign-in button
+	Issuer       string   `yaml:"issuer"` // Discovery runs against <issuer>/.well-known/openid-configuration
+	ClientID     string   `yaml:"clientId"`
+	ClientSecret string   `yaml:"clientSecret"` // Empty for public clients, which rely on PKCE alone
+	RedirectURL  string   `yaml:"redirectUrl"`  // Frontend callback route; defaults to <mail.appUrl>/auth/callback
+	Scopes       []string `yaml:"scopes"`
+}
+
+type SeedConfig struct {
+	Mode string `yaml:"mode"` // "", "sample" or "off"
+	File string `yaml:"file"`
+	// Overwrite resets the profile, role and password of users that already
+	// exist, and the details of existing items, to those in the fixture; by
+	// default existing users and items are left alone
+	Overwrite bool `yaml:"overwrite"`
+}
+
+type CatalogConfig struct {
+	CategoriesFile string `yaml:"categoriesFile"` // Category taxonomy; empty for the built-in one
+}
+
+// Default returns the development defaults
+func Default() *Config {
+	return &Config{
+		Env: defaultEnv(),
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadTimeout:       30 * time.Second, // Image uploads can be up to 10 MB
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    64 << 10,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Auth: AuthConfig{
+			JWTSecret:            defaultJWTSecret,
+			Issuer:               "borrowhub",
+			Audience:             "borrowhub-api",
+			AccessTokenTTL:       15 * time.Minute,
+			RefreshTokenTTL:      30 * 24 * time.Hour,
+			PasswordResetTTL:     time.Hour,
+			EmailVerificationTTL: 48 * time.Hour,
+			VerificationCooldown: time.Minute,
+			MFAChallengeTTL:      5 * time.Minute,
+			LockoutThreshold:     5,
+			IPLockoutThreshold:   50, // Offices and mobile carriers put many users behind one address
+			LockoutDelay:         time.Minute,
+			MaxLockoutDelay:      time.Hour,
+			PasswordMinLength:    8,
+			PasswordMinClasses:   2,
+			BcryptCost:           12,
+		},
+		CORS: CORSConfig{AllowedOrigins: []string{
+			"https://borrowhubb.live",
+			"http://localhost:5173",
+			"http://127.0.0.1:5173",
+			"http://localhost:3000",
+		}},
+		Payments: PaymentsConfig{RazorpayKeyID: defaultRazorpayKeyID},
+		Store: StoreConfig{
+			Backend:          "memory",
+			SQLitePath:       "borrowhub.db",
+			SnapshotInterval: 5 * time.Minute,
+		},
+		IDs: IDConfig{Format: "ulid", Prefixes: true},
+		Mail: MailConfig{
+			Driver:   "log",
+			Dir:      "mail",
+			From:     "BorrowHub <no-reply@borrowhubb.live>",
+			AppURL:   "http://localhost:5173",
+			SMTPPort: 587,
+		},
+		OIDC: OIDCConfig{
+			Name:   "OpenID Connect",
+			Scopes: []string{"openid", "email", "profile"},
+		},
+	}
+}
+
+// OnLambda reports whether the process runs as an AWS Lambda function
+func OnLambda() bool {
+	return os.Getenv("AWS_LAMBDA_RUNTIME_API") != ""
+}
+
+// defaultEnv is production on Lambda, which is always deployed to the
+// internet, and development anywhere else
+func defaultEnv() string {
+	if OnLambda() {
+		return "production"
+	}
+	return "development"
+}
+
+// Load builds and validates the configuration from the environment
+func Load() (*Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("BORROWHUB_CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+		// YAML is a superset of JSON, so either format works
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.readKeyFiles(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readKeyFiles loads the token keys configured as files
+func (c *Config) readKeyFiles() error {
+	for _, k := range []struct {
+		key, file string
+		dst       *string
+	}{
+		{"auth.signingKey", c.Auth.SigningKeyFile, &c.Auth.SigningKey},
+		{"auth.verificationKeys", c.Auth.VerificationKeysFile, &c.Auth.VerificationKeys},
+	} {
+		if k.file == "" {
+			continue
+		}
+		if *k.dst != "" {
+			return fmt.Errorf("%s: set either the key or its file, not both", k.key)
+		}
+		data, err := os.ReadFile(k.file)
+		if err != nil {
+			return fmt.Errorf("%sFile: %w", k.key, err)
+		}
+		*k.dst = string(data)
+	}
+	return nil
+}
+
+// applyEnv overrides configuration with any environment variables that are set
+func (c *Config) applyEnv() error {
+	var errs []error
+	str := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	duration := func(name string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+			*dst = d
+		}
+	}
+	integer := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+			*dst = n
+		}
+	}
+	boolean := func(name string, dst *bool) {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+			*dst = b
+		}
+	}
+
+	str("BORROWHUB_ENV", &c.Env)
+	str("BORROWHUB_ADDR", &c.Server.Addr)
+	if port, ok := os.LookupEnv("PORT"); ok && os.Getenv("BORROWHUB_ADDR") == "" {
+		c.Server.Addr = ":" + port
+	}
+	duration("BORROWHUB_READ_TIMEOUT", &c.Server.ReadTimeout)
+	duration("BORROWHUB_READ_HEADER_TIMEOUT", &c.Server.ReadHeaderTimeout)
+	duration("BORROWHUB_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	duration("BORROWHUB_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	duration("BORROWHUB_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+	integer("BORROWHUB_MAX_HEADER_BYTES", &c.Server.MaxHeaderBytes)
+	boolean("BORROWHUB_TRUST_PROXY", &c.Server.TrustProxy)
+	str("BORROWHUB_JWT_SECRET", &c.Auth.JWTSecret)
+	str("BORROWHUB_JWT_SIGNING_KEY", &c.Auth.SigningKey)
+	str("BORROWHUB_JWT_SIGNING_KEY_FILE", &c.Auth.SigningKeyFile)
+	str("BORROWHUB_JWT_VERIFICATION_KEYS", &c.Auth.VerificationKeys)
+	str("BORROWHUB_JWT_VERIFICATION_KEYS_FILE", &c.Auth.VerificationKeysFile)
+	str("BORROWHUB_JWT_ISSUER", &c.Auth.Issuer)
+	str("BORROWHUB_JWT_AUDIENCE", &c.Auth.Audience)
+	duration("BORROWHUB_ACCESS_TOKEN_TTL", &c.Auth.AccessTokenTTL)
+	duration("BORROWHUB_REFRESH_TOKEN_TTL", &c.Auth.RefreshTokenTTL)
+	duration("BORROWHUB_PASSWORD_RESET_TTL", &c.Auth.PasswordResetTTL)
+	duration("BORROWHUB_EMAIL_VERIFICATION_TTL", &c.Auth.EmailVerificationTTL)
+	duration("BORROWHUB_VERIFICATION_COOLDOWN", &c.Auth.VerificationCooldown)
+	duration("BORROWHUB_MFA_CHALLENGE_TTL", &c.Auth.MFAChallengeTTL)
+	integer("BORROWHUB_LOCKOUT_THRESHOLD", &c.Auth.LockoutThreshold)
+	integer("BORROWHUB_IP_LOCKOUT_THRESHOLD", &c.Auth.IPLockoutThreshold)
+	duration("BORROWHUB_LOCKOUT_DELAY", &c.Auth.LockoutDelay)
+	duration("BORROWHUB_MAX_LOCKOUT_DELAY", &c.Auth.MaxLockoutDelay)
+	integer("BORROWHUB_PASSWORD_MIN_LENGTH", &c.Auth.PasswordMinLength)
+	integer("BORROWHUB_PASSWORD_MIN_CLASSES", &c.Auth.PasswordMinClasses)
+	str("BORROWHUB_BREACHED_PASSWORDS_FILE", &c.Auth.BreachedPasswordsFile)
+	integer("BORROWHUB_BCRYPT_COST", &c.Auth.BcryptCost)
+	if v, ok := os.LookupEnv("BORROWHUB_CORS_ORIGINS"); ok {
+		c.CORS.AllowedOrigins = splitList(v)
+	}
+	str("RAZORPAY_KEY_ID", &c.Payments.RazorpayKeyID)
+	str("BORROWHUB_STORE", &c.Store.Backend)
+	str("BORROWHUB_SQLITE_PATH", &c.Store.SQLitePath)
+	str("DATABASE_URL", &c.Store.DatabaseURL)
+	str("BORROWHUB_SNAPSHOT_PATH", &c.Store.SnapshotPath)
+	duration("BORROWHUB_SNAPSHOT_INTERVAL", &c.Store.SnapshotInterval)
+	str("BORROWHUB_ID_FORMAT", &c.IDs.Format)
+	boolean("BORROWHUB_ID_PREFIXES", &c.IDs.Prefixes)
+	str("BORROWHUB_MAIL_DRIVER", &c.Mail.Driver)
+	str("BORROWHUB_MAIL_DIR", &c.Mail.Dir)
+	str("BORROWHUB_MAIL_FROM", &c.Mail.From)
+	str("BORROWHUB_APP_URL", &c.Mail.AppURL)
+	str("SMTP_HOST", &c.Mail.SMTPHost)
+	integer("SMTP_PORT", &c.Mail.SMTPPort)
+	str("SMTP_USERNAME", &c.Mail.SMTPUsername)
+	str("SMTP_PASSWORD", &c.Mail.SMTPPassword)
+	str("BORROWHUB_OIDC_NAME", &c.OIDC.Name)
+	str("BORROWHUB_OIDC_ISSUER", &c.OIDC.Issuer)
+	str("BORROWHUB_OIDC_CLIENT_ID", &c.OIDC.ClientID)
+	str("BORROWHUB_OIDC_CLIENT_SECRET", &c.OIDC.ClientSecret)
+	str("BORROWHUB_OIDC_REDIRECT_URL", &c.OIDC.RedirectURL)
+	if v, ok := os.LookupEnv("BORROWHUB_OIDC_SCOPES"); ok {
+		c.OIDC.Scopes = splitList(v)
+	}
+	str("BORROWHUB_SEED", &c.Seed.Mode)
+	str("BORROWHUB_SEED_FILE", &c.Seed.File)
+	boolean("BORROWHUB_SEED_OVERWRITE", &c.Seed.Overwrite)
+	str("BORROWHUB_CATEGORIES_FILE", &c.Catalog.CategoriesFile)
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every configuration problem at once
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Env != "development" && c.Env != "production" {
+		fail("env: must be development or production, got %q", c.Env)
+	} else if OnLambda() && !c.IsProduction() {
+		fail("env: must be production on AWS Lambda (BORROWHUB_ENV)")
+	}
+
+	if _, port, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		fail("server.addr: %v", err)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		fail("server.addr: invalid port %q", port)
+	}
+	for _, t := range []struct {
+		key string
+		d   time.Duration
+	}{
+		{"server.readTimeout", c.Server.ReadTimeout},
+		{"server.readHeaderTimeout", c.Server.ReadHeaderTimeout},
+		{"server.writeTimeout", c.Server.WriteTimeout},
+		{"server.idleTimeout", c.Server.IdleTimeout},
+		{"server.shutdownTimeout", c.Server.ShutdownTimeout},
+	} {
+		if t.d <= 0 {
+			fail("%s: must be positive", t.key)
+		}
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		fail("server.maxHeaderBytes: must be positive")
+	}
+
+	if c.Auth.JWTSecret == "" {
+		fail("auth.jwtSecret: must be set")
+	}
+	if c.Auth.Issuer == "" {
+		fail("auth.issuer: must be set")
+	}
+	if c.Auth.Audience == "" {
+		fail("auth.audience: must be set")
+	}
+	if c.Auth.AccessTokenTTL <= 0 {
+		fail("auth.accessTokenTtl: must be positive")
+	}
+	if c.Auth.RefreshTokenTTL < c.Auth.AccessTokenTTL {
+		fail("auth.refreshTokenTtl: must be at least auth.accessTokenTtl")
+	}
+	if c.Auth.PasswordResetTTL <= 0 {
+		fail("auth.passwordResetTtl: must be positive")
+	}
+	if c.Auth.EmailVerificationTTL <= 0 {
+		fail("auth.emailVerificationTtl: must be positive")
+	}
+	if c.Auth.VerificationCooldown < 0 {
+		fail("auth.verificationCooldown: must not be negative")
+	}
+	if c.Auth.MFAChallengeTTL <= 0 {
+		fail("auth.mfaChallengeTtl: must be positive")
+	}
+	if c.Auth.LockoutThreshold <= 0 {
+		fail("auth.lockoutThreshold: must be positive")
+	}
+	if c.Auth.IPLockoutThreshold <= 0 {
+		fail("auth.ipLockoutThreshold: must be positive")
+	}
+	if c.Auth.LockoutDelay <= 0 {
+		fail("auth.lockoutDelay: must be positive")
+	}
+	if c.Auth.MaxLockoutDelay < c.Auth.LockoutDelay {
+		fail("auth.maxLockoutDelay: must be at least auth.lockoutDelay")
+	}
+	// bcrypt only looks at the first 72 bytes
+	if c.Auth.PasswordMinLength < 1 || c.Auth.PasswordMinLength > 72 {
+		fail("auth.passwordMinLength: must be between 1 and 72")
+	}
+	if c.Auth.PasswordMinClasses < 1 || c.Auth.PasswordMinClasses > 4 {
+		fail("auth.passwordMinClasses: must be between 1 and 4")
+	}
+	if c.Auth.BcryptCost < bcrypt.MinCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		fail("auth.bcryptCost: must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		fail("cors.allowedOrigins: at least one origin is required")
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			fail("cors.allowedOrigins: %q is not an origin such as https://example.com", origin)
+		}
+	}
+
+	switch c.Store.Backend {
+	case "memory":
+		if c.Store.SnapshotPath != "" && c.Store.SnapshotInterval <= 0 {
+			fail("store.snapshotInterval: must be positive")
+		}
+	case "sqlite":
+		if c.Store.SQLitePath == "" {
+			fail("store.sqlitePath: required for the sqlite backend")
+		}
+	case "postgres":
+		if c.Store.DatabaseURL == "" {
+			fail("store.databaseUrl: DATABASE_URL is required for the postgres backend")
+		}
+	default:
+		fail("store.backend: must be memory, sqlite or postgres, got %q", c.Store.Backend)
+	}
+
+	if c.IDs.Format != "ulid" && c.IDs.Format != "uuidv7" {
+		fail("ids.format: must be ulid or uuidv7, got %q", c.IDs.Format)
+	}
+
+	switch c.Mail.Driver {
+	case "log":
+	case "file":
+		if c.Mail.Dir == "" {
+			fail("mail.dir: required for the file driver")
+		}
+	case "smtp":
+		if c.Mail.SMTPHost == "" {
+			fail("mail.smtpHost: SMTP_HOST is required for the smtp driver")
+		}
+		if c.Mail.SMTPPort <= 0 || c.Mail.SMTPPort > 65535 {
+			fail("mail.smtpPort: invalid port %d", c.Mail.SMTPPort)
+		}
+	default:
+		fail("mail.driver: must be log, file or smtp, got %q", c.Mail.Driver)
+	}
+	if c.Mail.From == "" {
+		fail("mail.from: must be set")
+	}
+	if u, err := url.Parse(c.Mail.AppURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail("mail.appUrl: %q is not an absolute http(s) URL", c.Mail.AppURL)
+	}
+
+	if c.OIDC.Enabled() {
+		if u, err := url.Parse(c.OIDC.Issuer); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			fail("oidc.issuer: %q is not an absolute http(s) URL", c.OIDC.Issuer)
+		} else if u.Scheme != "https" && c.IsProduction() {
+			fail("oidc.issuer: production requires https")
+		}
+		if c.OIDC.ClientID == "" {
+			fail("oidc.clientId: required when oidc.issuer is set")
+		}
+		if u, err := url.Parse(c.OIDCRedirectURL()); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("oidc.redirectUrl: %q is not an absolute http(s) URL", c.OIDCRedirectURL())
+		}
+		if !slices.Contains(c.OIDC.Scopes, "openid") {
+			fail("oidc.scopes: must include openid")
+		}
+	}
+
+	switch c.Seed.Mode {
+	case "", "sample", "off":
+	default:
+		fail("seed.mode: must be sample or off, got %q", c.Seed.Mode)
+	}
+
+	if c.IsProduction() {
+		if c.Auth.JWTSecret == defaultJWTSecret || len(c.Auth.JWTSecret) < minProductionSecretLen {
+			fail("auth.jwtSecret: production requires a random secret of at least %d characters (BORROWHUB_JWT_SECRET)", minProductionSecretLen)
+		}
+		if c.Auth.SigningKey == "" {
+			fail("auth.signingKey: production requires a signing key shared by every instance (BORROWHUB_JWT_SIGNING_KEY or BORROWHUB_JWT_SIGNING_KEY_FILE)")
+		}
+		if c.Payments.RazorpayKeyID == "" || c.Payments.RazorpayKeyID == defaultRazorpayKeyID {
+			fail("payments.razorpayKeyId: production requires a real Razorpay key (RAZORPAY_KEY_ID)")
+		}
+		if c.Mail.Driver != "smtp" {
+			fail("mail.driver: production requires smtp; the %s driver exposes reset links to anyone who can read it", c.Mail.Driver)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// IsProduction reports whether the service runs in the production environment
+func (c *Config) IsProduction() bool {
+	return c.Env == "production"
+}
+
+// IsAllowedOrigin reports whether origin may make credentialed CORS requests
+func (c *Config) IsAllowedOrigin(origin string) bool {
+	for _, allowed := range c.CORS.AllowedOrigins {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultOrigin is the origin advertised when a response carries no CORS headers
+func (c *Config) DefaultOrigin() string {
+	return c.CORS.AllowedOrigins[0]
+}
+
+// Enabled reports whether an OpenID Connect provider is configured
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// OIDCRedirectURL is where the provider sends users back to after signing in
+func (c *Config) OIDCRedirectURL() string {
+	if c.OIDC.RedirectURL != "" {
+		return c.OIDC.RedirectURL
+	}
+	return strings.TrimRight(c.Mail.AppURL, "/") + "/auth/callback"
+}
+
+// splitList parses a comma-separated list, dropping blanks
+func splitList(v string) []string {
+	var list []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
@@ -0,0 +1,96 @@
+// Package ids generates entity identifiers.
+package ids
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+
+	"borrowhub/internal/config"
+)
+
+// Entity prefixes for generated IDs. IDs issued before prefixes existed are
+// plain integers; they are still valid keys, since IDs are opaque strings.
+const (
+	PrefixUser     = "usr_"
+	PrefixItem     = "itm_"
+	PrefixBooking  = "bkg_"
+	PrefixPayment  = "pay_"
+	PrefixImage    = "img_"
+	PrefixOrder    = "order_"
+	PrefixSession  = "ses_"
+	PrefixRefresh  = "rtk_"
+	PrefixOneTime  = "ott_"
+	PrefixBackup   = "bkc_"
+	PrefixIdentity = "idn_"
+	PrefixSecurity = "sev_"
+	PrefixAudit    = "aud_"
+	PrefixAPIKey   = "key_"
+)
+
+// Generator produces globally unique, unguessable IDs that sort by creation time
+type Generator interface {
+	NewID() string
+}
+
+// ulidGenerator issues ULIDs. Within one millisecond it increments the random
+// part by a random amount, so IDs stay ordered without becoming guessable.
+type ulidGenerator struct {
+	mu      sync.Mutex
+	entropy io.Reader
+}
+
+func newULIDGenerator() *ulidGenerator {
+	return &ulidGenerator{entropy: ulid.Monotonic(rand.Reader, 0)}
+}
+
+func (g *ulidGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return ulid.MustNew(ulid.Timestamp(time.Now()), g.entropy).String()
+}
+
+// uuidV7Generator issues time-ordered RFC 9562 UUIDv7 values
+type uuidV7Generator struct{}
+
+func (uuidV7Generator) NewID() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+var (
+	generator Generator = newULIDGenerator()
+	prefixes            = true
+)
+
+// Configure selects the ID format ("ulid" or "uuidv7") and whether entity prefixes are used
+func Configure(cfg config.IDConfig) error {
+	switch cfg.Format {
+	case "ulid":
+		generator = newULIDGenerator()
+	case "uuidv7":
+		generator = uuidV7Generator{}
+	default:
+		return fmt.Errorf("unknown ID format %q (expected ulid or uuidv7)", cfg.Format)
+	}
+	prefixes = cfg.Prefixes
+	return nil
+}
+
+// New returns a new ID for the entity identified by prefix
+func New(prefix string) string {
+	if !prefixes {
+		return generator.NewID()
+	}
+	return prefix + generator.NewID()
+}
+
+// NewOrderID returns a payment gateway order ID. Gateways expect the order
+// prefix regardless of the entity prefix setting.
+func NewOrderID() string {
+	return PrefixOrder + generator.NewID()
+}
@@ -0,0 +1,87 @@
+package ids
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"borrowhub/internal/config"
+)
+
+var formats = map[string]*regexp.Regexp{
+	"ulid":   regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`),
+	"uuidv7": regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+}
+
+// configure applies cfg for the rest of the test, restoring the defaults afterwards
+func configure(t *testing.T, cfg config.IDConfig) {
+	t.Helper()
+	previous, previousPrefixes := generator, prefixes
+	t.Cleanup(func() { generator, prefixes = previous, previousPrefixes })
+	if err := Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for format, pattern := range formats {
+		for _, withPrefixes := range []bool{true, false} {
+			name := format
+			if !withPrefixes {
+				name += " without prefixes"
+			}
+			t.Run(name, func(t *testing.T) {
+				configure(t, config.IDConfig{Format: format, Prefixes: withPrefixes})
+
+				id := New(PrefixItem)
+				bare, prefixed := strings.CutPrefix(id, PrefixItem)
+				if prefixed != withPrefixes {
+					t.Fatalf("ID %q has the prefix %q: %v, want %v", id, PrefixItem, prefixed, withPrefixes)
+				}
+				if !pattern.MatchString(bare) {
+					t.Errorf("ID %q is not a %s", id, format)
+				}
+
+				// Order IDs carry their prefix either way, as gateways expect
+				order := NewOrderID()
+				if bare, ok := strings.CutPrefix(order, PrefixOrder); !ok || !pattern.MatchString(bare) {
+					t.Errorf("order ID %q, want %q and a %s", order, PrefixOrder, format)
+				}
+			})
+		}
+	}
+}
+
+func TestIDsAreUniqueAndOrdered(t *testing.T) {
+	for format := range formats {
+		t.Run(format, func(t *testing.T) {
+			configure(t, config.IDConfig{Format: format, Prefixes: true})
+
+			// Many IDs share a millisecond, so this covers ordering within one
+			const n = 10000
+			ids := make([]string, n)
+			for i := range ids {
+				ids[i] = New(PrefixBooking)
+			}
+			if !slices.IsSorted(ids) {
+				t.Error("IDs do not sort in the order they were issued")
+			}
+			if len(slices.Compact(slices.Clone(ids))) != n {
+				t.Error("an ID was issued twice")
+			}
+		})
+	}
+}
+
+func TestConfigureRejectsUnknownFormat(t *testing.T) {
+	configure(t, config.IDConfig{Format: "ulid", Prefixes: true})
+	if err := Configure(config.IDConfig{Format: "serial"}); err == nil {
+		t.Fatal("Configure accepted an unknown format")
+	}
+	// The previous settings stay in effect
+	id := New(PrefixUser)
+	if bare, ok := strings.CutPrefix(id, PrefixUser); !ok || !formats["ulid"].MatchString(bare) {
+		t.Errorf("ID %q after a rejected configuration, want a prefixed ULID", id)
+	}
+}
@@ -0,0 +1,321 @@
+// Package models holds the domain types shared by the store, the services
+// and the transports. JSON tags define the public API representation.
+package models
+
+import "time"
+
+// Enhanced Item model with all required fields
+type Item struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Title       string     `json:"title"` // Keep for backward compatibility
+	Description string     `json:"description"`
+	DailyRate   float64    `json:"dailyRate"`
+	Price       int        `json:"price"` // Keep for backward compatibility
+	ImageURL    string     `json:"imageUrl"`
+	OwnerID     string     `json:"ownerId"`
+	Available   bool       `json:"available"`
+	Category    string     `json:"category"`              // Slug of a category in the catalog taxonomy; empty if uncategorised
+	Tags        []string   `json:"tags,omitempty"`        // Free-form lower-case labels
+	Attributes  Attributes `json:"attributes,omitempty"`  // Values of the attributes the category defines
+	SuspendedAt *time.Time `json:"suspendedAt,omitempty"` // Set while a moderator has taken the listing down
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// Attributes maps attribute keys to their values: a string, a float64 or a bool
+type Attributes map[string]interface{}
+
+// Suspended reports whether a moderator has taken the listing down
+func (i Item) Suspended() bool {
+	return i.SuspendedAt != nil
+}
+
+// User roles, from least to most privileged
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// IsValidRole reports whether role is one of the known user roles
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// Enhanced User model with profile fields
+type User struct {
+	ID          string     `json:"id"`
+	Username    string     `json:"username"`
+	Email       string     `json:"email"`
+	Password    string     `json:"password,omitempty"` // Allow input but omit in output
+	FirstName   string     `json:"firstName"`
+	LastName    string     `json:"lastName"`
+	Phone       string     `json:"phone"`
+	Address     string     `json:"address"`
+	Verified    bool       `json:"verified"` // Whether the user has confirmed they own Email
+	Role        string     `json:"role"`
+	SuspendedAt *time.Time `json:"suspendedAt,omitempty"` // Set while an administrator has suspended the account
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"`   // Set once the user deleted the account and their personal data was erased
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// Suspended reports whether an administrator has suspended the account
+func (u User) Suspended() bool {
+	return u.SuspendedAt != nil
+}
+
+// Public returns a copy of the user that is safe to send to clients
+func (u User) Public() User {
+	u.Password = ""
+	return u
+}
+
+// Deleted reports whether the user deleted the account
+func (u User) Deleted() bool {
+	return u.DeletedAt != nil
+}
+
+// Booking statuses
+const (
+	BookingPending   = "pending"
+	BookingConfirmed = "confirmed"
+	BookingCompleted = "completed"
+	BookingCancelled = "cancelled"
+)
+
+// Enhanced Booking model with proper relationships and status
+type Booking struct {
+	ID         string    `json:"id"`
+	ItemID     string    `json:"itemId"`
+	UserID     string    `json:"userId"`
+	StartDate  time.Time `json:"startDate"`
+	EndDate    time.Time `json:"endDate"`
+	TotalPrice float64   `json:"totalPrice"`
+	Status     string    `json:"status"` // "pending", "confirmed", "completed", "cancelled"
+	PaymentID  string    `json:"paymentId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// BlocksDates reports whether a booking in this status occupies its dates
+func (b Booking) BlocksDates() bool {
+	return BookingStatusBlocksDates(b.Status)
+}
+
+// BookingStatusBlocksDates reports whether a booking in status occupies its dates
+func BookingStatusBlocksDates(status string) bool {
+	return status != BookingCancelled
+}
+
+// IsActive reports whether the booking is still pending or confirmed
+func (b Booking) IsActive() bool {
+	return b.Status == BookingPending || b.Status == BookingConfirmed
+}
+
+// IsValidBookingStatus reports whether status is one of the known booking statuses
+func IsValidBookingStatus(status string) bool {
+	switch status {
+	case BookingPending, BookingConfirmed, BookingCompleted, BookingCancelled:
+		return true
+	}
+	return false
+}
+
+// Payment model for tracking transactions
+type Payment struct {
+	ID            string    `json:"id"`
+	BookingID     string    `json:"bookingId"`
+	Amount        float64   `json:"amount"`
+	Currency      string    `json:"currency"`
+	Status        string    `json:"status"`              // "pending", "success", "failed", "refunded"
+	PaymentMethod string    `json:"paymentMethod"`       // "razorpay", "card", "upi"
+	GatewayID     string    `json:"gatewayId,omitempty"` // Razorpay payment ID
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// Calendar availability response
+type AvailabilityCalendar struct {
+	Date      string  `json:"date"`
+	Available bool    `json:"available"`
+	Price     float64 `json:"price,omitempty"`
+}
+
+// DateRange is a half-open [Start, End) interval occupied by a booking
+type DateRange struct {
+	Start     time.Time
+	End       time.Time
+	BookingID string
+}
+
+// Overlaps reports whether [start, end) intersects the range
+func (r DateRange) Overlaps(start, end time.Time) bool {
+	return DatesOverlap(start, end, r.Start, r.End)
+}
+
+// DatesOverlap reports whether [aStart, aEnd) and [bStart, bEnd) intersect
+func DatesOverlap(aStart, aEnd, bStart, bEnd time.Time) bool {
+	return aStart.Before(bEnd) && aEnd.After(bStart)
+}
+
+// Session is one login on one device. Its refresh tokens form a single
+// rotation chain (token family); revoking the session ends all of them.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"userId"`
+	UserAgent  string     `json:"userAgent"` // Of the client that signed in or last refreshed
+	IP         string     `json:"ip"`        // Likewise; empty if unknown
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"` // Last request with one of the session's tokens, to within a minute
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Active reports whether the session has not been revoked
+func (s Session) Active() bool {
+	return s.RevokedAt == nil
+}
+
+// RefreshToken is the server-side record of an issued refresh token. Only
+// a hash of the token secret is stored.
+type RefreshToken struct {
+	ID         string     `json:"id"`
+	SessionID  string     `json:"sessionId"`
+	UserID     string     `json:"userId"`
+	TokenHash  string     `json:"tokenHash"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	RotatedAt  *time.Time `json:"rotatedAt,omitempty"` // Set once the token has been exchanged
+	ReplacedBy string     `json:"replacedBy,omitempty"`
+}
+
+// One-time token purposes
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeOIDCState         = "oidc_state" // Not mailed; guards one OpenID Connect login round trip
+)
+
+// OneTimeToken is a single-use, expiring secret mailed to a user, such as a
+// password reset link. Only a hash of the secret is stored.
+type OneTimeToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"userId"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"tokenHash"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+}
+
+// TOTPCredential is a user's authenticator app secret. It protects logins
+// only once Confirmed, i.e. after the user proved their app produces codes.
+type TOTPCredential struct {
+	UserID       string     `json:"userId"`
+	Secret       string     `json:"secret"` // Base32, as shown to the user
+	Confirmed    bool       `json:"confirmed"`
+	LastUsedStep int64      `json:"lastUsedStep"` // Time step of the last accepted code; earlier steps are replays
+	CreatedAt    time.Time  `json:"createdAt"`
+	ConfirmedAt  *time.Time `json:"confirmedAt,omitempty"`
+}
+
+// BackupCode is a single-use recovery code for when the authenticator app is
+// unavailable. Only a hash of the code is stored.
+type BackupCode struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"userId"`
+	CodeHash  string     `json:"codeHash"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+}
+
+// Identity links a user to an account at an external OpenID Connect
+// provider. Provider is the issuer URL and Subject the provider's stable
+// user ID; the pair is unique.
+type Identity struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"` // As reported by the provider when linked
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// LoginThrottle counts the recent failed logins for one email address or one
+// client IP
+type LoginThrottle struct {
+	Key           string    `json:"key"` // "email:<address>" or "ip:<address>"
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"lastFailureAt"`
+}
+
+// Security event types
+const (
+	SecurityLoginFailed      = "login_failed"      // Wrong password or second factor
+	SecurityLoginBlocked     = "login_blocked"     // Attempt refused during a lockout
+	SecurityAccountLocked    = "account_locked"    // Too many failures for one email address
+	SecurityIPLocked         = "ip_locked"         // Too many failures from one client IP
+	SecurityPasswordChanged  = "password_changed"  // Changed by the user, knowing the old one
+	SecurityAccountReclaimed = "account_reclaimed" // Unverified account handed to whoever proved the address through single sign-on
+)
+
+// SecurityEvent is an entry in the security event log
+type SecurityEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	UserID    string    `json:"userId,omitempty"` // Empty when the email address is not registered
+	Email     string    `json:"email,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// APIKey lets a user's scripts call the API without a login. Only a hash of
+// the key is stored; the key itself is shown once, when it is created.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"userId"`
+	Name       string     `json:"name"`
+	KeyHash    string     `json:"keyHash,omitempty"`
+	Scopes     []string   `json:"scopes"`              // What the key may do, e.g. "items:write"
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"` // Never expires when nil
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Public returns a copy of the key that is safe to send to clients
+func (k APIKey) Public() APIKey {
+	k.KeyHash = ""
+	return k
+}
+
+// Active reports whether the key is neither revoked nor expired at now
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// Audit log actions
+const (
+	AuditUserSuspended  = "user_suspended"
+	AuditUserRestored   = "user_restored"
+	AuditRoleChanged    = "role_changed"
+	AuditItemSuspended  = "item_suspended"
+	AuditItemRestored   = "item_restored"
+	AuditBookingStatus  = "booking_status_forced"
+	AuditPaymentsViewed = "payments_viewed"
+)
+
+// AuditEntry records one action taken through the admin API
+type AuditEntry struct {
+	ID         string    `json:"id"`
+	ActorID    string    `json:"actorId"` // User who took the action
+	Action     string    `json:"action"`
+	TargetType string    `json:"targetType"` // "user", "item", "booking" or "payment"
+	TargetID   string    `json:"targetId,omitempty"`
+	Reason     string    `json:"reason,omitempty"`  // Given by the actor
+	Details    string    `json:"details,omitempty"` // What changed, e.g. "pending -> cancelled"
+	CreatedAt  time.Time `json:"createdAt"`
+}
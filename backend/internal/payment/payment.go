@@ -0,0 +1,152 @@
+// Package payment implements payment orders and verification for bookings.
+package payment
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/ids"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// Payment statuses
+const (
+	StatusPending  = "pending"
+	StatusSuccess  = "success"
+	StatusFailed   = "failed"
+	StatusRefunded = "refunded"
+)
+
+// Service creates gateway orders and records their outcome
+type Service struct {
+	store store.Store
+	keyID string // Razorpay key ID handed to the frontend checkout
+}
+
+func NewService(s store.Store, razorpayKeyID string) *Service {
+	return &Service{store: s, keyID: razorpayKeyID}
+}
+
+// Order is a payment awaiting checkout by the frontend
+type Order struct {
+	Payment *models.Payment
+	Booking *models.Booking
+	KeyID   string
+}
+
+// Verification is the reported outcome of a checkout
+type Verification struct {
+	PaymentID         string
+	RazorpayPaymentID string
+	RazorpayOrderID   string
+	RazorpaySignature string
+	Status            string
+}
+
+// CreateOrder starts payment of a pending booking made by userID
+func (s *Service) CreateOrder(ctx context.Context, userID, bookingID string) (*Order, error) {
+	booking, err := s.store.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, lookupError(err, "Booking not found")
+	}
+
+	if booking.UserID != userID {
+		return nil, apperr.New(apperr.Forbidden, "You can only pay for your own bookings")
+	}
+
+	if booking.Status != models.BookingPending {
+		return nil, apperr.New(apperr.Conflict, "Booking is not in pending status")
+	}
+
+	payment := &models.Payment{
+		ID:            ids.New(ids.PrefixPayment),
+		BookingID:     booking.ID,
+		Amount:        booking.TotalPrice,
+		Currency:      "INR",
+		Status:        StatusPending,
+		PaymentMethod: "razorpay",
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	// In a real implementation, you would create Razorpay order here
+	// For now, we'll simulate it
+	payment.GatewayID = ids.NewOrderID()
+
+	if err := s.store.CreatePayment(ctx, payment); err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error creating payment", err)
+	}
+	booking.PaymentID = payment.ID
+	if err := s.store.UpdateBooking(ctx, booking); err != nil {
+		return nil, lookupError(err, "Booking not found")
+	}
+
+	return &Order{Payment: payment, Booking: booking, KeyID: s.keyID}, nil
+}
+
+// Verify records the outcome of a checkout. A successful payment confirms
+// its booking; anything else marks the payment failed.
+func (s *Service) Verify(ctx context.Context, userID string, v Verification) (*models.Payment, *models.Booking, error) {
+	payment, err := s.store.GetPayment(ctx, v.PaymentID)
+	if err != nil {
+		return nil, nil, lookupError(err, "Payment not found")
+	}
+
+	booking, err := s.store.GetBooking(ctx, payment.BookingID)
+	if err != nil {
+		return nil, nil, lookupError(err, "Booking not found")
+	}
+
+	if booking.UserID != userID {
+		return nil, nil, apperr.New(apperr.Forbidden, "You can only verify your own payments")
+	}
+
+	// In a real implementation, you would verify the signature with Razorpay
+	// For now, we'll simulate successful payment
+	if v.Status != StatusSuccess {
+		payment.Status = StatusFailed
+		payment.UpdatedAt = time.Now()
+
+		if err := s.store.UpdatePayment(ctx, payment); err != nil {
+			return nil, nil, lookupError(err, "Payment not found")
+		}
+		return payment, booking, nil
+	}
+
+	payment.Status = StatusSuccess
+	payment.GatewayID = v.RazorpayPaymentID
+	payment.UpdatedAt = time.Now()
+
+	if err := s.store.UpdatePayment(ctx, payment); err != nil {
+		return nil, nil, lookupError(err, "Payment not found")
+	}
+	// A booking cancelled since checkout is only confirmed if its dates are still free
+	booking, _, err = s.store.SetBookingStatus(ctx, booking.ID, models.BookingConfirmed, time.Now())
+	if errors.Is(err, store.ErrBookingConflict) {
+		return nil, nil, apperr.New(apperr.Conflict, "The booking's dates have been taken by another booking")
+	}
+	if err != nil {
+		return nil, nil, lookupError(err, "Booking not found")
+	}
+	return payment, booking, nil
+}
+
+// History returns the payments for bookings made by userID
+func (s *Service) History(ctx context.Context, userID string) ([]*models.Payment, error) {
+	payments, err := s.store.ListPayments(ctx, store.PaymentFilter{UserID: userID})
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Internal, "Error loading payments", err)
+	}
+	return payments, nil
+}
+
+// lookupError maps store.ErrNotFound to a NotFound error with message
+func lookupError(err error, notFoundMessage string) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return apperr.Wrap(apperr.NotFound, notFoundMessage, err)
+	}
+	return apperr.Wrap(apperr.Internal, "Internal server error", err)
+}
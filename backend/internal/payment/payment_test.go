@@ -0,0 +1,126 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// newTestService returns a Service over a store holding a pending booking
+// of itm_tent by usr_renter
+func newTestService(t *testing.T) (*Service, *store.Database, *models.Booking) {
+	t.Helper()
+	db := store.NewDatabase()
+	ctx := context.Background()
+	if err := db.CreateItem(ctx, &models.Item{ID: "itm_tent", Name: "Tent", DailyRate: 10, OwnerID: "usr_owner", Available: true}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 7)
+	booking := &models.Booking{
+		ID: "bkg_tent", ItemID: "itm_tent", UserID: "usr_renter",
+		StartDate: start, EndDate: start.AddDate(0, 0, 3), TotalPrice: 30, Status: models.BookingPending,
+	}
+	if err := db.CreateBooking(ctx, booking); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(db, "rzp_test_key"), db, booking
+}
+
+func TestCreateOrder(t *testing.T) {
+	s, db, booking := newTestService(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		userID    string
+		bookingID string
+		want      apperr.Kind
+	}{
+		{"unknown booking", "usr_renter", "bkg_missing", apperr.NotFound},
+		{"someone else's booking", "usr_owner", booking.ID, apperr.Forbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.CreateOrder(ctx, tt.userID, tt.bookingID); apperr.KindOf(err) != tt.want {
+				t.Errorf("CreateOrder: %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	order, err := s.CreateOrder(ctx, "usr_renter", booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.KeyID != "rzp_test_key" || order.Payment.Amount != 30 || order.Payment.Status != StatusPending || order.Payment.GatewayID == "" {
+		t.Errorf("order %+v, payment %+v", order, order.Payment)
+	}
+	if b, err := db.GetBooking(ctx, booking.ID); err != nil || b.PaymentID != order.Payment.ID {
+		t.Errorf("booking %+v (err %v), want it linked to %s", b, err, order.Payment.ID)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	s, db, booking := newTestService(t)
+	ctx := context.Background()
+	order, err := s.CreateOrder(ctx, "usr_renter", booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.Verify(ctx, "usr_owner", Verification{PaymentID: order.Payment.ID, Status: StatusSuccess}); apperr.KindOf(err) != apperr.Forbidden {
+		t.Errorf("Verify of someone else's payment: %v, want Forbidden", err)
+	}
+	if _, _, err := s.Verify(ctx, "usr_renter", Verification{PaymentID: "pay_missing"}); apperr.KindOf(err) != apperr.NotFound {
+		t.Errorf("Verify of an unknown payment: %v, want NotFound", err)
+	}
+
+	// A failed checkout leaves the booking pending
+	payment, b, err := s.Verify(ctx, "usr_renter", Verification{PaymentID: order.Payment.ID, Status: StatusFailed})
+	if err != nil || payment.Status != StatusFailed || b.Status != models.BookingPending {
+		t.Errorf("failed checkout: payment %+v, booking %+v, %v", payment, b, err)
+	}
+
+	payment, b, err = s.Verify(ctx, "usr_renter", Verification{PaymentID: order.Payment.ID, RazorpayPaymentID: "pay_gw_1", Status: StatusSuccess})
+	if err != nil || payment.Status != StatusSuccess || payment.GatewayID != "pay_gw_1" || b.Status != models.BookingConfirmed {
+		t.Errorf("successful checkout: payment %+v, booking %+v, %v", payment, b, err)
+	}
+	history, err := s.History(ctx, "usr_renter")
+	if err != nil || len(history) != 1 || history[0].ID != order.Payment.ID {
+		t.Errorf("History: %+v, %v", history, err)
+	}
+	if stored, err := db.GetBooking(ctx, booking.ID); err != nil || stored.Status != models.BookingConfirmed {
+		t.Errorf("stored booking %+v (err %v), want it confirmed", stored, err)
+	}
+}
+
+func TestVerifyRefusesTakenDates(t *testing.T) {
+	s, db, booking := newTestService(t)
+	ctx := context.Background()
+	order, err := s.CreateOrder(ctx, "usr_renter", booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The booking is cancelled during checkout and its dates rebooked
+	if _, _, err := db.SetBookingStatus(ctx, booking.ID, models.BookingCancelled, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	other := &models.Booking{
+		ID: "bkg_other", ItemID: "itm_tent", UserID: "usr_other",
+		StartDate: booking.StartDate, EndDate: booking.EndDate, TotalPrice: 30, Status: models.BookingPending,
+	}
+	if err := db.CreateBooking(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.Verify(ctx, "usr_renter", Verification{PaymentID: order.Payment.ID, Status: StatusSuccess}); apperr.KindOf(err) != apperr.Conflict {
+		t.Errorf("Verify over taken dates: %v, want Conflict", err)
+	}
+	if b, err := db.GetBooking(ctx, booking.ID); err != nil || b.Status != models.BookingCancelled {
+		t.Errorf("booking %+v (err %v), want it still cancelled", b, err)
+	}
+}
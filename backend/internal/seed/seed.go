@@ -0,0 +1,289 @@
+// Package seed loads fixture data into a store.
+package seed
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+
+	"borrowhub/internal/catalog"
+	"borrowhub/internal/config"
+	"borrowhub/internal/ids"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+//go:embed sample.yaml
+var sampleFixture []byte
+
+// SeedFixture is the YAML (or JSON) document describing seed data
+type SeedFixture struct {
+	Users []SeedUser `yaml:"users"`
+	Items []SeedItem `yaml:"items"`
+}
+
+// SeedUser is matched to existing users by email, or by ID when one is given
+type SeedUser struct {
+	ID        string `yaml:"id"`
+	Username  string `yaml:"username"`
+	Email     string `yaml:"email"`
+	Password  string `yaml:"password"`
+	FirstName string `yaml:"firstName"`
+	LastName  string `yaml:"lastName"`
+	Phone     string `yaml:"phone"`
+	Address   string `yaml:"address"`
+	Role      string `yaml:"role"` // user (the default), moderator or admin
+}
+
+// SeedItem is matched by ID when one is given, otherwise by owner and name
+type SeedItem struct {
+	ID          string  `yaml:"id"`
+	Name        string  `yaml:"name"`
+	Description string  `yaml:"description"`
+	DailyRate   float64 `yaml:"dailyRate"`
+	ImageURL    string  `yaml:"imageUrl"`
+	Owner       string  `yaml:"owner"` // Email of a user in the fixture or the store
+	Available   *bool   `yaml:"available"`
+
+	Category   string            `yaml:"category"` // Slug in the category taxonomy
+	Tags       []string          `yaml:"tags"`
+	Attributes models.Attributes `yaml:"attributes"`
+}
+
+// loadFixture reads the fixture selected by the seed configuration.
+// It returns nil when seeding is disabled. Outside production the built-in
+// sample catalog is used by default; in production seeding is opt-in.
+func loadFixture(cfg config.SeedConfig, production bool) (*SeedFixture, string, error) {
+	mode := cfg.Mode
+	path := cfg.File
+
+	var data []byte
+	source := path
+	switch {
+	case mode == "off":
+		return nil, "", nil
+	case path != "":
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, "", fmt.Errorf("read seed file: %w", err)
+		}
+	case mode == "sample" || (mode == "" && !production):
+		data, source = sampleFixture, "built-in sample data"
+	default:
+		return nil, "", nil
+	}
+
+	var fixture SeedFixture
+	if err := yaml.Unmarshal(data, &fixture); err != nil {
+		return nil, "", fmt.Errorf("parse seed data from %s: %w", source, err)
+	}
+	return &fixture, source, nil
+}
+
+// Run loads the configured fixture into s, checking items against taxonomy
+// and hashing passwords at bcryptCost. Missing users and items are created;
+// existing ones are only updated when cfg.Overwrite is set, and deleted
+// accounts are never revived. Running it again with the same fixture leaves
+// the store unchanged.
+func Run(ctx context.Context, s store.Store, taxonomy *catalog.Taxonomy, cfg config.SeedConfig, bcryptCost int, production bool) error {
+	fixture, source, err := loadFixture(cfg, production)
+	if err != nil || fixture == nil {
+		return err
+	}
+	// The built-in sample loads by default wherever the environment is not
+	// production, so it may only hold regular accounts
+	if cfg.File == "" {
+		for _, su := range fixture.Users {
+			if su.Role != "" && su.Role != models.RoleUser {
+				return fmt.Errorf("seed user %s: role %s can only be seeded from a fixture file (BORROWHUB_SEED_FILE)", su.Email, su.Role)
+			}
+		}
+	}
+
+	owners := make(map[string]string) // Normalised email -> user ID
+	deleted := make(map[string]bool)  // Normalised emails of deleted accounts
+	var written []SeedUser            // Users whose fixture password is now theirs
+	for _, su := range fixture.Users {
+		user, wrote, err := seedUser(ctx, s, su, cfg.Overwrite, bcryptCost)
+		if err != nil {
+			return fmt.Errorf("seed user %s: %w", su.Email, err)
+		}
+		if user.Deleted() {
+			log.Printf("Not seeding %s or their items: the account was deleted", su.Email)
+			deleted[normalizeEmail(su.Email)] = true
+			continue
+		}
+		owners[normalizeEmail(su.Email)] = user.ID
+		if wrote {
+			written = append(written, su)
+		}
+	}
+
+	seededItems := 0
+	for _, si := range fixture.Items {
+		if deleted[normalizeEmail(si.Owner)] {
+			continue
+		}
+		ownerID, ok := owners[normalizeEmail(si.Owner)]
+		if !ok {
+			owner, err := s.GetUserByEmail(ctx, normalizeEmail(si.Owner))
+			if err != nil {
+				return fmt.Errorf("seed item %s: owner %s: %w", si.Name, si.Owner, err)
+			}
+			ownerID = owner.ID
+		}
+		wrote, err := seedItem(ctx, s, taxonomy, si, ownerID, cfg.Overwrite)
+		if err != nil {
+			return fmt.Errorf("seed item %s: %w", si.Name, err)
+		}
+		if wrote {
+			seededItems++
+		}
+	}
+
+	log.Printf("Seeded %d users and %d items from %s", len(written), seededItems, source)
+	// Fixture passwords are only ever echoed for local development, and only
+	// for users that now have them
+	if !production {
+		for _, su := range written {
+			fmt.Printf("- %s / %s\n", su.Email, su.Password)
+		}
+	}
+	return nil
+}
+
+// seedUser creates the user su describes if it does not exist yet. An
+// existing user is returned as it is, unless overwrite is set and it has not
+// been deleted, in which case it is updated to match su. wrote reports
+// whether the store was written to. Passwords are hashed at bcryptCost.
+func seedUser(ctx context.Context, s store.Store, su SeedUser, overwrite bool, bcryptCost int) (user *models.User, wrote bool, err error) {
+	su.Email = normalizeEmail(su.Email)
+	if su.Email == "" || su.Password == "" {
+		return nil, false, errors.New("email and password are required")
+	}
+	if su.Role != "" && !models.IsValidRole(su.Role) {
+		return nil, false, fmt.Errorf("unknown role %q", su.Role)
+	}
+
+	// A deleted account keeps its ID but not its email, so look it up by ID
+	// first; otherwise the fixture would create it again
+	if su.ID != "" {
+		user, err = s.GetUser(ctx, su.ID)
+	}
+	if su.ID == "" || errors.Is(err, store.ErrNotFound) {
+		user, err = s.GetUserByEmail(ctx, su.Email)
+	}
+	exists := err == nil
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, false, err
+	}
+	if exists && (!overwrite || user.Deleted()) {
+		return user, false, nil
+	}
+	if !exists {
+		id := su.ID
+		if id == "" {
+			id = ids.New(ids.PrefixUser)
+		}
+		user = &models.User{ID: id, Email: su.Email, Role: models.RoleUser, CreatedAt: time.Now()}
+	}
+
+	user.Username = su.Username
+	user.FirstName = su.FirstName
+	user.LastName = su.LastName
+	user.Phone = su.Phone
+	user.Address = su.Address
+	// Without a role in the fixture, one given through the admin API is kept
+	if su.Role != "" {
+		user.Role = su.Role
+	}
+	user.Verified = true // Fixture addresses are not mailed, so they are trusted as given
+	// Hashes are salted, so only re-hash when the stored one no longer matches
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(su.Password)) != nil {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(su.Password), bcryptCost)
+		if err != nil {
+			return nil, false, err
+		}
+		user.Password = string(hashed)
+	}
+
+	if exists {
+		return user, true, s.UpdateUser(ctx, user)
+	}
+	return user, true, s.CreateUser(ctx, user)
+}
+
+// seedItem creates the item si describes for ownerID if it does not exist
+// yet. An existing item is left as it is, owner's edits included, unless
+// overwrite is set, in which case it is updated to match si. wrote reports
+// whether the store was written to.
+func seedItem(ctx context.Context, s store.Store, taxonomy *catalog.Taxonomy, si SeedItem, ownerID string, overwrite bool) (wrote bool, err error) {
+	if si.Name == "" || si.DailyRate <= 0 {
+		return false, errors.New("name and daily rate are required")
+	}
+
+	var item *models.Item
+	if si.ID != "" {
+		existing, err := s.GetItem(ctx, si.ID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return false, err
+		}
+		item = existing
+	} else {
+		owned, err := s.ListItems(ctx, store.ItemFilter{OwnerID: ownerID})
+		if err != nil {
+			return false, err
+		}
+		for _, candidate := range owned {
+			if candidate.Name == si.Name {
+				item = candidate
+				break
+			}
+		}
+	}
+
+	exists := item != nil
+	if exists && !overwrite {
+		return false, nil
+	}
+	if !exists {
+		id := si.ID
+		if id == "" {
+			id = ids.New(ids.PrefixItem)
+		}
+		item = &models.Item{ID: id, CreatedAt: time.Now()}
+	}
+
+	item.Name = si.Name
+	item.Title = si.Name // Backward compatibility
+	item.Description = si.Description
+	item.DailyRate = si.DailyRate
+	item.Price = int(si.DailyRate) // Backward compatibility
+	item.ImageURL = si.ImageURL
+	item.OwnerID = ownerID
+	item.Available = si.Available == nil || *si.Available
+	item.Category = si.Category
+	item.Tags = si.Tags
+	item.Attributes = si.Attributes
+	if err := taxonomy.Classify(item); err != nil {
+		return false, err
+	}
+
+	if exists {
+		return true, s.UpdateItem(ctx, item)
+	}
+	return true, s.CreateItem(ctx, item)
+}
+
+// normalizeEmail puts email in the form stored and looked up by logins
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
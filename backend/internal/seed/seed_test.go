@@ -0,0 +1,206 @@
+package seed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"borrowhub/internal/catalog"
+	"borrowhub/internal/config"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+const testFixture = `
+users:
+  - id: usr_ana
+    username: ana
+    email: Ana@Example.com
+    password: fixture-password
+    firstName: Ana
+items:
+  - id: itm_tent
+    name: Tent
+    description: Sleeps four
+    dailyRate: 10
+    owner: ana@example.com
+  - name: Stove
+    dailyRate: 5
+    owner: ana@example.com
+    available: false
+`
+
+// testCost differs from bcrypt.DefaultCost so tests see which one was used
+const testCost = bcrypt.MinCost + 1
+
+// writeFixture saves fixture to a file and returns a configuration seeding from it
+func writeFixture(t *testing.T, fixture string, overwrite bool) config.SeedConfig {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.yaml")
+	if err := os.WriteFile(path, []byte(fixture), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return config.SeedConfig{File: path, Overwrite: overwrite}
+}
+
+func run(t *testing.T, db store.Store, cfg config.SeedConfig) {
+	t.Helper()
+	taxonomy, err := catalog.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(context.Background(), db, taxonomy, cfg, testCost, true); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func TestRunCreatesMissingRecords(t *testing.T) {
+	db := store.NewDatabase()
+	ctx := context.Background()
+	run(t, db, writeFixture(t, testFixture, false))
+
+	user, err := db.GetUserByEmail(ctx, "ana@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != "usr_ana" || user.FirstName != "Ana" || !user.Verified || user.Role != models.RoleUser {
+		t.Errorf("seeded user %+v", user)
+	}
+	if cost, err := bcrypt.Cost([]byte(user.Password)); err != nil || cost != testCost {
+		t.Errorf("password hashed at cost %d (%v), want the configured %d", cost, err, testCost)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("fixture-password")) != nil {
+		t.Error("seeded password does not match the fixture")
+	}
+
+	items, err := db.ListItems(ctx, store.ItemFilter{OwnerID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("seeded %d items, want 2", len(items))
+	}
+	for _, item := range items {
+		if item.Available != (item.ID == "itm_tent") {
+			t.Errorf("item %s available: %v", item.Name, item.Available)
+		}
+	}
+
+	// Running again changes nothing, and matches the item without an ID by name
+	run(t, db, writeFixture(t, testFixture, false))
+	again, err := db.GetUser(ctx, user.ID)
+	if err != nil || again.Password != user.Password {
+		t.Errorf("second run re-hashed the password: %v", err)
+	}
+	if items, _ := db.ListItems(ctx, store.ItemFilter{OwnerID: user.ID}); len(items) != 2 {
+		t.Errorf("%d items after a second run, want 2", len(items))
+	}
+}
+
+func TestRunOverwrite(t *testing.T) {
+	for _, overwrite := range []bool{false, true} {
+		t.Run(map[bool]string{false: "keep", true: "overwrite"}[overwrite], func(t *testing.T) {
+			db := store.NewDatabase()
+			ctx := context.Background()
+			run(t, db, writeFixture(t, testFixture, false))
+
+			// The owner edits their profile and items after the first start
+			user, err := db.GetUser(ctx, "usr_ana")
+			if err != nil {
+				t.Fatal(err)
+			}
+			user.FirstName = "Anabel"
+			user.Role = models.RoleModerator
+			if err := db.UpdateUser(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+			item, err := db.GetItem(ctx, "itm_tent")
+			if err != nil {
+				t.Fatal(err)
+			}
+			item.Description = "Sleeps six"
+			item.DailyRate = 12
+			if err := db.UpdateItem(ctx, item); err != nil {
+				t.Fatal(err)
+			}
+
+			run(t, db, writeFixture(t, strings.Replace(testFixture, "fixture-password", "new-password", 1), overwrite))
+
+			user, err = db.GetUser(ctx, "usr_ana")
+			if err != nil {
+				t.Fatal(err)
+			}
+			item, err = db.GetItem(ctx, "itm_tent")
+			if err != nil {
+				t.Fatal(err)
+			}
+			newPassword := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("new-password")) == nil
+			if overwrite {
+				if user.FirstName != "Ana" || !newPassword || item.Description != "Sleeps four" || item.DailyRate != 10 {
+					t.Errorf("after overwriting: user %s (new password %v), item %q at %v, want the fixture's",
+						user.FirstName, newPassword, item.Description, item.DailyRate)
+				}
+			} else if user.FirstName != "Anabel" || newPassword || item.Description != "Sleeps six" || item.DailyRate != 12 {
+				t.Errorf("without overwriting: user %s (new password %v), item %q at %v, want the owner's edits kept",
+					user.FirstName, newPassword, item.Description, item.DailyRate)
+			}
+			// Without a role in the fixture, the one given later is kept either way
+			if user.Role != models.RoleModerator {
+				t.Errorf("role %s, want the moderator role kept", user.Role)
+			}
+		})
+	}
+}
+
+func TestRunSkipsDeletedAccounts(t *testing.T) {
+	db := store.NewDatabase()
+	ctx := context.Background()
+	run(t, db, writeFixture(t, testFixture, false))
+
+	// Deleting the account frees the email and retires the never-booked items
+	now := time.Now()
+	erased := &models.User{ID: "usr_ana", Username: "deleted-user", Email: "usr_ana@deleted.invalid", Role: models.RoleUser, DeletedAt: &now}
+	if err := db.EraseUser(ctx, erased, now); err != nil {
+		t.Fatal(err)
+	}
+
+	run(t, db, writeFixture(t, testFixture, true))
+	if _, err := db.GetUserByEmail(ctx, "ana@example.com"); err == nil {
+		t.Error("the deleted account was seeded again")
+	}
+	if items, _ := db.ListItems(ctx, store.ItemFilter{}); len(items) != 0 {
+		t.Errorf("%d items of the deleted account were seeded again", len(items))
+	}
+}
+
+func TestRunRefusesStaffInSample(t *testing.T) {
+	fixture := strings.Replace(testFixture, "firstName: Ana", "firstName: Ana\n    role: admin", 1)
+	taxonomy, err := catalog.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// From a fixture file, staff accounts are fine
+	db := store.NewDatabase()
+	run(t, db, writeFixture(t, fixture, false))
+	if user, err := db.GetUser(context.Background(), "usr_ana"); err != nil || user.Role != models.RoleAdmin {
+		t.Errorf("user seeded from a file: %+v, %v, want an admin", user, err)
+	}
+
+	// The built-in sample, which loads by default, may not hold any
+	original := sampleFixture
+	sampleFixture = []byte(fixture)
+	t.Cleanup(func() { sampleFixture = original })
+	db = store.NewDatabase()
+	if err := Run(context.Background(), db, taxonomy, config.SeedConfig{}, testCost, false); err == nil || !strings.Contains(err.Error(), "role admin") {
+		t.Errorf("Run with an admin in the sample: %v, want refused", err)
+	}
+	if users, _ := db.ListUsers(context.Background()); len(users) != 0 {
+		t.Errorf("%d users seeded from a refused sample", len(users))
+	}
+}
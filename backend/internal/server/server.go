@@ -0,0 +1,139 @@
+// Package server runs an http.Handler with timeouts and a graceful,
+// hook-driven shutdown.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"borrowhub/internal/config"
+)
+
+// Hook is run once during shutdown, after the listener has stopped accepting requests
+type Hook func(ctx context.Context) error
+
+type namedHook struct {
+	name string
+	fn   Hook
+}
+
+// Server is an HTTP server whose shutdown drains in-flight requests and
+// then runs registered hooks
+type Server struct {
+	http            *http.Server
+	shutdownTimeout time.Duration
+
+	mu    sync.Mutex
+	hooks []namedHook
+}
+
+func New(cfg config.ServerConfig, handler http.Handler) *Server {
+	return &Server{
+		http: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		},
+		shutdownTimeout: cfg.ShutdownTimeout,
+	}
+}
+
+// OnShutdown registers a hook. Hooks run in reverse registration order, like
+// deferred calls, so something registered after its dependencies stops first.
+func (s *Server) OnShutdown(name string, fn Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, namedHook{name: name, fn: fn})
+}
+
+// Go runs a background worker until shutdown. Its context is cancelled when
+// the shutdown hooks run, and shutdown waits for run to return.
+func (s *Server) Go(name string, run func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(ctx)
+	}()
+
+	s.OnShutdown(name, func(shutdownCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-shutdownCtx.Done():
+			return fmt.Errorf("worker did not stop: %w", shutdownCtx.Err())
+		}
+	})
+}
+
+// Run serves until ctx is cancelled, typically by SIGINT or SIGTERM, then
+// stops accepting connections, waits for in-flight requests and runs the
+// shutdown hooks, all within the configured shutdown timeout
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve is Run on an existing listener
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.http.Serve(ln)
+	}()
+
+	var errs []error
+	select {
+	case err := <-serveErr:
+		// The listener failed on its own; still give the hooks a chance to run
+		errs = append(errs, fmt.Errorf("serve: %w", err))
+		serveErr = nil
+	case <-ctx.Done():
+		log.Printf("Shutting down, draining requests for up to %s", s.shutdownTimeout)
+	}
+
+	// The parent context is already cancelled, so the deadline needs a fresh one
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.shutdownTimeout)
+	defer cancel()
+
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("drain requests: %w", err))
+	}
+	if serveErr != nil {
+		if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+			errs = append(errs, fmt.Errorf("serve: %w", err))
+		}
+	}
+
+	errs = append(errs, s.runHooks(shutdownCtx)...)
+	return errors.Join(errs...)
+}
+
+func (s *Server) runHooks(ctx context.Context) []error {
+	s.mu.Lock()
+	hooks := append([]namedHook(nil), s.hooks...)
+	s.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if err := h.fn(ctx); err != nil {
+			log.Printf("Shutdown hook %s failed: %v", h.name, err)
+			errs = append(errs, fmt.Errorf("shutdown hook %s: %w", h.name, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"borrowhub/internal/config"
+)
+
+// start serves s on a loopback port until the returned cancel is
+// called; the channel yields what Serve returned
+func start(t *testing.T, s *Server) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+	return "http://" + ln.Addr().String(), cancel, done
+}
+
+// wait returns what Serve returned, failing the test if it does not stop
+func wait(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return")
+		return nil
+	}
+}
+
+func testConfig(shutdownTimeout time.Duration) config.ServerConfig {
+	cfg := config.Default().Server
+	cfg.ShutdownTimeout = shutdownTimeout
+	return cfg
+}
+
+func TestNewAppliesLimits(t *testing.T) {
+	cfg := config.ServerConfig{
+		Addr:              ":8081",
+		ReadTimeout:       time.Second,
+		ReadHeaderTimeout: 2 * time.Second,
+		WriteTimeout:      3 * time.Second,
+		IdleTimeout:       4 * time.Second,
+		MaxHeaderBytes:    1 << 12,
+		ShutdownTimeout:   5 * time.Second,
+	}
+	s := New(cfg, http.NotFoundHandler())
+	if s.http.Addr != cfg.Addr || s.http.ReadTimeout != cfg.ReadTimeout || s.http.ReadHeaderTimeout != cfg.ReadHeaderTimeout ||
+		s.http.WriteTimeout != cfg.WriteTimeout || s.http.IdleTimeout != cfg.IdleTimeout || s.http.MaxHeaderBytes != cfg.MaxHeaderBytes {
+		t.Errorf("http.Server %+v does not match %+v", s.http, cfg)
+	}
+	if s.shutdownTimeout != cfg.ShutdownTimeout {
+		t.Errorf("shutdown timeout %v, want %v", s.shutdownTimeout, cfg.ShutdownTimeout)
+	}
+}
+
+func TestShutdownDrainsRequestsThenRunsHooks(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	s := New(testConfig(5*time.Second), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	}))
+	var order []string
+	s.OnShutdown("store", func(context.Context) error { order = append(order, "store"); return nil })
+	s.OnShutdown("worker", func(context.Context) error { order = append(order, "worker"); return nil })
+
+	url, cancel, done := start(t, s)
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+	<-started
+
+	// Shutdown waits for the request in flight
+	cancel()
+	select {
+	case err := <-done:
+		t.Fatalf("Serve returned with a request in flight: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if len(order) != 0 {
+		t.Errorf("hooks %v ran before the request finished", order)
+	}
+	close(release)
+	if got := <-response; got != "done" {
+		t.Errorf("in-flight request got %q, want it completed", got)
+	}
+	if err := wait(t, done); err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+	if !slices.Equal(order, []string{"worker", "store"}) {
+		t.Errorf("hooks ran in order %v, want the last registered first", order)
+	}
+
+	// New connections are refused once shut down
+	if _, err := http.Get(url); err == nil {
+		t.Error("the server still accepts requests")
+	}
+}
+
+func TestShutdownReportsEveryFailure(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	s := New(testConfig(50*time.Millisecond), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	ran := false
+	s.OnShutdown("flush", func(context.Context) error { ran = true; return nil })
+	s.OnShutdown("broken", func(context.Context) error { return errors.New("disk full") })
+
+	url, cancel, done := start(t, s)
+	go http.Get(url)
+	<-started
+	cancel()
+
+	// A request outlasting the timeout and a failing hook are both reported,
+	// and the remaining hooks still run
+	err := wait(t, done)
+	for _, want := range []string{"drain requests", "shutdown hook broken: disk full"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Serve: %v, want an error containing %q", err, want)
+		}
+	}
+	if !ran {
+		t.Error("a hook after the failing one did not run")
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	s := New(testConfig(50*time.Millisecond), http.NotFoundHandler())
+	// Hooks share one deadline, so the stuck worker is registered first to stop last
+	stuck := make(chan struct{})
+	defer close(stuck)
+	s.Go("stuck", func(context.Context) { <-stuck })
+	stopped := make(chan struct{})
+	s.Go("polite", func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+
+	_, cancel, done := start(t, s)
+	cancel()
+	err := wait(t, done)
+	select {
+	case <-stopped:
+	default:
+		t.Error("the worker's context was not cancelled")
+	}
+	if err == nil || !strings.Contains(err.Error(), "shutdown hook stuck: worker did not stop") || strings.Contains(err.Error(), "polite") {
+		t.Errorf("Serve: %v, want only the stuck worker reported", err)
+	}
+}
+
+func TestServeFailureStillRunsHooks(t *testing.T) {
+	s := New(testConfig(time.Second), http.NotFoundHandler())
+	ran := false
+	s.OnShutdown("flush", func(context.Context) error { ran = true; return nil })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	if err := s.Serve(context.Background(), ln); err == nil || !strings.Contains(err.Error(), "serve:") {
+		t.Errorf("Serve on a closed listener: %v", err)
+	}
+	if !ran {
+		t.Error("hooks did not run after the listener failed")
+	}
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"borrowhub/internal/models"
+)
+
+// itemIntervals is an immutable, start-sorted list of an item's active bookings.
+// maxEnd[i] holds the latest End among ranges[0..i], which keeps overlap queries
+// correct even if an owner re-activates a booking that overlaps another.
+type itemIntervals struct {
+	ranges []models.DateRange
+	maxEnd []time.Time
+}
+
+func newItemIntervals(ranges []models.DateRange) *itemIntervals {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start.Before(ranges[j].Start) })
+	maxEnd := make([]time.Time, len(ranges))
+	for i, r := range ranges {
+		maxEnd[i] = r.End
+		if i > 0 && maxEnd[i-1].After(r.End) {
+			maxEnd[i] = maxEnd[i-1]
+		}
+	}
+	return &itemIntervals{ranges: ranges, maxEnd: maxEnd}
+}
+
+// overlaps reports whether any range intersects [start, end) in O(log n)
+func (iv *itemIntervals) overlaps(start, end time.Time) bool {
+	// Ranges from n onwards start at or after end, so cannot overlap
+	n := sort.Search(len(iv.ranges), func(i int) bool { return !iv.ranges[i].Start.Before(end) })
+	return n > 0 && iv.maxEnd[n-1].After(start)
+}
+
+// between returns the ranges intersecting [from, to)
+func (iv *itemIntervals) between(from, to time.Time) []models.DateRange {
+	n := sort.Search(len(iv.ranges), func(i int) bool { return !iv.ranges[i].Start.Before(to) })
+	result := make([]models.DateRange, 0)
+	for _, r := range iv.ranges[:n] {
+		if r.End.After(from) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// availabilityIndex maps item IDs to their booked intervals. Readers never
+// lock: each item's list is replaced wholesale (copy-on-write) by writers,
+// which are serialised by the owning store's mutex.
+type availabilityIndex struct {
+	items sync.Map // itemID -> *atomic.Pointer[itemIntervals]
+}
+
+func (x *availabilityIndex) load(itemID string) *itemIntervals {
+	if p, ok := x.items.Load(itemID); ok {
+		if iv := p.(*atomic.Pointer[itemIntervals]).Load(); iv != nil {
+			return iv
+		}
+	}
+	return newItemIntervals(nil)
+}
+
+func (x *availabilityIndex) store(itemID string, iv *itemIntervals) {
+	p, _ := x.items.LoadOrStore(itemID, &atomic.Pointer[itemIntervals]{})
+	p.(*atomic.Pointer[itemIntervals]).Store(iv)
+}
+
+// isAvailable reports whether no indexed booking overlaps [start, end)
+func (x *availabilityIndex) isAvailable(itemID string, start, end time.Time) bool {
+	return !x.load(itemID).overlaps(start, end)
+}
+
+// put indexes booking if its status blocks dates, replacing any earlier entry for the same booking
+func (x *availabilityIndex) put(booking *models.Booking) {
+	current := x.load(booking.ItemID).ranges
+	ranges := make([]models.DateRange, 0, len(current)+1)
+	for _, r := range current {
+		if r.BookingID != booking.ID {
+			ranges = append(ranges, r)
+		}
+	}
+	if models.BookingStatusBlocksDates(booking.Status) {
+		ranges = append(ranges, models.DateRange{Start: booking.StartDate, End: booking.EndDate, BookingID: booking.ID})
+	}
+	x.store(booking.ItemID, newItemIntervals(ranges))
+}
+
+// remove drops a booking from an item's intervals
+func (x *availabilityIndex) remove(itemID, bookingID string) {
+	current := x.load(itemID).ranges
+	ranges := make([]models.DateRange, 0, len(current))
+	for _, r := range current {
+		if r.BookingID != bookingID {
+			ranges = append(ranges, r)
+		}
+	}
+	x.store(itemID, newItemIntervals(ranges))
+}
+
+// rebuild replaces the whole index from a set of bookings
+func (x *availabilityIndex) rebuild(bookings map[string]*models.Booking) {
+	byItem := make(map[string][]models.DateRange)
+	for _, booking := range bookings {
+		if models.BookingStatusBlocksDates(booking.Status) {
+			byItem[booking.ItemID] = append(byItem[booking.ItemID],
+				models.DateRange{Start: booking.StartDate, End: booking.EndDate, BookingID: booking.ID})
+		}
+	}
+	x.items.Range(func(key, _ interface{}) bool {
+		x.items.Delete(key)
+		return true
+	})
+	for itemID, ranges := range byItem {
+		x.store(itemID, newItemIntervals(ranges))
+	}
+}
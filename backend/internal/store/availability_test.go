@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"borrowhub/internal/models"
+)
+
+var testEpoch = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// randomRange returns a range of one hour to five days within the first 60 days after testEpoch
+func randomRange(rng *rand.Rand) (time.Time, time.Time) {
+	start := testEpoch.Add(time.Duration(rng.Intn(60*24)) * time.Hour)
+	return start, start.Add(time.Duration(1+rng.Intn(5*24)) * time.Hour)
+}
+
+// bruteForceOverlaps returns the IDs of the blocking bookings of itemID that
+// overlap start to end, by checking every booking
+func bruteForceOverlaps(bookings map[string]*models.Booking, itemID string, start, end time.Time) []string {
+	var ids []string
+	for _, b := range bookings {
+		if b.ItemID == itemID && b.BlocksDates() && models.DatesOverlap(b.StartDate, b.EndDate, start, end) {
+			ids = append(ids, b.ID)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestAvailabilityIndexMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	statuses := []string{models.BookingPending, models.BookingConfirmed, models.BookingCompleted, models.BookingCancelled}
+	items := []string{"itm_a", "itm_b"}
+
+	var index availabilityIndex
+	bookings := make(map[string]*models.Booking)
+	for step := range 2000 {
+		switch op := rng.Intn(10); {
+		case op < 6 || len(bookings) == 0:
+			// New booking, or a changed status or dates for an existing one.
+			// Overlaps are allowed, as a re-activated booking can cause them.
+			id := fmt.Sprintf("bkg_%d", rng.Intn(150))
+			start, end := randomRange(rng)
+			b := &models.Booking{ID: id, ItemID: items[rng.Intn(len(items))], StartDate: start, EndDate: end,
+				Status: statuses[rng.Intn(len(statuses))]}
+			if old, ok := bookings[id]; ok {
+				b.ItemID = old.ItemID // Bookings never move between items
+			}
+			bookings[id] = b
+			index.put(b)
+		case op < 8:
+			for id, b := range bookings {
+				delete(bookings, id)
+				index.remove(b.ItemID, id)
+				break
+			}
+		default:
+			index.rebuild(bookings)
+		}
+
+		for range 5 {
+			itemID := items[rng.Intn(len(items))]
+			start, end := randomRange(rng)
+			want := bruteForceOverlaps(bookings, itemID, start, end)
+			if got := index.isAvailable(itemID, start, end); got != (len(want) == 0) {
+				t.Fatalf("step %d: isAvailable(%s, %v, %v) = %v, overlapping bookings %v", step, itemID, start, end, got, want)
+			}
+			var got []string
+			for _, r := range index.load(itemID).between(start, end) {
+				got = append(got, r.BookingID)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, want) {
+				t.Fatalf("step %d: between(%s, %v, %v) = %v, want %v", step, itemID, start, end, got, want)
+			}
+		}
+	}
+}
+
+// TestAvailabilityIndexConcurrentReaders runs lock-free readers against a
+// writer; run it with -race
+func TestAvailabilityIndexConcurrentReaders(t *testing.T) {
+	var index availabilityIndex
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for r := range 4 {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			rng := rand.New(rand.NewSource(int64(r)))
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				start, end := randomRange(rng)
+				// Each snapshot must be internally consistent: sorted by start
+				ranges := index.load("itm_a").between(start, end)
+				for i := 1; i < len(ranges); i++ {
+					if ranges[i].Start.Before(ranges[i-1].Start) {
+						t.Errorf("ranges out of order: %v", ranges)
+						return
+					}
+				}
+				index.isAvailable("itm_a", start, end)
+			}
+		}()
+	}
+
+	rng := rand.New(rand.NewSource(99))
+	for i := range 2000 {
+		start, end := randomRange(rng)
+		id := fmt.Sprintf("bkg_%d", i%50)
+		if i%7 == 0 {
+			index.remove("itm_a", id)
+			continue
+		}
+		index.put(&models.Booking{ID: id, ItemID: "itm_a", StartDate: start, EndDate: end, Status: models.BookingPending})
+	}
+	close(stop)
+	readers.Wait()
+}
+
+func TestMemoryConcurrentOverlappingBookings(t *testing.T) {
+	d := NewDatabase()
+	ctx := context.Background()
+	if err := d.CreateItem(ctx, &models.Item{ID: "itm_a", Name: "Tent", DailyRate: 10, OwnerID: "usr_owner", Available: true}); err != nil {
+		t.Fatal(err)
+	}
+	start := testEpoch
+
+	const renters = 16
+	var wg sync.WaitGroup
+	results := make(chan error, renters)
+	for i := range renters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- d.CreateBooking(ctx, &models.Booking{
+				ID:        fmt.Sprintf("bkg_%d", i),
+				ItemID:    "itm_a",
+				UserID:    fmt.Sprintf("usr_%d", i),
+				StartDate: start.AddDate(0, 0, i%3),
+				EndDate:   start.AddDate(0, 0, 4),
+				Status:    models.BookingPending,
+			})
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	created := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrBookingConflict):
+			t.Errorf("CreateBooking: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d overlapping bookings were created, want exactly 1", created)
+	}
+}
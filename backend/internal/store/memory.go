@@ -0,0 +1,1138 @@
+package store
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"borrowhub/internal/models"
+)
+
+// In-memory database, the default Store implementation
+type Database struct {
+	Users    map[string]*models.User    `json:"users"`
+	Items    map[string]*models.Item    `json:"items"`
+	Bookings map[string]*models.Booking `json:"bookings"`
+	Payments map[string]*models.Payment `json:"payments"`
+
+	Sessions      map[string]*models.Session      `json:"sessions"`
+	RefreshTokens map[string]*models.RefreshToken `json:"refreshTokens"`
+	OneTimeTokens map[string]*models.OneTimeToken `json:"oneTimeTokens"`
+
+	TOTPCredentials map[string]*models.TOTPCredential `json:"totpCredentials"` // Keyed by user ID
+	BackupCodes     map[string]*models.BackupCode     `json:"backupCodes"`
+
+	Identities map[string]*models.Identity `json:"identities"`
+
+	LoginThrottles map[string]*models.LoginThrottle `json:"loginThrottles"` // Keyed by throttle key
+	SecurityEvents map[string]*models.SecurityEvent `json:"securityEvents"`
+
+	AuditLog map[string]*models.AuditEntry `json:"auditLog"`
+
+	APIKeys map[string]*models.APIKey `json:"apiKeys"`
+
+	mutex sync.RWMutex
+	// emails maps email addresses to user IDs; it is kept in step with Users
+	emails map[string]string
+	// index answers availability queries without taking mutex; it is
+	// only written while mutex is held
+	index availabilityIndex
+	// persistence is set when the database is backed by a snapshot and write-ahead log
+	persistence *persistence
+}
+
+// NewDatabase creates an empty in-memory store
+func NewDatabase() *Database {
+	return &Database{
+		Users:    make(map[string]*models.User),
+		Items:    make(map[string]*models.Item),
+		Bookings: make(map[string]*models.Booking),
+		Payments: make(map[string]*models.Payment),
+
+		Sessions:      make(map[string]*models.Session),
+		RefreshTokens: make(map[string]*models.RefreshToken),
+		OneTimeTokens: make(map[string]*models.OneTimeToken),
+
+		TOTPCredentials: make(map[string]*models.TOTPCredential),
+		BackupCodes:     make(map[string]*models.BackupCode),
+
+		Identities: make(map[string]*models.Identity),
+
+		LoginThrottles: make(map[string]*models.LoginThrottle),
+		SecurityEvents: make(map[string]*models.SecurityEvent),
+
+		AuditLog: make(map[string]*models.AuditEntry),
+
+		APIKeys: make(map[string]*models.APIKey),
+
+		emails: make(map[string]string),
+	}
+}
+
+// rebuildEmailIndex recomputes emails from Users after they were loaded wholesale
+func (d *Database) rebuildEmailIndex() {
+	d.emails = make(map[string]string, len(d.Users))
+	for id, user := range d.Users {
+		d.emails[user.Email] = id
+	}
+}
+
+// User operations
+func (d *Database) CreateUser(ctx context.Context, user *models.User) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, exists := d.Users[user.ID]; exists {
+		return ErrAlreadyExists
+	}
+	if _, exists := d.emails[user.Email]; exists {
+		return ErrAlreadyExists
+	}
+	u := *user
+	if err := d.logPut("user", u.ID, &u); err != nil {
+		return err
+	}
+	d.Users[u.ID] = &u
+	d.emails[u.Email] = u.ID
+	return nil
+}
+
+func (d *Database) GetUser(ctx context.Context, id string) (*models.User, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	user, exists := d.Users[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	u := *user
+	return &u, nil
+}
+
+func (d *Database) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	id, exists := d.emails[email]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	u := *d.Users[id]
+	return &u, nil
+}
+
+func (d *Database) ListUsers(ctx context.Context) ([]*models.User, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	users := make([]*models.User, 0, len(d.Users))
+	for _, user := range d.Users {
+		u := *user
+		users = append(users, &u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].CreatedAt.Before(users[j].CreatedAt) })
+	return users, nil
+}
+
+func (d *Database) UpdateUser(ctx context.Context, user *models.User) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	existing, exists := d.Users[user.ID]
+	if !exists {
+		return ErrNotFound
+	}
+	if id, taken := d.emails[user.Email]; taken && id != user.ID {
+		return ErrAlreadyExists
+	}
+	u := *user
+	if err := d.logPut("user", u.ID, &u); err != nil {
+		return err
+	}
+	delete(d.emails, existing.Email)
+	d.Users[u.ID] = &u
+	d.emails[u.Email] = u.ID
+	return nil
+}
+
+func (d *Database) EraseUser(ctx context.Context, user *models.User, at time.Time) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	existing, exists := d.Users[user.ID]
+	if !exists {
+		return ErrNotFound
+	}
+	if id, taken := d.emails[user.Email]; taken && id != user.ID {
+		return ErrAlreadyExists
+	}
+	booked := make(map[string]bool) // Items of the user with any booking
+	for _, booking := range d.Bookings {
+		item, exists := d.Items[booking.ItemID]
+		ownItem := exists && item.OwnerID == user.ID
+		if ownItem {
+			booked[item.ID] = true
+		}
+		if (booking.UserID == user.ID || ownItem) && (booking.Status == "pending" || booking.Status == "confirmed") {
+			return ErrUserHasBookings
+		}
+	}
+
+	for id, item := range d.Items {
+		if item.OwnerID != user.ID {
+			continue
+		}
+		if !booked[id] {
+			if err := d.logDelete("item", id); err != nil {
+				return err
+			}
+			delete(d.Items, id)
+		} else if item.Available {
+			i := *item
+			i.Available = false
+			if err := d.logPut("item", id, &i); err != nil {
+				return err
+			}
+			d.Items[id] = &i
+		}
+	}
+
+	u := *user
+	if err := d.logPut("user", u.ID, &u); err != nil {
+		return err
+	}
+	delete(d.emails, existing.Email)
+	d.Users[u.ID] = &u
+	d.emails[u.Email] = u.ID
+
+	for id, identity := range d.Identities {
+		if identity.UserID != u.ID {
+			continue
+		}
+		if err := d.logDelete("identity", id); err != nil {
+			return err
+		}
+		delete(d.Identities, id)
+	}
+	if _, exists := d.TOTPCredentials[u.ID]; exists {
+		if err := d.logDelete("totpCredential", u.ID); err != nil {
+			return err
+		}
+		delete(d.TOTPCredentials, u.ID)
+	}
+	for id, code := range d.BackupCodes {
+		if code.UserID != u.ID {
+			continue
+		}
+		if err := d.logDelete("backupCode", id); err != nil {
+			return err
+		}
+		delete(d.BackupCodes, id)
+	}
+	for id, token := range d.OneTimeTokens {
+		if token.UserID != u.ID {
+			continue
+		}
+		if err := d.logDelete("oneTimeToken", id); err != nil {
+			return err
+		}
+		delete(d.OneTimeTokens, id)
+	}
+	for _, session := range d.Sessions {
+		if session.UserID != u.ID {
+			continue
+		}
+		s := *session
+		if s.Active() {
+			s.RevokedAt = &at
+		}
+		s.IP, s.UserAgent = "", ""
+		if err := d.logPut("session", s.ID, &s); err != nil {
+			return err
+		}
+		d.Sessions[s.ID] = &s
+	}
+	for _, key := range d.APIKeys {
+		if key.UserID != u.ID || key.RevokedAt != nil {
+			continue
+		}
+		k := copyAPIKey(key)
+		k.RevokedAt = &at
+		if err := d.logPut("apiKey", k.ID, k); err != nil {
+			return err
+		}
+		d.APIKeys[k.ID] = k
+	}
+	for _, event := range d.SecurityEvents {
+		if event.UserID != u.ID && (event.Email == "" || event.Email != existing.Email) {
+			continue
+		}
+		e := *event
+		e.Email, e.IP = "", ""
+		if err := d.logPut("securityEvent", e.ID, &e); err != nil {
+			return err
+		}
+		d.SecurityEvents[e.ID] = &e
+	}
+	return nil
+}
+
+// Item operations
+func (d *Database) CreateItem(ctx context.Context, item *models.Item) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, exists := d.Items[item.ID]; exists {
+		return ErrAlreadyExists
+	}
+	i := copyItem(item)
+	if err := d.logPut("item", i.ID, i); err != nil {
+		return err
+	}
+	d.Items[i.ID] = i
+	return nil
+}
+
+func (d *Database) GetItem(ctx context.Context, id string) (*models.Item, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	item, exists := d.Items[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return copyItem(item), nil
+}
+
+func (d *Database) ListItems(ctx context.Context, filter ItemFilter) ([]*models.Item, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	items := make([]*models.Item, 0, len(d.Items))
+	for _, item := range d.Items {
+		if filter.OwnerID != "" && item.OwnerID != filter.OwnerID {
+			continue
+		}
+		if filter.AvailableOnly && !item.Available {
+			continue
+		}
+		if filter.ListedOnly && item.Suspended() {
+			continue
+		}
+		if len(filter.Categories) > 0 && !slices.Contains(filter.Categories, item.Category) {
+			continue
+		}
+		if !hasTags(item, filter.Tags) {
+			continue
+		}
+		items = append(items, copyItem(item))
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt.Before(items[j].CreatedAt) })
+	return items, nil
+}
+
+func (d *Database) UpdateItem(ctx context.Context, item *models.Item) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, exists := d.Items[item.ID]; !exists {
+		return ErrNotFound
+	}
+	i := copyItem(item)
+	if err := d.logPut("item", i.ID, i); err != nil {
+		return err
+	}
+	d.Items[i.ID] = i
+	return nil
+}
+
+// copyItem copies item deeply enough that neither copy shares its tags or attributes
+func copyItem(item *models.Item) *models.Item {
+	i := *item
+	i.Tags = slices.Clone(item.Tags)
+	i.Attributes = maps.Clone(item.Attributes)
+	return &i
+}
+
+// hasTags reports whether item carries every one of tags
+func hasTags(item *models.Item, tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(item.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Database) DeleteItem(ctx context.Context, id string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, exists := d.Items[id]; !exists {
+		return ErrNotFound
+	}
+	for _, booking := range d.Bookings {
+		if booking.ItemID == id && (booking.Status == "pending" || booking.Status == "confirmed") {
+			return ErrItemHasBookings
+		}
+	}
+	if err := d.logDelete("item", id); err != nil {
+		return err
+	}
+	delete(d.Items, id)
+	return nil
+}
+
+// Booking operations
+func (d *Database) CreateBooking(ctx context.Context, booking *models.Booking) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	item, exists := d.Items[booking.ItemID]
+	if !exists {
+		return ErrNotFound
+	}
+	// Neither side may have deleted their account
+	for _, id := range []string{item.OwnerID, booking.UserID} {
+		if user, exists := d.Users[id]; exists && user.Deleted() {
+			return ErrNotFound
+		}
+	}
+	if !d.index.isAvailable(booking.ItemID, booking.StartDate, booking.EndDate) {
+		return ErrBookingConflict
+	}
+	b := *booking
+	if err := d.logPut("booking", b.ID, &b); err != nil {
+		return err
+	}
+	d.Bookings[b.ID] = &b
+	d.index.put(&b)
+	return nil
+}
+
+func (d *Database) GetBooking(ctx context.Context, id string) (*models.Booking, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	booking, exists := d.Bookings[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	b := *booking
+	return &b, nil
+}
+
+func (d *Database) ListBookings(ctx context.Context, filter BookingFilter) ([]*models.Booking, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	bookings := make([]*models.Booking, 0)
+	for _, booking := range d.Bookings {
+		if filter.UserID != "" && booking.UserID != filter.UserID {
+			continue
+		}
+		if filter.ItemID != "" && booking.ItemID != filter.ItemID {
+			continue
+		}
+		if filter.ItemOwnerID != "" {
+			item, exists := d.Items[booking.ItemID]
+			if !exists || item.OwnerID != filter.ItemOwnerID {
+				continue
+			}
+		}
+		if !hasStatus(filter.Statuses, booking.Status) {
+			continue
+		}
+		b := *booking
+		bookings = append(bookings, &b)
+	}
+	sort.Slice(bookings, func(i, j int) bool { return bookings[i].CreatedAt.Before(bookings[j].CreatedAt) })
+	return bookings, nil
+}
+
+func (d *Database) UpdateBooking(ctx context.Context, booking *models.Booking) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	existing, exists := d.Bookings[booking.ID]
+	if !exists {
+		return ErrNotFound
+	}
+	b := *booking
+	if err := d.logPut("booking", b.ID, &b); err != nil {
+		return err
+	}
+	if existing.ItemID != b.ItemID {
+		d.index.remove(existing.ItemID, existing.ID)
+	}
+	d.Bookings[b.ID] = &b
+	d.index.put(&b)
+	return nil
+}
+
+func (d *Database) SetBookingStatus(ctx context.Context, id, status string, at time.Time) (*models.Booking, string, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	existing, exists := d.Bookings[id]
+	if !exists {
+		return nil, "", ErrNotFound
+	}
+	// The index only holds blocking bookings, so a cancelled one is not in its own way
+	if !existing.BlocksDates() && models.BookingStatusBlocksDates(status) &&
+		!d.index.isAvailable(existing.ItemID, existing.StartDate, existing.EndDate) {
+		return nil, "", ErrBookingConflict
+	}
+	b := *existing
+	b.Status = status
+	b.UpdatedAt = at
+	if err := d.logPut("booking", b.ID, &b); err != nil {
+		return nil, "", err
+	}
+	d.Bookings[b.ID] = &b
+	d.index.put(&b)
+	updated := b
+	return &updated, existing.Status, nil
+}
+
+// IsItemAvailable reads the availability index and never takes the store lock
+func (d *Database) IsItemAvailable(ctx context.Context, itemID string, start, end time.Time) (bool, error) {
+	return d.index.isAvailable(itemID, start, end), nil
+}
+
+// BookedRanges reads the availability index and never takes the store lock
+func (d *Database) BookedRanges(ctx context.Context, itemID string, from, to time.Time) ([]models.DateRange, error) {
+	return d.index.load(itemID).between(from, to), nil
+}
+
+// Payment operations
+func (d *Database) CreatePayment(ctx context.Context, payment *models.Payment) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, exists := d.Payments[payment.ID]; exists {
+		return ErrAlreadyExists
+	}
+	p := *payment
+	if err := d.logPut("payment", p.ID, &p); err != nil {
+		return err
+	}
+	d.Payments[p.ID] = &p
+	return nil
+}
+
+func (d *Database) GetPayment(ctx context.Context, id string) (*models.Payment, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	payment, exists := d.Payments[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	p := *payment
+	return &p, nil
+}
+
+func (d *Database) ListPayments(ctx context.Context, filter PaymentFilter) ([]*models.Payment, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	payments := make([]*models.Payment, 0)
+	for _, payment := range d.Payments {
+		if filter.BookingID != "" && payment.BookingID != filter.BookingID {
+			continue
+		}
+		if filter.UserID != "" {
+			booking, exists := d.Bookings[payment.BookingID]
+			if !exists || booking.UserID != filter.UserID {
+				continue
+			}
+		}
+		p := *payment
+		payments = append(payments, &p)
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].CreatedAt.Before(payments[j].CreatedAt) })
+	return payments, nil
+}
+
+func (d *Database) UpdatePayment(ctx context.Context, payment *models.Payment) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, exists := d.Payments[payment.ID]; !exists {
+		return ErrNotFound
+	}
+	p := *payment
+	if err := d.logPut("payment", p.ID, &p); err != nil {
+		return err
+	}
+	d.Payments[p.ID] = &p
+	return nil
+}
+
+// Session operations
+func (d *Database) CreateSession(ctx context.Context, session *models.Session) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, exists := d.Sessions[session.ID]; exists {
+		return ErrAlreadyExists
+	}
+	s := *session
+	if err := d.logPut("session", s.ID, &s); err != nil {
+		return err
+	}
+	d.Sessions[s.ID] = &s
+	return nil
+}
+
+func (d *Database) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	session, exists := d.Sessions[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	s := *session
+	return &s, nil
+}
+
+func (d *Database) ListSessions(ctx context.Context, userID string) ([]*models.Session, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	sessions := make([]*models.Session, 0)
+	for _, session := range d.Sessions {
+		if session.UserID == userID && session.Active() {
+			s := *session
+			sessions = append(sessions, &s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (d *Database) TouchSession(ctx context.Context, id string, at time.Time, ip, userAgent string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	session, exists := d.Sessions[id]
+	if !exists {
+		return ErrNotFound
+	}
+	s := *session
+	s.LastSeenAt = at
+	if ip != "" {
+		s.IP = ip
+	}
+	if userAgent != "" {
+		s.UserAgent = userAgent
+	}
+	if err := d.logPut("session", s.ID, &s); err != nil {
+		return err
+	}
+	d.Sessions[s.ID] = &s
+	return nil
+}
+
+func (d *Database) RevokeSessions(ctx context.Context, filter SessionFilter, at time.Time) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	revoked := 0
+	for _, session := range d.Sessions {
+		if !session.Active() ||
+			(filter.ID != "" && session.ID != filter.ID) ||
+			(filter.UserID != "" && session.UserID != filter.UserID) ||
+			(filter.ExceptID != "" && session.ID == filter.ExceptID) {
+			continue
+		}
+		s := *session
+		s.RevokedAt = &at
+		if err := d.logPut("session", s.ID, &s); err != nil {
+			return revoked, err
+		}
+		d.Sessions[s.ID] = &s
+		revoked++
+	}
+	return revoked, nil
+}
+
+// Refresh token operations
+func (d *Database) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.putNewRefreshToken(token)
+}
+
+func (d *Database) GetRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	token, exists := d.RefreshTokens[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	t := *token
+	return &t, nil
+}
+
+func (d *Database) RotateRefreshToken(ctx context.Context, oldID string, next *models.RefreshToken) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	existing, exists := d.RefreshTokens[oldID]
+	if !exists {
+		return ErrNotFound
+	}
+	if existing.RotatedAt != nil {
+		return ErrTokenReused
+	}
+	if _, exists := d.RefreshTokens[next.ID]; exists {
+		return ErrAlreadyExists
+	}
+
+	old := *existing
+	rotatedAt := next.CreatedAt
+	old.RotatedAt = &rotatedAt
+	old.ReplacedBy = next.ID
+	if err := d.logPut("refreshToken", old.ID, &old); err != nil {
+		return err
+	}
+	d.RefreshTokens[old.ID] = &old
+	return d.putNewRefreshToken(next)
+}
+
+// putNewRefreshToken stores a copy of token; the caller holds mutex
+func (d *Database) putNewRefreshToken(token *models.RefreshToken) error {
+	if _, exists := d.RefreshTokens[token.ID]; exists {
+		return ErrAlreadyExists
+	}
+	t := *token
+	if err := d.logPut("refreshToken", t.ID, &t); err != nil {
+		return err
+	}
+	d.RefreshTokens[t.ID] = &t
+	return nil
+}
+
+// One-time token operations
+func (d *Database) CreateOneTimeToken(ctx context.Context, token *models.OneTimeToken) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, exists := d.OneTimeTokens[token.ID]; exists {
+		return ErrAlreadyExists
+	}
+	t := *token
+	if err := d.logPut("oneTimeToken", t.ID, &t); err != nil {
+		return err
+	}
+	d.OneTimeTokens[t.ID] = &t
+	return nil
+}
+
+func (d *Database) GetOneTimeToken(ctx context.Context, id string) (*models.OneTimeToken, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	token, exists := d.OneTimeTokens[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	t := *token
+	return &t, nil
+}
+
+func (d *Database) LatestOneTimeToken(ctx context.Context, userID, purpose string) (*models.OneTimeToken, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	var latest *models.OneTimeToken
+	for _, token := range d.OneTimeTokens {
+		if token.UserID == userID && token.Purpose == purpose && (latest == nil || token.CreatedAt.After(latest.CreatedAt)) {
+			latest = token
+		}
+	}
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+	t := *latest
+	return &t, nil
+}
+
+func (d *Database) ConsumeOneTimeToken(ctx context.Context, id string, at time.Time) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	token, exists := d.OneTimeTokens[id]
+	if !exists {
+		return ErrNotFound
+	}
+	if token.UsedAt != nil {
+		return ErrTokenReused
+	}
+	t := *token
+	t.UsedAt = &at
+	if err := d.logPut("oneTimeToken", t.ID, &t); err != nil {
+		return err
+	}
+	d.OneTimeTokens[t.ID] = &t
+	return nil
+}
+
+// Two-factor authentication operations
+func (d *Database) SaveTOTPCredential(ctx context.Context, cred *models.TOTPCredential) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	c := *cred
+	if err := d.logPut("totpCredential", c.UserID, &c); err != nil {
+		return err
+	}
+	d.TOTPCredentials[c.UserID] = &c
+	return nil
+}
+
+func (d *Database) GetTOTPCredential(ctx context.Context, userID string) (*models.TOTPCredential, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	cred, exists := d.TOTPCredentials[userID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	c := *cred
+	return &c, nil
+}
+
+func (d *Database) DeleteTOTPCredential(ctx context.Context, userID string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, exists := d.TOTPCredentials[userID]; !exists {
+		return ErrNotFound
+	}
+	if err := d.logDelete("totpCredential", userID); err != nil {
+		return err
+	}
+	delete(d.TOTPCredentials, userID)
+	return nil
+}
+
+func (d *Database) AdvanceTOTPStep(ctx context.Context, userID string, step int64) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	cred, exists := d.TOTPCredentials[userID]
+	if !exists {
+		return ErrNotFound
+	}
+	if step <= cred.LastUsedStep {
+		return ErrTokenReused
+	}
+	c := *cred
+	c.LastUsedStep = step
+	if err := d.logPut("totpCredential", c.UserID, &c); err != nil {
+		return err
+	}
+	d.TOTPCredentials[c.UserID] = &c
+	return nil
+}
+
+func (d *Database) ReplaceBackupCodes(ctx context.Context, userID string, codes []*models.BackupCode) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for id, code := range d.BackupCodes {
+		if code.UserID != userID {
+			continue
+		}
+		if err := d.logDelete("backupCode", id); err != nil {
+			return err
+		}
+		delete(d.BackupCodes, id)
+	}
+	for _, code := range codes {
+		c := *code
+		if err := d.logPut("backupCode", c.ID, &c); err != nil {
+			return err
+		}
+		d.BackupCodes[c.ID] = &c
+	}
+	return nil
+}
+
+func (d *Database) ListBackupCodes(ctx context.Context, userID string) ([]*models.BackupCode, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	codes := make([]*models.BackupCode, 0)
+	for _, code := range d.BackupCodes {
+		if code.UserID == userID {
+			c := *code
+			codes = append(codes, &c)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i].ID < codes[j].ID })
+	return codes, nil
+}
+
+func (d *Database) ConsumeBackupCode(ctx context.Context, id string, at time.Time) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	code, exists := d.BackupCodes[id]
+	if !exists {
+		return ErrNotFound
+	}
+	if code.UsedAt != nil {
+		return ErrTokenReused
+	}
+	c := *code
+	c.UsedAt = &at
+	if err := d.logPut("backupCode", c.ID, &c); err != nil {
+		return err
+	}
+	d.BackupCodes[c.ID] = &c
+	return nil
+}
+
+// External identity operations
+func (d *Database) CreateIdentity(ctx context.Context, identity *models.Identity) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, existing := range d.Identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			return ErrAlreadyExists
+		}
+	}
+	i := *identity
+	if err := d.logPut("identity", i.ID, &i); err != nil {
+		return err
+	}
+	d.Identities[i.ID] = &i
+	return nil
+}
+
+func (d *Database) GetIdentity(ctx context.Context, provider, subject string) (*models.Identity, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	for _, identity := range d.Identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			i := *identity
+			return &i, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Login throttling operations
+func (d *Database) GetLoginThrottle(ctx context.Context, key string) (*models.LoginThrottle, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	throttle, exists := d.LoginThrottles[key]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	t := *throttle
+	return &t, nil
+}
+
+func (d *Database) ReserveLoginAttempt(ctx context.Context, key string, at, since time.Time, locked func(*models.LoginThrottle) bool) (*models.LoginThrottle, bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var previous *models.LoginThrottle
+	if existing, exists := d.LoginThrottles[key]; exists && !existing.LastFailureAt.Before(since) {
+		t := *existing
+		previous = &t
+	}
+	if locked(previous) {
+		return previous, false, nil
+	}
+	t := models.LoginThrottle{Key: key, Failures: 1, LastFailureAt: at}
+	if previous != nil {
+		t.Failures = previous.Failures + 1
+	}
+	if err := d.logPut("loginThrottle", key, &t); err != nil {
+		return nil, false, err
+	}
+	d.LoginThrottles[key] = &t
+	result := t
+	return &result, true, nil
+}
+
+func (d *Database) ReleaseLoginAttempt(ctx context.Context, key string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	existing, exists := d.LoginThrottles[key]
+	if !exists || existing.Failures == 0 {
+		return nil
+	}
+	t := *existing
+	t.Failures--
+	if err := d.logPut("loginThrottle", key, &t); err != nil {
+		return err
+	}
+	d.LoginThrottles[key] = &t
+	return nil
+}
+
+func (d *Database) ClearLoginThrottle(ctx context.Context, key string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, exists := d.LoginThrottles[key]; !exists {
+		return nil
+	}
+	if err := d.logDelete("loginThrottle", key); err != nil {
+		return err
+	}
+	delete(d.LoginThrottles, key)
+	return nil
+}
+
+// Security event operations
+func (d *Database) RecordSecurityEvent(ctx context.Context, event *models.SecurityEvent) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	e := *event
+	if err := d.logPut("securityEvent", e.ID, &e); err != nil {
+		return err
+	}
+	d.SecurityEvents[e.ID] = &e
+	return nil
+}
+
+// API key operations
+func (d *Database) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, exists := d.APIKeys[key.ID]; exists {
+		return ErrAlreadyExists
+	}
+	k := copyAPIKey(key)
+	if err := d.logPut("apiKey", k.ID, k); err != nil {
+		return err
+	}
+	d.APIKeys[k.ID] = k
+	return nil
+}
+
+func (d *Database) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	key, exists := d.APIKeys[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return copyAPIKey(key), nil
+}
+
+func (d *Database) ListAPIKeys(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	keys := make([]*models.APIKey, 0)
+	for _, key := range d.APIKeys {
+		if key.UserID == userID {
+			keys = append(keys, copyAPIKey(key))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (d *Database) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	key, exists := d.APIKeys[id]
+	if !exists {
+		return ErrNotFound
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	k := copyAPIKey(key)
+	k.RevokedAt = &at
+	if err := d.logPut("apiKey", k.ID, k); err != nil {
+		return err
+	}
+	d.APIKeys[k.ID] = k
+	return nil
+}
+
+func (d *Database) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	key, exists := d.APIKeys[id]
+	if !exists {
+		return ErrNotFound
+	}
+	k := copyAPIKey(key)
+	k.LastUsedAt = &at
+	if err := d.logPut("apiKey", k.ID, k); err != nil {
+		return err
+	}
+	d.APIKeys[k.ID] = k
+	return nil
+}
+
+// copyAPIKey copies key deeply enough that neither copy shares its scopes
+func copyAPIKey(key *models.APIKey) *models.APIKey {
+	k := *key
+	k.Scopes = append([]string(nil), key.Scopes...)
+	return &k
+}
+
+// Audit log operations
+func (d *Database) RecordAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	e := *entry
+	if err := d.logPut("auditEntry", e.ID, &e); err != nil {
+		return err
+	}
+	d.AuditLog[e.ID] = &e
+	return nil
+}
+
+func (d *Database) ListAuditEntries(ctx context.Context, filter AuditFilter) ([]*models.AuditEntry, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	entries := make([]*models.AuditEntry, 0)
+	for _, entry := range d.AuditLog {
+		if filter.ActorID != "" && entry.ActorID != filter.ActorID {
+			continue
+		}
+		if filter.TargetID != "" && entry.TargetID != filter.TargetID {
+			continue
+		}
+		e := *entry
+		entries = append(entries, &e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].CreatedAt.Before(entries[j].CreatedAt) })
+	return entries, nil
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migration is a single forward-only schema change. Versions must be unique
+// and increasing; a migration is never edited once it has shipped.
+type migration struct {
+	version    int
+	name       string
+	statements []string
+}
+
+// migrationLock serialises migrations between processes that share a
+// database, such as several instances starting at once. Both statements run
+// on the same connection, as the lock belongs to the database session.
+type migrationLock struct {
+	acquire string
+	release string
+}
+
+// runMigrations applies every migration newer than the recorded schema version,
+// each inside its own transaction, and records it in schema_migrations. With
+// a lock, the whole run holds it, so concurrent runs apply each migration once.
+func runMigrations(ctx context.Context, db *sql.DB, migrations []migration, rebind func(string) string, lock *migrationLock) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migrations: %w", err)
+	}
+	defer conn.Close()
+
+	if lock != nil {
+		if _, err := conn.ExecContext(ctx, lock.acquire); err != nil {
+			return fmt.Errorf("take migration lock: %w", err)
+		}
+		defer func() {
+			// A fresh context, so the lock is released even when ctx is done
+			if _, err := conn.ExecContext(context.Background(), lock.release); err != nil {
+				log.Printf("Error releasing migration lock: %v", err)
+			}
+		}()
+	}
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("migration %d: %w", m.version, err)
+		}
+		for _, stmt := range m.statements {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx,
+			rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+			m.version, m.name, time.Now().UTC(),
+		); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", m.version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %w", m.version, err)
+		}
+
+		log.Printf("Applied migration %d: %s", m.version, m.name)
+		current = m.version
+	}
+
+	return nil
+}
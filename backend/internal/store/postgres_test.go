@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"borrowhub/internal/models"
+)
+
+// testPostgresDSN names the database the PostgreSQL tests run against, such
+// as the one `make postgres-up` starts. Without it they are skipped.
+const testPostgresDSN = "BORROWHUB_TEST_DATABASE_URL"
+
+// postgresSchema creates an empty schema for one test and returns a DSN
+// that uses it. The schema is dropped when the test ends.
+func postgresSchema(t *testing.T) string {
+	t.Helper()
+	dsn := os.Getenv(testPostgresDSN)
+	if dsn == "" {
+		t.Skipf("%s is not set", testPostgresDSN)
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("borrowhub_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func openPostgres(t *testing.T, dsn string) *PostgresStore {
+	t.Helper()
+	s, err := NewPostgresStore(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestPostgresConcurrentMigrations(t *testing.T) {
+	dsn := postgresSchema(t)
+
+	const instances = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, instances)
+	for range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := NewPostgresStore(context.Background(), dsn)
+			if err != nil {
+				errs <- err
+				return
+			}
+			s.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent start: %v", err)
+	}
+
+	s := openPostgres(t, dsn)
+	var applied, latest int
+	err := s.db.QueryRow("SELECT COUNT(*), MAX(version) FROM schema_migrations").Scan(&applied, &latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := postgresMigrations[len(postgresMigrations)-1].version; applied != len(postgresMigrations) || latest != want {
+		t.Errorf("schema_migrations has %d rows up to version %d, want %d up to %d", applied, latest, len(postgresMigrations), want)
+	}
+}
+
+func TestPostgresConcurrentOverlappingBookings(t *testing.T) {
+	s := openPostgres(t, postgresSchema(t))
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	owner := &models.User{ID: "usr_owner", Email: "owner@example.com", Role: models.RoleUser, CreatedAt: now}
+	if err := s.CreateUser(ctx, owner); err != nil {
+		t.Fatal(err)
+	}
+	item := &models.Item{ID: "itm_tent", Name: "Tent", DailyRate: 10, OwnerID: owner.ID, Available: true, CreatedAt: now}
+	if err := s.CreateItem(ctx, item); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every booking overlaps every other by at least a day
+	start := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	const renters = 8
+	var wg sync.WaitGroup
+	results := make(chan error, renters)
+	for i := range renters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			booking := &models.Booking{
+				ID:        fmt.Sprintf("bkg_%d", i),
+				ItemID:    item.ID,
+				UserID:    fmt.Sprintf("usr_renter_%d", i),
+				StartDate: start.AddDate(0, 0, i%2),
+				EndDate:   start.AddDate(0, 0, 3),
+				Status:    "pending",
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			results <- s.CreateBooking(ctx, booking)
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	created := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, ErrBookingConflict):
+		default:
+			t.Errorf("CreateBooking: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d overlapping bookings were created, want exactly 1", created)
+	}
+
+	bookings, err := s.ListBookings(ctx, BookingFilter{ItemID: item.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookings) != 1 {
+		t.Errorf("item has %d bookings, want 1", len(bookings))
+	}
+}
@@ -0,0 +1,325 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"borrowhub/internal/models"
+)
+
+// walEntry is one line of the write-ahead log: either a full record ("put")
+// or a deletion by ID. Kind names the Database map the record belongs to.
+type walEntry struct {
+	Op   string          `json:"op"`
+	Kind string          `json:"kind"`
+	ID   string          `json:"id"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// persistence holds the snapshot and WAL files of a durable in-memory Database
+type persistence struct {
+	snapshotPath string
+	wal          *os.File
+	// snapshotMu serialises snapshots; WAL appends are already serialised by Database.mutex
+	snapshotMu sync.Mutex
+}
+
+// NewPersistentDatabase restores a Database from the snapshot at path and
+// replays the write-ahead log (path + ".wal") written since that snapshot.
+// Every later mutation is appended to the log before it is applied.
+func NewPersistentDatabase(path string) (*Database, error) {
+	d := NewDatabase()
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, d); err != nil {
+			return nil, fmt.Errorf("read snapshot %s: %w", path, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("read snapshot %s: %w", path, err)
+	}
+
+	walPath := path + ".wal"
+	replayed, torn, err := d.replayWAL(walPath)
+	if err != nil {
+		return nil, err
+	}
+	// Cut off a torn final line, or the next append would be glued to it and
+	// the log would no longer replay
+	if torn >= 0 {
+		log.Printf("Discarding incomplete trailing write-ahead log entry in %s", walPath)
+		if err := os.Truncate(walPath, torn); err != nil {
+			return nil, fmt.Errorf("truncate write-ahead log: %w", err)
+		}
+	}
+	d.index.rebuild(d.Bookings)
+	lowerCaseEmails(d.Users)
+	d.rebuildEmailIndex()
+	// Users saved before roles existed are ordinary users
+	for _, user := range d.Users {
+		if user.Role == "" {
+			user.Role = models.RoleUser
+		}
+	}
+	// Sessions saved before last-seen tracking were last seen when they started
+	for _, session := range d.Sessions {
+		if session.LastSeenAt.IsZero() {
+			session.LastSeenAt = session.CreatedAt
+		}
+	}
+
+	wal, err := os.OpenFile(walPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open write-ahead log: %w", err)
+	}
+	d.persistence = &persistence{snapshotPath: path, wal: wal}
+
+	log.Printf("Restored %d users, %d items, %d bookings, %d payments from %s (%d log entries)",
+		len(d.Users), len(d.Items), len(d.Bookings), len(d.Payments), path, replayed)
+	return d, nil
+}
+
+// replayWAL applies logged mutations in order and returns how many it
+// applied. A torn final line from a crash mid-write is skipped and its offset
+// returned as torn, which is -1 when the log ends cleanly; anything else
+// malformed is an error.
+func (d *Database) replayWAL(path string) (count int, torn int64, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, -1, nil
+	}
+	if err != nil {
+		return 0, -1, fmt.Errorf("open write-ahead log: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var offset int64 // End of the last complete line
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				return count, offset, nil
+			}
+			return count, -1, nil
+		}
+		if err != nil {
+			return count, -1, fmt.Errorf("read write-ahead log: %w", err)
+		}
+
+		var entry walEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return count, -1, fmt.Errorf("write-ahead log entry %d: %w", count+1, err)
+		}
+		if err := d.applyWALEntry(entry); err != nil {
+			return count, -1, fmt.Errorf("write-ahead log entry %d: %w", count+1, err)
+		}
+		count++
+		offset += int64(len(line))
+	}
+}
+
+// lowerCaseEmails brings emails saved before they were normalised into the
+// lower-case form they are looked up in, except where that would merge two
+// accounts, which is left for an administrator
+func lowerCaseEmails(users map[string]*models.User) {
+	accounts := make(map[string]int, len(users))
+	for _, user := range users {
+		accounts[strings.ToLower(strings.TrimSpace(user.Email))]++
+	}
+	for _, user := range users {
+		lower := strings.ToLower(strings.TrimSpace(user.Email))
+		if user.Email != lower && accounts[lower] == 1 {
+			user.Email = lower
+		}
+	}
+}
+
+func (d *Database) applyWALEntry(entry walEntry) error {
+	switch entry.Kind {
+	case "user":
+		return applyEntry(d.Users, entry)
+	case "item":
+		return applyEntry(d.Items, entry)
+	case "booking":
+		return applyEntry(d.Bookings, entry)
+	case "payment":
+		return applyEntry(d.Payments, entry)
+	case "session":
+		return applyEntry(d.Sessions, entry)
+	case "refreshToken":
+		return applyEntry(d.RefreshTokens, entry)
+	case "oneTimeToken":
+		return applyEntry(d.OneTimeTokens, entry)
+	case "totpCredential":
+		return applyEntry(d.TOTPCredentials, entry)
+	case "backupCode":
+		return applyEntry(d.BackupCodes, entry)
+	case "identity":
+		return applyEntry(d.Identities, entry)
+	case "loginThrottle":
+		return applyEntry(d.LoginThrottles, entry)
+	case "securityEvent":
+		return applyEntry(d.SecurityEvents, entry)
+	case "auditEntry":
+		return applyEntry(d.AuditLog, entry)
+	case "apiKey":
+		return applyEntry(d.APIKeys, entry)
+	default:
+		return fmt.Errorf("unknown kind %q", entry.Kind)
+	}
+}
+
+func applyEntry[T any](records map[string]*T, entry walEntry) error {
+	switch entry.Op {
+	case "put":
+		var record T
+		if err := json.Unmarshal(entry.Data, &record); err != nil {
+			return err
+		}
+		records[entry.ID] = &record
+	case "delete":
+		delete(records, entry.ID)
+	default:
+		return fmt.Errorf("unknown op %q", entry.Op)
+	}
+	return nil
+}
+
+// logPut appends a record to the write-ahead log; the caller must hold d.mutex for writing
+func (d *Database) logPut(kind, id string, record interface{}) error {
+	if d.persistence == nil {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return d.appendWAL(walEntry{Op: "put", Kind: kind, ID: id, Data: data})
+}
+
+// logDelete appends a deletion to the write-ahead log; the caller must hold d.mutex for writing
+func (d *Database) logDelete(kind, id string) error {
+	if d.persistence == nil {
+		return nil
+	}
+	return d.appendWAL(walEntry{Op: "delete", Kind: kind, ID: id})
+}
+
+func (d *Database) appendWAL(entry walEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := d.persistence.wal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write-ahead log: %w", err)
+	}
+	if err := d.persistence.wal.Sync(); err != nil {
+		return fmt.Errorf("write-ahead log: %w", err)
+	}
+	return nil
+}
+
+// Persistent reports whether the database was opened with a snapshot path
+func (d *Database) Persistent() bool {
+	return d.persistence != nil
+}
+
+// Snapshot writes the whole database to the snapshot path via a temporary file
+// and an atomic rename, then truncates the write-ahead log it supersedes
+func (d *Database) Snapshot() error {
+	if d.persistence == nil {
+		return nil
+	}
+	p := d.persistence
+	p.snapshotMu.Lock()
+	defer p.snapshotMu.Unlock()
+
+	// A read lock excludes writers, so the snapshot and the log truncation see the same state
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	dir := filepath.Dir(p.snapshotPath)
+	tmp, err := os.CreateTemp(dir, filepath.Base(p.snapshotPath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p.snapshotPath); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	syncDir(dir)
+
+	// Everything in the log is now part of the snapshot
+	if err := p.wal.Truncate(0); err != nil {
+		return fmt.Errorf("truncate write-ahead log: %w", err)
+	}
+	return nil
+}
+
+// RunSnapshots snapshots every interval until ctx is cancelled, then takes a final snapshot
+func (d *Database) RunSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.Snapshot(); err != nil {
+				log.Printf("Snapshot failed: %v", err)
+			}
+		case <-ctx.Done():
+			if err := d.Snapshot(); err != nil {
+				log.Printf("Final snapshot failed: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// Close closes the write-ahead log; writes after Close fail. Take a final
+// Snapshot first to keep the log short.
+func (d *Database) Close() error {
+	if d.persistence == nil {
+		return nil
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.persistence.wal.Close()
+}
+
+// syncDir flushes a directory entry so a completed rename survives power loss
+func syncDir(dir string) {
+	if f, err := os.Open(dir); err == nil {
+		f.Sync()
+		f.Close()
+	}
+}
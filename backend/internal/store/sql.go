@@ -0,0 +1,1095 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"borrowhub/internal/models"
+)
+
+// sqlStore implements Store on top of database/sql. Queries are written with
+// "?" placeholders and passed through rebind for the active driver.
+type sqlStore struct {
+	db     *sql.DB
+	rebind func(string) string
+	// txOptions are used for transactions that must not interleave, such as booking creation
+	txOptions *sql.TxOptions
+	// isRetryable reports transaction errors worth retrying, such as serialization failures
+	isRetryable func(error) bool
+	// translate maps driver errors, such as unique violations, to store errors
+	translate func(error) error
+}
+
+// maxTxAttempts bounds retries of transactions aborted by the database
+const maxTxAttempts = 5
+
+// Close releases the underlying database handle
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// inTx runs fn inside a transaction, committing on success and retrying
+// from scratch when the database aborts it with a retryable error
+func (s *sqlStore) inTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	for attempt := 1; ; attempt++ {
+		err := s.runTx(ctx, opts, fn)
+		if err == nil || s.isRetryable == nil || !s.isRetryable(err) || attempt == maxTxAttempts {
+			return s.translateError(err)
+		}
+	}
+}
+
+func (s *sqlStore) runTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// translateError applies the driver-specific error mapping, if any
+func (s *sqlStore) translateError(err error) error {
+	if err == nil || s.translate == nil {
+		return err
+	}
+	return s.translate(err)
+}
+
+// execOne runs an UPDATE/DELETE and maps "no rows affected" to ErrNotFound
+func (s *sqlStore) execOne(ctx context.Context, q queryer, query string, args ...interface{}) error {
+	res, err := q.ExecContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return s.translateError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// utc normalises timestamps before they are written so stored values compare consistently
+func utc(t time.Time) time.Time {
+	return t.UTC()
+}
+
+// utcPtr is utc for optional timestamps, which are stored as NULL when unset
+func utcPtr(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+// User operations
+const userColumns = "id, username, email, password, first_name, last_name, phone, address, verified, role, suspended_at, deleted_at, created_at"
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var u models.User
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &u.FirstName, &u.LastName, &u.Phone, &u.Address, &u.Verified, &u.Role, &u.SuspendedAt, &u.DeletedAt, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *sqlStore) CreateUser(ctx context.Context, user *models.User) error {
+	return s.inTx(ctx, nil, func(tx *sql.Tx) error {
+		var exists int
+		err := tx.QueryRowContext(ctx, s.rebind("SELECT 1 FROM users WHERE email = ?"), user.Email).Scan(&exists)
+		if err == nil {
+			return ErrAlreadyExists
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, s.rebind("INSERT INTO users ("+userColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+			user.ID, user.Username, user.Email, user.Password, user.FirstName, user.LastName, user.Phone, user.Address, user.Verified,
+			user.Role, utcPtr(user.SuspendedAt), utcPtr(user.DeletedAt), utc(user.CreatedAt))
+		return err
+	})
+}
+
+func (s *sqlStore) GetUser(ctx context.Context, id string) (*models.User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, s.rebind("SELECT "+userColumns+" FROM users WHERE id = ?"), id))
+}
+
+func (s *sqlStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, s.rebind("SELECT "+userColumns+" FROM users WHERE email = ?"), email))
+}
+
+func (s *sqlStore) ListUsers(ctx context.Context) ([]*models.User, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *sqlStore) UpdateUser(ctx context.Context, user *models.User) error {
+	return s.execOne(ctx, s.db,
+		"UPDATE users SET username = ?, email = ?, password = ?, first_name = ?, last_name = ?, phone = ?, address = ?, verified = ?, role = ?, suspended_at = ?, deleted_at = ? WHERE id = ?",
+		user.Username, user.Email, user.Password, user.FirstName, user.LastName, user.Phone, user.Address, user.Verified,
+		user.Role, utcPtr(user.SuspendedAt), utcPtr(user.DeletedAt), user.ID)
+}
+
+func (s *sqlStore) EraseUser(ctx context.Context, user *models.User, at time.Time) error {
+	return s.inTx(ctx, s.txOptions, func(tx *sql.Tx) error {
+		var email string
+		if err := tx.QueryRowContext(ctx, s.rebind("SELECT email FROM users WHERE id = ?"), user.ID).Scan(&email); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+		var active int
+		err := tx.QueryRowContext(ctx, s.rebind(
+			"SELECT COUNT(*) FROM bookings WHERE status IN ('pending', 'confirmed') AND (user_id = ? OR item_id IN (SELECT id FROM items WHERE owner_id = ?))"),
+			user.ID, user.ID,
+		).Scan(&active)
+		if err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrUserHasBookings
+		}
+		if err := s.execOne(ctx, tx,
+			"UPDATE users SET username = ?, email = ?, password = ?, first_name = ?, last_name = ?, phone = ?, address = ?, verified = ?, role = ?, suspended_at = ?, deleted_at = ? WHERE id = ?",
+			user.Username, user.Email, user.Password, user.FirstName, user.LastName, user.Phone, user.Address, user.Verified,
+			user.Role, utcPtr(user.SuspendedAt), utcPtr(user.DeletedAt), user.ID); err != nil {
+			return err
+		}
+
+		statements := []struct {
+			query string
+			args  []interface{}
+		}{
+			{"DELETE FROM items WHERE owner_id = ? AND NOT EXISTS (SELECT 1 FROM bookings WHERE bookings.item_id = items.id)", []interface{}{user.ID}},
+			{"UPDATE items SET available = ? WHERE owner_id = ? AND available = ?", []interface{}{false, user.ID, true}},
+			{"DELETE FROM user_identities WHERE user_id = ?", []interface{}{user.ID}},
+			{"DELETE FROM totp_credentials WHERE user_id = ?", []interface{}{user.ID}},
+			{"DELETE FROM backup_codes WHERE user_id = ?", []interface{}{user.ID}},
+			{"DELETE FROM one_time_tokens WHERE user_id = ?", []interface{}{user.ID}},
+			{"UPDATE sessions SET revoked_at = COALESCE(revoked_at, ?), ip = '', user_agent = '' WHERE user_id = ?", []interface{}{utc(at), user.ID}},
+			{"UPDATE api_keys SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", []interface{}{utc(at), user.ID}},
+			{"UPDATE security_events SET email = '', ip = '' WHERE user_id = ? OR (email = ? AND email <> '')", []interface{}{user.ID, email}},
+		}
+		for _, st := range statements {
+			if _, err := tx.ExecContext(ctx, s.rebind(st.query), st.args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Item operations
+// Tags are stored space-separated and attributes as a JSON object
+const itemColumns = "id, name, title, description, daily_rate, price, image_url, owner_id, available, category, tags, attributes, suspended_at, created_at"
+
+func scanItem(row rowScanner) (*models.Item, error) {
+	var i models.Item
+	var tags, attributes string
+	err := row.Scan(&i.ID, &i.Name, &i.Title, &i.Description, &i.DailyRate, &i.Price, &i.ImageURL, &i.OwnerID, &i.Available,
+		&i.Category, &tags, &attributes, &i.SuspendedAt, &i.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	i.Tags = strings.Fields(tags)
+	if len(i.Tags) == 0 {
+		i.Tags = nil
+	}
+	if err := json.Unmarshal([]byte(attributes), &i.Attributes); err != nil {
+		return nil, fmt.Errorf("item %s attributes: %w", i.ID, err)
+	}
+	if len(i.Attributes) == 0 {
+		i.Attributes = nil
+	}
+	return &i, nil
+}
+
+// encodeAttributes returns the stored form of an item's attributes
+func encodeAttributes(attributes models.Attributes) (string, error) {
+	if attributes == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(attributes)
+	return string(data), err
+}
+
+func (s *sqlStore) CreateItem(ctx context.Context, item *models.Item) error {
+	attributes, err := encodeAttributes(item.Attributes)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.rebind("INSERT INTO items ("+itemColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		item.ID, item.Name, item.Title, item.Description, item.DailyRate, item.Price, item.ImageURL, item.OwnerID, item.Available,
+		item.Category, strings.Join(item.Tags, " "), attributes, utcPtr(item.SuspendedAt), utc(item.CreatedAt))
+	return s.translateError(err)
+}
+
+func (s *sqlStore) GetItem(ctx context.Context, id string) (*models.Item, error) {
+	return scanItem(s.db.QueryRowContext(ctx, s.rebind("SELECT "+itemColumns+" FROM items WHERE id = ?"), id))
+}
+
+func (s *sqlStore) ListItems(ctx context.Context, filter ItemFilter) ([]*models.Item, error) {
+	var where []string
+	var args []interface{}
+	if filter.OwnerID != "" {
+		where = append(where, "owner_id = ?")
+		args = append(args, filter.OwnerID)
+	}
+	if filter.AvailableOnly {
+		where = append(where, "available = ?")
+		args = append(args, true)
+	}
+	if filter.ListedOnly {
+		where = append(where, "suspended_at IS NULL")
+	}
+	if len(filter.Categories) > 0 {
+		where = append(where, "category IN ("+placeholders(len(filter.Categories))+")")
+		for _, category := range filter.Categories {
+			args = append(args, category)
+		}
+	}
+	for _, tag := range filter.Tags {
+		// Tags hold no spaces or LIKE wildcards, so padding the list finds whole tags
+		where = append(where, "' ' || tags || ' ' LIKE ?")
+		args = append(args, "% "+tag+" %")
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.rebind("SELECT "+itemColumns+" FROM items"+whereClause(where)+" ORDER BY created_at"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*models.Item, 0)
+	for rows.Next() {
+		i, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+func (s *sqlStore) UpdateItem(ctx context.Context, item *models.Item) error {
+	attributes, err := encodeAttributes(item.Attributes)
+	if err != nil {
+		return err
+	}
+	return s.execOne(ctx, s.db,
+		"UPDATE items SET name = ?, title = ?, description = ?, daily_rate = ?, price = ?, image_url = ?, owner_id = ?, available = ?, category = ?, tags = ?, attributes = ?, suspended_at = ? WHERE id = ?",
+		item.Name, item.Title, item.Description, item.DailyRate, item.Price, item.ImageURL, item.OwnerID, item.Available,
+		item.Category, strings.Join(item.Tags, " "), attributes, utcPtr(item.SuspendedAt), item.ID)
+}
+
+func (s *sqlStore) DeleteItem(ctx context.Context, id string) error {
+	return s.inTx(ctx, s.txOptions, func(tx *sql.Tx) error {
+		var active int
+		err := tx.QueryRowContext(ctx,
+			s.rebind("SELECT COUNT(*) FROM bookings WHERE item_id = ? AND status IN ('pending', 'confirmed')"), id,
+		).Scan(&active)
+		if err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrItemHasBookings
+		}
+		return s.execOne(ctx, tx, "DELETE FROM items WHERE id = ?", id)
+	})
+}
+
+// Booking operations
+const bookingColumns = "id, item_id, user_id, start_date, end_date, total_price, status, payment_id, created_at, updated_at"
+
+func scanBooking(row rowScanner) (*models.Booking, error) {
+	var b models.Booking
+	err := row.Scan(&b.ID, &b.ItemID, &b.UserID, &b.StartDate, &b.EndDate, &b.TotalPrice, &b.Status, &b.PaymentID, &b.CreatedAt, &b.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (s *sqlStore) CreateBooking(ctx context.Context, booking *models.Booking) error {
+	return s.inTx(ctx, s.txOptions, func(tx *sql.Tx) error {
+		var ownerID string
+		err := tx.QueryRowContext(ctx, s.rebind("SELECT owner_id FROM items WHERE id = ?"), booking.ItemID).Scan(&ownerID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		// Neither side may have deleted their account
+		var deleted int
+		err = tx.QueryRowContext(ctx, s.rebind("SELECT COUNT(*) FROM users WHERE id IN (?, ?) AND deleted_at IS NOT NULL"), ownerID, booking.UserID).Scan(&deleted)
+		if err != nil {
+			return err
+		}
+		if deleted > 0 {
+			return ErrNotFound
+		}
+
+		available, err := s.isItemAvailable(ctx, tx, booking.ItemID, booking.StartDate, booking.EndDate)
+		if err != nil {
+			return err
+		}
+		if !available {
+			return ErrBookingConflict
+		}
+
+		_, err = tx.ExecContext(ctx, s.rebind("INSERT INTO bookings ("+bookingColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+			booking.ID, booking.ItemID, booking.UserID, utc(booking.StartDate), utc(booking.EndDate), booking.TotalPrice,
+			booking.Status, booking.PaymentID, utc(booking.CreatedAt), utc(booking.UpdatedAt))
+		return err
+	})
+}
+
+func (s *sqlStore) GetBooking(ctx context.Context, id string) (*models.Booking, error) {
+	return scanBooking(s.db.QueryRowContext(ctx, s.rebind("SELECT "+bookingColumns+" FROM bookings WHERE id = ?"), id))
+}
+
+func (s *sqlStore) ListBookings(ctx context.Context, filter BookingFilter) ([]*models.Booking, error) {
+	var where []string
+	var args []interface{}
+	if filter.UserID != "" {
+		where = append(where, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.ItemID != "" {
+		where = append(where, "item_id = ?")
+		args = append(args, filter.ItemID)
+	}
+	if filter.ItemOwnerID != "" {
+		where = append(where, "item_id IN (SELECT id FROM items WHERE owner_id = ?)")
+		args = append(args, filter.ItemOwnerID)
+	}
+	if len(filter.Statuses) > 0 {
+		where = append(where, "status IN ("+placeholders(len(filter.Statuses))+")")
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.rebind("SELECT "+bookingColumns+" FROM bookings"+whereClause(where)+" ORDER BY created_at"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookings := make([]*models.Booking, 0)
+	for rows.Next() {
+		b, err := scanBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, b)
+	}
+	return bookings, rows.Err()
+}
+
+func (s *sqlStore) UpdateBooking(ctx context.Context, booking *models.Booking) error {
+	return s.execOne(ctx, s.db,
+		"UPDATE bookings SET item_id = ?, user_id = ?, start_date = ?, end_date = ?, total_price = ?, status = ?, payment_id = ?, updated_at = ? WHERE id = ?",
+		booking.ItemID, booking.UserID, utc(booking.StartDate), utc(booking.EndDate), booking.TotalPrice,
+		booking.Status, booking.PaymentID, utc(booking.UpdatedAt), booking.ID)
+}
+
+func (s *sqlStore) SetBookingStatus(ctx context.Context, id, status string, at time.Time) (*models.Booking, string, error) {
+	var booking *models.Booking
+	var previous string
+	err := s.inTx(ctx, s.txOptions, func(tx *sql.Tx) error {
+		var err error
+		booking, err = scanBooking(tx.QueryRowContext(ctx, s.rebind("SELECT "+bookingColumns+" FROM bookings WHERE id = ?"), id))
+		if err != nil {
+			return err
+		}
+		previous = booking.Status
+
+		// A cancelled booking is not among the ranges it is checked against
+		if !booking.BlocksDates() && models.BookingStatusBlocksDates(status) {
+			available, err := s.isItemAvailable(ctx, tx, booking.ItemID, booking.StartDate, booking.EndDate)
+			if err != nil {
+				return err
+			}
+			if !available {
+				return ErrBookingConflict
+			}
+		}
+
+		booking.Status = status
+		booking.UpdatedAt = at
+		return s.execOne(ctx, tx, "UPDATE bookings SET status = ?, updated_at = ? WHERE id = ?", status, utc(at), id)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return booking, previous, nil
+}
+
+func (s *sqlStore) IsItemAvailable(ctx context.Context, itemID string, start, end time.Time) (bool, error) {
+	return s.isItemAvailable(ctx, s.db, itemID, start, end)
+}
+
+// isItemAvailable checks the item's booked ranges for an overlap with [start, end)
+func (s *sqlStore) isItemAvailable(ctx context.Context, q queryer, itemID string, start, end time.Time) (bool, error) {
+	booked, err := s.bookedRanges(ctx, q, itemID, start, end)
+	if err != nil {
+		return false, err
+	}
+	return len(booked) == 0, nil
+}
+
+func (s *sqlStore) BookedRanges(ctx context.Context, itemID string, from, to time.Time) ([]models.DateRange, error) {
+	return s.bookedRanges(ctx, s.db, itemID, from, to)
+}
+
+// bookedRanges compares dates in Go so the check behaves the same on every driver
+func (s *sqlStore) bookedRanges(ctx context.Context, q queryer, itemID string, from, to time.Time) ([]models.DateRange, error) {
+	rows, err := q.QueryContext(ctx,
+		s.rebind("SELECT id, start_date, end_date FROM bookings WHERE item_id = ? AND status <> 'cancelled' ORDER BY start_date"), itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	booked := make([]models.DateRange, 0)
+	for rows.Next() {
+		var r models.DateRange
+		if err := rows.Scan(&r.BookingID, &r.Start, &r.End); err != nil {
+			return nil, err
+		}
+		if models.DatesOverlap(from, to, r.Start, r.End) {
+			booked = append(booked, r)
+		}
+	}
+	return booked, rows.Err()
+}
+
+// Payment operations
+const paymentColumns = "id, booking_id, amount, currency, status, payment_method, gateway_id, created_at, updated_at"
+
+func scanPayment(row rowScanner) (*models.Payment, error) {
+	var p models.Payment
+	err := row.Scan(&p.ID, &p.BookingID, &p.Amount, &p.Currency, &p.Status, &p.PaymentMethod, &p.GatewayID, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *sqlStore) CreatePayment(ctx context.Context, payment *models.Payment) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("INSERT INTO payments ("+paymentColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		payment.ID, payment.BookingID, payment.Amount, payment.Currency, payment.Status, payment.PaymentMethod,
+		payment.GatewayID, utc(payment.CreatedAt), utc(payment.UpdatedAt))
+	return s.translateError(err)
+}
+
+func (s *sqlStore) GetPayment(ctx context.Context, id string) (*models.Payment, error) {
+	return scanPayment(s.db.QueryRowContext(ctx, s.rebind("SELECT "+paymentColumns+" FROM payments WHERE id = ?"), id))
+}
+
+func (s *sqlStore) ListPayments(ctx context.Context, filter PaymentFilter) ([]*models.Payment, error) {
+	var where []string
+	var args []interface{}
+	if filter.BookingID != "" {
+		where = append(where, "booking_id = ?")
+		args = append(args, filter.BookingID)
+	}
+	if filter.UserID != "" {
+		where = append(where, "booking_id IN (SELECT id FROM bookings WHERE user_id = ?)")
+		args = append(args, filter.UserID)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.rebind("SELECT "+paymentColumns+" FROM payments"+whereClause(where)+" ORDER BY created_at"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := make([]*models.Payment, 0)
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+func (s *sqlStore) UpdatePayment(ctx context.Context, payment *models.Payment) error {
+	return s.execOne(ctx, s.db,
+		"UPDATE payments SET booking_id = ?, amount = ?, currency = ?, status = ?, payment_method = ?, gateway_id = ?, updated_at = ? WHERE id = ?",
+		payment.BookingID, payment.Amount, payment.Currency, payment.Status, payment.PaymentMethod,
+		payment.GatewayID, utc(payment.UpdatedAt), payment.ID)
+}
+
+// Query building helpers
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// noRebind leaves "?" placeholders untouched, for drivers that accept them natively
+func noRebind(query string) string {
+	return query
+}
+
+// wrapOpenError adds the backend name to errors raised while opening a store
+func wrapOpenError(backend string, err error) error {
+	return fmt.Errorf("open %s store: %w", backend, err)
+}
+
+// Session operations
+const sessionColumns = "id, user_id, user_agent, ip, created_at, last_seen_at, revoked_at"
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	var s models.Session
+	err := row.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.RevokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *sqlStore) CreateSession(ctx context.Context, session *models.Session) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("INSERT INTO sessions ("+sessionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)"),
+		session.ID, session.UserID, session.UserAgent, session.IP, utc(session.CreatedAt), utc(session.LastSeenAt), utcPtr(session.RevokedAt))
+	return s.translateError(err)
+}
+
+func (s *sqlStore) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	return scanSession(s.db.QueryRowContext(ctx, s.rebind("SELECT "+sessionColumns+" FROM sessions WHERE id = ?"), id))
+}
+
+func (s *sqlStore) ListSessions(ctx context.Context, userID string) ([]*models.Session, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind("SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? AND revoked_at IS NULL ORDER BY last_seen_at DESC, id DESC"), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*models.Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *sqlStore) TouchSession(ctx context.Context, id string, at time.Time, ip, userAgent string) error {
+	return s.execOne(ctx, s.db,
+		"UPDATE sessions SET last_seen_at = ?, ip = CASE WHEN ? = '' THEN ip ELSE ? END, user_agent = CASE WHEN ? = '' THEN user_agent ELSE ? END WHERE id = ?",
+		utc(at), ip, ip, userAgent, userAgent, id)
+}
+
+func (s *sqlStore) RevokeSessions(ctx context.Context, filter SessionFilter, at time.Time) (int, error) {
+	where := []string{"revoked_at IS NULL"}
+	args := []interface{}{utc(at)}
+	if filter.ID != "" {
+		where = append(where, "id = ?")
+		args = append(args, filter.ID)
+	}
+	if filter.UserID != "" {
+		where = append(where, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.ExceptID != "" {
+		where = append(where, "id <> ?")
+		args = append(args, filter.ExceptID)
+	}
+
+	res, err := s.db.ExecContext(ctx, s.rebind("UPDATE sessions SET revoked_at = ?"+whereClause(where)), args...)
+	if err != nil {
+		return 0, s.translateError(err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Refresh token operations
+const refreshTokenColumns = "id, session_id, user_id, token_hash, expires_at, created_at, rotated_at, replaced_by"
+
+func scanRefreshToken(row rowScanner) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	err := row.Scan(&t.ID, &t.SessionID, &t.UserID, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt, &t.RotatedAt, &t.ReplacedBy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *sqlStore) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return s.translateError(s.insertRefreshToken(ctx, s.db, token))
+}
+
+func (s *sqlStore) insertRefreshToken(ctx context.Context, q queryer, token *models.RefreshToken) error {
+	_, err := q.ExecContext(ctx, s.rebind("INSERT INTO refresh_tokens ("+refreshTokenColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"),
+		token.ID, token.SessionID, token.UserID, token.TokenHash, utc(token.ExpiresAt), utc(token.CreatedAt),
+		utcPtr(token.RotatedAt), token.ReplacedBy)
+	return err
+}
+
+func (s *sqlStore) GetRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error) {
+	return scanRefreshToken(s.db.QueryRowContext(ctx, s.rebind("SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE id = ?"), id))
+}
+
+func (s *sqlStore) RotateRefreshToken(ctx context.Context, oldID string, next *models.RefreshToken) error {
+	return s.inTx(ctx, nil, func(tx *sql.Tx) error {
+		// The conditional update is the compare-and-swap: only one exchange of oldID can win
+		err := s.execOne(ctx, tx, "UPDATE refresh_tokens SET rotated_at = ?, replaced_by = ? WHERE id = ? AND rotated_at IS NULL",
+			utc(next.CreatedAt), next.ID, oldID)
+		if errors.Is(err, ErrNotFound) {
+			var exists int
+			err = tx.QueryRowContext(ctx, s.rebind("SELECT 1 FROM refresh_tokens WHERE id = ?"), oldID).Scan(&exists)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			if err != nil {
+				return err
+			}
+			return ErrTokenReused
+		}
+		if err != nil {
+			return err
+		}
+		return s.insertRefreshToken(ctx, tx, next)
+	})
+}
+
+// One-time token operations
+const oneTimeTokenColumns = "id, user_id, purpose, token_hash, expires_at, created_at, used_at"
+
+func scanOneTimeToken(row rowScanner) (*models.OneTimeToken, error) {
+	var t models.OneTimeToken
+	err := row.Scan(&t.ID, &t.UserID, &t.Purpose, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt, &t.UsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *sqlStore) CreateOneTimeToken(ctx context.Context, token *models.OneTimeToken) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("INSERT INTO one_time_tokens ("+oneTimeTokenColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)"),
+		token.ID, token.UserID, token.Purpose, token.TokenHash, utc(token.ExpiresAt), utc(token.CreatedAt), utcPtr(token.UsedAt))
+	return s.translateError(err)
+}
+
+func (s *sqlStore) GetOneTimeToken(ctx context.Context, id string) (*models.OneTimeToken, error) {
+	return scanOneTimeToken(s.db.QueryRowContext(ctx, s.rebind("SELECT "+oneTimeTokenColumns+" FROM one_time_tokens WHERE id = ?"), id))
+}
+
+func (s *sqlStore) LatestOneTimeToken(ctx context.Context, userID, purpose string) (*models.OneTimeToken, error) {
+	return scanOneTimeToken(s.db.QueryRowContext(ctx, s.rebind("SELECT "+oneTimeTokenColumns+
+		" FROM one_time_tokens WHERE user_id = ? AND purpose = ? ORDER BY created_at DESC LIMIT 1"), userID, purpose))
+}
+
+func (s *sqlStore) ConsumeOneTimeToken(ctx context.Context, id string, at time.Time) error {
+	return s.inTx(ctx, nil, func(tx *sql.Tx) error {
+		err := s.execOne(ctx, tx, "UPDATE one_time_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL", utc(at), id)
+		if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		var exists int
+		err = tx.QueryRowContext(ctx, s.rebind("SELECT 1 FROM one_time_tokens WHERE id = ?"), id).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return ErrTokenReused
+	})
+}
+
+// Two-factor authentication operations
+const totpCredentialColumns = "user_id, secret, confirmed, last_used_step, created_at, confirmed_at"
+
+func scanTOTPCredential(row rowScanner) (*models.TOTPCredential, error) {
+	var c models.TOTPCredential
+	err := row.Scan(&c.UserID, &c.Secret, &c.Confirmed, &c.LastUsedStep, &c.CreatedAt, &c.ConfirmedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *sqlStore) SaveTOTPCredential(ctx context.Context, cred *models.TOTPCredential) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("INSERT INTO totp_credentials ("+totpCredentialColumns+") VALUES (?, ?, ?, ?, ?, ?)"+
+		" ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, confirmed = excluded.confirmed,"+
+		" last_used_step = excluded.last_used_step, created_at = excluded.created_at, confirmed_at = excluded.confirmed_at"),
+		cred.UserID, cred.Secret, cred.Confirmed, cred.LastUsedStep, utc(cred.CreatedAt), utcPtr(cred.ConfirmedAt))
+	return s.translateError(err)
+}
+
+func (s *sqlStore) GetTOTPCredential(ctx context.Context, userID string) (*models.TOTPCredential, error) {
+	return scanTOTPCredential(s.db.QueryRowContext(ctx, s.rebind("SELECT "+totpCredentialColumns+" FROM totp_credentials WHERE user_id = ?"), userID))
+}
+
+func (s *sqlStore) DeleteTOTPCredential(ctx context.Context, userID string) error {
+	return s.execOne(ctx, s.db, "DELETE FROM totp_credentials WHERE user_id = ?", userID)
+}
+
+func (s *sqlStore) AdvanceTOTPStep(ctx context.Context, userID string, step int64) error {
+	return s.inTx(ctx, nil, func(tx *sql.Tx) error {
+		err := s.execOne(ctx, tx, "UPDATE totp_credentials SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?", step, userID, step)
+		if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		var exists int
+		err = tx.QueryRowContext(ctx, s.rebind("SELECT 1 FROM totp_credentials WHERE user_id = ?"), userID).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return ErrTokenReused
+	})
+}
+
+const backupCodeColumns = "id, user_id, code_hash, created_at, used_at"
+
+func scanBackupCode(row rowScanner) (*models.BackupCode, error) {
+	var c models.BackupCode
+	err := row.Scan(&c.ID, &c.UserID, &c.CodeHash, &c.CreatedAt, &c.UsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *sqlStore) ReplaceBackupCodes(ctx context.Context, userID string, codes []*models.BackupCode) error {
+	return s.inTx(ctx, nil, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.rebind("DELETE FROM backup_codes WHERE user_id = ?"), userID); err != nil {
+			return err
+		}
+		for _, c := range codes {
+			if _, err := tx.ExecContext(ctx, s.rebind("INSERT INTO backup_codes ("+backupCodeColumns+") VALUES (?, ?, ?, ?, ?)"),
+				c.ID, c.UserID, c.CodeHash, utc(c.CreatedAt), utcPtr(c.UsedAt)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqlStore) ListBackupCodes(ctx context.Context, userID string) ([]*models.BackupCode, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind("SELECT "+backupCodeColumns+" FROM backup_codes WHERE user_id = ? ORDER BY id"), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := make([]*models.BackupCode, 0)
+	for rows.Next() {
+		c, err := scanBackupCode(rows)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, c)
+	}
+	return codes, rows.Err()
+}
+
+func (s *sqlStore) ConsumeBackupCode(ctx context.Context, id string, at time.Time) error {
+	return s.inTx(ctx, nil, func(tx *sql.Tx) error {
+		err := s.execOne(ctx, tx, "UPDATE backup_codes SET used_at = ? WHERE id = ? AND used_at IS NULL", utc(at), id)
+		if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		var exists int
+		err = tx.QueryRowContext(ctx, s.rebind("SELECT 1 FROM backup_codes WHERE id = ?"), id).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return ErrTokenReused
+	})
+}
+
+// External identity operations
+const identityColumns = "id, user_id, provider, subject, email, created_at"
+
+func (s *sqlStore) CreateIdentity(ctx context.Context, identity *models.Identity) error {
+	return s.inTx(ctx, nil, func(tx *sql.Tx) error {
+		var exists int
+		err := tx.QueryRowContext(ctx, s.rebind("SELECT 1 FROM user_identities WHERE provider = ? AND subject = ?"),
+			identity.Provider, identity.Subject).Scan(&exists)
+		if err == nil {
+			return ErrAlreadyExists
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, s.rebind("INSERT INTO user_identities ("+identityColumns+") VALUES (?, ?, ?, ?, ?, ?)"),
+			identity.ID, identity.UserID, identity.Provider, identity.Subject, identity.Email, utc(identity.CreatedAt))
+		return err
+	})
+}
+
+func (s *sqlStore) GetIdentity(ctx context.Context, provider, subject string) (*models.Identity, error) {
+	var i models.Identity
+	err := s.db.QueryRowContext(ctx, s.rebind("SELECT "+identityColumns+" FROM user_identities WHERE provider = ? AND subject = ?"), provider, subject).
+		Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// Login throttling operations
+const loginThrottleColumns = "key, failures, last_failure_at"
+
+func scanLoginThrottle(row rowScanner) (*models.LoginThrottle, error) {
+	var t models.LoginThrottle
+	err := row.Scan(&t.Key, &t.Failures, &t.LastFailureAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *sqlStore) GetLoginThrottle(ctx context.Context, key string) (*models.LoginThrottle, error) {
+	return scanLoginThrottle(s.db.QueryRowContext(ctx, s.rebind("SELECT "+loginThrottleColumns+" FROM login_throttles WHERE key = ?"), key))
+}
+
+func (s *sqlStore) ReserveLoginAttempt(ctx context.Context, key string, at, since time.Time, locked func(*models.LoginThrottle) bool) (*models.LoginThrottle, bool, error) {
+	var throttle *models.LoginThrottle
+	var reserved bool
+	// Attempts on one key must not interleave between the check and the count
+	err := s.inTx(ctx, s.txOptions, func(tx *sql.Tx) error {
+		previous, err := scanLoginThrottle(tx.QueryRowContext(ctx, s.rebind("SELECT "+loginThrottleColumns+" FROM login_throttles WHERE key = ?"), key))
+		if errors.Is(err, ErrNotFound) || (err == nil && previous.LastFailureAt.Before(since)) {
+			previous, err = nil, nil
+		}
+		if err != nil {
+			return err
+		}
+		if locked(previous) {
+			throttle, reserved = previous, false
+			return nil
+		}
+
+		throttle = &models.LoginThrottle{Key: key, Failures: 1, LastFailureAt: at}
+		if previous != nil {
+			throttle.Failures = previous.Failures + 1
+		}
+		_, err = tx.ExecContext(ctx, s.rebind("INSERT INTO login_throttles ("+loginThrottleColumns+") VALUES (?, ?, ?)"+
+			" ON CONFLICT (key) DO UPDATE SET failures = excluded.failures, last_failure_at = excluded.last_failure_at"),
+			key, throttle.Failures, utc(at))
+		reserved = true
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return throttle, reserved, nil
+}
+
+func (s *sqlStore) ReleaseLoginAttempt(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("UPDATE login_throttles SET failures = failures - 1 WHERE key = ? AND failures > 0"), key)
+	return err
+}
+
+func (s *sqlStore) ClearLoginThrottle(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("DELETE FROM login_throttles WHERE key = ?"), key)
+	return err
+}
+
+// Security event operations
+const securityEventColumns = "id, type, user_id, email, ip, created_at"
+
+func (s *sqlStore) RecordSecurityEvent(ctx context.Context, event *models.SecurityEvent) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("INSERT INTO security_events ("+securityEventColumns+") VALUES (?, ?, ?, ?, ?, ?)"),
+		event.ID, event.Type, event.UserID, event.Email, event.IP, utc(event.CreatedAt))
+	return s.translateError(err)
+}
+
+// API key operations
+const apiKeyColumns = "id, user_id, name, key_hash, scopes, expires_at, last_used_at, created_at, revoked_at"
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var k models.APIKey
+	var scopes string
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.KeyHash, &scopes, &k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt, &k.RevokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Scopes are stored space-separated, as in OAuth
+	k.Scopes = strings.Fields(scopes)
+	return &k, nil
+}
+
+func (s *sqlStore) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("INSERT INTO api_keys ("+apiKeyColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		key.ID, key.UserID, key.Name, key.KeyHash, strings.Join(key.Scopes, " "), utcPtr(key.ExpiresAt),
+		utcPtr(key.LastUsedAt), utc(key.CreatedAt), utcPtr(key.RevokedAt))
+	return s.translateError(err)
+}
+
+func (s *sqlStore) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return scanAPIKey(s.db.QueryRowContext(ctx, s.rebind("SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?"), id))
+}
+
+func (s *sqlStore) ListAPIKeys(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind("SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = ? ORDER BY created_at"), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*models.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (s *sqlStore) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	return s.execOne(ctx, s.db, "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?", utc(at), id)
+}
+
+func (s *sqlStore) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	return s.execOne(ctx, s.db, "UPDATE api_keys SET last_used_at = ? WHERE id = ?", utc(at), id)
+}
+
+// Audit log operations
+const auditEntryColumns = "id, actor_id, action, target_type, target_id, reason, details, created_at"
+
+func scanAuditEntry(row rowScanner) (*models.AuditEntry, error) {
+	var e models.AuditEntry
+	err := row.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetType, &e.TargetID, &e.Reason, &e.Details, &e.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *sqlStore) RecordAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("INSERT INTO audit_log ("+auditEntryColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"),
+		entry.ID, entry.ActorID, entry.Action, entry.TargetType, entry.TargetID, entry.Reason, entry.Details, utc(entry.CreatedAt))
+	return s.translateError(err)
+}
+
+func (s *sqlStore) ListAuditEntries(ctx context.Context, filter AuditFilter) ([]*models.AuditEntry, error) {
+	var where []string
+	var args []interface{}
+	if filter.ActorID != "" {
+		where = append(where, "actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.TargetID != "" {
+		where = append(where, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.rebind("SELECT "+auditEntryColumns+" FROM audit_log"+whereClause(where)+" ORDER BY created_at"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*models.AuditEntry, 0)
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,296 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+
+	"modernc.org/sqlite" // Pure Go driver, keeps CGO_ENABLED=0 builds working
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteMigrations is the SQLite schema history. Append new migrations; never edit shipped ones.
+var sqliteMigrations = []migration{
+	{
+		version: 1,
+		name:    "create users, items, bookings and payments",
+		statements: []string{
+			`CREATE TABLE users (
+				id         TEXT PRIMARY KEY,
+				username   TEXT NOT NULL DEFAULT '',
+				email      TEXT NOT NULL UNIQUE,
+				password   TEXT NOT NULL DEFAULT '',
+				first_name TEXT NOT NULL DEFAULT '',
+				last_name  TEXT NOT NULL DEFAULT '',
+				phone      TEXT NOT NULL DEFAULT '',
+				address    TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL
+			)`,
+			`CREATE TABLE items (
+				id          TEXT PRIMARY KEY,
+				name        TEXT NOT NULL,
+				title       TEXT NOT NULL DEFAULT '',
+				description TEXT NOT NULL DEFAULT '',
+				daily_rate  REAL NOT NULL,
+				price       INTEGER NOT NULL DEFAULT 0,
+				image_url   TEXT NOT NULL DEFAULT '',
+				owner_id    TEXT NOT NULL,
+				available   BOOLEAN NOT NULL DEFAULT 1,
+				created_at  DATETIME NOT NULL
+			)`,
+			`CREATE INDEX idx_items_owner ON items (owner_id)`,
+			`CREATE TABLE bookings (
+				id          TEXT PRIMARY KEY,
+				item_id     TEXT NOT NULL,
+				user_id     TEXT NOT NULL,
+				start_date  DATETIME NOT NULL,
+				end_date    DATETIME NOT NULL,
+				total_price REAL NOT NULL,
+				status      TEXT NOT NULL,
+				payment_id  TEXT NOT NULL DEFAULT '',
+				created_at  DATETIME NOT NULL,
+				updated_at  DATETIME NOT NULL
+			)`,
+			`CREATE INDEX idx_bookings_item ON bookings (item_id)`,
+			`CREATE INDEX idx_bookings_user ON bookings (user_id)`,
+			`CREATE TABLE payments (
+				id             TEXT PRIMARY KEY,
+				booking_id     TEXT NOT NULL,
+				amount         REAL NOT NULL,
+				currency       TEXT NOT NULL,
+				status         TEXT NOT NULL,
+				payment_method TEXT NOT NULL,
+				gateway_id     TEXT NOT NULL DEFAULT '',
+				created_at     DATETIME NOT NULL,
+				updated_at     DATETIME NOT NULL
+			)`,
+			`CREATE INDEX idx_payments_booking ON payments (booking_id)`,
+		},
+	},
+	{
+		version: 2,
+		name:    "create sessions and refresh tokens",
+		statements: []string{
+			`CREATE TABLE sessions (
+				id         TEXT PRIMARY KEY,
+				user_id    TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				revoked_at DATETIME
+			)`,
+			`CREATE INDEX idx_sessions_user ON sessions (user_id)`,
+			`CREATE TABLE refresh_tokens (
+				id          TEXT PRIMARY KEY,
+				session_id  TEXT NOT NULL,
+				user_id     TEXT NOT NULL,
+				token_hash  TEXT NOT NULL,
+				expires_at  DATETIME NOT NULL,
+				created_at  DATETIME NOT NULL,
+				rotated_at  DATETIME,
+				replaced_by TEXT NOT NULL DEFAULT ''
+			)`,
+			`CREATE INDEX idx_refresh_tokens_session ON refresh_tokens (session_id)`,
+		},
+	},
+	{
+		version: 3,
+		name:    "create one-time tokens",
+		statements: []string{
+			`CREATE TABLE one_time_tokens (
+				id         TEXT PRIMARY KEY,
+				user_id    TEXT NOT NULL,
+				purpose    TEXT NOT NULL,
+				token_hash TEXT NOT NULL,
+				expires_at DATETIME NOT NULL,
+				created_at DATETIME NOT NULL,
+				used_at    DATETIME
+			)`,
+			`CREATE INDEX idx_one_time_tokens_user ON one_time_tokens (user_id, purpose)`,
+		},
+	},
+	{
+		version: 4,
+		name:    "add users.verified",
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN verified BOOLEAN NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 5,
+		name:    "create two-factor credentials and backup codes",
+		statements: []string{
+			`CREATE TABLE totp_credentials (
+				user_id        TEXT PRIMARY KEY,
+				secret         TEXT NOT NULL,
+				confirmed      BOOLEAN NOT NULL DEFAULT 0,
+				last_used_step INTEGER NOT NULL DEFAULT 0,
+				created_at     DATETIME NOT NULL,
+				confirmed_at   DATETIME
+			)`,
+			`CREATE TABLE backup_codes (
+				id         TEXT PRIMARY KEY,
+				user_id    TEXT NOT NULL,
+				code_hash  TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				used_at    DATETIME
+			)`,
+			`CREATE INDEX idx_backup_codes_user ON backup_codes (user_id)`,
+		},
+	},
+	{
+		version: 6,
+		name:    "create user identities",
+		statements: []string{
+			`CREATE TABLE user_identities (
+				id         TEXT PRIMARY KEY,
+				user_id    TEXT NOT NULL,
+				provider   TEXT NOT NULL,
+				subject    TEXT NOT NULL,
+				email      TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL,
+				UNIQUE (provider, subject)
+			)`,
+			`CREATE INDEX idx_user_identities_user ON user_identities (user_id)`,
+		},
+	},
+	{
+		version: 7,
+		name:    "create login throttles and security events",
+		statements: []string{
+			`CREATE TABLE login_throttles (
+				key             TEXT PRIMARY KEY,
+				failures        INTEGER NOT NULL,
+				last_failure_at DATETIME NOT NULL
+			)`,
+			`CREATE TABLE security_events (
+				id         TEXT PRIMARY KEY,
+				type       TEXT NOT NULL,
+				user_id    TEXT NOT NULL DEFAULT '',
+				email      TEXT NOT NULL DEFAULT '',
+				ip         TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL
+			)`,
+			`CREATE INDEX idx_security_events_user ON security_events (user_id, created_at)`,
+		},
+	},
+	{
+		version: 8,
+		name:    "add roles and suspensions, create the audit log",
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'`,
+			`ALTER TABLE users ADD COLUMN suspended_at DATETIME`,
+			`ALTER TABLE items ADD COLUMN suspended_at DATETIME`,
+			`CREATE TABLE audit_log (
+				id          TEXT PRIMARY KEY,
+				actor_id    TEXT NOT NULL,
+				action      TEXT NOT NULL,
+				target_type TEXT NOT NULL,
+				target_id   TEXT NOT NULL DEFAULT '',
+				reason      TEXT NOT NULL DEFAULT '',
+				details     TEXT NOT NULL DEFAULT '',
+				created_at  DATETIME NOT NULL
+			)`,
+			`CREATE INDEX idx_audit_log_target ON audit_log (target_id, created_at)`,
+		},
+	},
+	{
+		version: 9,
+		name:    "create api keys",
+		statements: []string{
+			`CREATE TABLE api_keys (
+				id           TEXT PRIMARY KEY,
+				user_id      TEXT NOT NULL,
+				name         TEXT NOT NULL,
+				key_hash     TEXT NOT NULL,
+				scopes       TEXT NOT NULL,
+				expires_at   DATETIME,
+				last_used_at DATETIME,
+				created_at   DATETIME NOT NULL,
+				revoked_at   DATETIME
+			)`,
+			`CREATE INDEX idx_api_keys_user ON api_keys (user_id)`,
+		},
+	},
+	{
+		version: 10,
+		name:    "add users.deleted_at",
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN deleted_at DATETIME`,
+		},
+	},
+	{
+		version: 11,
+		name:    "add session devices and last-seen times",
+		statements: []string{
+			`ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE sessions ADD COLUMN ip TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE sessions ADD COLUMN last_seen_at DATETIME`,
+			`UPDATE sessions SET last_seen_at = created_at`,
+		},
+	},
+	{
+		version: 12,
+		name:    "add item categories, tags and attributes",
+		statements: []string{
+			`ALTER TABLE items ADD COLUMN category TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE items ADD COLUMN tags TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE items ADD COLUMN attributes TEXT NOT NULL DEFAULT '{}'`,
+			`CREATE INDEX idx_items_category ON items (category)`,
+		},
+	},
+	{
+		version: 13,
+		name:    "lower-case user emails",
+		statements: []string{
+			// Except where that would merge two accounts, which is left for an administrator
+			`UPDATE users SET email = LOWER(TRIM(email))
+				WHERE email <> LOWER(TRIM(email))
+				AND (SELECT COUNT(*) FROM users other WHERE LOWER(TRIM(other.email)) = LOWER(TRIM(users.email))) = 1`,
+		},
+	},
+}
+
+// SQLiteStore is a single-file Store backed by an embedded SQLite database
+type SQLiteStore struct {
+	sqlStore
+}
+
+// NewSQLiteStore opens (or creates) the database at path and applies pending migrations
+func NewSQLiteStore(ctx context.Context, path string) (*SQLiteStore, error) {
+	params := url.Values{}
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "foreign_keys(1)")
+	// Writers take the lock up front so availability checks cannot interleave
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, wrapOpenError("sqlite", err)
+	}
+	// SQLite allows a single writer; one connection avoids SQLITE_BUSY between our own goroutines
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, wrapOpenError("sqlite", err)
+	}
+	if err := runMigrations(ctx, db, sqliteMigrations, noRebind, nil); err != nil {
+		db.Close()
+		return nil, wrapOpenError("sqlite", err)
+	}
+
+	return &SQLiteStore{sqlStore{db: db, rebind: noRebind, translate: translateSQLiteError}}, nil
+}
+
+// translateSQLiteError maps constraint violations to store errors
+func translateSQLiteError(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY, sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+			return ErrAlreadyExists
+		}
+	}
+	return err
+}
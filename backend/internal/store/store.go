@@ -0,0 +1,222 @@
+// Package store defines the persistence interface and its backends:
+// an in-memory Database (optionally durable via snapshots), SQLite and PostgreSQL.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"borrowhub/internal/config"
+	"borrowhub/internal/models"
+)
+
+// Store errors returned by every backend so handlers can map them to HTTP statuses
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrAlreadyExists   = errors.New("already exists")
+	ErrBookingConflict = errors.New("item is not available for the selected dates")
+	ErrItemHasBookings = errors.New("item has active bookings")
+	ErrUserHasBookings = errors.New("user has active bookings")
+	ErrTokenReused     = errors.New("token already used")
+)
+
+// ItemFilter narrows ListItems results. Zero values match everything.
+type ItemFilter struct {
+	OwnerID       string
+	AvailableOnly bool
+	ListedOnly    bool     // Leave out items a moderator has suspended
+	Categories    []string // Only items in one of these categories
+	Tags          []string // Only items carrying every one of these tags
+}
+
+// BookingFilter narrows ListBookings results. Zero values match everything.
+type BookingFilter struct {
+	UserID      string   // Bookings made by this user
+	ItemID      string   // Bookings for this item
+	ItemOwnerID string   // Bookings for items owned by this user
+	Statuses    []string // Only bookings in one of these statuses
+}
+
+// PaymentFilter narrows ListPayments results. Zero values match everything.
+type PaymentFilter struct {
+	UserID    string // Payments for bookings made by this user
+	BookingID string
+}
+
+// SessionFilter selects sessions. Zero values match everything.
+type SessionFilter struct {
+	ID       string
+	UserID   string
+	ExceptID string // Leaves this session alone, e.g. the caller's own
+}
+
+// AuditFilter narrows ListAuditEntries results. Zero values match everything.
+type AuditFilter struct {
+	ActorID  string
+	TargetID string
+}
+
+// Store is the persistence layer used by the HTTP handlers.
+// Implementations must be safe for concurrent use and must return copies,
+// so callers can freely modify the returned models and persist them with
+// the matching Update method.
+type Store interface {
+	// Users
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUser(ctx context.Context, id string) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	ListUsers(ctx context.Context) ([]*models.User, error)
+	UpdateUser(ctx context.Context, user *models.User) error
+	// EraseUser replaces the user record with user, already stripped of
+	// personal data, and removes what else identifies them: linked
+	// identities, two-factor credentials, backup codes and one-time tokens
+	// are deleted, sessions and API keys revoked, and their sessions and
+	// security events lose the email address, IP and user agent. Bookings, payments and audit entries are kept.
+	// Their items leave the catalog: those never booked are deleted, the
+	// others made unavailable. It refuses with ErrUserHasBookings, changing
+	// nothing, while the user has pending or confirmed bookings, made or
+	// received.
+	EraseUser(ctx context.Context, user *models.User, at time.Time) error
+
+	// Items
+	CreateItem(ctx context.Context, item *models.Item) error
+	GetItem(ctx context.Context, id string) (*models.Item, error)
+	ListItems(ctx context.Context, filter ItemFilter) ([]*models.Item, error)
+	UpdateItem(ctx context.Context, item *models.Item) error
+	// DeleteItem refuses with ErrItemHasBookings while the item has pending or confirmed bookings
+	DeleteItem(ctx context.Context, id string) error
+
+	// Bookings
+	// CreateBooking atomically checks for overlapping bookings and inserts,
+	// returning ErrBookingConflict when the dates are taken. It returns
+	// ErrNotFound if the item is missing or its owner or the renter has
+	// deleted their account.
+	CreateBooking(ctx context.Context, booking *models.Booking) error
+	GetBooking(ctx context.Context, id string) (*models.Booking, error)
+	ListBookings(ctx context.Context, filter BookingFilter) ([]*models.Booking, error)
+	UpdateBooking(ctx context.Context, booking *models.Booking) error
+	// SetBookingStatus changes the status of booking id as of at, returning
+	// the updated booking and its previous status. Re-activating a cancelled
+	// booking re-checks its dates atomically with the change, returning
+	// ErrBookingConflict when they have been taken.
+	SetBookingStatus(ctx context.Context, id, status string, at time.Time) (*models.Booking, string, error)
+	// IsItemAvailable reports whether no active booking overlaps [start, end)
+	IsItemAvailable(ctx context.Context, itemID string, start, end time.Time) (bool, error)
+	// BookedRanges returns the active bookings of an item that intersect [from, to)
+	BookedRanges(ctx context.Context, itemID string, from, to time.Time) ([]models.DateRange, error)
+
+	// Payments
+	CreatePayment(ctx context.Context, payment *models.Payment) error
+	GetPayment(ctx context.Context, id string) (*models.Payment, error)
+	ListPayments(ctx context.Context, filter PaymentFilter) ([]*models.Payment, error)
+	UpdatePayment(ctx context.Context, payment *models.Payment) error
+
+	// Sessions and refresh tokens
+	CreateSession(ctx context.Context, session *models.Session) error
+	GetSession(ctx context.Context, id string) (*models.Session, error)
+	// ListSessions returns the active sessions of the user, most recently seen first
+	ListSessions(ctx context.Context, userID string) ([]*models.Session, error)
+	// TouchSession records that the session was used at the given time. An
+	// empty ip or userAgent leaves the stored one unchanged.
+	TouchSession(ctx context.Context, id string, at time.Time, ip, userAgent string) error
+	// RevokeSessions revokes the matching active sessions and returns how many it revoked
+	RevokeSessions(ctx context.Context, filter SessionFilter, at time.Time) (int, error)
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error)
+	// RotateRefreshToken atomically marks oldID as rotated into next and
+	// stores next. It returns ErrTokenReused if oldID was already rotated.
+	RotateRefreshToken(ctx context.Context, oldID string, next *models.RefreshToken) error
+
+	// One-time tokens
+	CreateOneTimeToken(ctx context.Context, token *models.OneTimeToken) error
+	GetOneTimeToken(ctx context.Context, id string) (*models.OneTimeToken, error)
+	// LatestOneTimeToken returns the most recently created token of the user for purpose
+	LatestOneTimeToken(ctx context.Context, userID, purpose string) (*models.OneTimeToken, error)
+	// ConsumeOneTimeToken atomically marks the token used. It returns
+	// ErrTokenReused if the token was already used.
+	ConsumeOneTimeToken(ctx context.Context, id string, at time.Time) error
+
+	// Two-factor authentication
+	// SaveTOTPCredential creates or replaces the credential of cred.UserID
+	SaveTOTPCredential(ctx context.Context, cred *models.TOTPCredential) error
+	GetTOTPCredential(ctx context.Context, userID string) (*models.TOTPCredential, error)
+	DeleteTOTPCredential(ctx context.Context, userID string) error
+	// AdvanceTOTPStep atomically records step as the last accepted time
+	// step. It returns ErrTokenReused unless step is later than the last one.
+	AdvanceTOTPStep(ctx context.Context, userID string, step int64) error
+	// ReplaceBackupCodes deletes the user's backup codes and stores codes instead
+	ReplaceBackupCodes(ctx context.Context, userID string, codes []*models.BackupCode) error
+	ListBackupCodes(ctx context.Context, userID string) ([]*models.BackupCode, error)
+	// ConsumeBackupCode atomically marks the code used. It returns
+	// ErrTokenReused if the code was already used.
+	ConsumeBackupCode(ctx context.Context, id string, at time.Time) error
+
+	// External identities
+	// CreateIdentity returns ErrAlreadyExists if the provider and subject are already linked
+	CreateIdentity(ctx context.Context, identity *models.Identity) error
+	GetIdentity(ctx context.Context, provider, subject string) (*models.Identity, error)
+
+	// Login throttling
+	GetLoginThrottle(ctx context.Context, key string) (*models.LoginThrottle, error)
+	// ReserveLoginAttempt counts an attempt for key at the given time before
+	// it is checked, unless locked reports that the attempts counted so far
+	// (nil if none) lock key out. Checking and counting are atomic, so
+	// concurrent attempts cannot all get in under a threshold. It returns
+	// the throttle as it is afterwards and whether the attempt was counted.
+	// Attempts before since are forgotten: the count starts over at 1.
+	ReserveLoginAttempt(ctx context.Context, key string, at, since time.Time, locked func(*models.LoginThrottle) bool) (*models.LoginThrottle, bool, error)
+	// ReleaseLoginAttempt takes back an attempt counted for key that did not
+	// fail; it is not an error if there is none
+	ReleaseLoginAttempt(ctx context.Context, key string) error
+	// ClearLoginThrottle forgets the failures of key; it is not an error if there are none
+	ClearLoginThrottle(ctx context.Context, key string) error
+
+	// Security event log
+	RecordSecurityEvent(ctx context.Context, event *models.SecurityEvent) error
+
+	// API keys
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	GetAPIKey(ctx context.Context, id string) (*models.APIKey, error)
+	// ListAPIKeys returns the user's keys, revoked and expired ones included
+	ListAPIKeys(ctx context.Context, userID string) ([]*models.APIKey, error)
+	// RevokeAPIKey marks the key revoked; revoking it again is not an error
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) error
+	// TouchAPIKey records that the key was used at the given time
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+
+	// Admin audit log
+	RecordAuditEntry(ctx context.Context, entry *models.AuditEntry) error
+	ListAuditEntries(ctx context.Context, filter AuditFilter) ([]*models.AuditEntry, error)
+}
+
+// hasStatus reports whether status is in statuses, treating an empty list as a match
+func hasStatus(statuses []string, status string) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Open opens the configured Store backend
+func Open(ctx context.Context, cfg config.StoreConfig) (Store, error) {
+	switch cfg.Backend {
+	case "memory":
+		if cfg.SnapshotPath != "" {
+			return NewPersistentDatabase(cfg.SnapshotPath)
+		}
+		return NewDatabase(), nil
+	case "sqlite":
+		return NewSQLiteStore(ctx, cfg.SQLitePath)
+	case "postgres":
+		return NewPostgresStore(ctx, cfg.DatabaseURL)
+	default:
+		return nil, fmt.Errorf("unknown store backend %q (expected memory, sqlite or postgres)", cfg.Backend)
+	}
+}
@@ -0,0 +1,903 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"borrowhub/internal/models"
+)
+
+// eachBackend runs test against an empty store of every kind: in memory,
+// SQLite in a temporary file and, when BORROWHUB_TEST_DATABASE_URL is set,
+// PostgreSQL in a schema of its own
+func eachBackend(t *testing.T, test func(t *testing.T, s Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewDatabase())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		s, err := NewSQLiteStore(context.Background(), filepath.Join(t.TempDir(), "borrowhub.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		test(t, s)
+	})
+	t.Run("postgres", func(t *testing.T) {
+		test(t, openPostgres(t, postgresSchema(t)))
+	})
+}
+
+func TestConcurrentReactivations(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		if err := s.CreateItem(ctx, &models.Item{ID: "itm_tent", Name: "Tent", DailyRate: 10, OwnerID: "usr_owner", Available: true, CreatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Cancelled bookings do not block each other, whatever their dates
+		start := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+		const renters = 8
+		for i := range renters {
+			err := s.CreateBooking(ctx, &models.Booking{
+				ID:        fmt.Sprintf("bkg_%d", i),
+				ItemID:    "itm_tent",
+				UserID:    fmt.Sprintf("usr_renter_%d", i),
+				StartDate: start.AddDate(0, 0, i%2),
+				EndDate:   start.AddDate(0, 0, 3),
+				Status:    models.BookingCancelled,
+				CreatedAt: now,
+				UpdatedAt: now,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var wg sync.WaitGroup
+		results := make(chan error, renters)
+		for i := range renters {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				booking, previous, err := s.SetBookingStatus(ctx, fmt.Sprintf("bkg_%d", i), models.BookingPending, now.Add(time.Minute))
+				if err == nil && (booking.Status != models.BookingPending || !booking.UpdatedAt.Equal(now.Add(time.Minute)) || previous != models.BookingCancelled) {
+					err = fmt.Errorf("updated to %s at %v from %s", booking.Status, booking.UpdatedAt, previous)
+				}
+				results <- err
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		reactivated := 0
+		for err := range results {
+			switch {
+			case err == nil:
+				reactivated++
+			case !errors.Is(err, ErrBookingConflict):
+				t.Errorf("SetBookingStatus: %v", err)
+			}
+		}
+		if reactivated != 1 {
+			t.Errorf("%d overlapping bookings were re-activated, want exactly 1", reactivated)
+		}
+
+		active, err := s.ListBookings(ctx, BookingFilter{ItemID: "itm_tent", Statuses: []string{models.BookingPending}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(active) != 1 {
+			t.Fatalf("item has %d pending bookings, want 1", len(active))
+		}
+		// Changes that leave the dates blocked or free need no check
+		if _, previous, err := s.SetBookingStatus(ctx, active[0].ID, models.BookingConfirmed, now); err != nil || previous != models.BookingPending {
+			t.Errorf("confirming the re-activated booking: previous %q, %v", previous, err)
+		}
+		if _, _, err := s.SetBookingStatus(ctx, "bkg_missing", models.BookingPending, now); !errors.Is(err, ErrNotFound) {
+			t.Errorf("SetBookingStatus of a missing booking: %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestReserveLoginAttempt(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		since := now.Add(-time.Hour)
+		// The key locks after three attempts
+		locked := func(throttle *models.LoginThrottle) bool {
+			return throttle != nil && throttle.Failures >= 3
+		}
+
+		const attempts = 10
+		var wg sync.WaitGroup
+		results := make(chan bool, attempts)
+		for range attempts {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, reserved, err := s.ReserveLoginAttempt(ctx, "email:ana@example.com", now, since, locked)
+				if err != nil {
+					t.Errorf("ReserveLoginAttempt: %v", err)
+				}
+				results <- reserved
+			}()
+		}
+		wg.Wait()
+		close(results)
+		reserved := 0
+		for ok := range results {
+			if ok {
+				reserved++
+			}
+		}
+		if reserved != 3 {
+			t.Errorf("%d of %d concurrent attempts were counted, want 3", reserved, attempts)
+		}
+
+		// Releasing one makes room for one more
+		if err := s.ReleaseLoginAttempt(ctx, "email:ana@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		throttle, ok, err := s.ReserveLoginAttempt(ctx, "email:ana@example.com", now, since, locked)
+		if err != nil || !ok || throttle.Failures != 3 || !throttle.LastFailureAt.Equal(now) {
+			t.Errorf("attempt after a release: %+v counted %v (err %v), want the third", throttle, ok, err)
+		}
+		if throttle, ok, _ := s.ReserveLoginAttempt(ctx, "email:ana@example.com", now, since, locked); ok || throttle.Failures != 3 {
+			t.Errorf("attempt while locked: %+v counted %v, want refused with 3 failures", throttle, ok)
+		}
+
+		// Attempts before since are forgotten
+		later := now.Add(2 * time.Hour)
+		throttle, ok, err = s.ReserveLoginAttempt(ctx, "email:ana@example.com", later, later.Add(-time.Hour), func(previous *models.LoginThrottle) bool {
+			if previous != nil {
+				t.Errorf("stale attempts passed to locked: %+v", previous)
+			}
+			return false
+		})
+		if err != nil || !ok || throttle.Failures != 1 {
+			t.Errorf("attempt after the failures expired: %+v counted %v (err %v), want the first", throttle, ok, err)
+		}
+
+		// Releasing a key without attempts is not an error
+		if err := s.ReleaseLoginAttempt(ctx, "ip:192.0.2.1"); err != nil {
+			t.Errorf("ReleaseLoginAttempt of an unknown key: %v", err)
+		}
+		if err := s.ClearLoginThrottle(ctx, "email:ana@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetLoginThrottle(ctx, "email:ana@example.com"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetLoginThrottle after clearing: %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestEraseUser(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		for _, email := range []string{"ana@example.com", "ben@example.com"} {
+			err := s.CreateUser(ctx, &models.User{ID: "usr_" + email, Username: email, Email: email, Password: "hash", Role: models.RoleUser, CreatedAt: now})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, item := range []*models.Item{
+			{ID: "itm_tent", Name: "Tent", DailyRate: 10, OwnerID: "usr_ana@example.com", Available: true, CreatedAt: now},
+			{ID: "itm_stove", Name: "Stove", DailyRate: 5, OwnerID: "usr_ana@example.com", Available: true, CreatedAt: now},
+			{ID: "itm_kayak", Name: "Kayak", DailyRate: 30, OwnerID: "usr_ben@example.com", Available: true, CreatedAt: now},
+		} {
+			if err := s.CreateItem(ctx, item); err != nil {
+				t.Fatal(err)
+			}
+		}
+		start := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+		book := func(id, itemID, userID string, offset int) *models.Booking {
+			t.Helper()
+			booking := &models.Booking{ID: id, ItemID: itemID, UserID: userID, StartDate: start.AddDate(0, 0, offset), EndDate: start.AddDate(0, 0, offset+2),
+				Status: models.BookingPending, CreatedAt: now, UpdatedAt: now}
+			if err := s.CreateBooking(ctx, booking); err != nil {
+				t.Fatal(err)
+			}
+			return booking
+		}
+		received := book("bkg_received", "itm_tent", "usr_ben@example.com", 0)
+		made := book("bkg_made", "itm_kayak", "usr_ana@example.com", 0)
+
+		erased := &models.User{ID: "usr_ana@example.com", Username: "deleted-user", Email: "usr_ana@example.com@deleted.invalid", Role: models.RoleUser, DeletedAt: &now, CreatedAt: now}
+		for _, booking := range []*models.Booking{received, made} {
+			if err := s.EraseUser(ctx, erased, now); !errors.Is(err, ErrUserHasBookings) {
+				t.Fatalf("EraseUser with an active booking: %v, want ErrUserHasBookings", err)
+			}
+			// Refused erasures change nothing
+			if user, err := s.GetUser(ctx, erased.ID); err != nil || user.Deleted() || user.Email != "ana@example.com" {
+				t.Fatalf("user after a refused erasure: %+v, %v", user, err)
+			}
+			if _, err := s.GetItem(ctx, "itm_stove"); err != nil {
+				t.Fatalf("never booked item after a refused erasure: %v", err)
+			}
+			if _, _, err := s.SetBookingStatus(ctx, booking.ID, models.BookingCancelled, now); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := s.EraseUser(ctx, erased, now); err != nil {
+			t.Fatalf("EraseUser: %v", err)
+		}
+		user, err := s.GetUser(ctx, erased.ID)
+		if err != nil || !user.Deleted() || user.Email != erased.Email || user.Password != "" {
+			t.Errorf("erased user: %+v, %v", user, err)
+		}
+		if _, err := s.GetItem(ctx, "itm_stove"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("never booked item: %v, want ErrNotFound", err)
+		}
+		if item, err := s.GetItem(ctx, "itm_tent"); err != nil || item.Available {
+			t.Errorf("booked item: %+v, %v, want kept but unavailable", item, err)
+		}
+		if item, err := s.GetItem(ctx, "itm_kayak"); err != nil || !item.Available {
+			t.Errorf("item of another user: %+v, %v, want unchanged", item, err)
+		}
+
+		// Nobody books with or from a deleted account
+		if err := s.CreateBooking(ctx, &models.Booking{ID: "bkg_by_deleted", ItemID: "itm_kayak", UserID: erased.ID, StartDate: start.AddDate(0, 0, 10), EndDate: start.AddDate(0, 0, 12),
+			Status: models.BookingPending, CreatedAt: now, UpdatedAt: now}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("CreateBooking by a deleted user: %v, want ErrNotFound", err)
+		}
+		if err := s.CreateBooking(ctx, &models.Booking{ID: "bkg_of_deleted", ItemID: "itm_tent", UserID: "usr_ben@example.com", StartDate: start.AddDate(0, 0, 10), EndDate: start.AddDate(0, 0, 12),
+			Status: models.BookingPending, CreatedAt: now, UpdatedAt: now}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("CreateBooking of a deleted user's item: %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestUsers(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		ana := &models.User{ID: "usr_ana", Username: "ana", Email: "ana@example.com", Password: "hash", FirstName: "Ana",
+			Phone: "+44 20 7946 0000", Verified: true, Role: models.RoleUser, CreatedAt: now}
+		ben := &models.User{ID: "usr_ben", Username: "ben", Email: "ben@example.com", Role: models.RoleAdmin, SuspendedAt: &now, CreatedAt: now.Add(time.Second)}
+		for _, user := range []*models.User{ana, ben} {
+			if err := s.CreateUser(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.CreateUser(ctx, &models.User{ID: "usr_other", Email: ana.Email, Role: models.RoleUser, CreatedAt: now}); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("CreateUser with a taken email: %v, want ErrAlreadyExists", err)
+		}
+		if err := s.CreateUser(ctx, &models.User{ID: ana.ID, Email: "other@example.com", Role: models.RoleUser, CreatedAt: now}); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("CreateUser with a taken ID: %v, want ErrAlreadyExists", err)
+		}
+
+		got, err := s.GetUser(ctx, ana.ID)
+		if err != nil || !reflect.DeepEqual(got, ana) {
+			t.Errorf("GetUser: %+v, %v, want %+v", got, err, ana)
+		}
+		if got, err := s.GetUserByEmail(ctx, ben.Email); err != nil || got.ID != ben.ID || !got.Suspended() || !got.SuspendedAt.Equal(now) {
+			t.Errorf("GetUserByEmail: %+v, %v", got, err)
+		}
+		if _, err := s.GetUser(ctx, "usr_missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetUser of a missing user: %v, want ErrNotFound", err)
+		}
+		if _, err := s.GetUserByEmail(ctx, "missing@example.com"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetUserByEmail of a missing address: %v, want ErrNotFound", err)
+		}
+
+		// Returned users are copies
+		got.FirstName = "Changed"
+		if again, _ := s.GetUser(ctx, ana.ID); again.FirstName != "Ana" {
+			t.Errorf("changing a returned user changed the store: %q", again.FirstName)
+		}
+
+		// Changing the email frees the old one
+		got.Email = "ana@example.org"
+		if err := s.UpdateUser(ctx, got); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetUserByEmail(ctx, "ana@example.com"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("old email after a change: %v, want ErrNotFound", err)
+		}
+		if again, err := s.GetUserByEmail(ctx, "ana@example.org"); err != nil || again.FirstName != "Changed" {
+			t.Errorf("new email after a change: %+v, %v", again, err)
+		}
+		got.Email = ben.Email
+		if err := s.UpdateUser(ctx, got); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("UpdateUser to a taken email: %v, want ErrAlreadyExists", err)
+		}
+		if err := s.UpdateUser(ctx, &models.User{ID: "usr_missing", Email: "missing@example.com"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("UpdateUser of a missing user: %v, want ErrNotFound", err)
+		}
+
+		users, err := s.ListUsers(ctx)
+		if err != nil || len(users) != 2 || users[0].ID != ana.ID || users[1].ID != ben.ID {
+			t.Errorf("ListUsers: %v, want ana then ben", err)
+		}
+	})
+}
+
+func TestItems(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		camera := &models.Item{ID: "itm_camera", Name: "Camera", Description: "With a 50mm lens", DailyRate: 25.5, ImageURL: "https://example.com/camera.jpg",
+			OwnerID: "usr_ana", Available: true, Category: "dslr", Tags: []string{"photo", "travel"},
+			Attributes: models.Attributes{"brand": "Nikon", "megapixels": 24.0, "weather_sealed": true}, CreatedAt: now}
+		tent := &models.Item{ID: "itm_tent", Name: "Tent", DailyRate: 10, OwnerID: "usr_ana", Category: "tents", Tags: []string{"travel"}, CreatedAt: now.Add(time.Second)}
+		kayak := &models.Item{ID: "itm_kayak", Name: "Kayak", DailyRate: 30, OwnerID: "usr_ben", Available: true, SuspendedAt: &now, CreatedAt: now.Add(2 * time.Second)}
+		for _, item := range []*models.Item{camera, tent, kayak} {
+			if err := s.CreateItem(ctx, item); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.CreateItem(ctx, &models.Item{ID: camera.ID, Name: "Other", OwnerID: "usr_ben", CreatedAt: now}); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("CreateItem with a taken ID: %v, want ErrAlreadyExists", err)
+		}
+
+		got, err := s.GetItem(ctx, camera.ID)
+		if err != nil || !reflect.DeepEqual(got, camera) {
+			t.Errorf("GetItem: %+v, %v, want %+v", got, err, camera)
+		}
+		if _, err := s.GetItem(ctx, "itm_missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetItem of a missing item: %v, want ErrNotFound", err)
+		}
+		got.Tags[0] = "changed"
+		got.Attributes["brand"] = "Changed"
+		if again, _ := s.GetItem(ctx, camera.ID); again.Tags[0] != "photo" || again.Attributes["brand"] != "Nikon" {
+			t.Errorf("changing a returned item changed the store: %v %v", again.Tags, again.Attributes)
+		}
+
+		filters := []struct {
+			name   string
+			filter ItemFilter
+			want   []string
+		}{
+			{"all", ItemFilter{}, []string{"itm_camera", "itm_tent", "itm_kayak"}},
+			{"owner", ItemFilter{OwnerID: "usr_ana"}, []string{"itm_camera", "itm_tent"}},
+			{"available", ItemFilter{AvailableOnly: true}, []string{"itm_camera", "itm_kayak"}},
+			{"listed", ItemFilter{ListedOnly: true}, []string{"itm_camera", "itm_tent"}},
+			{"categories", ItemFilter{Categories: []string{"dslr", "tents"}}, []string{"itm_camera", "itm_tent"}},
+			{"one tag", ItemFilter{Tags: []string{"travel"}}, []string{"itm_camera", "itm_tent"}},
+			{"every tag", ItemFilter{Tags: []string{"travel", "photo"}}, []string{"itm_camera"}},
+			{"combined", ItemFilter{OwnerID: "usr_ana", AvailableOnly: true, Tags: []string{"travel"}}, []string{"itm_camera"}},
+			{"none", ItemFilter{OwnerID: "usr_nobody"}, []string{}},
+		}
+		for _, tt := range filters {
+			items, err := s.ListItems(ctx, tt.filter)
+			ids := []string{}
+			for _, item := range items {
+				ids = append(ids, item.ID)
+			}
+			if err != nil || !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ListItems %s: %v, %v, want %v", tt.name, ids, err, tt.want)
+			}
+		}
+
+		// Updates replace tags and attributes wholesale
+		tent.Available = true
+		tent.Tags = nil
+		tent.Attributes = models.Attributes{"capacity": 4.0}
+		if err := s.UpdateItem(ctx, tent); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetItem(ctx, tent.ID); err != nil || !reflect.DeepEqual(got, tent) {
+			t.Errorf("item after UpdateItem: %+v, %v, want %+v", got, err, tent)
+		}
+		if err := s.UpdateItem(ctx, &models.Item{ID: "itm_missing"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("UpdateItem of a missing item: %v, want ErrNotFound", err)
+		}
+
+		// Items cannot be deleted while they have active bookings
+		start := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+		if err := s.CreateBooking(ctx, &models.Booking{ID: "bkg_1", ItemID: tent.ID, UserID: "usr_ben", StartDate: start, EndDate: start.AddDate(0, 0, 2),
+			Status: models.BookingConfirmed, CreatedAt: now, UpdatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteItem(ctx, tent.ID); !errors.Is(err, ErrItemHasBookings) {
+			t.Errorf("DeleteItem with a confirmed booking: %v, want ErrItemHasBookings", err)
+		}
+		if _, _, err := s.SetBookingStatus(ctx, "bkg_1", models.BookingCompleted, now); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteItem(ctx, tent.ID); err != nil {
+			t.Errorf("DeleteItem with a completed booking: %v", err)
+		}
+		if _, err := s.GetItem(ctx, tent.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetItem after deleting: %v, want ErrNotFound", err)
+		}
+		if err := s.DeleteItem(ctx, tent.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("DeleteItem of a missing item: %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestBookings(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		for _, item := range []*models.Item{
+			{ID: "itm_tent", Name: "Tent", DailyRate: 10, OwnerID: "usr_ana", Available: true, CreatedAt: now},
+			{ID: "itm_kayak", Name: "Kayak", DailyRate: 30, OwnerID: "usr_ben", Available: true, CreatedAt: now},
+		} {
+			if err := s.CreateItem(ctx, item); err != nil {
+				t.Fatal(err)
+			}
+		}
+		day := func(n int) time.Time { return time.Date(2030, 6, 1+n, 0, 0, 0, 0, time.UTC) }
+		booking := func(id, itemID, userID string, from, to int, status string, created int) *models.Booking {
+			return &models.Booking{ID: id, ItemID: itemID, UserID: userID, StartDate: day(from), EndDate: day(to), TotalPrice: 10 * float64(to-from),
+				Status: status, CreatedAt: now.Add(time.Duration(created) * time.Second), UpdatedAt: now}
+		}
+
+		first := booking("bkg_1", "itm_tent", "usr_ben", 0, 3, models.BookingConfirmed, 0)
+		for _, b := range []*models.Booking{
+			first,
+			booking("bkg_2", "itm_tent", "usr_cara", 3, 5, models.BookingPending, 1), // Starts as the first ends
+			booking("bkg_3", "itm_tent", "usr_cara", 5, 7, models.BookingPending, 2),
+			booking("bkg_4", "itm_kayak", "usr_ana", 0, 3, models.BookingPending, 3), // Other items are independent
+		} {
+			if err := s.CreateBooking(ctx, b); err != nil {
+				t.Fatalf("CreateBooking %s: %v", b.ID, err)
+			}
+		}
+		// Cancelled bookings take no dates
+		if _, _, err := s.SetBookingStatus(ctx, "bkg_3", models.BookingCancelled, now); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateBooking(ctx, booking("bkg_5", "itm_tent", "usr_dan", 2, 4, models.BookingPending, 4)); !errors.Is(err, ErrBookingConflict) {
+			t.Errorf("CreateBooking over taken dates: %v, want ErrBookingConflict", err)
+		}
+		if err := s.CreateBooking(ctx, booking("bkg_6", "itm_missing", "usr_dan", 0, 1, models.BookingPending, 4)); !errors.Is(err, ErrNotFound) {
+			t.Errorf("CreateBooking of a missing item: %v, want ErrNotFound", err)
+		}
+
+		if got, err := s.GetBooking(ctx, first.ID); err != nil || !reflect.DeepEqual(got, first) {
+			t.Errorf("GetBooking: %+v, %v, want %+v", got, err, first)
+		}
+		if _, err := s.GetBooking(ctx, "bkg_missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetBooking of a missing booking: %v, want ErrNotFound", err)
+		}
+
+		availability := []struct {
+			from, to int
+			want     bool
+		}{{-2, 0, true}, {-1, 1, false}, {1, 2, false}, {4, 6, false}, {5, 7, true}}
+		for _, tt := range availability {
+			if ok, err := s.IsItemAvailable(ctx, "itm_tent", day(tt.from), day(tt.to)); err != nil || ok != tt.want {
+				t.Errorf("IsItemAvailable(%d, %d) = %v, %v, want %v", tt.from, tt.to, ok, err, tt.want)
+			}
+		}
+		ranges, err := s.BookedRanges(ctx, "itm_tent", day(2), day(10))
+		if err != nil || len(ranges) != 2 ||
+			ranges[0].BookingID != "bkg_1" || !ranges[0].Start.Equal(day(0)) || !ranges[0].End.Equal(day(3)) ||
+			ranges[1].BookingID != "bkg_2" || !ranges[1].Start.Equal(day(3)) || !ranges[1].End.Equal(day(5)) {
+			t.Errorf("BookedRanges: %+v, %v", ranges, err)
+		}
+
+		filters := []struct {
+			name   string
+			filter BookingFilter
+			want   []string
+		}{
+			{"all", BookingFilter{}, []string{"bkg_1", "bkg_2", "bkg_3", "bkg_4"}},
+			{"renter", BookingFilter{UserID: "usr_cara"}, []string{"bkg_2", "bkg_3"}},
+			{"item", BookingFilter{ItemID: "itm_kayak"}, []string{"bkg_4"}},
+			{"item owner", BookingFilter{ItemOwnerID: "usr_ana"}, []string{"bkg_1", "bkg_2", "bkg_3"}},
+			{"statuses", BookingFilter{Statuses: []string{models.BookingPending, models.BookingConfirmed}}, []string{"bkg_1", "bkg_2", "bkg_4"}},
+			{"combined", BookingFilter{ItemOwnerID: "usr_ana", Statuses: []string{models.BookingPending}}, []string{"bkg_2"}},
+		}
+		for _, tt := range filters {
+			bookings, err := s.ListBookings(ctx, tt.filter)
+			ids := []string{}
+			for _, b := range bookings {
+				ids = append(ids, b.ID)
+			}
+			if err != nil || !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ListBookings %s: %v, %v, want %v", tt.name, ids, err, tt.want)
+			}
+		}
+
+		first.PaymentID = "pay_1"
+		first.UpdatedAt = now.Add(time.Minute)
+		if err := s.UpdateBooking(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetBooking(ctx, first.ID); err != nil || got.PaymentID != "pay_1" || !got.UpdatedAt.Equal(first.UpdatedAt) {
+			t.Errorf("booking after UpdateBooking: %+v, %v", got, err)
+		}
+		if err := s.UpdateBooking(ctx, booking("bkg_missing", "itm_tent", "usr_dan", 8, 9, models.BookingPending, 0)); !errors.Is(err, ErrNotFound) {
+			t.Errorf("UpdateBooking of a missing booking: %v, want ErrNotFound", err)
+		}
+
+		// Cancelling frees the dates
+		if _, previous, err := s.SetBookingStatus(ctx, first.ID, models.BookingCancelled, now); err != nil || previous != models.BookingConfirmed {
+			t.Fatalf("cancelling: previous %q, %v", previous, err)
+		}
+		if ok, _ := s.IsItemAvailable(ctx, "itm_tent", day(0), day(3)); !ok {
+			t.Error("dates of a cancelled booking are still taken")
+		}
+	})
+}
+
+func TestPayments(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		if err := s.CreateItem(ctx, &models.Item{ID: "itm_tent", Name: "Tent", DailyRate: 10, OwnerID: "usr_ana", Available: true, CreatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+		start := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+		for i, renter := range []string{"usr_ben", "usr_cara"} {
+			if err := s.CreateBooking(ctx, &models.Booking{ID: fmt.Sprintf("bkg_%d", i), ItemID: "itm_tent", UserID: renter,
+				StartDate: start.AddDate(0, 0, 2*i), EndDate: start.AddDate(0, 0, 2*i+1), Status: models.BookingPending, CreatedAt: now, UpdatedAt: now}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		payment := &models.Payment{ID: "pay_0", BookingID: "bkg_0", Amount: 10, Currency: "INR", Status: "pending", PaymentMethod: "upi", CreatedAt: now, UpdatedAt: now}
+		for _, p := range []*models.Payment{
+			payment,
+			{ID: "pay_1", BookingID: "bkg_1", Amount: 10, Currency: "INR", Status: "failed", PaymentMethod: "card", CreatedAt: now.Add(time.Second), UpdatedAt: now},
+			{ID: "pay_2", BookingID: "bkg_1", Amount: 10, Currency: "INR", Status: "success", PaymentMethod: "card", CreatedAt: now.Add(2 * time.Second), UpdatedAt: now},
+		} {
+			if err := s.CreatePayment(ctx, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.CreatePayment(ctx, payment); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("CreatePayment with a taken ID: %v, want ErrAlreadyExists", err)
+		}
+		if got, err := s.GetPayment(ctx, payment.ID); err != nil || !reflect.DeepEqual(got, payment) {
+			t.Errorf("GetPayment: %+v, %v, want %+v", got, err, payment)
+		}
+		if _, err := s.GetPayment(ctx, "pay_missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetPayment of a missing payment: %v, want ErrNotFound", err)
+		}
+
+		filters := []struct {
+			name   string
+			filter PaymentFilter
+			want   []string
+		}{
+			{"all", PaymentFilter{}, []string{"pay_0", "pay_1", "pay_2"}},
+			{"renter", PaymentFilter{UserID: "usr_cara"}, []string{"pay_1", "pay_2"}},
+			{"booking", PaymentFilter{BookingID: "bkg_0"}, []string{"pay_0"}},
+		}
+		for _, tt := range filters {
+			payments, err := s.ListPayments(ctx, tt.filter)
+			ids := []string{}
+			for _, p := range payments {
+				ids = append(ids, p.ID)
+			}
+			if err != nil || !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ListPayments %s: %v, %v, want %v", tt.name, ids, err, tt.want)
+			}
+		}
+
+		payment.Status = "success"
+		payment.GatewayID = "pay_gateway_1"
+		payment.UpdatedAt = now.Add(time.Minute)
+		if err := s.UpdatePayment(ctx, payment); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetPayment(ctx, payment.ID); err != nil || !reflect.DeepEqual(got, payment) {
+			t.Errorf("payment after UpdatePayment: %+v, %v, want %+v", got, err, payment)
+		}
+		if err := s.UpdatePayment(ctx, &models.Payment{ID: "pay_missing"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("UpdatePayment of a missing payment: %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestSessionsAndRefreshTokens(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		for i, id := range []string{"ses_1", "ses_2", "ses_3"} {
+			at := now.Add(time.Duration(i) * time.Minute)
+			if err := s.CreateSession(ctx, &models.Session{ID: id, UserID: "usr_ana", UserAgent: "browser", IP: "192.0.2.1", CreatedAt: at, LastSeenAt: at}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.CreateSession(ctx, &models.Session{ID: "ses_ben", UserID: "usr_ben", CreatedAt: now, LastSeenAt: now}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetSession(ctx, "ses_missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetSession of a missing session: %v, want ErrNotFound", err)
+		}
+
+		// Touching keeps the client details unless new ones are given
+		later := now.Add(time.Hour)
+		if err := s.TouchSession(ctx, "ses_1", later, "", ""); err != nil {
+			t.Fatal(err)
+		}
+		if session, err := s.GetSession(ctx, "ses_1"); err != nil || !session.LastSeenAt.Equal(later) || session.IP != "192.0.2.1" || session.UserAgent != "browser" {
+			t.Errorf("session after touching: %+v, %v", session, err)
+		}
+		if err := s.TouchSession(ctx, "ses_1", later, "198.51.100.1", "phone"); err != nil {
+			t.Fatal(err)
+		}
+		if session, _ := s.GetSession(ctx, "ses_1"); session.IP != "198.51.100.1" || session.UserAgent != "phone" {
+			t.Errorf("session after touching from a new client: %+v", session)
+		}
+		if err := s.TouchSession(ctx, "ses_missing", later, "", ""); !errors.Is(err, ErrNotFound) {
+			t.Errorf("TouchSession of a missing session: %v, want ErrNotFound", err)
+		}
+
+		sessionIDs := func() []string {
+			t.Helper()
+			sessions, err := s.ListSessions(ctx, "usr_ana")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, session := range sessions {
+				ids = append(ids, session.ID)
+			}
+			return ids
+		}
+		if ids := sessionIDs(); !reflect.DeepEqual(ids, []string{"ses_1", "ses_3", "ses_2"}) {
+			t.Errorf("ListSessions: %v, want the most recently seen first", ids)
+		}
+
+		if n, err := s.RevokeSessions(ctx, SessionFilter{UserID: "usr_ana", ExceptID: "ses_1"}, later); err != nil || n != 2 {
+			t.Errorf("revoking the other sessions: %d, %v, want 2", n, err)
+		}
+		if n, err := s.RevokeSessions(ctx, SessionFilter{ID: "ses_2"}, later); err != nil || n != 0 {
+			t.Errorf("revoking a revoked session: %d, %v, want 0", n, err)
+		}
+		if ids := sessionIDs(); !reflect.DeepEqual(ids, []string{"ses_1"}) {
+			t.Errorf("sessions after revoking the others: %v", ids)
+		}
+		if session, err := s.GetSession(ctx, "ses_2"); err != nil || session.Active() || !session.RevokedAt.Equal(later) {
+			t.Errorf("revoked session: %+v, %v", session, err)
+		}
+		if session, _ := s.GetSession(ctx, "ses_ben"); !session.Active() {
+			t.Error("revoking one user's sessions revoked another's")
+		}
+
+		token := &models.RefreshToken{ID: "rt_1", SessionID: "ses_1", UserID: "usr_ana", TokenHash: "hash_1", ExpiresAt: later, CreatedAt: now}
+		if err := s.CreateRefreshToken(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetRefreshToken(ctx, token.ID); err != nil || !reflect.DeepEqual(got, token) {
+			t.Errorf("GetRefreshToken: %+v, %v, want %+v", got, err, token)
+		}
+		next := &models.RefreshToken{ID: "rt_2", SessionID: "ses_1", UserID: "usr_ana", TokenHash: "hash_2", ExpiresAt: later, CreatedAt: later}
+		if err := s.RotateRefreshToken(ctx, token.ID, next); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetRefreshToken(ctx, token.ID); err != nil || got.RotatedAt == nil || got.ReplacedBy != next.ID {
+			t.Errorf("rotated token: %+v, %v", got, err)
+		}
+		if _, err := s.GetRefreshToken(ctx, next.ID); err != nil {
+			t.Errorf("next token: %v", err)
+		}
+		reused := &models.RefreshToken{ID: "rt_3", SessionID: "ses_1", UserID: "usr_ana", TokenHash: "hash_3", ExpiresAt: later, CreatedAt: later}
+		if err := s.RotateRefreshToken(ctx, token.ID, reused); !errors.Is(err, ErrTokenReused) {
+			t.Errorf("rotating a rotated token: %v, want ErrTokenReused", err)
+		}
+		if _, err := s.GetRefreshToken(ctx, reused.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("token from a refused rotation: %v, want ErrNotFound", err)
+		}
+		if err := s.RotateRefreshToken(ctx, "rt_missing", reused); !errors.Is(err, ErrNotFound) {
+			t.Errorf("rotating a missing token: %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestOneTimeTokens(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		for i, token := range []*models.OneTimeToken{
+			{ID: "ott_1", UserID: "usr_ana", Purpose: models.TokenPurposePasswordReset, TokenHash: "hash_1"},
+			{ID: "ott_2", UserID: "usr_ana", Purpose: models.TokenPurposePasswordReset, TokenHash: "hash_2"},
+			{ID: "ott_3", UserID: "usr_ana", Purpose: models.TokenPurposeEmailVerification, TokenHash: "hash_3"},
+		} {
+			token.CreatedAt = now.Add(time.Duration(i) * time.Second)
+			token.ExpiresAt = now.Add(time.Hour)
+			if err := s.CreateOneTimeToken(ctx, token); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if latest, err := s.LatestOneTimeToken(ctx, "usr_ana", models.TokenPurposePasswordReset); err != nil || latest.ID != "ott_2" {
+			t.Errorf("LatestOneTimeToken: %+v, %v, want ott_2", latest, err)
+		}
+		if _, err := s.LatestOneTimeToken(ctx, "usr_ben", models.TokenPurposePasswordReset); !errors.Is(err, ErrNotFound) {
+			t.Errorf("LatestOneTimeToken without tokens: %v, want ErrNotFound", err)
+		}
+
+		if err := s.ConsumeOneTimeToken(ctx, "ott_1", now); err != nil {
+			t.Fatal(err)
+		}
+		if token, err := s.GetOneTimeToken(ctx, "ott_1"); err != nil || token.UsedAt == nil || !token.UsedAt.Equal(now) {
+			t.Errorf("consumed token: %+v, %v", token, err)
+		}
+		if err := s.ConsumeOneTimeToken(ctx, "ott_1", now); !errors.Is(err, ErrTokenReused) {
+			t.Errorf("consuming a used token: %v, want ErrTokenReused", err)
+		}
+		if err := s.ConsumeOneTimeToken(ctx, "ott_missing", now); !errors.Is(err, ErrNotFound) {
+			t.Errorf("consuming a missing token: %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestTwoFactorCredentials(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		cred := &models.TOTPCredential{UserID: "usr_ana", Secret: "JBSWY3DPEHPK3PXP", CreatedAt: now}
+		if err := s.SaveTOTPCredential(ctx, cred); err != nil {
+			t.Fatal(err)
+		}
+		// Saving again replaces the credential
+		cred.Confirmed = true
+		cred.ConfirmedAt = &now
+		cred.LastUsedStep = 100
+		if err := s.SaveTOTPCredential(ctx, cred); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetTOTPCredential(ctx, "usr_ana"); err != nil || !reflect.DeepEqual(got, cred) {
+			t.Errorf("GetTOTPCredential: %+v, %v, want %+v", got, err, cred)
+		}
+
+		if err := s.AdvanceTOTPStep(ctx, "usr_ana", 101); err != nil {
+			t.Errorf("advancing to a later step: %v", err)
+		}
+		for _, step := range []int64{101, 99} {
+			if err := s.AdvanceTOTPStep(ctx, "usr_ana", step); !errors.Is(err, ErrTokenReused) {
+				t.Errorf("advancing to step %d after 101: %v, want ErrTokenReused", step, err)
+			}
+		}
+		if err := s.AdvanceTOTPStep(ctx, "usr_ben", 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("advancing without a credential: %v, want ErrNotFound", err)
+		}
+
+		codes := func(ids ...string) []*models.BackupCode {
+			var codes []*models.BackupCode
+			for _, id := range ids {
+				codes = append(codes, &models.BackupCode{ID: id, UserID: "usr_ana", CodeHash: "hash_" + id, CreatedAt: now})
+			}
+			return codes
+		}
+		if err := s.ReplaceBackupCodes(ctx, "usr_ana", codes("bc_1", "bc_2")); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ReplaceBackupCodes(ctx, "usr_ana", codes("bc_3", "bc_4")); err != nil {
+			t.Fatal(err)
+		}
+		listed, err := s.ListBackupCodes(ctx, "usr_ana")
+		if err != nil || !reflect.DeepEqual(listed, codes("bc_3", "bc_4")) {
+			t.Errorf("ListBackupCodes after replacing: %+v, %v", listed, err)
+		}
+		if err := s.ConsumeBackupCode(ctx, "bc_3", now); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ConsumeBackupCode(ctx, "bc_3", now); !errors.Is(err, ErrTokenReused) {
+			t.Errorf("consuming a used backup code: %v, want ErrTokenReused", err)
+		}
+		if err := s.ConsumeBackupCode(ctx, "bc_1", now); !errors.Is(err, ErrNotFound) {
+			t.Errorf("consuming a replaced backup code: %v, want ErrNotFound", err)
+		}
+
+		if err := s.DeleteTOTPCredential(ctx, "usr_ana"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetTOTPCredential(ctx, "usr_ana"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetTOTPCredential after deleting: %v, want ErrNotFound", err)
+		}
+		if err := s.DeleteTOTPCredential(ctx, "usr_ana"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("deleting a missing credential: %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestIdentities(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		identity := &models.Identity{ID: "idn_1", UserID: "usr_ana", Provider: "https://accounts.example.com", Subject: "1234", Email: "ana@example.com", CreatedAt: now}
+		if err := s.CreateIdentity(ctx, identity); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetIdentity(ctx, identity.Provider, identity.Subject); err != nil || !reflect.DeepEqual(got, identity) {
+			t.Errorf("GetIdentity: %+v, %v, want %+v", got, err, identity)
+		}
+		taken := &models.Identity{ID: "idn_2", UserID: "usr_ben", Provider: identity.Provider, Subject: identity.Subject, CreatedAt: now}
+		if err := s.CreateIdentity(ctx, taken); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("linking a linked subject: %v, want ErrAlreadyExists", err)
+		}
+		// The same subject at another provider is another account
+		other := &models.Identity{ID: "idn_3", UserID: "usr_ben", Provider: "https://login.example.org", Subject: identity.Subject, CreatedAt: now}
+		if err := s.CreateIdentity(ctx, other); err != nil {
+			t.Errorf("linking the subject at another provider: %v", err)
+		}
+		if _, err := s.GetIdentity(ctx, identity.Provider, "5678"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetIdentity of an unlinked subject: %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestAPIKeysAndAuditLog(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		expires := now.Add(24 * time.Hour)
+		key := &models.APIKey{ID: "key_1", UserID: "usr_ana", Name: "Backups", KeyHash: "hash_1", Scopes: []string{"items:read", "bookings:read"}, ExpiresAt: &expires, CreatedAt: now}
+		for _, k := range []*models.APIKey{
+			key,
+			{ID: "key_2", UserID: "usr_ana", Name: "Sync", KeyHash: "hash_2", Scopes: []string{"items:write"}, CreatedAt: now.Add(time.Second)},
+			{ID: "key_3", UserID: "usr_ben", Name: "Other", KeyHash: "hash_3", Scopes: []string{"items:read"}, CreatedAt: now},
+		} {
+			if err := s.CreateAPIKey(ctx, k); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got, err := s.GetAPIKey(ctx, key.ID); err != nil || !reflect.DeepEqual(got, key) {
+			t.Errorf("GetAPIKey: %+v, %v, want %+v", got, err, key)
+		}
+		if _, err := s.GetAPIKey(ctx, "key_missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetAPIKey of a missing key: %v, want ErrNotFound", err)
+		}
+
+		later := now.Add(time.Hour)
+		if err := s.TouchAPIKey(ctx, key.ID, later); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RevokeAPIKey(ctx, key.ID, later); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RevokeAPIKey(ctx, key.ID, later.Add(time.Hour)); err != nil {
+			t.Errorf("revoking a revoked key: %v", err)
+		}
+		if got, _ := s.GetAPIKey(ctx, key.ID); got.LastUsedAt == nil || !got.LastUsedAt.Equal(later) || got.RevokedAt == nil || !got.RevokedAt.Equal(later) {
+			t.Errorf("key after use and revocation: %+v, want both at %v", got, later)
+		}
+		for _, err := range []error{s.TouchAPIKey(ctx, "key_missing", later), s.RevokeAPIKey(ctx, "key_missing", later)} {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("changing a missing key: %v, want ErrNotFound", err)
+			}
+		}
+		// Revoked keys are still listed
+		keys, err := s.ListAPIKeys(ctx, "usr_ana")
+		if err != nil || len(keys) != 2 || keys[0].ID != "key_1" || keys[1].ID != "key_2" {
+			t.Errorf("ListAPIKeys: %v, want key_1 then key_2", err)
+		}
+
+		for i, entry := range []*models.AuditEntry{
+			{ID: "aud_1", ActorID: "usr_admin", Action: models.AuditUserSuspended, TargetType: "user", TargetID: "usr_ana", Reason: "spam"},
+			{ID: "aud_2", ActorID: "usr_mod", Action: models.AuditItemSuspended, TargetType: "item", TargetID: "itm_tent", Reason: "spam"},
+			{ID: "aud_3", ActorID: "usr_admin", Action: models.AuditUserRestored, TargetType: "user", TargetID: "usr_ana", Reason: "appeal"},
+		} {
+			entry.CreatedAt = now.Add(time.Duration(i) * time.Second)
+			if err := s.RecordAuditEntry(ctx, entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+		filters := []struct {
+			name   string
+			filter AuditFilter
+			want   []string
+		}{
+			{"all", AuditFilter{}, []string{"aud_1", "aud_2", "aud_3"}},
+			{"actor", AuditFilter{ActorID: "usr_admin"}, []string{"aud_1", "aud_3"}},
+			{"target", AuditFilter{TargetID: "itm_tent"}, []string{"aud_2"}},
+		}
+		for _, tt := range filters {
+			entries, err := s.ListAuditEntries(ctx, tt.filter)
+			ids := []string{}
+			for _, entry := range entries {
+				ids = append(ids, entry.ID)
+			}
+			if err != nil || !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ListAuditEntries %s: %v, %v, want %v", tt.name, ids, err, tt.want)
+			}
+		}
+	})
+}
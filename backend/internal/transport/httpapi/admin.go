@@ -0,0 +1,156 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"borrowhub/internal/store"
+)
+
+// adminRequest is the body of admin actions; which fields apply depends on the action
+type adminRequest struct {
+	Reason string `json:"reason"`
+	Role   string `json:"role"`
+	Status string `json:"status"`
+}
+
+// decodeAdminRequest reads the optional body of an admin action
+func decodeAdminRequest(r *http.Request) (*adminRequest, error) {
+	var request adminRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (s *Server) adminListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.admin.ListUsers(r.Context())
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, users)
+}
+
+func (s *Server) adminSuspendUser(w http.ResponseWriter, r *http.Request) {
+	request, err := decodeAdminRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	user, err := s.admin.SuspendUser(r.Context(), principal(r).UserID, mux.Vars(r)["id"], request.Reason)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) adminRestoreUser(w http.ResponseWriter, r *http.Request) {
+	request, err := decodeAdminRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	user, err := s.admin.RestoreUser(r.Context(), principal(r).UserID, mux.Vars(r)["id"], request.Reason)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) adminSetRole(w http.ResponseWriter, r *http.Request) {
+	request, err := decodeAdminRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	user, err := s.admin.SetRole(r.Context(), principal(r).UserID, mux.Vars(r)["id"], request.Role, request.Reason)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) adminListItems(w http.ResponseWriter, r *http.Request) {
+	items, err := s.admin.ListItems(r.Context())
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, items)
+}
+
+func (s *Server) adminSuspendItem(w http.ResponseWriter, r *http.Request) {
+	request, err := decodeAdminRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	item, err := s.admin.SuspendItem(r.Context(), principal(r).UserID, mux.Vars(r)["id"], request.Reason)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, item)
+}
+
+func (s *Server) adminRestoreItem(w http.ResponseWriter, r *http.Request) {
+	request, err := decodeAdminRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	item, err := s.admin.RestoreItem(r.Context(), principal(r).UserID, mux.Vars(r)["id"], request.Reason)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, item)
+}
+
+func (s *Server) adminForceBookingStatus(w http.ResponseWriter, r *http.Request) {
+	request, err := decodeAdminRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	booking, err := s.admin.ForceBookingStatus(r.Context(), principal(r).UserID, mux.Vars(r)["id"], request.Status, request.Reason)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, booking)
+}
+
+func (s *Server) adminListPayments(w http.ResponseWriter, r *http.Request) {
+	filter := store.PaymentFilter{
+		UserID:    r.URL.Query().Get("userId"),
+		BookingID: r.URL.Query().Get("bookingId"),
+	}
+	payments, err := s.admin.ListPayments(r.Context(), principal(r).UserID, filter)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, payments)
+}
+
+func (s *Server) adminAuditLog(w http.ResponseWriter, r *http.Request) {
+	filter := store.AuditFilter{
+		ActorID:  r.URL.Query().Get("actorId"),
+		TargetID: r.URL.Query().Get("targetId"),
+	}
+	entries, err := s.admin.AuditLog(r.Context(), filter)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// createAPIKey issues a personal API key. The key itself is only in this response.
+func (s *Server) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	created, err := s.auth.CreateAPIKey(r.Context(), principal(r).UserID, request.Name, request.Scopes, request.ExpiresAt)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"key":    created.Key,
+		"apiKey": created.Secret,
+	})
+}
+
+func (s *Server) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.auth.ListAPIKeys(r.Context(), principal(r).UserID)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, keys)
+}
+
+func (s *Server) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if err := s.auth.RevokeAPIKey(r.Context(), mux.Vars(r)["id"]); err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "API key revoked"})
+}
@@ -0,0 +1,202 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"borrowhub/internal/auth"
+	"borrowhub/internal/models"
+)
+
+// Authentication handlers
+func (s *Server) register(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tokens, err := s.auth.Register(r.Context(), &user, s.client(r))
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, sessionResponse(tokens, &user))
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var credentials struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := s.auth.Login(r.Context(), credentials.Email, credentials.Password, s.client(r))
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithLoginResult(w, result)
+}
+
+func (s *Server) refreshSession(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tokens, err := s.auth.Refresh(r.Context(), request.RefreshToken, s.client(r))
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, sessionResponse(tokens, nil))
+}
+
+// logout ends the session the access token belongs to
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	if err := s.auth.Logout(r.Context(), principal(r).SessionID); err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Logged out"})
+}
+
+// logoutAll ends every session of the user, on every device
+func (s *Server) logoutAll(w http.ResponseWriter, r *http.Request) {
+	revoked, err := s.auth.LogoutAll(r.Context(), principal(r).UserID)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":         "Logged out of all devices",
+		"sessionsRevoked": revoked,
+	})
+}
+
+// forgotPassword mails a reset link. The response does not reveal whether the email is registered.
+func (s *Server) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Email string `json:"email"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := s.auth.ForgotPassword(r.Context(), request.Email, s.clientIP(r)); err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]string{
+		"message": "If an account exists for that email, a password reset link has been sent",
+	})
+}
+
+// resetPassword sets a new password and signs the user out everywhere
+func (s *Server) resetPassword(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := s.auth.ResetPassword(r.Context(), request.Token, request.Password); err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Password has been reset; please log in again"})
+}
+
+// verifyEmail confirms the address a verification link was sent to
+func (s *Server) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token string `json:"token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := s.auth.VerifyEmail(r.Context(), request.Token); err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Email address verified"})
+}
+
+// resendVerification mails the signed-in user a new verification link
+func (s *Server) resendVerification(w http.ResponseWriter, r *http.Request) {
+	if err := s.auth.ResendVerification(r.Context(), principal(r).UserID); err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"message": "Verification email sent"})
+}
+
+// getJWKS publishes the public keys access tokens are signed with. Keys
+// change only on rotation, which keeps old keys published for a while, so
+// verifiers may cache the set briefly.
+func (s *Server) getJWKS(w http.ResponseWriter, r *http.Request) {
+	jwks, err := s.auth.JWKS()
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, json.RawMessage(jwks))
+}
+
+// respondWithLoginResult sends the session, or the MFA challenge when a second factor is needed
+func respondWithLoginResult(w http.ResponseWriter, result *auth.LoginResult) {
+	if result.MFAToken != "" {
+		// Second phase: POST /auth/mfa/challenge with this token and a code
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"mfaRequired": true,
+			"mfaToken":    result.MFAToken,
+			"expiresIn":   int(result.MFAExpiresIn.Seconds()),
+		})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, sessionResponse(result.Tokens, result.User))
+}
+
+// sessionResponse is the body returned whenever a token pair is issued.
+// "token" is the access token, kept under its original name for existing clients.
+func sessionResponse(tokens *auth.TokenPair, user *models.User) map[string]interface{} {
+	response := map[string]interface{}{
+		"token":        tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+		"expiresIn":    int(tokens.ExpiresIn.Seconds()),
+	}
+	if user != nil {
+		response["user"] = user.Public()
+	}
+	return response
+}
@@ -0,0 +1,76 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"borrowhub/internal/models"
+)
+
+// Enhanced Booking handlers
+func (s *Server) createBooking(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+	if userID == "" {
+		respondWithError(w, http.StatusUnauthorized, "User authentication required")
+		return
+	}
+
+	var booking models.Booking
+	if err := json.NewDecoder(r.Body).Decode(&booking); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := s.bookings.Create(r.Context(), userID, &booking); err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, booking)
+}
+
+func (s *Server) getUserBookings(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+	if userID == "" {
+		respondWithError(w, http.StatusUnauthorized, "User authentication required")
+		return
+	}
+
+	userBookings, err := s.bookings.ListForUser(r.Context(), userID)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userBookings)
+}
+
+func (s *Server) updateBookingStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bookingID := vars["id"]
+	userID := principal(r).UserID
+
+	if bookingID == "" {
+		respondWithError(w, http.StatusBadRequest, "Booking ID is required")
+		return
+	}
+
+	var statusUpdate struct {
+		Status string `json:"status"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&statusUpdate); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	booking, err := s.bookings.UpdateStatus(r.Context(), userID, bookingID, statusUpdate.Status)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, booking)
+}
@@ -0,0 +1,265 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"borrowhub/internal/catalog"
+	"borrowhub/internal/ids"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// Item handlers
+
+// getItems lists the items that can be booked. The query parameters category
+// (which includes its subcategories), tags (comma-separated; items need all
+// of them) and attr.<key> (attribute values, given a category) narrow it down.
+func (s *Server) getItems(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := catalog.Query{Category: params.Get("category")}
+	for _, tag := range strings.Split(params.Get("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			query.Tags = append(query.Tags, tag)
+		}
+	}
+	for param, values := range params {
+		if key, ok := strings.CutPrefix(param, "attr."); ok {
+			if query.Attributes == nil {
+				query.Attributes = make(map[string]string)
+			}
+			query.Attributes[key] = values[0]
+		}
+	}
+	filter, err := s.catalog.Compile(query)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	items, err := s.store.ListItems(r.Context(), filter.Narrow(store.ItemFilter{AvailableOnly: true, ListedOnly: true}))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading items")
+		return
+	}
+	matching := items[:0]
+	for _, item := range items {
+		if filter.Matches(item) {
+			matching = append(matching, item)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, matching)
+}
+
+// getCategories returns the category taxonomy, parents before their children
+func (s *Server) getCategories(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, s.catalog.Categories())
+}
+
+func (s *Server) getItemDetails(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+
+	if itemID == "" {
+		respondWithError(w, http.StatusBadRequest, "Item ID is required")
+		return
+	}
+
+	item, err := s.store.GetItem(r.Context(), itemID)
+	if err == nil && item.Suspended() {
+		// Taken down by a moderator; its owner still sees it under /api/my-items
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		respondWithStoreError(w, err, "Item not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, item)
+}
+
+func (s *Server) addItem(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+	if userID == "" {
+		respondWithError(w, http.StatusUnauthorized, "User authentication required")
+		return
+	}
+
+	var item models.Item
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Basic validation
+	if item.Name == "" || item.DailyRate <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Name and daily rate are required")
+		return
+	}
+	if err := s.catalog.Classify(&item); err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	// Create new item
+	item.ID = ids.New(ids.PrefixItem)
+	item.OwnerID = userID
+	item.Available = true
+	item.CreatedAt = time.Now()
+	item.Title = item.Name           // Backward compatibility
+	item.Price = int(item.DailyRate) // Backward compatibility
+
+	if err := s.store.CreateItem(r.Context(), &item); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating item")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, item)
+}
+
+// Item CRUD operations
+func (s *Server) updateItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+
+	if itemID == "" {
+		respondWithError(w, http.StatusBadRequest, "Item ID is required")
+		return
+	}
+
+	var itemUpdates struct {
+		models.Item
+		Category *string `json:"category"` // Unlike the other fields, "" clears it
+	}
+	if err := json.NewDecoder(r.Body).Decode(&itemUpdates); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	item, err := s.store.GetItem(r.Context(), itemID)
+	if err != nil {
+		respondWithStoreError(w, err, "Item not found")
+		return
+	}
+
+	// Update only provided fields
+	if itemUpdates.Name != "" {
+		item.Name = itemUpdates.Name
+		item.Title = itemUpdates.Name // Backward compatibility
+	}
+	if itemUpdates.Description != "" {
+		item.Description = itemUpdates.Description
+	}
+	if itemUpdates.DailyRate > 0 {
+		item.DailyRate = itemUpdates.DailyRate
+		item.Price = int(itemUpdates.DailyRate) // Backward compatibility
+	}
+	if itemUpdates.ImageURL != "" {
+		item.ImageURL = itemUpdates.ImageURL
+	}
+	// Tags and attributes given replace the old ones; an empty list or object
+	// clears them. An item taken out of its category loses its attributes
+	// unless new ones are given, which Classify then refuses.
+	if itemUpdates.Category != nil {
+		item.Category = *itemUpdates.Category
+		if item.Category == "" {
+			item.Attributes = nil
+		}
+	}
+	if itemUpdates.Tags != nil {
+		item.Tags = itemUpdates.Tags
+	}
+	if itemUpdates.Attributes != nil {
+		item.Attributes = itemUpdates.Attributes
+	}
+	if err := s.catalog.Classify(item); err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	if err := s.store.UpdateItem(r.Context(), item); err != nil {
+		respondWithStoreError(w, err, "Item not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, item)
+}
+
+func (s *Server) deleteItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+
+	if itemID == "" {
+		respondWithError(w, http.StatusBadRequest, "Item ID is required")
+		return
+	}
+
+	// The itemOwner policy has checked that the caller owns the item.
+	// Refuses while the item has active bookings.
+	if err := s.store.DeleteItem(r.Context(), itemID); err != nil {
+		if errors.Is(err, store.ErrItemHasBookings) {
+			respondWithError(w, http.StatusConflict, "Cannot delete item with active bookings")
+			return
+		}
+		respondWithStoreError(w, err, "Item not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Item deleted successfully"})
+}
+
+func (s *Server) getUserItems(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+	if userID == "" {
+		respondWithError(w, http.StatusUnauthorized, "User authentication required")
+		return
+	}
+
+	userItems, err := s.store.ListItems(r.Context(), store.ItemFilter{OwnerID: userID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading items")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userItems)
+}
+
+func (s *Server) getAvailabilityCalendar(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+
+	if itemID == "" {
+		respondWithError(w, http.StatusBadRequest, "Item ID is required")
+		return
+	}
+
+	// Parse query parameters for month and year
+	month := 0
+	year := 0
+
+	if monthStr := r.URL.Query().Get("month"); monthStr != "" {
+		if m, err := strconv.Atoi(monthStr); err == nil {
+			month = m
+		}
+	}
+
+	if yearStr := r.URL.Query().Get("year"); yearStr != "" {
+		if y, err := strconv.Atoi(yearStr); err == nil {
+			year = y
+		}
+	}
+
+	calendar, err := s.bookings.Calendar(r.Context(), itemID, month, year)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, calendar)
+}
@@ -0,0 +1,140 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"borrowhub/internal/auth"
+	"borrowhub/internal/catalog"
+	"borrowhub/internal/models"
+)
+
+// newItemsTestServer serves the item routes over the built-in taxonomy and
+// returns an access token for usr_owner@example.com, who owns the items given
+func newItemsTestServer(t *testing.T, items ...*models.Item) (http.Handler, string) {
+	t.Helper()
+	taxonomy, err := catalog.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, handler, db := newTestServer(t, func(s *Server, router *mux.Router) {
+		s.catalog = taxonomy
+		s.handle(router, "/api/items", public, s.getItems).Methods("GET")
+		s.handle(router, "/api/items/{id}", itemOwner.withScope(auth.ScopeItemsWrite), s.updateItem).Methods("PUT")
+	})
+	ctx := context.Background()
+	owner := createTestUser(t, db, "owner@example.com", models.RoleUser)
+	for _, item := range items {
+		item.OwnerID = owner.ID
+		if err := taxonomy.Classify(item); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.CreateItem(ctx, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := s.auth.Login(ctx, owner.Email, testPassword, auth.Client{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handler, result.Tokens.AccessToken
+}
+
+func serve(t *testing.T, handler http.Handler, method, target, token, body string, out any) int {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code == http.StatusOK && out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: %v", method, target, err)
+		}
+	}
+	return w.Code
+}
+
+func TestGetItemsTags(t *testing.T) {
+	handler, _ := newItemsTestServer(t,
+		&models.Item{ID: "itm_both", Name: "Both", DailyRate: 10, Available: true, Tags: []string{"video", "kit-lens"}},
+		&models.Item{ID: "itm_video", Name: "Video", DailyRate: 10, Available: true, Tags: []string{"video"}},
+	)
+
+	tests := []struct {
+		tags   string
+		status int
+		want   []string
+	}{
+		{"", http.StatusOK, []string{"itm_both", "itm_video"}},
+		{"video", http.StatusOK, []string{"itm_both", "itm_video"}},
+		{"video,kit-lens", http.StatusOK, []string{"itm_both"}},
+		{" video , Kit-Lens ", http.StatusOK, []string{"itm_both"}},
+		{"video,,kit-lens,", http.StatusOK, []string{"itm_both"}},
+		{",", http.StatusOK, []string{"itm_both", "itm_video"}},
+		{"video,not a tag", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		var items []models.Item
+		status := serve(t, handler, "GET", "/api/items?tags="+strings.ReplaceAll(tt.tags, " ", "%20"), "", "", &items)
+		if status != tt.status {
+			t.Errorf("tags=%q: status %d, want %d", tt.tags, status, tt.status)
+			continue
+		}
+		var got []string
+		for _, item := range items {
+			got = append(got, item.ID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("tags=%q: %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateItemCategory(t *testing.T) {
+	console := func() *models.Item {
+		return &models.Item{ID: "itm_console", Name: "Console", DailyRate: 25, Available: true,
+			Category: "consoles", Attributes: models.Attributes{"platform": "xbox"}}
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		status     int
+		category   string
+		attributes bool
+	}{
+		{"category left out", `{"name": "Renamed"}`, http.StatusOK, "consoles", true},
+		{"null category", `{"category": null}`, http.StatusOK, "consoles", true},
+		{"category cleared", `{"category": ""}`, http.StatusOK, "", false},
+		{"cleared with empty attributes", `{"category": "", "attributes": {}}`, http.StatusOK, "", false},
+		{"cleared with attributes", `{"category": "", "attributes": {"platform": "xbox"}}`, http.StatusBadRequest, "", false},
+		{"moved", `{"category": "dslr", "attributes": {"sensor_size": "aps-c"}}`, http.StatusOK, "dslr", true},
+		{"unknown category", `{"category": "boats"}`, http.StatusBadRequest, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, token := newItemsTestServer(t, console())
+			var item models.Item
+			status := serve(t, handler, "PUT", "/api/items/itm_console", token, tt.body, &item)
+			if status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			if item.Category != tt.category || (len(item.Attributes) > 0) != tt.attributes {
+				t.Errorf("item is in %q with attributes %v, want %q (attributes %v)", item.Category, item.Attributes, tt.category, tt.attributes)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// mfaCodeRequest carries an authenticator code or a backup code
+type mfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// completeMFALogin is the second phase of login for users with two-factor authentication
+func (s *Server) completeMFALogin(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		MFAToken string `json:"mfaToken"`
+		Code     string `json:"code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, tokens, err := s.auth.CompleteLogin(r.Context(), request.MFAToken, request.Code, s.client(r))
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, sessionResponse(tokens, user))
+}
+
+func (s *Server) getMFAStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := s.auth.MFAStatus(r.Context(), principal(r).UserID)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled":              status.Enabled,
+		"backupCodesRemaining": status.BackupCodesRemaining,
+	})
+}
+
+// enrollTOTP starts two-factor enrollment; it takes effect after confirmTOTP
+func (s *Server) enrollTOTP(w http.ResponseWriter, r *http.Request) {
+	enrollment, err := s.auth.EnrollTOTP(r.Context(), principal(r).UserID)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"secret":     enrollment.Secret,
+		"otpauthUri": enrollment.URI,
+	})
+}
+
+// confirmTOTP enables two-factor authentication and returns the backup codes
+func (s *Server) confirmTOTP(w http.ResponseWriter, r *http.Request) {
+	var request mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	codes, err := s.auth.ConfirmTOTP(r.Context(), principal(r).UserID, request.Code)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":     "Two-factor authentication enabled",
+		"backupCodes": codes,
+	})
+}
+
+func (s *Server) disableTOTP(w http.ResponseWriter, r *http.Request) {
+	var request mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := s.auth.DisableTOTP(r.Context(), principal(r).UserID, request.Code, s.clientIP(r)); err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// regenerateBackupCodes replaces every backup code, e.g. after running low
+func (s *Server) regenerateBackupCodes(w http.ResponseWriter, r *http.Request) {
+	var request mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	codes, err := s.auth.RegenerateBackupCodes(r.Context(), principal(r).UserID, request.Code, s.clientIP(r))
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"backupCodes": codes})
+}
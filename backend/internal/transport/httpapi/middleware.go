@@ -0,0 +1,137 @@
+package httpapi
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"borrowhub/internal/auth"
+)
+
+// Custom CORS middleware for better control
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+
+		// Check if origin is allowed
+		if s.config.IsAllowedOrigin(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token, X-Requested-With")
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
+
+		// Handle preflight OPTIONS requests
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authMiddleware enforces the policy of the matched route, authenticating
+// the caller unless the route is public
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Handle OPTIONS preflight requests
+		if r.Method == "OPTIONS" {
+			// Let CORS middleware handle this
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// Identity only ever comes from the verified token, never from the client
+		stripIdentityHeaders(r)
+
+		p := s.policies[mux.CurrentRoute(r)]
+		if p.access == accessPublic {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			respondWithError(w, http.StatusUnauthorized, "Authorization header required")
+			return
+		}
+
+		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
+		// Rejects tokens whose session was logged out or revoked
+		caller, err := s.auth.Authenticate(r.Context(), tokenString)
+		if err != nil {
+			respondWithAppError(w, err)
+			return
+		}
+
+		r = r.WithContext(auth.WithPrincipal(r.Context(), caller))
+		if !s.authorize(w, r, p) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireVerified lets only users with a verified email address reach next
+func (s *Server) requireVerified(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.auth.RequireVerified(r.Context(), principal(r).UserID); err != nil {
+			respondWithAppError(w, err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// principal returns the authenticated caller of r. On public routes that is
+// the zero Principal, whose empty UserID handlers already reject.
+func principal(r *http.Request) *auth.Principal {
+	if p, ok := auth.PrincipalFrom(r.Context()); ok {
+		return p
+	}
+	return &auth.Principal{}
+}
+
+// stripIdentityHeaders drops X-User-* and X-Session-ID headers sent by the
+// client, which older versions of the API used to pass the caller to handlers
+func stripIdentityHeaders(r *http.Request) {
+	for name := range r.Header {
+		canonical := http.CanonicalHeaderKey(name)
+		if strings.HasPrefix(canonical, "X-User-") || canonical == "X-Session-Id" {
+			delete(r.Header, name)
+		}
+	}
+}
+
+// client describes the device r came from, for session records
+func (s *Server) client(r *http.Request) auth.Client {
+	return auth.Client{IP: s.clientIP(r), UserAgent: r.UserAgent()}
+}
+
+// clientIP returns the address the request came from. Behind a reverse
+// proxy (server.trustProxy) that is the last X-Forwarded-For entry, the one
+// the proxy itself appended; earlier entries are client-supplied.
+func (s *Server) clientIP(r *http.Request) string {
+	if s.config.Server.TrustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(strings.Join(forwarded, ","), ",")
+			if ip := net.ParseIP(strings.TrimSpace(hops[len(hops)-1])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"borrowhub/internal/auth"
+	"borrowhub/internal/models"
+)
+
+func TestStripIdentityHeaders(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-User-ID", "usr_admin")
+	r.Header.Set("X-User-Role", models.RoleAdmin)
+	r.Header.Set("X-Session-ID", "ses_other")
+	r.Header["x-user-email"] = []string{"admin@example.com"} // Not in canonical form
+	r.Header.Set("X-Request-ID", "req-1")
+	r.Header.Set("Authorization", "Bearer token")
+
+	stripIdentityHeaders(r)
+	for name := range r.Header {
+		if name != "X-Request-Id" && name != "Authorization" {
+			t.Errorf("header %s was kept", name)
+		}
+	}
+	if len(r.Header) != 2 {
+		t.Errorf("headers %v, want X-Request-Id and Authorization", r.Header)
+	}
+}
+
+func TestPrincipalComesFromTheToken(t *testing.T) {
+	// Each route reports the caller its handler sees
+	var seen *auth.Principal
+	var spoofed string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		seen, spoofed = principal(r), r.Header.Get("X-User-ID")
+		w.WriteHeader(http.StatusNoContent)
+	}
+	s, handler, db := newTestServer(t, func(s *Server, router *mux.Router) {
+		s.handle(router, "/public", public, record).Methods("GET")
+		s.handle(router, "/private", authenticated, record).Methods("GET")
+	})
+	admin := createTestUser(t, db, "admin@example.com", models.RoleAdmin)
+	ana := createTestUser(t, db, "ana@example.com", models.RoleUser)
+	result, err := s.auth.Login(context.Background(), ana.Email, testPassword, auth.Client{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		token  string
+		want   string // UserID of the principal
+	}{
+		{"public route", "/public", "", ""},
+		{"authenticated route", "/private", result.Tokens.AccessToken, ana.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen, spoofed = nil, ""
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			r.Header.Set("X-User-ID", admin.ID)
+			r.Header.Set("X-User-Role", models.RoleAdmin)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("GET %s = %d", tt.target, w.Code)
+			}
+			if seen == nil || seen.UserID != tt.want || seen.Role == models.RoleAdmin {
+				t.Errorf("handler saw principal %+v, want user %q", seen, tt.want)
+			}
+			if spoofed != "" {
+				t.Errorf("handler saw X-User-ID %q", spoofed)
+			}
+		})
+	}
+
+	// A spoofed header is no substitute for a token
+	r := httptest.NewRequest("GET", "/private", nil)
+	r.Header.Set("X-User-ID", ana.ID)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /private with only X-User-ID = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
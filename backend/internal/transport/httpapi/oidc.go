@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// getOIDCProvider tells the frontend whether to offer single sign-on
+func (s *Server) getOIDCProvider(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.auth.OIDCProvider()
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled": ok,
+		"name":    name,
+	})
+}
+
+// startOIDCLogin returns the provider URL to redirect the browser to. The
+// frontend keeps the state and checks it when the provider redirects back.
+func (s *Server) startOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	authURL, state, err := s.auth.StartOIDCLogin(r.Context())
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"authorizationUrl": authURL,
+		"state":            state,
+	})
+}
+
+// completeOIDCLogin receives the code and state the provider redirected back with
+func (s *Server) completeOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := s.auth.CompleteOIDCLogin(r.Context(), request.Code, request.State, s.client(r))
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithLoginResult(w, result)
+}
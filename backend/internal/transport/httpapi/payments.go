@@ -0,0 +1,103 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"borrowhub/internal/payment"
+)
+
+// Payment handlers for Razorpay integration
+func (s *Server) createPaymentOrder(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+	if userID == "" {
+		respondWithError(w, http.StatusUnauthorized, "User authentication required")
+		return
+	}
+
+	var request struct {
+		BookingID string `json:"bookingId"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	order, err := s.payments.CreateOrder(r.Context(), userID, request.BookingID)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	// Return payment order details for frontend
+	response := map[string]interface{}{
+		"paymentId":   order.Payment.ID,
+		"orderId":     order.Payment.GatewayID,
+		"amount":      order.Payment.Amount * 100, // Razorpay expects amount in paise
+		"currency":    order.Payment.Currency,
+		"key":         order.KeyID,
+		"name":        "BorrowHub",
+		"description": "Booking payment for item",
+		"bookingId":   order.Booking.ID,
+	}
+
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func (s *Server) verifyPayment(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+	if userID == "" {
+		respondWithError(w, http.StatusUnauthorized, "User authentication required")
+		return
+	}
+
+	var request struct {
+		PaymentID         string `json:"paymentId"`
+		RazorpayPaymentID string `json:"razorpayPaymentId"`
+		RazorpayOrderID   string `json:"razorpayOrderId"`
+		RazorpaySignature string `json:"razorpaySignature"`
+		Status            string `json:"status"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	p, booking, err := s.payments.Verify(r.Context(), userID, payment.Verification(request))
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	if p.Status != payment.StatusSuccess {
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"status":  "failed",
+			"message": "Payment verification failed",
+		})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"booking": booking,
+		"payment": p,
+	})
+}
+
+func (s *Server) getPaymentHistory(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+	if userID == "" {
+		respondWithError(w, http.StatusUnauthorized, "User authentication required")
+		return
+	}
+
+	userPayments, err := s.payments.History(r.Context(), userID)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userPayments)
+}
@@ -0,0 +1,191 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"borrowhub/internal/auth"
+)
+
+// access is who a route admits. The zero value means no policy was declared.
+type access int
+
+const (
+	accessPublic access = iota + 1
+	accessAuthenticated
+	accessOwner
+	accessPermission
+)
+
+// policy declares who may call a route. authMiddleware enforces it before
+// the handler runs.
+type policy struct {
+	access access
+
+	// Owner policies only: the users who own the resource the request names,
+	// and the messages for a missing resource and, if anyone may see the
+	// resource, for everyone else. Without denied, other users' resources
+	// answer notFound too, so their IDs cannot be probed.
+	owners   func(s *Server, r *http.Request) ([]string, error)
+	notFound string
+	denied   string
+
+	// Permission policies only: what the caller's role must grant
+	permission auth.Permission
+
+	// What an API key must be granted to call the route. API keys are
+	// refused on routes without one.
+	scope auth.Scope
+}
+
+var (
+	// public routes need no token
+	public = policy{access: accessPublic}
+	// authenticated routes need a valid access token
+	authenticated = policy{access: accessAuthenticated}
+
+	// itemOwner admits the owner of the item in the {id} route variable.
+	// Items are listed publicly, so other users are told they are not theirs.
+	itemOwner = policy{
+		access: accessOwner,
+		owners: func(s *Server, r *http.Request) ([]string, error) {
+			item, err := s.store.GetItem(r.Context(), mux.Vars(r)["id"])
+			if err != nil {
+				return nil, err
+			}
+			return []string{item.OwnerID}, nil
+		},
+		notFound: "Item not found",
+		denied:   "You can only change your own items",
+	}
+
+	// bookingParty admits the renter of the booking in the {id} route
+	// variable and the owner of the booked item
+	bookingParty = policy{
+		access: accessOwner,
+		owners: func(s *Server, r *http.Request) ([]string, error) {
+			booking, err := s.store.GetBooking(r.Context(), mux.Vars(r)["id"])
+			if err != nil {
+				return nil, err
+			}
+			item, err := s.store.GetItem(r.Context(), booking.ItemID)
+			if err != nil {
+				return nil, err
+			}
+			return []string{booking.UserID, item.OwnerID}, nil
+		},
+		notFound: "Booking not found",
+	}
+
+	// apiKeyOwner admits the owner of the API key in the {id} route variable
+	apiKeyOwner = policy{
+		access: accessOwner,
+		owners: func(s *Server, r *http.Request) ([]string, error) {
+			key, err := s.store.GetAPIKey(r.Context(), mux.Vars(r)["id"])
+			if err != nil {
+				return nil, err
+			}
+			return []string{key.UserID}, nil
+		},
+		notFound: "API key not found",
+	}
+
+	// sessionOwner admits the user the session in the {id} route variable belongs to
+	sessionOwner = policy{
+		access: accessOwner,
+		owners: func(s *Server, r *http.Request) ([]string, error) {
+			session, err := s.store.GetSession(r.Context(), mux.Vars(r)["id"])
+			if err != nil {
+				return nil, err
+			}
+			return []string{session.UserID}, nil
+		},
+		notFound: "Session not found",
+	}
+)
+
+// requires admits callers whose role grants permission
+func requires(permission auth.Permission) policy {
+	return policy{access: accessPermission, permission: permission}
+}
+
+// withScope returns a copy of p that also admits API keys granted scope
+func (p policy) withScope(scope auth.Scope) policy {
+	p.scope = scope
+	return p
+}
+
+// handle registers handler for path under policy p
+func (s *Server) handle(router *mux.Router, path string, p policy, handler http.HandlerFunc) *mux.Route {
+	route := router.HandleFunc(path, handler)
+	s.policies[route] = p
+	return route
+}
+
+// checkPolicies reports every route of router that was registered without a policy
+func (s *Server) checkPolicies(router *mux.Router) error {
+	var undeclared []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if s.policies[route].access != 0 {
+			return nil
+		}
+		name, err := route.GetPathTemplate()
+		if err != nil {
+			name = "(no path)"
+		}
+		if methods, err := route.GetMethods(); err == nil {
+			name = strings.Join(methods, ",") + " " + name
+		}
+		undeclared = append(undeclared, name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(undeclared) > 0 {
+		return fmt.Errorf("routes without an access policy: %s", strings.Join(undeclared, "; "))
+	}
+	return nil
+}
+
+// authorize checks the caller of r against policy p once authentication has
+// succeeded, and responds itself when the caller is refused
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, p policy) bool {
+	if caller := principal(r); caller.ViaAPIKey() && (p.scope == "" || !caller.HasScope(p.scope)) {
+		respondWithError(w, http.StatusForbidden, "This API key is not allowed to do this")
+		return false
+	}
+
+	switch p.access {
+	case accessAuthenticated:
+		return true
+	case accessOwner:
+		owners, err := p.owners(s, r)
+		if err != nil {
+			respondWithStoreError(w, err, p.notFound)
+			return false
+		}
+		if !slices.Contains(owners, principal(r).UserID) {
+			if p.denied == "" {
+				respondWithError(w, http.StatusNotFound, p.notFound)
+			} else {
+				respondWithError(w, http.StatusForbidden, p.denied)
+			}
+			return false
+		}
+		return true
+	case accessPermission:
+		if !principal(r).Can(p.permission) {
+			respondWithError(w, http.StatusForbidden, "You do not have permission to do this")
+			return false
+		}
+		return true
+	}
+	// Undeclared policies never reach here past checkPolicies; refuse them all the same
+	respondWithError(w, http.StatusForbidden, "Access denied")
+	return false
+}
@@ -0,0 +1,322 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+
+	"borrowhub/internal/auth"
+	"borrowhub/internal/mail"
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+const testPassword = "correct horse battery staple"
+
+func ok(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestCheckPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes func(s *Server) *mux.Router
+		want   []string // Routes the error must name; none if it must succeed
+	}{
+		{
+			name:   "every API route",
+			routes: (*Server).routes,
+		},
+		{
+			name: "declared",
+			routes: func(s *Server) *mux.Router {
+				router := mux.NewRouter()
+				s.handle(router, "/open", public, ok).Methods("GET")
+				s.handle(router, "/closed", requires(auth.PermViewUsers), ok).Methods("GET")
+				return router
+			},
+		},
+		{
+			name: "registered without handle",
+			routes: func(s *Server) *mux.Router {
+				router := mux.NewRouter()
+				s.handle(router, "/open", public, ok).Methods("GET")
+				router.HandleFunc("/forgotten", ok).Methods("POST", "DELETE")
+				return router
+			},
+			want: []string{"POST,DELETE /forgotten"},
+		},
+		{
+			name: "subrouter",
+			routes: func(s *Server) *mux.Router {
+				router := mux.NewRouter()
+				api := router.PathPrefix("/api").Subrouter()
+				s.handle(api, "/declared", authenticated, ok).Methods("GET")
+				api.HandleFunc("/forgotten", ok).Methods("GET")
+				return router
+			},
+			want: []string{"GET /api/forgotten"},
+		},
+		{
+			name: "zero policy",
+			routes: func(s *Server) *mux.Router {
+				router := mux.NewRouter()
+				s.handle(router, "/zero", policy{}, ok).Methods("PUT")
+				s.handle(router, "/scope-only", policy{scope: auth.ScopeItemsRead}, ok).Methods("GET")
+				return router
+			},
+			want: []string{"PUT /zero", "GET /scope-only"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{policies: make(map[*mux.Route]policy)}
+			err := s.checkPolicies(tt.routes(s))
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("checkPolicies: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("checkPolicies accepted routes without a policy, want %v named", tt.want)
+			}
+			for _, route := range tt.want {
+				if !strings.Contains(err.Error(), route) {
+					t.Errorf("checkPolicies error %q does not name %s", err, route)
+				}
+			}
+		})
+	}
+}
+
+// newTestServer returns a Server over an in-memory store whose auth
+// middleware guards the routes registered by configure
+func newTestServer(t *testing.T, configure func(s *Server, router *mux.Router)) (*Server, http.Handler, *store.Database) {
+	t.Helper()
+	db := store.NewDatabase()
+	s := &Server{
+		store:    db,
+		auth:     newTestAuth(t, db),
+		policies: make(map[*mux.Route]policy),
+	}
+	router := mux.NewRouter()
+	configure(s, router)
+	if err := s.checkPolicies(router); err != nil {
+		t.Fatal(err)
+	}
+	router.Use(s.authMiddleware)
+	return s, router, db
+}
+
+// newTestAuth returns an auth service over db with a throwaway key and cheap hashing
+func newTestAuth(t *testing.T, db store.Store) *auth.Service {
+	t.Helper()
+	keys, err := auth.GenerateKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := auth.NewTokenManager(auth.TokenOptions{
+		Keys:     keys,
+		Issuer:   "borrowhub",
+		Audience: "borrowhub-api",
+		TTL:      15 * time.Minute,
+		Secret:   []byte("test-secret-used-only-by-these-tests"),
+	})
+	return auth.NewService(db, tokens, auth.Options{
+		RefreshTTL:     time.Hour,
+		Lockout:        auth.LockoutPolicy{Threshold: 3, IPThreshold: 10, Delay: time.Minute, MaxDelay: time.Hour},
+		PasswordPolicy: auth.PasswordPolicy{MinLength: 8, MinClasses: 1},
+		BcryptCost:     bcrypt.MinCost,
+		Mailer:         mail.LogMailer{},
+	})
+}
+
+// createTestUser stores a verified user with testPassword and role
+func createTestUser(t *testing.T, db store.Store, email, role string) *models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{
+		ID:        "usr_" + email,
+		Username:  email,
+		Email:     email,
+		Password:  string(hash),
+		Verified:  true,
+		Role:      role,
+		CreatedAt: time.Now(),
+	}
+	if err := db.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestAuthMiddlewareScopes(t *testing.T) {
+	s, handler, db := newTestServer(t, func(s *Server, router *mux.Router) {
+		s.handle(router, "/public", public, ok).Methods("GET")
+		s.handle(router, "/items", authenticated.withScope(auth.ScopeItemsRead), ok).Methods("GET")
+		s.handle(router, "/items/{id}", itemOwner.withScope(auth.ScopeItemsWrite), ok).Methods("PUT")
+		s.handle(router, "/sessions", authenticated, ok).Methods("GET")
+		s.handle(router, "/admin/users", requires(auth.PermViewUsers), ok).Methods("GET")
+	})
+	ctx := context.Background()
+	admin := createTestUser(t, db, "admin@example.com", models.RoleAdmin)
+	if err := db.CreateItem(ctx, &models.Item{ID: "itm_tent", Name: "Tent", DailyRate: 10, OwnerID: admin.ID, Available: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.auth.Login(ctx, admin.Email, testPassword, auth.Client{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken := result.Tokens.AccessToken
+	apiKey := func(scopes ...auth.Scope) *auth.NewAPIKey {
+		names := make([]string, 0, len(scopes))
+		for _, scope := range scopes {
+			names = append(names, string(scope))
+		}
+		key, err := s.auth.CreateAPIKey(ctx, admin.ID, "test", names, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	readItems := apiKey(auth.ScopeItemsRead).Secret
+	writeItems := apiKey(auth.ScopeItemsWrite).Secret
+	everything := apiKey(auth.ScopeItemsRead, auth.ScopeItemsWrite, auth.ScopeBookingsRead, auth.ScopeBookingsWrite,
+		auth.ScopePaymentsRead, auth.ScopePaymentsWrite, auth.ScopeProfileRead, auth.ScopeProfileWrite).Secret
+	revoked := apiKey(auth.ScopeItemsRead)
+	if err := s.auth.RevokeAPIKey(ctx, revoked.Key.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"public route without a token", "GET", "/public", "", http.StatusNoContent},
+		{"public route with a key lacking scopes", "GET", "/public", writeItems, http.StatusNoContent},
+		{"no token", "GET", "/items", "", http.StatusUnauthorized},
+		{"access token on scoped route", "GET", "/items", accessToken, http.StatusNoContent},
+		{"key with the scope", "GET", "/items", readItems, http.StatusNoContent},
+		{"key with another scope", "GET", "/items", writeItems, http.StatusForbidden},
+		{"revoked key with the scope", "GET", "/items", revoked.Secret, http.StatusUnauthorized},
+		{"owner key with the write scope", "PUT", "/items/itm_tent", writeItems, http.StatusNoContent},
+		{"owner key with only the read scope", "PUT", "/items/itm_tent", readItems, http.StatusForbidden},
+		{"access token on unscoped route", "GET", "/sessions", accessToken, http.StatusNoContent},
+		{"key on unscoped route", "GET", "/sessions", everything, http.StatusForbidden},
+		{"admin access token on permission route", "GET", "/admin/users", accessToken, http.StatusNoContent},
+		{"admin key on permission route", "GET", "/admin/users", everything, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("%s %s = %d %s, want %d", tt.method, tt.path, w.Code, strings.TrimSpace(w.Body.String()), tt.want)
+			}
+		})
+	}
+}
+
+func TestOwnerPolicies(t *testing.T) {
+	s, handler, db := newTestServer(t, func(s *Server, router *mux.Router) {
+		s.handle(router, "/items/{id}", itemOwner, ok).Methods("PUT")
+		s.handle(router, "/bookings/{id}", bookingParty, ok).Methods("PUT")
+		s.handle(router, "/keys/{id}", apiKeyOwner, ok).Methods("DELETE")
+		s.handle(router, "/sessions/{id}", sessionOwner, ok).Methods("DELETE")
+	})
+	ctx := context.Background()
+	ana := createTestUser(t, db, "ana@example.com", models.RoleUser)
+	ben := createTestUser(t, db, "ben@example.com", models.RoleUser)
+	eve := createTestUser(t, db, "eve@example.com", models.RoleUser)
+	tokens := make(map[string]string)
+	var sessionID string
+	for _, user := range []*models.User{ana, ben, eve} {
+		result, err := s.auth.Login(ctx, user.Email, testPassword, auth.Client{IP: "192.0.2.1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[user.Email] = result.Tokens.AccessToken
+		if user == ana {
+			sessions, err := db.ListSessions(ctx, ana.ID)
+			if err != nil || len(sessions) != 1 {
+				t.Fatalf("sessions of ana: %v, %v", sessions, err)
+			}
+			sessionID = sessions[0].ID
+		}
+	}
+	if err := db.CreateItem(ctx, &models.Item{ID: "itm_tent", Name: "Tent", DailyRate: 10, OwnerID: ana.ID, Available: true}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	if err := db.CreateBooking(ctx, &models.Booking{ID: "bkg_tent", ItemID: "itm_tent", UserID: ben.ID, StartDate: start, EndDate: start.AddDate(0, 0, 2), Status: models.BookingPending}); err != nil {
+		t.Fatal(err)
+	}
+	key, err := s.auth.CreateAPIKey(ctx, ana.ID, "test", []string{string(auth.ScopeItemsRead)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		caller string
+		want   int
+	}{
+		{"item owner", "PUT", "/items/itm_tent", ana.Email, http.StatusNoContent},
+		{"item of another user", "PUT", "/items/itm_tent", ben.Email, http.StatusForbidden},
+		{"missing item", "PUT", "/items/itm_missing", ana.Email, http.StatusNotFound},
+		{"booking renter", "PUT", "/bookings/bkg_tent", ben.Email, http.StatusNoContent},
+		{"booked item owner", "PUT", "/bookings/bkg_tent", ana.Email, http.StatusNoContent},
+		{"booking of others", "PUT", "/bookings/bkg_tent", eve.Email, http.StatusNotFound},
+		{"missing booking", "PUT", "/bookings/bkg_missing", eve.Email, http.StatusNotFound},
+		{"key owner", "DELETE", "/keys/" + key.Key.ID, ana.Email, http.StatusNoContent},
+		{"key of another user", "DELETE", "/keys/" + key.Key.ID, ben.Email, http.StatusNotFound},
+		{"missing key", "DELETE", "/keys/key_missing", ben.Email, http.StatusNotFound},
+		{"session owner", "DELETE", "/sessions/" + sessionID, ana.Email, http.StatusNoContent},
+		{"session of another user", "DELETE", "/sessions/" + sessionID, ben.Email, http.StatusNotFound},
+		{"missing session", "DELETE", "/sessions/ses_missing", ben.Email, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			r.Header.Set("Authorization", "Bearer "+tokens[tt.caller])
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("%s %s as %s = %d %s, want %d", tt.method, tt.path, tt.caller, w.Code, strings.TrimSpace(w.Body.String()), tt.want)
+			}
+		})
+	}
+
+	// Someone else's resource and a missing one answer alike
+	answer := func(path, caller string) string {
+		r := httptest.NewRequest("DELETE", path, nil)
+		r.Header.Set("Authorization", "Bearer "+tokens[caller])
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+	if other, missing := answer("/sessions/"+sessionID, ben.Email), answer("/sessions/ses_missing", ben.Email); other != missing {
+		t.Errorf("another user's session answered %q, a missing one %q", other, missing)
+	}
+}
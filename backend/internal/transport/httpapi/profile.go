@@ -0,0 +1,172 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"borrowhub/internal/models"
+	"borrowhub/internal/store"
+)
+
+// Enhanced profile handlers
+func (s *Server) getUserProfile(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+	if userID == "" {
+		respondWithError(w, http.StatusUnauthorized, "User authentication required")
+		return
+	}
+
+	user, err := s.store.GetUser(r.Context(), userID)
+	if err != nil {
+		respondWithStoreError(w, err, "User not found")
+		return
+	}
+
+	// Add user statistics
+	items, err := s.store.ListItems(r.Context(), store.ItemFilter{OwnerID: userID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading profile")
+		return
+	}
+	bookings, err := s.store.ListBookings(r.Context(), store.BookingFilter{UserID: userID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading profile")
+		return
+	}
+	// Calculate earnings from completed bookings of the user's items
+	completed, err := s.store.ListBookings(r.Context(), store.BookingFilter{ItemOwnerID: userID, Statuses: []string{models.BookingCompleted}})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading profile")
+		return
+	}
+
+	totalEarnings := 0.0
+	for _, booking := range completed {
+		totalEarnings += booking.TotalPrice
+	}
+
+	response := map[string]interface{}{
+		"user": user.Public(),
+		"stats": map[string]interface{}{
+			"itemsListed":   len(items),
+			"bookingsMade":  len(bookings),
+			"totalEarnings": totalEarnings,
+		},
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) updateUserProfile(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+	if userID == "" {
+		respondWithError(w, http.StatusUnauthorized, "User authentication required")
+		return
+	}
+
+	var updates models.User
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := s.store.GetUser(r.Context(), userID)
+	if err != nil {
+		respondWithStoreError(w, err, "User not found")
+		return
+	}
+
+	// Update only provided fields
+	if updates.FirstName != "" {
+		user.FirstName = updates.FirstName
+	}
+	if updates.LastName != "" {
+		user.LastName = updates.LastName
+	}
+	if updates.Phone != "" {
+		user.Phone = updates.Phone
+	}
+	if updates.Address != "" {
+		user.Address = updates.Address
+	}
+	if updates.Username != "" {
+		user.Username = updates.Username
+	}
+
+	if err := s.store.UpdateUser(r.Context(), user); err != nil {
+		respondWithStoreError(w, err, "User not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, user.Public())
+}
+
+// changePassword sets a new password for the caller, who must give the current one
+func (s *Server) changePassword(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	caller := principal(r)
+	if err := s.auth.ChangePassword(r.Context(), caller.UserID, caller.SessionID, request.CurrentPassword, request.NewPassword, s.clientIP(r)); err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Password changed"})
+}
+
+// exportProfile returns the caller's personal data as a JSON download
+func (s *Server) exportProfile(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+	export, err := s.accounts.Export(r.Context(), userID)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="borrowhub-export-`+userID+`.json"`)
+	respondWithJSON(w, http.StatusOK, export)
+}
+
+// deleteAccount erases the caller's personal data and signs them out everywhere
+func (s *Server) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	request, err := decodePasswordConfirmation(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	caller := principal(r)
+	if err := s.accounts.Delete(r.Context(), caller.UserID, caller.SessionID, request.Password, request.Code, s.clientIP(r)); err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Account deleted"})
+}
+
+// passwordConfirmation is the body of requests that need the caller's
+// password, or a second-factor code from accounts without one
+type passwordConfirmation struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// decodePasswordConfirmation reads the body of r, which may be empty for
+// accounts confirmed by a fresh login
+func decodePasswordConfirmation(r *http.Request) (*passwordConfirmation, error) {
+	var request passwordConfirmation
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return &request, nil
+}
@@ -0,0 +1,54 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"borrowhub/internal/apperr"
+	"borrowhub/internal/store"
+)
+
+// Utility functions
+func respondWithJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func respondWithError(w http.ResponseWriter, status int, message string) {
+	respondWithJSON(w, status, map[string]string{"error": message})
+}
+
+// respondWithStoreError maps store errors to HTTP statuses, using notFoundMessage for ErrNotFound
+func respondWithStoreError(w http.ResponseWriter, err error, notFoundMessage string) {
+	if errors.Is(err, store.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, notFoundMessage)
+		return
+	}
+	log.Printf("Store error: %v", err)
+	respondWithError(w, http.StatusInternalServerError, "Internal server error")
+}
+
+// respondWithAppError maps a service error to its HTTP status and client-facing message
+func respondWithAppError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch apperr.KindOf(err) {
+	case apperr.Invalid:
+		status = http.StatusBadRequest
+	case apperr.Unauthorized:
+		status = http.StatusUnauthorized
+	case apperr.Forbidden:
+		status = http.StatusForbidden
+	case apperr.NotFound:
+		status = http.StatusNotFound
+	case apperr.Conflict:
+		status = http.StatusConflict
+	case apperr.TooManyRequests:
+		status = http.StatusTooManyRequests
+	default:
+		log.Printf("Internal error: %v", err)
+	}
+	respondWithError(w, status, apperr.MessageOf(err))
+}
@@ -0,0 +1,179 @@
+// Package httpapi exposes the BorrowHub REST API over net/http.
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"borrowhub/internal/account"
+	"borrowhub/internal/admin"
+	"borrowhub/internal/auth"
+	"borrowhub/internal/booking"
+	"borrowhub/internal/catalog"
+	"borrowhub/internal/config"
+	"borrowhub/internal/payment"
+	"borrowhub/internal/store"
+)
+
+// Deps are the collaborators the API is built from
+type Deps struct {
+	Config   *config.Config
+	Store    store.Store
+	Auth     *auth.Service
+	Bookings *booking.Service
+	Payments *payment.Service
+	Admin    *admin.Service
+	Accounts *account.Service
+	Catalog  *catalog.Taxonomy
+}
+
+// Server holds the dependencies shared by the handlers
+type Server struct {
+	config   *config.Config
+	store    store.Store
+	auth     *auth.Service
+	bookings *booking.Service
+	payments *payment.Service
+	admin    *admin.Service
+	accounts *account.Service
+	catalog  *catalog.Taxonomy
+	policies map[*mux.Route]policy // Who may call each route
+}
+
+// New returns the API handler, wrapped in the CORS middleware. Each route is
+// guarded by the access policy it was registered with; New fails if a route
+// has none.
+func New(d Deps) (http.Handler, error) {
+	s := &Server{
+		config:   d.Config,
+		store:    d.Store,
+		auth:     d.Auth,
+		bookings: d.Bookings,
+		payments: d.Payments,
+		admin:    d.Admin,
+		accounts: d.Accounts,
+		catalog:  d.Catalog,
+		policies: make(map[*mux.Route]policy),
+	}
+	router := s.routes()
+	if err := s.checkPolicies(router); err != nil {
+		return nil, err
+	}
+	router.Use(s.authMiddleware)
+	return s.corsMiddleware(router), nil
+}
+
+// routes registers every endpoint on a new router
+func (s *Server) routes() *mux.Router {
+	router := mux.NewRouter()
+
+	// Authentication routes (no /api prefix to match frontend)
+	s.handle(router, "/register", public, s.register).Methods("POST", "OPTIONS")
+	s.handle(router, "/login", public, s.login).Methods("POST", "OPTIONS")
+	s.handle(router, "/auth/refresh", public, s.refreshSession).Methods("POST", "OPTIONS")
+	s.handle(router, "/auth/logout", authenticated, s.logout).Methods("POST", "OPTIONS")
+	s.handle(router, "/auth/logout-all", authenticated, s.logoutAll).Methods("POST", "OPTIONS")
+	s.handle(router, "/auth/forgot-password", public, s.forgotPassword).Methods("POST", "OPTIONS")
+	s.handle(router, "/auth/reset-password", public, s.resetPassword).Methods("POST", "OPTIONS")
+	s.handle(router, "/auth/verify-email", public, s.verifyEmail).Methods("POST", "OPTIONS")
+	s.handle(router, "/auth/resend-verification", authenticated, s.resendVerification).Methods("POST", "OPTIONS")
+
+	// Public keys for services that verify access tokens themselves
+	s.handle(router, "/.well-known/jwks.json", public, s.getJWKS).Methods("GET", "OPTIONS")
+
+	// Single sign-on with an external OpenID Connect provider
+	s.handle(router, "/auth/oidc", public, s.getOIDCProvider).Methods("GET", "OPTIONS")
+	s.handle(router, "/auth/oidc/start", public, s.startOIDCLogin).Methods("POST", "OPTIONS")
+	s.handle(router, "/auth/oidc/callback", public, s.completeOIDCLogin).Methods("POST", "OPTIONS")
+
+	// Two-factor authentication
+	s.handle(router, "/auth/mfa/challenge", public, s.completeMFALogin).Methods("POST", "OPTIONS")
+	s.handle(router, "/auth/mfa", authenticated, s.getMFAStatus).Methods("GET", "OPTIONS")
+	s.handle(router, "/auth/mfa/enroll", authenticated, s.enrollTOTP).Methods("POST", "OPTIONS")
+	s.handle(router, "/auth/mfa/verify", authenticated, s.confirmTOTP).Methods("POST", "OPTIONS")
+	s.handle(router, "/auth/mfa/disable", authenticated, s.disableTOTP).Methods("POST", "OPTIONS")
+	s.handle(router, "/auth/mfa/backup-codes", authenticated, s.regenerateBackupCodes).Methods("POST", "OPTIONS")
+
+	// Item routes (support both /items and /api/items patterns)
+	s.handle(router, "/items", public, s.getItems).Methods("GET", "OPTIONS")
+	s.handle(router, "/items/{id}", public, s.getItemDetails).Methods("GET", "OPTIONS")
+	s.handle(router, "/api/items", public, s.getItems).Methods("GET", "OPTIONS")
+	s.handle(router, "/api/items/{id}", public, s.getItemDetails).Methods("GET", "OPTIONS")
+	s.handle(router, "/api/items", authenticated.withScope(auth.ScopeItemsWrite), s.requireVerified(s.addItem)).Methods("POST", "OPTIONS")
+	s.handle(router, "/api/items/{id}", itemOwner.withScope(auth.ScopeItemsWrite), s.updateItem).Methods("PUT", "OPTIONS")
+	s.handle(router, "/api/items/{id}", itemOwner.withScope(auth.ScopeItemsWrite), s.deleteItem).Methods("DELETE", "OPTIONS")
+
+	// Category taxonomy, with the attributes each category takes
+	s.handle(router, "/api/categories", public, s.getCategories).Methods("GET", "OPTIONS")
+
+	// User's own items
+	s.handle(router, "/api/my-items", authenticated.withScope(auth.ScopeItemsRead), s.getUserItems).Methods("GET", "OPTIONS")
+
+	// Availability calendar
+	s.handle(router, "/api/items/{id}/availability", authenticated.withScope(auth.ScopeItemsRead), s.getAvailabilityCalendar).Methods("GET", "OPTIONS")
+
+	// Booking routes (with /api prefix to match frontend)
+	s.handle(router, "/api/bookings", authenticated.withScope(auth.ScopeBookingsWrite), s.requireVerified(s.createBooking)).Methods("POST", "OPTIONS")
+	s.handle(router, "/api/bookings", authenticated.withScope(auth.ScopeBookingsRead), s.getUserBookings).Methods("GET", "OPTIONS")
+	s.handle(router, "/bookings", authenticated.withScope(auth.ScopeBookingsRead), s.getUserBookings).Methods("GET", "OPTIONS") // Alternative endpoint
+	s.handle(router, "/api/bookings/{id}", bookingParty.withScope(auth.ScopeBookingsWrite), s.updateBookingStatus).Methods("PUT", "OPTIONS")
+	s.handle(router, "/bookings/{id}", bookingParty.withScope(auth.ScopeBookingsWrite), s.updateBookingStatus).Methods("PUT", "OPTIONS") // Alternative endpoint
+
+	// Payment routes for Razorpay
+	s.handle(router, "/api/payments/create-order", authenticated.withScope(auth.ScopePaymentsWrite), s.requireVerified(s.createPaymentOrder)).Methods("POST", "OPTIONS")
+	s.handle(router, "/api/payments/verify", authenticated.withScope(auth.ScopePaymentsWrite), s.verifyPayment).Methods("POST", "OPTIONS")
+	s.handle(router, "/api/payments/history", authenticated.withScope(auth.ScopePaymentsRead), s.getPaymentHistory).Methods("GET", "OPTIONS")
+
+	// Image upload
+	s.handle(router, "/api/upload/image", authenticated.withScope(auth.ScopeItemsWrite), s.uploadImage).Methods("POST", "OPTIONS")
+
+	// Profile routes (support both patterns)
+	s.handle(router, "/api/profile", authenticated.withScope(auth.ScopeProfileRead), s.getUserProfile).Methods("GET", "OPTIONS")
+	s.handle(router, "/profile", authenticated.withScope(auth.ScopeProfileRead), s.getUserProfile).Methods("GET", "OPTIONS")
+	s.handle(router, "/api/profile", authenticated.withScope(auth.ScopeProfileWrite), s.updateUserProfile).Methods("PUT", "OPTIONS")
+	s.handle(router, "/profile", authenticated.withScope(auth.ScopeProfileWrite), s.updateUserProfile).Methods("PUT", "OPTIONS")
+	s.handle(router, "/api/profile/password", authenticated, s.changePassword).Methods("PUT", "OPTIONS")
+	s.handle(router, "/api/profile/export", authenticated, s.exportProfile).Methods("GET", "OPTIONS")
+	s.handle(router, "/api/profile", authenticated, s.deleteAccount).Methods("DELETE", "OPTIONS")
+
+	// Personal API keys; managing them takes a login, not a key
+	s.handle(router, "/api/keys", authenticated, s.createAPIKey).Methods("POST", "OPTIONS")
+	s.handle(router, "/api/keys", authenticated, s.listAPIKeys).Methods("GET", "OPTIONS")
+	s.handle(router, "/api/keys/{id}", apiKeyOwner, s.revokeAPIKey).Methods("DELETE", "OPTIONS")
+
+	// Signed-in devices; API keys cannot see or end them
+	s.handle(router, "/api/sessions", authenticated, s.listSessions).Methods("GET", "OPTIONS")
+	s.handle(router, "/api/sessions/{id}", sessionOwner, s.revokeSession).Methods("DELETE", "OPTIONS")
+
+	// Admin API, for staff roles with the matching permission
+	s.handle(router, "/admin/users", requires(auth.PermViewUsers), s.adminListUsers).Methods("GET", "OPTIONS")
+	s.handle(router, "/admin/users/{id}/suspend", requires(auth.PermSuspendUsers), s.adminSuspendUser).Methods("POST", "OPTIONS")
+	s.handle(router, "/admin/users/{id}/restore", requires(auth.PermSuspendUsers), s.adminRestoreUser).Methods("POST", "OPTIONS")
+	s.handle(router, "/admin/users/{id}/role", requires(auth.PermAssignRoles), s.adminSetRole).Methods("PUT", "OPTIONS")
+	s.handle(router, "/admin/items", requires(auth.PermModerateItems), s.adminListItems).Methods("GET", "OPTIONS")
+	s.handle(router, "/admin/items/{id}/suspend", requires(auth.PermModerateItems), s.adminSuspendItem).Methods("POST", "OPTIONS")
+	s.handle(router, "/admin/items/{id}/restore", requires(auth.PermModerateItems), s.adminRestoreItem).Methods("POST", "OPTIONS")
+	s.handle(router, "/admin/bookings/{id}/status", requires(auth.PermManageBookings), s.adminForceBookingStatus).Methods("PUT", "OPTIONS")
+	s.handle(router, "/admin/payments", requires(auth.PermViewPayments), s.adminListPayments).Methods("GET", "OPTIONS")
+	s.handle(router, "/admin/audit", requires(auth.PermViewAuditLog), s.adminAuditLog).Methods("GET", "OPTIONS")
+
+	// Health check endpoint
+	s.handle(router, "/health", public, func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
+	}).Methods("GET")
+
+	// OPTIONS handler for preflight requests
+	catchAll := router.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			// This will be handled by corsMiddleware
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		// Handle 404 for other requests
+		respondWithError(w, http.StatusNotFound, "Endpoint not found")
+	}).Methods("OPTIONS", "GET", "POST", "PUT", "DELETE")
+	s.policies[catchAll] = public
+
+	return router
+}
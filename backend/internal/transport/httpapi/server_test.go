@@ -0,0 +1,98 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"borrowhub/internal/account"
+	"borrowhub/internal/admin"
+	"borrowhub/internal/auth"
+	"borrowhub/internal/booking"
+	"borrowhub/internal/catalog"
+	"borrowhub/internal/config"
+	"borrowhub/internal/models"
+	"borrowhub/internal/payment"
+	"borrowhub/internal/store"
+)
+
+// newTestAPI returns the full API built by New over its own in-memory store,
+// and the auth service it uses
+func newTestAPI(t *testing.T) (http.Handler, *store.Database, *auth.Service) {
+	t.Helper()
+	taxonomy, err := catalog.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := store.NewDatabase()
+	authService := newTestAuth(t, db)
+	handler, err := New(Deps{
+		Config:   config.Default(),
+		Store:    db,
+		Auth:     authService,
+		Bookings: booking.NewService(db),
+		Payments: payment.NewService(db, "rzp_test_key"),
+		Admin:    admin.NewService(db),
+		Accounts: account.NewService(db, authService),
+		Catalog:  taxonomy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handler, db, authService
+}
+
+func TestNewServesItsOwnDependencies(t *testing.T) {
+	first, firstDB, _ := newTestAPI(t)
+	second, _, _ := newTestAPI(t)
+	owner := createTestUser(t, firstDB, "owner@example.com", models.RoleUser)
+	item := &models.Item{ID: "itm_tent", OwnerID: owner.ID, Name: "Tent", DailyRate: 10, Available: true}
+	if err := firstDB.CreateItem(context.Background(), item); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each handler reads only the store it was given
+	var items []models.Item
+	if code := serve(t, first, "GET", "/api/items", "", "", &items); code != http.StatusOK || len(items) != 1 || items[0].ID != "itm_tent" {
+		t.Errorf("first handler: %d %+v, want itm_tent", code, items)
+	}
+	items = nil
+	if code := serve(t, second, "GET", "/api/items", "", "", &items); code != http.StatusOK || len(items) != 0 {
+		t.Errorf("second handler: %d %+v, want no items", code, items)
+	}
+
+	// Routes are guarded by their policies
+	if code := serve(t, first, "GET", "/api/bookings", "", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/bookings without a token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := serve(t, first, "GET", "/api/nowhere", "", "", nil); code != http.StatusNotFound {
+		t.Errorf("GET /api/nowhere = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestNewAllowsConfiguredOrigins(t *testing.T) {
+	handler, _, _ := newTestAPI(t)
+	tests := []struct {
+		origin string
+		want   string // Access-Control-Allow-Origin
+	}{
+		{"http://localhost:5173", "http://localhost:5173"},
+		{"https://evil.example.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			// Preflight requests are answered before authentication
+			r := httptest.NewRequest("OPTIONS", "/api/bookings", nil)
+			r.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Errorf("preflight = %d, want %d", w.Code, http.StatusOK)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+				t.Errorf("Access-Control-Allow-Origin %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"borrowhub/internal/models"
+)
+
+// sessionInfo is a session as listed to its user, marking the one making the request
+type sessionInfo struct {
+	*models.Session
+	Current bool `json:"current"`
+}
+
+// listSessions returns the devices the user is signed in on
+func (s *Server) listSessions(w http.ResponseWriter, r *http.Request) {
+	caller := principal(r)
+	sessions, err := s.auth.ListSessions(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+
+	infos := make([]sessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, sessionInfo{Session: session, Current: session.ID == caller.SessionID})
+	}
+	respondWithJSON(w, http.StatusOK, infos)
+}
+
+// revokeSession signs one device out. Its access token stops working at once.
+func (s *Server) revokeSession(w http.ResponseWriter, r *http.Request) {
+	if err := s.auth.Logout(r.Context(), mux.Vars(r)["id"]); err != nil {
+		respondWithAppError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Session revoked"})
+}
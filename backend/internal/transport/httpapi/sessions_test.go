@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"borrowhub/internal/auth"
+	"borrowhub/internal/models"
+)
+
+func TestSessionEndpoints(t *testing.T) {
+	s, handler, db := newTestServer(t, func(s *Server, router *mux.Router) {
+		s.handle(router, "/api/sessions", authenticated, s.listSessions).Methods("GET")
+		s.handle(router, "/api/sessions/{id}", sessionOwner, s.revokeSession).Methods("DELETE")
+	})
+	ctx := context.Background()
+	ana := createTestUser(t, db, "ana@example.com", models.RoleUser)
+	laptop, err := s.auth.Login(ctx, ana.Email, testPassword, auth.Client{IP: "192.0.2.1", UserAgent: "Laptop browser"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone, err := s.auth.Login(ctx, ana.Email, testPassword, auth.Client{IP: "198.51.100.7", UserAgent: "Phone app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sessions []struct {
+		ID        string `json:"id"`
+		UserAgent string `json:"userAgent"`
+		IP        string `json:"ip"`
+		Current   bool   `json:"current"`
+	}
+	if code := serve(t, handler, "GET", "/api/sessions", laptop.Tokens.AccessToken, "", &sessions); code != http.StatusOK {
+		t.Fatalf("GET /api/sessions = %d", code)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("%d sessions, want 2", len(sessions))
+	}
+	var phoneSession string
+	for _, session := range sessions {
+		switch session.UserAgent {
+		case "Laptop browser":
+			if !session.Current || session.IP != "192.0.2.1" {
+				t.Errorf("laptop session %+v, want the current one from 192.0.2.1", session)
+			}
+		case "Phone app":
+			if session.Current || session.IP != "198.51.100.7" {
+				t.Errorf("phone session %+v, want another one from 198.51.100.7", session)
+			}
+			phoneSession = session.ID
+		default:
+			t.Errorf("unexpected session %+v", session)
+		}
+	}
+
+	// Revoking the phone's session stops its access token at once
+	if code := serve(t, handler, "DELETE", "/api/sessions/"+phoneSession, laptop.Tokens.AccessToken, "", nil); code != http.StatusOK {
+		t.Fatalf("DELETE /api/sessions/%s = %d", phoneSession, code)
+	}
+	if code := serve(t, handler, "GET", "/api/sessions", phone.Tokens.AccessToken, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("revoked session's token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if _, err := s.auth.Refresh(ctx, phone.Tokens.RefreshToken, auth.Client{}); err == nil {
+		t.Error("the revoked session could still be refreshed")
+	}
+	sessions = nil
+	if code := serve(t, handler, "GET", "/api/sessions", laptop.Tokens.AccessToken, "", &sessions); code != http.StatusOK || len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("sessions after revoking the phone: %d %+v", code, sessions)
+	}
+
+	// Personal API keys do not stand in for a signed-in device
+	key, err := s.auth.CreateAPIKey(ctx, ana.ID, "script", []string{string(auth.ScopeItemsRead)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := serve(t, handler, "GET", "/api/sessions", key.Secret, "", nil); code != http.StatusForbidden {
+		t.Errorf("GET /api/sessions with an API key = %d, want %d", code, http.StatusForbidden)
+	}
+}
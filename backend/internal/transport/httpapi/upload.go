@@ -0,0 +1,68 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"borrowhub/internal/ids"
+)
+
+// Image upload handler (basic implementation)
+func (s *Server) uploadImage(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+	if userID == "" {
+		respondWithError(w, http.StatusUnauthorized, "User authentication required")
+		return
+	}
+
+	// Parse multipart form
+	err := r.ParseMultipartForm(10 << 20) // 10 MB max
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to parse form data")
+		return
+	}
+
+	file, handler, err := r.FormFile("image")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to get image file")
+		return
+	}
+	defer file.Close()
+
+	// Basic file validation
+	if handler.Size > 10<<20 { // 10MB
+		respondWithError(w, http.StatusBadRequest, "File size too large (max 10MB)")
+		return
+	}
+
+	// Check file type
+	allowedTypes := map[string]bool{
+		"image/jpeg": true,
+		"image/jpg":  true,
+		"image/png":  true,
+		"image/gif":  true,
+	}
+
+	contentType := handler.Header.Get("Content-Type")
+	if !allowedTypes[contentType] {
+		respondWithError(w, http.StatusBadRequest, "Invalid file type. Only JPEG, PNG, and GIF allowed")
+		return
+	}
+
+	// In a real implementation, you would:
+	// 1. Save the file to cloud storage (AWS S3, Google Cloud Storage, etc.)
+	// 2. Generate a proper URL
+	// 3. Optimize/resize the image
+
+	// For now, we'll simulate a successful upload
+	imageID := ids.New(ids.PrefixImage)
+	imageURL := fmt.Sprintf("https://placehold.co/600x400/556cd6/white?text=Image+%s", imageID)
+
+	response := map[string]interface{}{
+		"imageId":  imageID,
+		"imageUrl": imageURL,
+		"message":  "Image uploaded successfully",
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,53 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"borrowhub/internal/auth"
+	"borrowhub/internal/models"
+)
+
+func TestUnverifiedUsersCannotListOrBook(t *testing.T) {
+	handler, db, authService := newTestAPI(t)
+	ctx := context.Background()
+	ana := createTestUser(t, db, "ana@example.com", models.RoleUser)
+	ana.Verified = false
+	if err := db.UpdateUser(ctx, ana); err != nil {
+		t.Fatal(err)
+	}
+	result, err := authService.Login(ctx, ana.Email, testPassword, auth.Client{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := result.Tokens.AccessToken
+
+	gated := []string{"/api/items", "/api/bookings", "/api/payments/create-order"}
+	for _, target := range gated {
+		if code := serve(t, handler, "POST", target, token, "{}", nil); code != http.StatusForbidden {
+			t.Errorf("POST %s before verifying = %d, want %d", target, code, http.StatusForbidden)
+		}
+	}
+	// Everything else still works
+	if code := serve(t, handler, "GET", "/api/profile", token, "", nil); code != http.StatusOK {
+		t.Errorf("GET /api/profile before verifying = %d, want %d", code, http.StatusOK)
+	}
+	if code := serve(t, handler, "POST", "/auth/resend-verification", token, "", nil); code != http.StatusAccepted {
+		t.Errorf("POST /auth/resend-verification = %d, want %d", code, http.StatusAccepted)
+	}
+
+	ana.Verified = true
+	if err := db.UpdateUser(ctx, ana); err != nil {
+		t.Fatal(err)
+	}
+	// The empty bodies are now refused for what they are
+	for _, target := range gated {
+		if code := serve(t, handler, "POST", target, token, "{}", nil); code == http.StatusForbidden {
+			t.Errorf("POST %s after verifying = %d", target, code)
+		}
+	}
+	if code := serve(t, handler, "POST", "/auth/resend-verification", token, "", nil); code != http.StatusConflict {
+		t.Errorf("POST /auth/resend-verification once verified = %d, want %d", code, http.StatusConflict)
+	}
+}
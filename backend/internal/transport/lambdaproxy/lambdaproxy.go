@@ -0,0 +1,141 @@
+// Package lambdaproxy serves an http.Handler behind AWS API Gateway proxy integration.
+package lambdaproxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+type contextKey struct{}
+
+// Adapter converts API Gateway proxy events to HTTP requests for handler
+type Adapter struct {
+	handler       http.Handler
+	defaultOrigin string // Used for CORS headers the handler did not set
+}
+
+func New(handler http.Handler, defaultOrigin string) *Adapter {
+	return &Adapter{handler: handler, defaultOrigin: defaultOrigin}
+}
+
+// RequestContext returns the API Gateway request context of a proxied request
+func RequestContext(ctx context.Context) (events.APIGatewayProxyRequestContext, bool) {
+	rc, ok := ctx.Value(contextKey{}).(events.APIGatewayProxyRequestContext)
+	return rc, ok
+}
+
+// Handle is the Lambda handler function
+func (a *Adapter) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Convert API Gateway request to HTTP request
+	req, err := toHTTPRequest(ctx, request)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+			Headers: map[string]string{
+				"Content-Type":                     "application/json",
+				"Access-Control-Allow-Origin":      a.defaultOrigin,
+				"Access-Control-Allow-Methods":     "GET, POST, PUT, DELETE, OPTIONS",
+				"Access-Control-Allow-Headers":     "Content-Type, Authorization, Accept, X-Requested-With",
+				"Access-Control-Allow-Credentials": "true",
+			},
+			Body: `{"error": "Failed to process request"}`,
+		}, err
+	}
+
+	// Create response recorder
+	recorder := httptest.NewRecorder()
+
+	// Handle the request using our existing router
+	a.handler.ServeHTTP(recorder, req)
+
+	// Convert HTTP response to API Gateway response
+	return a.toProxyResponse(recorder), nil
+}
+
+// Convert API Gateway proxy request to standard HTTP request
+func toHTTPRequest(ctx context.Context, request events.APIGatewayProxyRequest) (*http.Request, error) {
+	// Build URL with path and query parameters
+	path := request.Path
+	if request.PathParameters != nil {
+		// Replace path parameters (e.g., {id} -> actual value)
+		for key, value := range request.PathParameters {
+			path = strings.Replace(path, "{"+key+"}", value, -1)
+		}
+	}
+
+	// Add query parameters
+	queryValues := url.Values{}
+	for key, value := range request.QueryStringParameters {
+		queryValues.Set(key, value)
+	}
+	for key, values := range request.MultiValueQueryStringParameters {
+		for _, value := range values {
+			queryValues.Add(key, value)
+		}
+	}
+
+	fullURL := "https://example.com" + path
+	if len(queryValues) > 0 {
+		fullURL += "?" + queryValues.Encode()
+	}
+
+	// Create HTTP request, carrying the API Gateway context
+	ctx = context.WithValue(ctx, contextKey{}, request.RequestContext)
+	req, err := http.NewRequestWithContext(ctx, request.HTTPMethod, fullURL, strings.NewReader(request.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.RemoteAddr = request.RequestContext.Identity.SourceIP
+
+	// Set headers
+	for key, value := range request.Headers {
+		req.Header.Set(key, value)
+	}
+	for key, values := range request.MultiValueHeaders {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	return req, nil
+}
+
+// Convert HTTP response to API Gateway proxy response
+func (a *Adapter) toProxyResponse(recorder *httptest.ResponseRecorder) events.APIGatewayProxyResponse {
+	headers := make(map[string]string)
+	multiValueHeaders := make(map[string][]string)
+
+	for key, values := range recorder.Header() {
+		if len(values) == 1 {
+			headers[key] = values[0]
+		} else {
+			multiValueHeaders[key] = values
+		}
+	}
+
+	// Ensure CORS headers are always present
+	if headers["Access-Control-Allow-Origin"] == "" {
+		headers["Access-Control-Allow-Origin"] = a.defaultOrigin
+	}
+	if headers["Access-Control-Allow-Methods"] == "" {
+		headers["Access-Control-Allow-Methods"] = "GET, POST, PUT, DELETE, OPTIONS"
+	}
+	if headers["Access-Control-Allow-Headers"] == "" {
+		headers["Access-Control-Allow-Headers"] = "Content-Type, Authorization, Accept, X-Requested-With"
+	}
+	if headers["Access-Control-Allow-Credentials"] == "" {
+		headers["Access-Control-Allow-Credentials"] = "true"
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode:        recorder.Code,
+		Headers:           headers,
+		MultiValueHeaders: multiValueHeaders,
+		Body:              recorder.Body.String(),
+	}
+}
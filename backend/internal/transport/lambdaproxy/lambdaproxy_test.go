@@ -0,0 +1,81 @@
+package lambdaproxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestHandle(t *testing.T) {
+	var got *http.Request
+	var body string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"ok":true}`)
+	})
+	adapter := New(handler, "https://borrowhubb.live")
+
+	response, err := adapter.Handle(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:                      "PUT",
+		Path:                            "/api/items/{id}",
+		PathParameters:                  map[string]string{"id": "itm_tent"},
+		QueryStringParameters:           map[string]string{"notify": "true"},
+		MultiValueQueryStringParameters: map[string][]string{"tags": {"camping", "tent"}},
+		Headers:                         map[string]string{"Authorization": "Bearer token"},
+		Body:                            `{"name":"Tent"}`,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID: "req-1",
+			Identity:  events.APIGatewayRequestIdentity{SourceIP: "192.0.2.1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The handler sees an ordinary request
+	if got.Method != "PUT" || got.URL.Path != "/api/items/itm_tent" {
+		t.Errorf("request %s %s, want PUT /api/items/itm_tent", got.Method, got.URL.Path)
+	}
+	if q := got.URL.Query(); q.Get("notify") != "true" || len(q["tags"]) != 2 {
+		t.Errorf("query %v", q)
+	}
+	if got.Header.Get("Authorization") != "Bearer token" || got.RemoteAddr != "192.0.2.1" || body != `{"name":"Tent"}` {
+		t.Errorf("authorization %q, remote address %q, body %q", got.Header.Get("Authorization"), got.RemoteAddr, body)
+	}
+	if rc, ok := RequestContext(got.Context()); !ok || rc.RequestID != "req-1" {
+		t.Errorf("request context %+v, %v", rc, ok)
+	}
+
+	// And its response is returned to API Gateway, with CORS headers filled in
+	if response.StatusCode != http.StatusCreated || response.Body != `{"ok":true}` {
+		t.Errorf("response %d %q", response.StatusCode, response.Body)
+	}
+	if len(response.MultiValueHeaders["Set-Cookie"]) != 2 || response.Headers["Content-Type"] != "application/json" {
+		t.Errorf("headers %v, multi-value headers %v", response.Headers, response.MultiValueHeaders)
+	}
+	if response.Headers["Access-Control-Allow-Origin"] != "https://borrowhubb.live" || response.Headers["Access-Control-Allow-Credentials"] != "true" {
+		t.Errorf("CORS headers %v", response.Headers)
+	}
+}
+
+func TestHandleKeepsHandlerCORS(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
+	})
+	response, err := New(handler, "https://borrowhubb.live").Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/items"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := response.Headers["Access-Control-Allow-Origin"]; got != "http://localhost:5173" {
+		t.Errorf("Access-Control-Allow-Origin %q, want the handler's", got)
+	}
+}
@@ -6,6 +6,10 @@ import Navbar from './components/Navbar';
 import HomePage from './pages/HomePage';
 import RegisterPage from './pages/RegisterPage';
 import LoginPage from './pages/LoginPage';
+import ForgotPasswordPage from './pages/ForgotPasswordPage';
+import ResetPasswordPage from './pages/ResetPasswordPage';
+import VerifyEmailPage from './pages/VerifyEmailPage';
+import OidcCallbackPage from './pages/OidcCallbackPage';
 import AddItemPage from './pages/AddItemPage';
 import ItemDetailsPage from './pages/ItemDetailsPage';
 import PaymentPage from './pages/PaymentPage';
@@ -35,6 +39,26 @@ function App() {
                   <LoginPage />
                 </Container>
               } />
+              <Route path="/forgot-password" element={
+                <Container component="main" sx={{ mt: 4, mb: 4 }}>
+                  <ForgotPasswordPage />
+                </Container>
+              } />
+              <Route path="/reset-password" element={
+                <Container component="main" sx={{ mt: 4, mb: 4 }}>
+                  <ResetPasswordPage />
+                </Container>
+              } />
+              <Route path="/verify-email" element={
+                <Container component="main" sx={{ mt: 4, mb: 4 }}>
+                  <VerifyEmailPage />
+                </Container>
+              } />
+              <Route path="/auth/callback" element={
+                <Container component="main" sx={{ mt: 4, mb: 4 }}>
+                  <OidcCallbackPage />
+                </Container>
+              } />
               <Route path="/item/:id" element={
                 <Container component="main" sx={{ mt: 4, mb: 4 }}>
                   <ItemDetailsPage />
@@ -80,6 +80,31 @@ const shouldTryEmergencyFallback = (error) => {
 // Helper function to delay execution
 const delay = (ms) => new Promise(resolve => setTimeout(resolve, ms));
 
+// Access tokens are short-lived; exchange the refresh token for a new pair.
+// Concurrent 401s share one refresh, since each refresh token works only once.
+let refreshPromise = null;
+const refreshSession = () => {
+  if (!refreshPromise) {
+    const refreshToken = localStorage.getItem('refreshToken');
+    refreshPromise = (refreshToken
+      ? axios.post(`${axiosInstance.defaults.baseURL}/auth/refresh`, { refreshToken })
+      : Promise.reject(new Error('No refresh token')))
+      .then((response) => {
+        localStorage.setItem('token', response.data.token);
+        localStorage.setItem('refreshToken', response.data.refreshToken);
+        return response.data.token;
+      })
+      .finally(() => {
+        refreshPromise = null;
+      });
+  }
+  return refreshPromise;
+};
+
+const isAuthEndpoint = (url = '') =>
+  ['/login', '/register', '/auth/refresh', '/auth/forgot-password', '/auth/reset-password', '/auth/verify-email', '/auth/mfa/challenge', '/auth/oidc', '/auth/oidc/start', '/auth/oidc/callback']
+    .some((path) => url.endsWith(path));
+
 // Request interceptor to add auth token and retry logic
 axiosInstance.interceptors.request.use(
   (config) => {
@@ -104,9 +129,20 @@ axiosInstance.interceptors.response.use(
   async (error) => {
     const config = error.config;
     
-    // Handle authentication errors
+    // Handle authentication errors: refresh once, then give up on the session
     if (error.response?.status === 401) {
+      if (config && !config.authRetried && !isAuthEndpoint(config.url)) {
+        config.authRetried = true;
+        try {
+          const token = await refreshSession();
+          config.headers.Authorization = `Bearer ${token}`;
+          return axiosInstance(config);
+        } catch {
+          // Fall through and clear the session
+        }
+      }
       localStorage.removeItem('token');
+      localStorage.removeItem('refreshToken');
       delete axiosInstance.defaults.headers.common['Authorization'];
       // Redirect to login could be handled here
     }
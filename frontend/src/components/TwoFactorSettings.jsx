@@ -0,0 +1,160 @@
+// frontend/src/components/TwoFactorSettings.jsx
+
+import React, { useState, useEffect } from 'react';
+import {
+  Box,
+  Typography,
+  Card,
+  CardContent,
+  TextField,
+  Button,
+  Alert,
+  CircularProgress,
+  Link,
+} from '@mui/material';
+import axios from '../api/axios';
+
+// Enrollment steps: idle -> enrolling (secret shown, waiting for a code) -> backupCodes (shown once)
+const TwoFactorSettings = () => {
+  const [status, setStatus] = useState(null);
+  const [enrollment, setEnrollment] = useState(null);
+  const [backupCodes, setBackupCodes] = useState([]);
+  const [code, setCode] = useState('');
+  const [busy, setBusy] = useState(false);
+  const [error, setError] = useState('');
+
+  const loadStatus = async () => {
+    try {
+      const response = await axios.get('/auth/mfa');
+      setStatus(response.data);
+    } catch (err) {
+      setError(err.response?.data?.error || 'Failed to load two-factor settings');
+    }
+  };
+
+  useEffect(() => {
+    loadStatus();
+  }, []);
+
+  const run = async (request) => {
+    setBusy(true);
+    setError('');
+    try {
+      await request();
+      setCode('');
+    } catch (err) {
+      setError(err.response?.data?.error || 'Request failed');
+    } finally {
+      setBusy(false);
+    }
+  };
+
+  const startEnrollment = () => run(async () => {
+    const response = await axios.post('/auth/mfa/enroll');
+    setEnrollment(response.data);
+    setBackupCodes([]);
+  });
+
+  const confirmEnrollment = () => run(async () => {
+    const response = await axios.post('/auth/mfa/verify', { code });
+    setEnrollment(null);
+    setBackupCodes(response.data.backupCodes);
+    await loadStatus();
+  });
+
+  const regenerateCodes = () => run(async () => {
+    const response = await axios.post('/auth/mfa/backup-codes', { code });
+    setBackupCodes(response.data.backupCodes);
+    await loadStatus();
+  });
+
+  const disable = () => run(async () => {
+    await axios.post('/auth/mfa/disable', { code });
+    setBackupCodes([]);
+    await loadStatus();
+  });
+
+  const codeField = (label) => (
+    <TextField
+      size="small"
+      label={label}
+      value={code}
+      onChange={(e) => setCode(e.target.value)}
+      autoComplete="one-time-code"
+      sx={{ mr: 2, mt: 1 }}
+    />
+  );
+
+  return (
+    <Card sx={{ mt: 3 }}>
+      <CardContent>
+        <Typography variant="h6" gutterBottom>
+          Two-factor authentication
+        </Typography>
+
+        {error && <Alert severity="error" sx={{ mb: 2 }}>{error}</Alert>}
+
+        {!status && !error && <CircularProgress size={24} />}
+
+        {backupCodes.length > 0 && (
+          <Alert severity="warning" sx={{ mb: 2 }}>
+            Save these backup codes somewhere safe. Each works once if you lose your authenticator app,
+            and they will not be shown again.
+            <Box component="pre" sx={{ mt: 1, mb: 0, fontFamily: 'monospace' }}>
+              {backupCodes.join('\n')}
+            </Box>
+          </Alert>
+        )}
+
+        {status && !status.enabled && !enrollment && (
+          <>
+            <Typography variant="body2" color="text.secondary" sx={{ mb: 2 }}>
+              Protect your account with a code from an authenticator app in addition to your password.
+            </Typography>
+            <Button variant="contained" onClick={startEnrollment} disabled={busy}>
+              Enable two-factor authentication
+            </Button>
+          </>
+        )}
+
+        {enrollment && (
+          <>
+            <Typography variant="body2" sx={{ mb: 1 }}>
+              Add BorrowHub to your authenticator app with{' '}
+              <Link href={enrollment.otpauthUri}>this link</Link> or by entering the key below, then
+              type the code it shows.
+            </Typography>
+            <Typography variant="body2" sx={{ fontFamily: 'monospace', mb: 1, wordBreak: 'break-all' }}>
+              {enrollment.secret}
+            </Typography>
+            <Box sx={{ display: 'flex', alignItems: 'center', flexWrap: 'wrap' }}>
+              {codeField('6-digit code')}
+              <Button variant="contained" onClick={confirmEnrollment} disabled={busy || !code} sx={{ mt: 1 }}>
+                Verify and enable
+              </Button>
+            </Box>
+          </>
+        )}
+
+        {status && status.enabled && (
+          <>
+            <Typography variant="body2" color="text.secondary" sx={{ mb: 1 }}>
+              Enabled. {status.backupCodesRemaining} backup codes remaining.
+            </Typography>
+            <Box sx={{ display: 'flex', alignItems: 'center', flexWrap: 'wrap' }}>
+              {codeField('Authentication code')}
+              <Button variant="outlined" onClick={regenerateCodes} disabled={busy || !code} sx={{ mr: 2, mt: 1 }}>
+                New backup codes
+              </Button>
+              <Button variant="outlined" color="error" onClick={disable} disabled={busy || !code} sx={{ mt: 1 }}>
+                Disable
+              </Button>
+            </Box>
+          </>
+        )}
+      </CardContent>
+    </Card>
+  );
+};
+
+export default TwoFactorSettings;
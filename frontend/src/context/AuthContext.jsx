@@ -23,6 +23,7 @@ const authReducer = (state, action) => {
       };
     case 'LOGIN_SUCCESS':
       localStorage.setItem('token', action.payload.token);
+      localStorage.setItem('refreshToken', action.payload.refreshToken);
       return {
         ...state,
         isAuthenticated: true,
@@ -31,8 +32,15 @@ const authReducer = (state, action) => {
         loading: false,
         error: null,
       };
+    case 'MFA_REQUIRED':
+      return {
+        ...state,
+        loading: false,
+        error: null,
+      };
     case 'LOGIN_FAILURE':
       localStorage.removeItem('token');
+      localStorage.removeItem('refreshToken');
       return {
         ...state,
         isAuthenticated: false,
@@ -43,6 +51,7 @@ const authReducer = (state, action) => {
       };
     case 'LOGOUT':
       localStorage.removeItem('token');
+      localStorage.removeItem('refreshToken');
       return {
         ...state,
         isAuthenticated: false,
@@ -74,11 +83,16 @@ export const AuthProvider = ({ children }) => {
     dispatch({ type: 'LOGIN_START' });
     try {
       const response = await axios.post('/login', { email, password });
-      const { token, user } = response.data;
+      // Accounts with two-factor authentication get a challenge instead of a session
+      if (response.data.mfaRequired) {
+        dispatch({ type: 'MFA_REQUIRED' });
+        return { success: false, mfaRequired: true, mfaToken: response.data.mfaToken };
+      }
+      const { token, refreshToken, user } = response.data;
       
       dispatch({ 
         type: 'LOGIN_SUCCESS', 
-        payload: { token, user } 
+        payload: { token, refreshToken, user } 
       });
       
       return { success: true };
@@ -92,15 +106,64 @@ export const AuthProvider = ({ children }) => {
     }
   };
 
+  const completeMfaLogin = async (mfaToken, code) => {
+    dispatch({ type: 'LOGIN_START' });
+    try {
+      const response = await axios.post('/auth/mfa/challenge', { mfaToken, code });
+      const { token, refreshToken, user } = response.data;
+
+      dispatch({
+        type: 'LOGIN_SUCCESS',
+        payload: { token, refreshToken, user }
+      });
+
+      return { success: true };
+    } catch (error) {
+      const errorMessage = error.response?.data?.error || 'Verification failed';
+      dispatch({
+        type: 'LOGIN_FAILURE',
+        payload: { error: errorMessage }
+      });
+      return { success: false, error: errorMessage };
+    }
+  };
+
+  // Finishes a sign-in with the external OpenID Connect provider
+  const completeOidcLogin = async (code, state) => {
+    dispatch({ type: 'LOGIN_START' });
+    try {
+      const response = await axios.post('/auth/oidc/callback', { code, state });
+      if (response.data.mfaRequired) {
+        dispatch({ type: 'MFA_REQUIRED' });
+        return { success: false, mfaRequired: true, mfaToken: response.data.mfaToken };
+      }
+      const { token, refreshToken, user } = response.data;
+
+      dispatch({
+        type: 'LOGIN_SUCCESS',
+        payload: { token, refreshToken, user }
+      });
+
+      return { success: true };
+    } catch (error) {
+      const errorMessage = error.response?.data?.error || 'Sign-in failed';
+      dispatch({
+        type: 'LOGIN_FAILURE',
+        payload: { error: errorMessage }
+      });
+      return { success: false, error: errorMessage };
+    }
+  };
+
   const register = async (userData) => {
     dispatch({ type: 'LOGIN_START' });
     try {
       const response = await axios.post('/register', userData);
-      const { token, user } = response.data;
+      const { token, refreshToken, user } = response.data;
       
       dispatch({ 
         type: 'LOGIN_SUCCESS', 
-        payload: { token, user } 
+        payload: { token, refreshToken, user } 
       });
       
       return { success: true };
@@ -115,6 +178,13 @@ export const AuthProvider = ({ children }) => {
   };
 
   const logout = () => {
+    // Revoke the session server-side; the local session ends regardless
+    const token = localStorage.getItem('token');
+    if (token) {
+      axios
+        .post('/auth/logout', null, { headers: { Authorization: `Bearer ${token}` } })
+        .catch(() => {});
+    }
     dispatch({ type: 'LOGOUT' });
   };
 
@@ -127,6 +197,8 @@ export const AuthProvider = ({ children }) => {
       ...state, 
       dispatch, 
       login, 
+      completeMfaLogin,
+      completeOidcLogin,
       register, 
       logout
     }}>
@@ -33,6 +33,8 @@ const HomePage = () => {
     const [isUsingMockData, setIsUsingMockData] = useState(false);
     const [retryCount, setRetryCount] = useState(0);
     const [apiStatus, setApiStatus] = useState(null);
+    // Category slug -> parent slug, from the server's taxonomy
+    const [categoryParents, setCategoryParents] = useState({});
 
     // Mock data for when API is not available
     const mockItems = [
@@ -42,6 +44,7 @@ const HomePage = () => {
             description: "Professional full-frame mirrorless camera with 45MP sensor. Perfect for weddings, events, and professional photography.",
             dailyRate: 2500,
             imageUrl: "https://picsum.photos/400/300?random=1",
+            category: "cameras",
             status: "available"
         },
         {
@@ -50,6 +53,7 @@ const HomePage = () => {
             description: "4K camera drone with 34-minute flight time. Great for aerial photography and videography projects.",
             dailyRate: 1800,
             imageUrl: "https://picsum.photos/400/300?random=2",
+            category: "electronics",
             status: "available"
         },
         {
@@ -58,6 +62,7 @@ const HomePage = () => {
             description: "M1 Pro chip with 16GB RAM and 512GB SSD. Perfect for video editing, design work, and development.",
             dailyRate: 1200,
             imageUrl: "https://picsum.photos/400/300?random=3",
+            category: "electronics",
             status: "rented"
         },
         {
@@ -66,6 +71,7 @@ const HomePage = () => {
             description: "Full-frame mirrorless camera with excellent low-light performance. Includes 24-70mm lens.",
             dailyRate: 2000,
             imageUrl: "https://picsum.photos/400/300?random=4",
+            category: "cameras",
             status: "available"
         },
         {
@@ -74,6 +80,7 @@ const HomePage = () => {
             description: "Latest iPhone with ProRAW camera and cinematic mode. Perfect for content creation.",
             dailyRate: 800,
             imageUrl: "https://picsum.photos/400/300?random=5",
+            category: "electronics",
             status: "available"
         },
         {
@@ -82,12 +89,14 @@ const HomePage = () => {
             description: "Action camera with 5.3K video recording. Waterproof and perfect for adventure activities.",
             dailyRate: 500,
             imageUrl: "https://picsum.photos/400/300?random=6",
+            category: "cameras",
             status: "available"
         }
     ];
 
     useEffect(() => {
         fetchItems();
+        fetchCategories();
         // Update API status
         setApiStatus(getApiStatus());
     }, []);
@@ -140,6 +149,27 @@ const HomePage = () => {
         }
     };
 
+    const fetchCategories = async () => {
+        try {
+            const res = await axios.get('/api/categories');
+            setCategoryParents(Object.fromEntries((res.data || []).map(c => [c.slug, c.parent])));
+        } catch (err) {
+            // Without the taxonomy only exact category matches are found
+            console.error("Error fetching categories:", err);
+        }
+    };
+
+    // Whether an item's category is the given one or one of its subcategories
+    const inCategory = (slug, category) => {
+        while (slug) {
+            if (slug === category) {
+                return true;
+            }
+            slug = categoryParents[slug];
+        }
+        return false;
+    };
+
     const handleRetry = () => {
         setRetryCount(prev => prev + 1);
         setApiStatus(getApiStatus());
@@ -193,23 +223,7 @@ const HomePage = () => {
 
         // Apply category filter
         if (filters.category && filters.category !== '') {
-            // Map category to item properties - this would be enhanced with actual category field in items
-            const categoryKeywords = {
-                'cameras': ['camera', 'dslr', 'photography', 'photo'],
-                'electronics': ['electronics', 'laptop', 'phone', 'computer', 'macbook', 'iphone'],
-                'tools': ['tool', 'drill', 'hammer', 'equipment'],
-                'sports': ['bike', 'bicycle', 'sports', 'fitness'],
-                'gaming': ['game', 'gaming', 'console', 'xbox', 'playstation'],
-                'music': ['music', 'audio', 'speaker', 'headphone']
-            };
-            
-            const keywords = categoryKeywords[filters.category] || [];
-            if (keywords.length > 0) {
-                filtered = filtered.filter(item => {
-                    const itemText = `${item.name} ${item.description}`.toLowerCase();
-                    return keywords.some(keyword => itemText.includes(keyword));
-                });
-            }
+            filtered = filtered.filter(item => inCategory(item.category, filters.category));
         }
 
         // Apply price range filter
@@ -267,7 +281,7 @@ const HomePage = () => {
                     filtered.sort((a, b) => (b.dailyRate || b.price || 0) - (a.dailyRate || a.price || 0));
                     break;
                 case 'newest':
-                    filtered.sort((a, b) => (parseInt(b.id) || 0) - (parseInt(a.id) || 0));
+                    filtered.sort((a, b) => new Date(b.createdAt || 0) - new Date(a.createdAt || 0));
                     break;
                 case 'rating':
                     // Sort by rating (mock data - all items have same rating for demo)
@@ -12,19 +12,30 @@ import {
   Link as MuiLink,
   Alert,
   CircularProgress,
+  Divider,
 } from '@mui/material';
 import LockOutlinedIcon from '@mui/icons-material/LockOutlined';
-import { Link as RouterLink, useNavigate } from 'react-router-dom';
+import { Link as RouterLink, useLocation, useNavigate } from 'react-router-dom';
 import AuthContext from '../context/AuthContext';
+import axios from '../api/axios';
+
+// Key under which the OpenID Connect state waits for the provider's redirect
+export const OIDC_STATE_KEY = 'oidcState';
 
 const LoginPage = () => {
   const [formData, setFormData] = useState({
     email: '',
     password: '',
   });
+  const location = useLocation();
   const [localError, setLocalError] = useState('');
+  // Set when the account has two-factor authentication and the first factor was accepted,
+  // here or by the single sign-on callback
+  const [mfaToken, setMfaToken] = useState(location.state?.mfaToken || '');
+  const [code, setCode] = useState('');
+  const [oidcProvider, setOidcProvider] = useState(null);
   const navigate = useNavigate();
-  const { login, loading, isAuthenticated } = useContext(AuthContext);
+  const { login, completeMfaLogin, loading, isAuthenticated } = useContext(AuthContext);
 
   const { email, password } = formData;
 
@@ -35,6 +46,28 @@ const LoginPage = () => {
     }
   }, [isAuthenticated, navigate]);
 
+  React.useEffect(() => {
+    axios
+      .get('/auth/oidc')
+      .then((response) => {
+        if (response.data.enabled) {
+          setOidcProvider(response.data.name);
+        }
+      })
+      .catch(() => {});
+  }, []);
+
+  const startOidcLogin = async () => {
+    setLocalError('');
+    try {
+      const response = await axios.post('/auth/oidc/start');
+      sessionStorage.setItem(OIDC_STATE_KEY, response.data.state);
+      window.location.assign(response.data.authorizationUrl);
+    } catch (err) {
+      setLocalError(err.response?.data?.error || 'Single sign-on is unavailable');
+    }
+  };
+
   const onChange = (e) => {
     setFormData({ ...formData, [e.target.name]: e.target.value });
     // Clear local error when user starts typing
@@ -47,16 +80,34 @@ const LoginPage = () => {
     e.preventDefault();
     setLocalError('');
     
+    if (mfaToken) {
+      const result = await completeMfaLogin(mfaToken, code);
+      if (result.success) {
+        navigate('/');
+      } else {
+        setLocalError(result.error || 'Verification failed');
+      }
+      return;
+    }
+
     if (login) {
       const result = await login(email, password);
       if (result.success) {
         navigate('/');
+      } else if (result.mfaRequired) {
+        setMfaToken(result.mfaToken);
       } else {
         setLocalError(result.error || 'Login failed');
       }
     }
   };
 
+  const startOver = () => {
+    setMfaToken('');
+    setCode('');
+    setLocalError('');
+  };
+
   return (
     <Container component="main" maxWidth="xs">
       <Box
@@ -74,30 +125,57 @@ const LoginPage = () => {
           Sign in
         </Typography>
         <Box component="form" noValidate onSubmit={onSubmit} sx={{ mt: 1 }}>
-          <TextField
-            margin="normal"
-            required
-            fullWidth
-            id="email"
-            label="Email Address"
-            name="email"
-            autoComplete="email"
-            autoFocus
-            value={email}
-            onChange={onChange}
-          />
-          <TextField
-            margin="normal"
-            required
-            fullWidth
-            name="password"
-            label="Password"
-            type="password"
-            id="password"
-            autoComplete="current-password"
-            value={password}
-            onChange={onChange}
-          />
+          {mfaToken ? (
+            <>
+              <Typography variant="body2" color="text.secondary" sx={{ mt: 1 }}>
+                Enter the 6-digit code from your authenticator app, or one of your backup codes.
+              </Typography>
+              <TextField
+                margin="normal"
+                required
+                fullWidth
+                id="code"
+                label="Authentication code"
+                name="code"
+                autoComplete="one-time-code"
+                autoFocus
+                value={code}
+                onChange={(e) => {
+                  setCode(e.target.value);
+                  if (localError) {
+                    setLocalError('');
+                  }
+                }}
+              />
+            </>
+          ) : (
+            <>
+              <TextField
+                margin="normal"
+                required
+                fullWidth
+                id="email"
+                label="Email Address"
+                name="email"
+                autoComplete="email"
+                autoFocus
+                value={email}
+                onChange={onChange}
+              />
+              <TextField
+                margin="normal"
+                required
+                fullWidth
+                name="password"
+                label="Password"
+                type="password"
+                id="password"
+                autoComplete="current-password"
+                value={password}
+                onChange={onChange}
+              />
+            </>
+          )}
           {localError && <Alert severity="error" sx={{ mt: 2, width: '100%' }}>{localError}</Alert>}
           <Button
             type="submit"
@@ -106,9 +184,27 @@ const LoginPage = () => {
             sx={{ mt: 3, mb: 2 }}
             disabled={loading}
           >
-            {loading ? <CircularProgress size={24} /> : 'Sign In'}
+            {loading ? <CircularProgress size={24} /> : mfaToken ? 'Verify' : 'Sign In'}
           </Button>
+          {mfaToken && (
+            <Button fullWidth onClick={startOver} sx={{ mb: 2 }}>
+              Use a different account
+            </Button>
+          )}
+          {!mfaToken && oidcProvider && (
+            <>
+              <Divider sx={{ mb: 2 }}>or</Divider>
+              <Button fullWidth variant="outlined" onClick={startOidcLogin} sx={{ mb: 2 }}>
+                Sign in with {oidcProvider}
+              </Button>
+            </>
+          )}
           <Grid container>
+            <Grid item xs>
+              <MuiLink component={RouterLink} to="/forgot-password" variant="body2">
+                Forgot password?
+              </MuiLink>
+            </Grid>
             <Grid item>
               <MuiLink component={RouterLink} to="/register" variant="body2">
                 {"Don't have an account? Sign Up"}
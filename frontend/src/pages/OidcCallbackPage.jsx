@@ -0,0 +1,98 @@
+// frontend/src/pages/OidcCallbackPage.jsx
+
+import React, { useState, useEffect, useContext, useRef } from 'react';
+import {
+  Container,
+  Box,
+  Avatar,
+  Typography,
+  Button,
+  Alert,
+  CircularProgress,
+} from '@mui/material';
+import LockOutlinedIcon from '@mui/icons-material/LockOutlined';
+import { Link as RouterLink, useNavigate, useSearchParams } from 'react-router-dom';
+import AuthContext from '../context/AuthContext';
+import { OIDC_STATE_KEY } from './LoginPage';
+
+// The identity provider redirects here with ?code=...&state=... after sign-in
+const OidcCallbackPage = () => {
+  const [searchParams] = useSearchParams();
+  const navigate = useNavigate();
+  const { completeOidcLogin } = useContext(AuthContext);
+  const [error, setError] = useState('');
+  // The code works once; StrictMode runs effects twice in development
+  const submitted = useRef(false);
+
+  useEffect(() => {
+    if (submitted.current) {
+      return;
+    }
+    submitted.current = true;
+
+    const code = searchParams.get('code');
+    const state = searchParams.get('state');
+    const expectedState = sessionStorage.getItem(OIDC_STATE_KEY);
+    sessionStorage.removeItem(OIDC_STATE_KEY);
+
+    if (searchParams.get('error')) {
+      setError(searchParams.get('error_description') || 'Sign-in was cancelled.');
+      return;
+    }
+    // Only finish a sign-in this browser started
+    if (!code || !state || state !== expectedState) {
+      setError('This sign-in link is invalid or was already used.');
+      return;
+    }
+
+    completeOidcLogin(code, state).then((result) => {
+      if (result.success) {
+        navigate('/', { replace: true });
+      } else if (result.mfaRequired) {
+        navigate('/login', { replace: true, state: { mfaToken: result.mfaToken } });
+      } else {
+        setError(result.error || 'Sign-in failed');
+      }
+    });
+  }, [searchParams, completeOidcLogin, navigate]);
+
+  return (
+    <Container component="main" maxWidth="xs">
+      <Box
+        sx={{
+          marginTop: 8,
+          display: 'flex',
+          flexDirection: 'column',
+          alignItems: 'center',
+        }}
+      >
+        <Avatar sx={{ m: 1, bgcolor: 'secondary.main' }}>
+          <LockOutlinedIcon />
+        </Avatar>
+        <Typography component="h1" variant="h5">
+          Signing in
+        </Typography>
+        {error ? (
+          <>
+            <Alert severity="error" sx={{ mt: 2, width: '100%' }}>
+              {error}
+            </Alert>
+            <Button
+              component={RouterLink}
+              to="/login"
+              fullWidth
+              variant="contained"
+              sx={{ mt: 3, mb: 2 }}
+            >
+              Back to sign in
+            </Button>
+          </>
+        ) : (
+          <CircularProgress sx={{ mt: 3 }} />
+        )}
+      </Box>
+    </Container>
+  );
+};
+
+export default OidcCallbackPage;
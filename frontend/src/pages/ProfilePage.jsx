@@ -15,6 +15,7 @@ import {
 import { Edit, Save, Cancel } from '@mui/icons-material';
 import AuthContext from '../context/AuthContext';
 import axios from '../api/axios';
+import TwoFactorSettings from '../components/TwoFactorSettings';
 
 const ProfilePage = () => {
   const { user, isAuthenticated } = useContext(AuthContext);
@@ -22,6 +23,7 @@ const ProfilePage = () => {
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState('');
   const [success, setSuccess] = useState('');
+  const [resending, setResending] = useState(false);
   const [formData, setFormData] = useState({
     firstName: '',
     lastName: '',
@@ -91,6 +93,21 @@ const ProfilePage = () => {
     }
   };
 
+  const handleResendVerification = async () => {
+    setResending(true);
+    setError('');
+    setSuccess('');
+
+    try {
+      await axios.post('/auth/resend-verification');
+      setSuccess(`Verification email sent to ${user.email}`);
+    } catch (err) {
+      setError(err.response?.data?.error || 'Failed to send verification email');
+    } finally {
+      setResending(false);
+    }
+  };
+
   if (!isAuthenticated) {
     return (
       <Container maxWidth="sm">
@@ -126,6 +143,20 @@ const ProfilePage = () => {
               </Box>
             </Box>
 
+            {user && !user.verified && (
+              <Alert
+                severity="info"
+                sx={{ mb: 2 }}
+                action={
+                  <Button color="inherit" size="small" onClick={handleResendVerification} disabled={resending}>
+                    {resending ? <CircularProgress size={16} /> : 'Resend email'}
+                  </Button>
+                }
+              >
+                Confirm your email address to list items and make bookings.
+              </Alert>
+            )}
+
             {error && (
               <Alert severity="error" sx={{ mb: 2 }}>
                 {error}
@@ -240,6 +271,8 @@ const ProfilePage = () => {
             </Box>
           </CardContent>
         </Card>
+
+        <TwoFactorSettings />
       </Box>
     </Container>
   );
@@ -0,0 +1,132 @@
+// frontend/src/pages/ResetPasswordPage.jsx
+
+import React, { useState } from 'react';
+import {
+  Container,
+  Box,
+  Avatar,
+  Typography,
+  TextField,
+  Button,
+  Grid,
+  Link as MuiLink,
+  Alert,
+  CircularProgress,
+} from '@mui/material';
+import LockOutlinedIcon from '@mui/icons-material/LockOutlined';
+import { Link as RouterLink, useNavigate, useSearchParams } from 'react-router-dom';
+import axios from '../api/axios';
+
+const ResetPasswordPage = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+  const [formData, setFormData] = useState({
+    password: '',
+    confirmPassword: '',
+  });
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState('');
+  const navigate = useNavigate();
+
+  const { password, confirmPassword } = formData;
+
+  const onChange = (e) => {
+    setFormData({ ...formData, [e.target.name]: e.target.value });
+    if (error) {
+      setError('');
+    }
+  };
+
+  const onSubmit = async (e) => {
+    e.preventDefault();
+    if (password !== confirmPassword) {
+      setError('Passwords do not match');
+      return;
+    }
+
+    setLoading(true);
+    try {
+      await axios.post('/auth/reset-password', { token, password });
+      // Every session was revoked, including this browser's
+      localStorage.removeItem('token');
+      localStorage.removeItem('refreshToken');
+      navigate('/login');
+    } catch (err) {
+      setError(err.response?.data?.error || 'Failed to reset password');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <Container component="main" maxWidth="xs">
+      <Box
+        sx={{
+          marginTop: 8,
+          display: 'flex',
+          flexDirection: 'column',
+          alignItems: 'center',
+        }}
+      >
+        <Avatar sx={{ m: 1, bgcolor: 'secondary.main' }}>
+          <LockOutlinedIcon />
+        </Avatar>
+        <Typography component="h1" variant="h5">
+          Choose a new password
+        </Typography>
+        {!token ? (
+          <Alert severity="error" sx={{ mt: 2, width: '100%' }}>
+            This reset link is incomplete. Request a new one from the sign in page.
+          </Alert>
+        ) : (
+          <Box component="form" noValidate onSubmit={onSubmit} sx={{ mt: 1 }}>
+            <TextField
+              margin="normal"
+              required
+              fullWidth
+              name="password"
+              label="New Password"
+              type="password"
+              id="password"
+              autoComplete="new-password"
+              autoFocus
+              value={password}
+              onChange={onChange}
+            />
+            <TextField
+              margin="normal"
+              required
+              fullWidth
+              name="confirmPassword"
+              label="Confirm New Password"
+              type="password"
+              id="confirmPassword"
+              autoComplete="new-password"
+              value={confirmPassword}
+              onChange={onChange}
+            />
+            {error && <Alert severity="error" sx={{ mt: 2, width: '100%' }}>{error}</Alert>}
+            <Button
+              type="submit"
+              fullWidth
+              variant="contained"
+              sx={{ mt: 3, mb: 2 }}
+              disabled={loading || !password}
+            >
+              {loading ? <CircularProgress size={24} /> : 'Reset Password'}
+            </Button>
+          </Box>
+        )}
+        <Grid container>
+          <Grid item>
+            <MuiLink component={RouterLink} to="/forgot-password" variant="body2">
+              Request a new link
+            </MuiLink>
+          </Grid>
+        </Grid>
+      </Box>
+    </Container>
+  );
+};
+
+export default ResetPasswordPage;
@@ -0,0 +1,91 @@
+// frontend/src/pages/VerifyEmailPage.jsx
+
+import React, { useState, useEffect, useContext, useRef } from 'react';
+import {
+  Container,
+  Box,
+  Avatar,
+  Typography,
+  Button,
+  Alert,
+  CircularProgress,
+} from '@mui/material';
+import MarkEmailReadOutlinedIcon from '@mui/icons-material/MarkEmailReadOutlined';
+import { Link as RouterLink, useSearchParams } from 'react-router-dom';
+import AuthContext from '../context/AuthContext';
+import axios from '../api/axios';
+
+const VerifyEmailPage = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+  const { user, isAuthenticated, dispatch } = useContext(AuthContext);
+  const [status, setStatus] = useState(token ? 'verifying' : 'error');
+  const [error, setError] = useState(token ? '' : 'This verification link is incomplete.');
+  // Tokens work once; StrictMode runs effects twice in development
+  const submitted = useRef(false);
+
+  useEffect(() => {
+    if (!token || submitted.current) {
+      return;
+    }
+    submitted.current = true;
+
+    axios
+      .post('/auth/verify-email', { token })
+      .then(() => setStatus('verified'))
+      .catch((err) => {
+        setStatus('error');
+        setError(err.response?.data?.error || 'Failed to verify email address');
+      });
+  }, [token]);
+
+  useEffect(() => {
+    if (status === 'verified' && user && !user.verified) {
+      dispatch({ type: 'UPDATE_USER', payload: { user: { ...user, verified: true } } });
+    }
+  }, [status, user, dispatch]);
+
+  return (
+    <Container component="main" maxWidth="xs">
+      <Box
+        sx={{
+          marginTop: 8,
+          display: 'flex',
+          flexDirection: 'column',
+          alignItems: 'center',
+        }}
+      >
+        <Avatar sx={{ m: 1, bgcolor: 'secondary.main' }}>
+          <MarkEmailReadOutlinedIcon />
+        </Avatar>
+        <Typography component="h1" variant="h5">
+          Email verification
+        </Typography>
+        {status === 'verifying' && <CircularProgress sx={{ mt: 3 }} />}
+        {status === 'verified' && (
+          <Alert severity="success" sx={{ mt: 2, width: '100%' }}>
+            Your email address is confirmed. You can now list items and make bookings.
+          </Alert>
+        )}
+        {status === 'error' && (
+          <Alert severity="error" sx={{ mt: 2, width: '100%' }}>
+            {error} {isAuthenticated && 'You can request a new link from your profile.'}
+          </Alert>
+        )}
+        {status !== 'verifying' && (
+          <Button
+            component={RouterLink}
+            to={isAuthenticated ? '/profile' : '/login'}
+            fullWidth
+            variant="contained"
+            sx={{ mt: 3, mb: 2 }}
+          >
+            {isAuthenticated ? 'Go to profile' : 'Sign in'}
+          </Button>
+        )}
+      </Box>
+    </Container>
+  );
+};
+
+export default VerifyEmailPage;